@@ -0,0 +1,35 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package charts embeds the default values of the Universal Crossplane chart.
+package charts
+
+import (
+	_ "embed" // Required for go:embed.
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const errParseValues = "cannot parse bundled chart values"
+
+//go:embed universal-crossplane/values.yaml
+var values []byte
+
+// Values returns the default values of the Universal Crossplane chart.
+func Values() (map[string]any, error) {
+	v := map[string]any{}
+	return v, errors.Wrap(yaml.Unmarshal(values, &v), errParseValues)
+}
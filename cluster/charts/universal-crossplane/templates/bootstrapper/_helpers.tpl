@@ -4,6 +4,26 @@
 {{- "upbound-bootstrapper" -}}
 {{- end -}}
 
+{{/*
+The bootstrapper runs for AWS Marketplace billing or if any of its controllers
+is enabled.
+*/}}
+{{- define "bootstrapperEnabled" -}}
+{{- if or .Values.billing.awsMarketplace.enabled .Values.bootstrapper.controllers -}}
+true
+{{- end -}}
+{{- end -}}
+
+{{/*
+The operator controller installs Crossplane and the RBAC manager as a
+UniversalCrossplane specifies, so the chart does not render them.
+*/}}
+{{- define "operatorEnabled" -}}
+{{- if has "operator" .Values.bootstrapper.controllers -}}
+true
+{{- end -}}
+{{- end -}}
+
 {{/*
 Labels - bootstrapper
 */}}
@@ -18,4 +38,22 @@ Selector labels - bootstrapper
 {{- define "selectorLabelsBootstrapper" -}}
 {{ include "selectorLabels" . }}
 app.kubernetes.io/component: bootstrapper
-{{- end }}
\ No newline at end of file
+{{- end }}
+
+{{/*
+Labels - preflight. The hook pods must not match the selector of the
+bootstrapper Deployment, or anything else that selects its pods.
+*/}}
+{{- define "labelsPreflight" -}}
+{{ include "labels" . }}
+app.kubernetes.io/component: preflight
+{{- end }}
+
+{{/*
+Labels - uninstall. Like the preflight labels, they must not match the
+selector of the bootstrapper Deployment.
+*/}}
+{{- define "labelsUninstall" -}}
+{{ include "labels" . }}
+app.kubernetes.io/component: uninstall
+{{- end }}
@@ -0,0 +1,104 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crds embeds the Crossplane CRDs bundled with Universal Crossplane.
+package crds
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"sort"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	errReadCRDs    = "cannot read bundled CRDs"
+	errFmtParseCRD = "cannot parse bundled CRD %s"
+)
+
+//go:embed *.yaml
+var files embed.FS
+
+// Load returns the bundled CRDs sorted by name.
+func Load() ([]*extv1.CustomResourceDefinition, error) {
+	names, err := fs.Glob(files, "*.yaml")
+	if err != nil {
+		return nil, errors.Wrap(err, errReadCRDs)
+	}
+	crds := make([]*extv1.CustomResourceDefinition, 0, len(names))
+	for _, n := range names {
+		b, err := files.ReadFile(n)
+		if err != nil {
+			return nil, errors.Wrap(err, errReadCRDs)
+		}
+		crd := &extv1.CustomResourceDefinition{}
+		if err := yaml.Unmarshal(b, crd); err != nil {
+			return nil, errors.Wrapf(err, errFmtParseCRD, n)
+		}
+		crds = append(crds, crd)
+	}
+	sort.Slice(crds, func(i, j int) bool { return crds[i].GetName() < crds[j].GetName() })
+	return crds, nil
+}
+
+// StorageVersion returns the storage version of the given CRD.
+func StorageVersion(crd *extv1.CustomResourceDefinition) string {
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// ServedVersions returns the sorted names of the served versions of the given
+// CRD.
+func ServedVersions(crd *extv1.CustomResourceDefinition) []string {
+	served := make([]string, 0, len(crd.Spec.Versions))
+	for _, v := range crd.Spec.Versions {
+		if v.Served {
+			served = append(served, v.Name)
+		}
+	}
+	sort.Strings(served)
+	return served
+}
+
+// SchemaHashes returns the hashes of the OpenAPI schemas of the versions of
+// the given CRD, keyed by version name. Versions without a schema have an
+// empty hash.
+func SchemaHashes(crd *extv1.CustomResourceDefinition) map[string]string {
+	hashes := make(map[string]string, len(crd.Spec.Versions))
+	for _, v := range crd.Spec.Versions {
+		hashes[v.Name] = ""
+		if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		// Maps are marshalled with sorted keys, so this is deterministic.
+		b, err := json.Marshal(v.Schema.OpenAPIV3Schema)
+		if err != nil {
+			continue
+		}
+		h := sha256.Sum256(b)
+		hashes[v.Name] = hex.EncodeToString(h[:])
+	}
+	return hashes
+}
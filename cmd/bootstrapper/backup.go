@@ -0,0 +1,117 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/backup"
+)
+
+// BackupCmd represents the "backup" command.
+type BackupCmd struct {
+	Namespace      string        `default:"upbound-system" help:"Namespace Universal Crossplane is installed in."`
+	Output         string        `default:"."              help:"Directory to write the archive to." short:"o" type:"path"`
+	IncludeSecrets bool          `help:"Back up the Secrets in the namespace. The entitlement Secret is never backed up."`
+	Timeout        time.Duration `default:"5m"             help:"How long to wait for the backup to complete."`
+}
+
+// Run exports the Crossplane API objects into an archive in the output
+// directory.
+func (c *BackupCmd) Run() error {
+	kube, err := newClient(corev1.AddToScheme)
+	if err != nil {
+		return err
+	}
+
+	var opts []backup.ExporterOption
+	if c.IncludeSecrets {
+		opts = append(opts, backup.WithSecretsFrom(c.Namespace))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	a, err := backup.NewExporter(kube, opts...).Export(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot export crossplane objects")
+	}
+
+	path := filepath.Join(c.Output, a.Name()+".tar.gz")
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "cannot create archive file")
+	}
+	if err := a.Write(f); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "cannot write archive")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "cannot close archive file")
+	}
+	for _, e := range a.Manifest.Entries {
+		if e.Count > 0 {
+			fmt.Fprintf(os.Stdout, "Backed up %d %s (%s)\n", e.Count, e.Kind, e.APIVersion)
+		}
+	}
+	fmt.Fprintf(os.Stdout, "Wrote backup to %s\n", path)
+	return nil
+}
+
+// RestoreCmd represents the "restore" command.
+type RestoreCmd struct {
+	Archive     string        `arg:"" help:"Path of the archive written by the backup command." type:"existingfile"`
+	KeepPaused  bool          `help:"Leave the restored composite resources and claims paused."`
+	WaitTimeout time.Duration `default:"5m"  help:"How long to wait for each kind to be served, e.g. for a package to install its CRDs."`
+	Timeout     time.Duration `default:"30m" help:"How long to wait for the restore to complete."`
+}
+
+// Run restores the Crossplane API objects of an archive in dependency order.
+func (c *RestoreCmd) Run(zl logr.Logger) error {
+	f, err := os.Open(filepath.Clean(c.Archive))
+	if err != nil {
+		return errors.Wrap(err, "cannot open archive")
+	}
+	defer f.Close() //nolint:errcheck // The archive is only read.
+	a, err := backup.Read(f)
+	if err != nil {
+		return errors.Wrap(err, "cannot read archive")
+	}
+
+	kube, err := newClient(corev1.AddToScheme)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	r := backup.NewRestorer(kube,
+		backup.WithLogger(logging.NewLogrLogger(zl.WithName("restore"))),
+		backup.WithKeepPaused(c.KeepPaused),
+		backup.WithWaitTimeout(c.WaitTimeout),
+	)
+	if err := r.Restore(ctx, a); err != nil {
+		return errors.Wrap(err, "cannot restore crossplane objects")
+	}
+	fmt.Fprintf(os.Stdout, "Restored backup %s\n", a.Name())
+	return nil
+}
@@ -16,65 +16,43 @@
 package main
 
 import (
-	"fmt"
-	"time"
-
 	"github.com/alecthomas/kong"
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
-	"github.com/crossplane/crossplane-runtime/pkg/logging"
 
-	"github.com/upbound/universal-crossplane/internal/controllers/billing"
-	"github.com/upbound/universal-crossplane/internal/version"
+	"github.com/upbound/universal-crossplane/internal/logger"
 )
 
-// BootstrapCmd represents the "bootstrap" command.
-type BootstrapCmd struct {
-	SyncPeriod  time.Duration `default:"10m"`
-	Namespace   string        `default:"upbound-system"`
-	Controllers []string      `default:"aws-marketplace" help:"List of controllers you want to run" name:"controller"`
-	MetricsPort int           `default:"8085"            help:"Port for metrics server."`
-}
-
 var cli struct { //nolint:gochecknoglobals // CLI definition.
 	Debug bool `help:"Enable debug mode"`
 
+	LogFormat          string            `help:"Log format, one of json or console. Defaults to console in debug mode and json otherwise."`
+	LogLevel           string            `help:"Log level, one of debug, info, error or a positive verbosity. Defaults to debug in debug mode and info otherwise."`
+	LogLevelController map[string]string `help:"Log level overrides per controller, e.g. aws-marketplace=debug."`
+	LogSampling        bool              `help:"Sample repeated log lines to reduce log volume."`
+
 	Bootstrap BootstrapCmd `cmd:"" help:"Bootstraps Universal Crossplane" name:"start"`
+	Version   VersionCmd   `cmd:"" help:"Print the build information of the bootstrapper."`
+	Preflight PreflightCmd `cmd:"" help:"Check whether the installed Crossplane can be upgraded to this release."`
+
+	SupportBundle SupportBundleCmd `cmd:"" help:"Collect diagnostics of Universal Crossplane into a support bundle." name:"support-bundle"`
+	Backup        BackupCmd        `cmd:"" help:"Back up the Crossplane API objects into an archive."`
+	Restore       RestoreCmd       `cmd:"" help:"Restore the Crossplane API objects of an archive in dependency order."`
+	Migrate       MigrateCmd       `cmd:"" help:"Migrate to Universal Crossplane."`
+	Uninstall     UninstallCmd     `cmd:"" help:"Remove the objects the bootstrapper created."`
 }
 
 func main() {
 	ctx := kong.Parse(&cli)
-	zl := zap.New(zap.UseDevMode(cli.Debug))
-	ctrl.SetLogger(zl)
-	s := runtime.NewScheme()
-	ctx.FatalIfErrorf(corev1.AddToScheme(s), "cannot add corev1 to client-go scheme")
-	ctx.FatalIfErrorf(appsv1.AddToScheme(s), "cannot add appsv1 to client-go scheme")
-
-	cfg, err := ctrl.GetConfig()
-	ctx.FatalIfErrorf(errors.Wrap(err, "cannot get config"))
-	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
-		Scheme:             s,
-		SyncPeriod:         &cli.Bootstrap.SyncPeriod,
-		Namespace:          cli.Bootstrap.Namespace,
-		MetricsBindAddress: fmt.Sprintf(":%d", cli.Bootstrap.MetricsPort),
+	zl, err := logger.New(logger.Options{
+		Debug:            cli.Debug,
+		Format:           cli.LogFormat,
+		Level:            cli.LogLevel,
+		ControllerLevels: cli.LogLevelController,
+		Sampling:         cli.LogSampling,
 	})
-	ctx.FatalIfErrorf(errors.Wrap(err, "cannot create manager"))
-
-	logger := logging.NewLogrLogger(zl.WithName("bootstrapper"))
-	for _, c := range cli.Bootstrap.Controllers {
-		switch c {
-		case "aws-marketplace":
-			ctx.FatalIfErrorf(errors.Wrapf(billing.SetupAWSMarketplace(mgr, logger), "cannot setup %s controller", c))
-		default:
-			ctx.Errorf("unknown controller name: %s", c)
-		}
-	}
-
-	logger.Info("Starting bootstrapper", "version", version.Version)
-	ctx.FatalIfErrorf(errors.Wrap(mgr.Start(ctrl.SetupSignalHandler()), "cannot start controller manager"))
+	ctx.FatalIfErrorf(errors.Wrap(err, "cannot create logger"))
+	ctrl.SetLogger(zl)
+	ctx.FatalIfErrorf(ctx.Run(zl))
 }
@@ -0,0 +1,158 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/cluster/charts"
+	"github.com/upbound/universal-crossplane/cluster/crds"
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/migrate"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// MigrateCmd represents the "migrate" command.
+type MigrateCmd struct {
+	FromOSS        MigrateFromOSSCmd        `cmd:"" help:"Migrate an open source Crossplane Helm release to Universal Crossplane." name:"from-oss"`
+	RuntimeConfigs MigrateRuntimeConfigsCmd `cmd:"" help:"Convert deprecated ControllerConfigs to DeploymentRuntimeConfigs and repoint the Providers that reference them." name:"runtime-configs"`
+}
+
+// MigrateFromOSSCmd represents the "migrate from-oss" command.
+type MigrateFromOSSCmd struct {
+	Namespace     string        `default:"crossplane-system"         help:"Namespace of the open source Crossplane release."`
+	Release       string        `default:"crossplane"                help:"Name of the open source Crossplane release."`
+	TargetVersion string        `help:"Universal Crossplane version to migrate to. Defaults to the version bundled with this release."`
+	Plan          string        `default:"uxp-migration-plan.yaml"   help:"Path of the plan file to write, or to read when applying."   type:"path"`
+	Values        string        `default:"uxp-migration-values.yaml" help:"Path of the file to write the values to upgrade the release with to." type:"path"`
+	Apply         bool          `help:"Apply the reviewed plan file instead of writing a new one."`
+	Timeout       time.Duration `default:"2m"                        help:"How long to wait for the migration to complete."`
+}
+
+// Run writes a migration plan, or applies a reviewed one.
+func (c *MigrateFromOSSCmd) Run() error {
+	kube, err := newClient(corev1.AddToScheme, appsv1.AddToScheme, extv1.AddToScheme)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	if c.Apply {
+		b, err := os.ReadFile(filepath.Clean(c.Plan))
+		if err != nil {
+			return errors.Wrap(err, "cannot read plan file")
+		}
+		plan := &migrate.Plan{}
+		if err := yaml.Unmarshal(b, plan); err != nil {
+			return errors.Wrap(err, "cannot parse plan file")
+		}
+		if err := migrate.Apply(ctx, kube, plan); err != nil {
+			return errors.Wrap(err, "cannot apply plan")
+		}
+		fmt.Fprintf(os.Stdout, "Applied %d actions of %s. Upgrade the release with:\n\n  helm upgrade %s universal-crossplane --repo https://charts.upbound.io/stable --namespace %s --version %s -f %s\n",
+			len(plan.Actions), c.Plan, plan.Release, plan.Namespace, plan.Target, c.Values)
+		return nil
+	}
+
+	tv := c.TargetVersion
+	if tv == "" {
+		tv = version.CrossplaneVersion
+	}
+	target, err := version.Parse(tv)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse target version")
+	}
+	bundled, err := crds.Load()
+	if err != nil {
+		return err
+	}
+	values, err := charts.Values()
+	if err != nil {
+		return err
+	}
+	plan, err := migrate.NewPlanner(kube, c.Namespace, c.Release, target, bundled, values).Plan(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot plan migration")
+	}
+
+	if err := writeYAML(c.Plan, plan); err != nil {
+		return errors.Wrap(err, "cannot write plan file")
+	}
+	if err := writeYAML(c.Values, plan.CarriedValues); err != nil {
+		return errors.Wrap(err, "cannot write values file")
+	}
+	fmt.Fprint(os.Stdout, plan.String())
+	if !plan.Ready() {
+		return errors.Errorf("migration is not supported, see the failed checks in %s", c.Plan)
+	}
+	fmt.Fprintf(os.Stdout, "\nWrote plan to %s and values to %s. Review them and run with --apply to apply the plan.\n", c.Plan, c.Values)
+	return nil
+}
+
+// MigrateRuntimeConfigsCmd represents the "migrate runtime-configs" command.
+type MigrateRuntimeConfigsCmd struct {
+	Apply   bool          `help:"Apply the migration instead of only printing its diff."`
+	Timeout time.Duration `default:"2m"                                                  help:"How long to wait for the migration to complete."`
+}
+
+// Run prints the diff of the migration, and applies it if requested. The
+// ControllerConfigs are not deleted.
+func (c *MigrateRuntimeConfigsCmd) Run() error {
+	kube, err := newClient(pkgv1.AddToScheme)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	m, err := migrate.PlanRuntimeConfigs(ctx, kube)
+	if err != nil {
+		return errors.Wrap(err, "cannot plan migration")
+	}
+	fmt.Fprint(os.Stdout, m.Diff())
+	if m.Empty() {
+		fmt.Fprintln(os.Stdout, "No ControllerConfigs to migrate.")
+		return nil
+	}
+	if !c.Apply {
+		fmt.Fprintln(os.Stdout, "\nReview the diff and run with --apply to apply it.")
+		return nil
+	}
+	if err := migrate.ApplyRuntimeConfigs(ctx, kube, m); err != nil {
+		return errors.Wrap(err, "cannot apply migration")
+	}
+	fmt.Fprintf(os.Stdout, "\nCreated %d DeploymentRuntimeConfigs and repointed %d Providers. The ControllerConfigs can be deleted once the Providers are healthy.\n", len(m.Create), len(m.Repoint))
+	return nil
+}
+
+func writeYAML(path string, obj any) error {
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(path), b, 0o600)
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/cluster/crds"
+	"github.com/upbound/universal-crossplane/internal/preflight"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// PreflightCmd represents the "preflight" command.
+type PreflightCmd struct {
+	Namespace     string        `default:"upbound-system" help:"Namespace Crossplane is installed in."`
+	TargetVersion string        `help:"Crossplane version to upgrade to. Defaults to the version bundled with this release."`
+	Output        string        `default:"text"           enum:"text,json" help:"Output format, one of text or json." short:"o"`
+	Timeout       time.Duration `default:"2m"             help:"How long to wait for the checks to complete."`
+
+	AllowIncompatiblePackages bool `help:"Pass the checks even if installed packages are not compatible with the target version."`
+}
+
+// Run checks whether the installed Crossplane can be upgraded to the target
+// version and fails if it cannot.
+func (c *PreflightCmd) Run() error {
+	tv := c.TargetVersion
+	if tv == "" {
+		tv = version.CrossplaneVersion
+	}
+	target, err := version.Parse(tv)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse target version")
+	}
+	bundled, err := crds.Load()
+	if err != nil {
+		return err
+	}
+
+	kube, err := newClient(corev1.AddToScheme, appsv1.AddToScheme, extv1.AddToScheme)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	r, err := preflight.NewChecker(kube, c.Namespace, target, bundled, preflight.WithAllowIncompatiblePackages(c.AllowIncompatiblePackages)).Run(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot run preflight checks")
+	}
+
+	if c.Output == "json" {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if err := e.Encode(r); err != nil {
+			return errors.Wrap(err, "cannot encode report")
+		}
+	} else {
+		fmt.Fprint(os.Stdout, r.String())
+	}
+	if !r.Passed() {
+		return errors.New("preflight checks failed, the upgrade is not supported")
+	}
+	return nil
+}
+
+// newClient returns an uncached client whose scheme is built with the given
+// functions.
+func newClient(addToScheme ...func(*runtime.Scheme) error) (client.Client, error) {
+	s := runtime.NewScheme()
+	for _, add := range addToScheme {
+		if err := add(s); err != nil {
+			return nil, errors.Wrap(err, "cannot build scheme")
+		}
+	}
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get config")
+	}
+	kube, err := client.New(cfg, client.Options{Scheme: s})
+	return kube, errors.Wrap(err, "cannot create client")
+}
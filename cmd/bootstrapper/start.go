@@ -0,0 +1,308 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	operatorv1alpha1 "github.com/upbound/universal-crossplane/internal/apis/operator/v1alpha1"
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/controllers/autoupgrade"
+	"github.com/upbound/universal-crossplane/internal/controllers/billing"
+	"github.com/upbound/universal-crossplane/internal/controllers/compat"
+	"github.com/upbound/universal-crossplane/internal/controllers/connect"
+	"github.com/upbound/universal-crossplane/internal/controllers/crddrift"
+	"github.com/upbound/universal-crossplane/internal/controllers/gc"
+	"github.com/upbound/universal-crossplane/internal/controllers/health"
+	"github.com/upbound/universal-crossplane/internal/controllers/mirror"
+	"github.com/upbound/universal-crossplane/internal/controllers/operator"
+	"github.com/upbound/universal-crossplane/internal/controllers/packages"
+	"github.com/upbound/universal-crossplane/internal/controllers/pullsecrets"
+	"github.com/upbound/universal-crossplane/internal/controllers/telemetry"
+	"github.com/upbound/universal-crossplane/internal/controllers/updates"
+	"github.com/upbound/universal-crossplane/internal/controllers/versions"
+	"github.com/upbound/universal-crossplane/internal/controllers/xgql"
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/metrics"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// BootstrapCmd represents the "bootstrap" command.
+type BootstrapCmd struct {
+	SyncPeriod  time.Duration `default:"10m"`
+	Namespace   string        `default:"upbound-system"`
+	Controllers []string      `default:"aws-marketplace" help:"List of controllers you want to run" name:"controller"`
+	MetricsPort int           `default:"8085"            help:"Port for metrics server."`
+
+	MetricsSecure     bool   `help:"Serve metrics over HTTPS."`
+	MetricsCertSecret string `help:"Name of the TLS Secret in the namespace to serve metrics with. A self-signed certificate is generated and rotated if empty."`
+	MetricsAuth       bool   `help:"Authenticate and authorize metrics requests with TokenReviews and SubjectAccessReviews. Requires --metrics-secure."`
+
+	TracingEndpoint    string  `help:"OTLP/HTTP endpoint (host:port) to export traces to. Traces are not exported if empty."`
+	TracingInsecure    bool    `help:"Disable TLS when exporting traces."`
+	TracingSampleRatio float64 `default:"1" help:"Fraction of reconciles that are traced."`
+
+	AgentImage     string `default:"xpkg.upbound.io/upbound/agent:v0.1.0" help:"Image of the Upbound agent run by the cloud-connect controller."`
+	XGQLEnabled    bool   `default:"true" help:"Install XGQL when the xgql controller runs, remove it if false." name:"xgql-enabled" negatable:""`
+	CRDDriftRepair bool   `help:"Replace CRDs that drifted from the bundled ones when the crd-drift controller runs." name:"crd-drift-repair"`
+
+	TelemetryEndpoint string        `help:"HTTP endpoint the telemetry controller posts reports to. Reports are only written to a ConfigMap if empty."`
+	TelemetryDryRun   bool          `help:"Only write telemetry reports to a ConfigMap, even if an endpoint is set."`
+	TelemetryInterval time.Duration `default:"24h" help:"How often the telemetry controller reports."`
+
+	GCDryRun bool `help:"Only report the objects the gc controller would prune." name:"gc-dry-run"`
+
+	AutoUpgradeRegistry string        `help:"Registry endpoint the auto-upgrade controller lists package tags from, e.g. a local OCI registry. The registries of the packages are used if empty."`
+	AutoUpgradeInterval time.Duration `default:"1h" help:"How often the auto-upgrade controller lists package tags."`
+
+	CompatRegistry string `help:"Registry endpoint the compat controller reads package metadata from, e.g. a local OCI registry. The registries of the packages are used if empty."`
+
+	UpdatesCatalogURL string        `help:"URL the updates controller fetches the release catalog from, e.g. a mirror. The catalog in the updates ConfigMap or the bundled catalog is used if empty." name:"updates-catalog-url"`
+	UpdatesInterval   time.Duration `default:"6h" help:"How often the updates controller checks the release catalog."`
+}
+
+// Run starts the bootstrapper controllers.
+func (c *BootstrapCmd) Run(zl logr.Logger) error {
+	s := runtime.NewScheme()
+	if err := corev1.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add corev1 to client-go scheme")
+	}
+	if err := appsv1.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add appsv1 to client-go scheme")
+	}
+	if err := authnv1.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add authenticationv1 to client-go scheme")
+	}
+	if err := authzv1.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add authorizationv1 to client-go scheme")
+	}
+	if err := rbacv1.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add rbacv1 to client-go scheme")
+	}
+	if err := extv1.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add apiextensions v1 to client-go scheme")
+	}
+	if err := pkgv1.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add Crossplane pkg v1 to client-go scheme")
+	}
+	if err := operatorv1alpha1.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add UXP operator v1alpha1 to client-go scheme")
+	}
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{
+		Endpoint:    c.TracingEndpoint,
+		Insecure:    c.TracingInsecure,
+		SampleRatio: c.TracingSampleRatio,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot setup tracing")
+	}
+	defer shutdown(context.Background()) //nolint:errcheck // Nothing we can do if flushing traces fails on exit.
+
+	if c.MetricsAuth && !c.MetricsSecure {
+		return errors.New("--metrics-auth requires --metrics-secure")
+	}
+	metricsAddr := fmt.Sprintf(":%d", c.MetricsPort)
+	if c.MetricsSecure {
+		// The built-in metrics server of controller-runtime only serves
+		// plain HTTP, so we serve metrics ourselves.
+		metricsAddr = "0"
+	}
+
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "cannot get config")
+	}
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             s,
+		SyncPeriod:         &c.SyncPeriod,
+		Namespace:          c.Namespace,
+		MetricsBindAddress: metricsAddr,
+		NewClient:          newTracingClient,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot create manager")
+	}
+
+	log := logging.NewLogrLogger(zl.WithName("bootstrapper"))
+	bi := version.Get()
+	if err := metrics.RegisterBuildInfo(crmetrics.Registry, bi); err != nil {
+		return err
+	}
+	if err := metrics.RegisterComponentHealth(crmetrics.Registry); err != nil {
+		return err
+	}
+	if err := metrics.RegisterCRDDrift(crmetrics.Registry); err != nil {
+		return err
+	}
+	if err := metrics.RegisterPackageHealth(crmetrics.Registry); err != nil {
+		return err
+	}
+	if err := metrics.RegisterPackageCompatibility(crmetrics.Registry); err != nil {
+		return err
+	}
+	if err := metrics.RegisterUpdates(crmetrics.Registry); err != nil {
+		return err
+	}
+	if c.MetricsSecure {
+		if err := mgr.Add(c.newMetricsServer(mgr, log)); err != nil {
+			return errors.Wrap(err, "cannot add metrics server")
+		}
+	}
+	for _, name := range c.Controllers {
+		// Controller loggers are named so that their levels can be overridden.
+		cl := logging.NewLogrLogger(zl.WithName("bootstrapper").WithName(name))
+		switch name {
+		case "aws-marketplace":
+			if err := billing.SetupAWSMarketplace(mgr, cl); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "versions":
+			if err := versions.Setup(mgr, cl); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "cloud-connect":
+			if err := connect.Setup(mgr, cl, c.AgentImage, c.inventory(mgr, name)); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "xgql":
+			if err := xgql.Setup(mgr, cl, c.XGQLEnabled, c.inventory(mgr, name)); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "packages":
+			if err := packages.Setup(mgr, cl, c.Namespace, c.inventory(mgr, name)); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "crd-drift":
+			if err := crddrift.Setup(mgr, cl, c.Namespace, c.CRDDriftRepair); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "health":
+			if err := health.Setup(mgr, cl, c.Namespace); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "registry-mirror":
+			if err := mirror.Setup(mgr, cl, c.Namespace, c.inventory(mgr, name)); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "telemetry":
+			if err := telemetry.Setup(mgr, cl, c.TelemetryEndpoint, c.TelemetryDryRun, c.TelemetryInterval); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "pull-secrets":
+			if err := pullsecrets.Setup(mgr, cl, c.Namespace, c.inventory(mgr, name)); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "auto-upgrade":
+			if err := autoupgrade.Setup(mgr, cl, c.AutoUpgradeRegistry, c.AutoUpgradeInterval); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "compat":
+			if err := compat.Setup(mgr, cl, c.Namespace, c.CompatRegistry); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "updates":
+			if err := updates.Setup(mgr, cl, c.Namespace, c.UpdatesCatalogURL, c.UpdatesInterval); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "operator":
+			if err := operator.Setup(mgr, cl, c.Namespace, c.inventory(mgr, name)); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		case "gc":
+			if err := gc.Setup(mgr, cl, c.Controllers, c.GCDryRun); err != nil {
+				return errors.Wrapf(err, "cannot setup %s controller", name)
+			}
+		default:
+			return errors.Errorf("unknown controller name: %s", name)
+		}
+	}
+
+	log.Info("Starting bootstrapper",
+		"version", bi.Version,
+		"commit", bi.Commit,
+		"buildDate", bi.BuildDate,
+		"dirty", bi.Dirty,
+		"goVersion", bi.GoVersion,
+		"platform", bi.Platform,
+		"crossplaneVersion", bi.CrossplaneVersion,
+	)
+	return errors.Wrap(mgr.Start(ctrl.SetupSignalHandler()), "cannot start controller manager")
+}
+
+// inventory returns the Recorder the named controller records the objects
+// it applies with. Nothing is recorded unless the gc controller runs.
+func (c *BootstrapCmd) inventory(mgr ctrl.Manager, controller string) inventory.Recorder {
+	for _, name := range c.Controllers {
+		if name == "gc" {
+			return inventory.NewConfigMapRecorder(mgr.GetClient(), c.Namespace, controller)
+		}
+	}
+	return inventory.NopRecorder{}
+}
+
+// newMetricsServer returns a metrics server that serves HTTPS and optionally
+// authorizes its clients.
+func (c *BootstrapCmd) newMetricsServer(mgr ctrl.Manager, log logging.Logger) *metrics.Server {
+	l := log.WithValues("component", "metrics")
+	var cs metrics.CertificateSource
+	if c.MetricsCertSecret != "" {
+		cs = metrics.NewSecretCertificateSource(mgr.GetAPIReader(), types.NamespacedName{Name: c.MetricsCertSecret, Namespace: c.Namespace}, l)
+	} else {
+		hosts := []string{"localhost", "127.0.0.1"}
+		if h, err := os.Hostname(); err == nil {
+			hosts = append(hosts, h)
+		}
+		cs = metrics.NewSelfSignedCertificateSource(hosts, l)
+	}
+	opts := []metrics.ServerOption{metrics.WithCertificateSource(cs), metrics.WithLogger(l)}
+	if c.MetricsAuth {
+		opts = append(opts, metrics.WithAuthorizer(metrics.NewKubernetesAuthorizer(mgr.GetClient())))
+	}
+	return metrics.NewServer(fmt.Sprintf(":%d", c.MetricsPort), opts...)
+}
+
+// newTracingClient returns the default controller-runtime client wrapped so
+// that every call made to the API server is recorded as a span.
+func newTracingClient(c cache.Cache, cfg *rest.Config, o client.Options, uncached ...client.Object) (client.Client, error) {
+	cl, err := cluster.DefaultNewClient(c, cfg, o, uncached...)
+	if err != nil {
+		return nil, err
+	}
+	return tracing.NewClient(cl), nil
+}
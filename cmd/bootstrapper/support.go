@@ -0,0 +1,82 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/support"
+)
+
+// SupportBundleCmd represents the "support-bundle" command.
+type SupportBundleCmd struct {
+	Namespace   string        `default:"upbound-system" help:"Namespace Universal Crossplane is installed in."`
+	Output      string        `default:"."              help:"Directory to write the bundle to." short:"o" type:"path"`
+	EventsSince time.Duration `default:"1h"             help:"How old the collected events can be."`
+	LogLines    int64         `default:"1000"           help:"Number of the most recent log lines collected per container."`
+	Timeout     time.Duration `default:"2m"             help:"How long to wait for the collection to complete."`
+}
+
+// Run collects a support bundle and writes it to the output directory.
+func (c *SupportBundleCmd) Run() error {
+	kube, err := newClient(corev1.AddToScheme, appsv1.AddToScheme)
+	if err != nil {
+		return err
+	}
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "cannot get config")
+	}
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot create clientset")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	b, err := support.NewCollector(kube, support.NewClientsetLogSource(cs, c.LogLines), c.Namespace, support.WithEventsSince(c.EventsSince)).Collect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot collect support bundle")
+	}
+
+	path := filepath.Join(c.Output, b.Name()+".tar.gz")
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "cannot create bundle file")
+	}
+	if err := b.Write(f); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "cannot write bundle")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "cannot close bundle file")
+	}
+	fmt.Fprintf(os.Stdout, "Wrote support bundle to %s\n", path)
+	for _, e := range b.Manifest.Errors {
+		fmt.Fprintf(os.Stdout, "Not collected: %s\n", e)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/uninstall"
+)
+
+// UninstallCmd represents the "uninstall" command.
+type UninstallCmd struct {
+	Namespace       string        `default:"upbound-system" help:"Namespace Universal Crossplane is installed in."`
+	DeletePackages  bool          `help:"Delete all Crossplane packages, Configurations first. This deletes their CRDs and every managed resource."`
+	StripFinalizers bool          `help:"Remove the finalizers of the objects that are stuck in deletion, after confirmation."`
+	Yes             bool          `help:"Confirm removing finalizers without prompting, e.g. when run as a Helm hook." short:"y"`
+	DryRun          bool          `help:"Only report what would be removed."`
+	Report          string        `help:"Path of the file to write the report to, in addition to printing it." type:"path"`
+	WaitTimeout     time.Duration `default:"2m"             help:"How long to wait for deleted objects to be gone before they are considered stuck."`
+	Timeout         time.Duration `default:"10m"            help:"How long to wait for the uninstall to complete."`
+}
+
+// Run removes the objects the bootstrapper created and reports them.
+func (c *UninstallCmd) Run(zl logr.Logger) error {
+	kube, err := newClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	opts := []uninstall.Option{
+		uninstall.WithLogger(logging.NewLogrLogger(zl.WithName("uninstall"))),
+		uninstall.WithDeletePackages(c.DeletePackages),
+		uninstall.WithDryRun(c.DryRun),
+		uninstall.WithWaitTimeout(c.WaitTimeout),
+	}
+	if c.StripFinalizers {
+		opts = append(opts, uninstall.WithStripFinalizers(c.confirm))
+	}
+	r := uninstall.New(kube, c.Namespace, opts...).Run(ctx)
+
+	b, err := yaml.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal report")
+	}
+	fmt.Fprint(os.Stdout, string(b))
+	if c.Report != "" {
+		if err := writeYAML(c.Report, r); err != nil {
+			return errors.Wrap(err, "cannot write report file")
+		}
+	}
+	if len(r.Errors) > 0 {
+		return errors.Errorf("cannot remove %d objects, see the errors in the report", len(r.Errors))
+	}
+	return nil
+}
+
+// confirm asks whether the finalizers of the stuck objects may be removed,
+// unless that was confirmed up front.
+func (c *UninstallCmd) confirm(stuck []uninstall.Object) bool {
+	if c.Yes {
+		return true
+	}
+	fmt.Fprintln(os.Stdout, "The following objects are stuck in deletion:")
+	for _, o := range stuck {
+		fmt.Fprintf(os.Stdout, "  %s %v\n", o, o.Finalizers)
+	}
+	fmt.Fprint(os.Stdout, "Removing their finalizers may orphan the resources they clean up. Remove them? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(answer), "y")
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// VersionCmd represents the "version" command.
+type VersionCmd struct {
+	Output string `default:"text" enum:"text,json" help:"Output format, one of text or json." short:"o"`
+}
+
+// Run prints the build information.
+func (c *VersionCmd) Run() error {
+	i := version.Get()
+	if c.Output == "json" {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		return errors.Wrap(e.Encode(i), "cannot encode build information")
+	}
+	_, err := fmt.Fprint(os.Stdout, i.String())
+	return err
+}
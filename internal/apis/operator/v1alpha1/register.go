@@ -0,0 +1,54 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package v1alpha1 contains the operator.upbound.io/v1alpha1 API types of the
+// operator mode of the bootstrapper, in which it installs and upgrades
+// Universal Crossplane as a UniversalCrossplane specifies, e.g. when it is
+// installed by the Operator Lifecycle Manager.
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+// Package type metadata.
+const (
+	Group   = "operator.upbound.io"
+	Version = "v1alpha1"
+)
+
+var (
+	// SchemeGroupVersion is group version used to register these objects.
+	SchemeGroupVersion = schema.GroupVersion{Group: Group, Version: Version} //nolint:gochecknoglobals // Required by the scheme builder.
+
+	// SchemeBuilder is used to add go types to the GroupVersionKind scheme.
+	SchemeBuilder = &scheme.Builder{GroupVersion: SchemeGroupVersion} //nolint:gochecknoglobals // Required by the scheme builder.
+
+	// AddToScheme adds the types of this group version to a scheme.
+	AddToScheme = SchemeBuilder.AddToScheme //nolint:gochecknoglobals // Required by the scheme builder.
+)
+
+// UniversalCrossplane type metadata.
+const (
+	UniversalCrossplaneKind = "UniversalCrossplane"
+)
+
+// UniversalCrossplaneGroupVersionKind is the GroupVersionKind of a
+// UniversalCrossplane.
+var UniversalCrossplaneGroupVersionKind = SchemeGroupVersion.WithKind(UniversalCrossplaneKind) //nolint:gochecknoglobals // We treat this as a constant.
+
+func init() {
+	SchemeBuilder.Register(&UniversalCrossplane{}, &UniversalCrossplaneList{})
+}
@@ -0,0 +1,259 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// UniversalCrossplaneName is the name of the only UniversalCrossplane that is
+// reconciled.
+const UniversalCrossplaneName = "uxp"
+
+// Condition types of a UniversalCrossplane.
+const (
+	// TypeUpgrading is true while the components are upgraded to a new
+	// version of Universal Crossplane.
+	TypeUpgrading xpv1.ConditionType = "Upgrading"
+)
+
+// Reasons of the Upgrading condition.
+const (
+	ReasonUpgrading xpv1.ConditionReason = "Upgrading"
+	ReasonUpToDate  xpv1.ConditionReason = "UpToDate"
+)
+
+// ImageSpec specifies the Crossplane image. The RBAC manager uses the same
+// image.
+type ImageSpec struct {
+	// Repository of the Crossplane image.
+	// +optional
+	Repository string `json:"repository,omitempty"`
+
+	// Tag of the Crossplane image, i.e. the version of Universal Crossplane.
+	// Defaults to the version bundled with the bootstrapper.
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// PullPolicy of the Crossplane and RBAC manager images.
+	// +optional
+	PullPolicy corev1.PullPolicy `json:"pullPolicy,omitempty"`
+}
+
+// CacheSpec specifies the package cache of Crossplane.
+type CacheSpec struct {
+	// Medium of the cache. Set to Memory to hold the cache in a RAM backed
+	// file system.
+	// +optional
+	Medium corev1.StorageMedium `json:"medium,omitempty"`
+
+	// SizeLimit of the cache.
+	// +optional
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+
+	// PVC is the name of a PersistentVolumeClaim to use as the cache instead
+	// of an emptyDir volume.
+	// +optional
+	PVC string `json:"pvc,omitempty"`
+}
+
+// WebhooksSpec specifies the webhooks of Crossplane.
+type WebhooksSpec struct {
+	// Enabled webhooks for Crossplane and installed Provider packages.
+	// Defaults to true.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// MetricsSpec specifies the metrics of Crossplane and the RBAC manager.
+type MetricsSpec struct {
+	// Enabled Prometheus scrape annotations and metrics ports.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// RBACManagerSpec specifies the RBAC manager.
+type RBACManagerSpec struct {
+	// Deploy the RBAC manager and its roles. Defaults to true.
+	// +optional
+	Deploy *bool `json:"deploy,omitempty"`
+
+	// SkipAggregatedClusterRoles skips the aggregated Crossplane ClusterRoles.
+	// +optional
+	SkipAggregatedClusterRoles bool `json:"skipAggregatedClusterRoles,omitempty"`
+
+	// Replicas of the RBAC manager. Defaults to 1.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// LeaderElection of the RBAC manager. Defaults to true.
+	// +optional
+	LeaderElection *bool `json:"leaderElection,omitempty"`
+
+	// Args of the RBAC manager.
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// NodeSelector of the RBAC manager pods.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the RBAC manager pods.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity of the RBAC manager pods.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+}
+
+// UniversalCrossplaneSpec specifies the desired state of Universal
+// Crossplane. Its fields mirror the values of the universal-crossplane Helm
+// chart.
+type UniversalCrossplaneSpec struct {
+	// Replicas of Crossplane. Defaults to 1.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Image of Crossplane and the RBAC manager.
+	// +optional
+	Image ImageSpec `json:"image,omitempty"`
+
+	// ImagePullSecrets are the names of the Secrets added to the Crossplane
+	// ServiceAccount.
+	// +optional
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+
+	// LeaderElection of Crossplane. Defaults to true.
+	// +optional
+	LeaderElection *bool `json:"leaderElection,omitempty"`
+
+	// Args of Crossplane.
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// NodeSelector of the Crossplane pods.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the Crossplane pods.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity of the Crossplane pods.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// PriorityClassName of the Crossplane and RBAC manager pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// ResourcesCrossplane are the resources of the Crossplane containers.
+	// +optional
+	ResourcesCrossplane *corev1.ResourceRequirements `json:"resourcesCrossplane,omitempty"`
+
+	// ResourcesRBACManager are the resources of the RBAC manager container.
+	// +optional
+	ResourcesRBACManager *corev1.ResourceRequirements `json:"resourcesRBACManager,omitempty"`
+
+	// ExtraEnvVarsCrossplane are environment variables of the Crossplane
+	// container. Any "." in a name is replaced with "_".
+	// +optional
+	ExtraEnvVarsCrossplane map[string]string `json:"extraEnvVarsCrossplane,omitempty"`
+
+	// ExtraEnvVarsRBACManager are environment variables of the RBAC manager
+	// container. Any "." in a name is replaced with "_".
+	// +optional
+	ExtraEnvVarsRBACManager map[string]string `json:"extraEnvVarsRBACManager,omitempty"`
+
+	// PackageCache of Crossplane.
+	// +optional
+	PackageCache CacheSpec `json:"packageCache,omitempty"`
+
+	// Webhooks of Crossplane.
+	// +optional
+	Webhooks WebhooksSpec `json:"webhooks,omitempty"`
+
+	// Metrics of Crossplane and the RBAC manager.
+	// +optional
+	Metrics MetricsSpec `json:"metrics,omitempty"`
+
+	// RBACManager of Crossplane.
+	// +optional
+	RBACManager RBACManagerSpec `json:"rbacManager,omitempty"`
+}
+
+// ComponentStatus is the observed state of a component of Universal
+// Crossplane.
+type ComponentStatus struct {
+	// Name of the component.
+	Name string `json:"name"`
+
+	// Version the component runs.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Ready is true if the component is rolled out and available.
+	Ready bool `json:"ready"`
+
+	// Message explains why the component is not ready.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// UniversalCrossplaneStatus is the observed state of Universal Crossplane.
+type UniversalCrossplaneStatus struct {
+	xpv1.ConditionedStatus `json:",inline"`
+
+	// ObservedGeneration is the generation of the spec the status reflects.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Version of Universal Crossplane all components run.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Components of Universal Crossplane.
+	// +optional
+	Components []ComponentStatus `json:"components,omitempty"`
+}
+
+// A UniversalCrossplane installs and upgrades Universal Crossplane in the
+// namespace of the bootstrapper. Only the one named uxp is reconciled.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories=uxp
+// +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".status.version"
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+type UniversalCrossplane struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UniversalCrossplaneSpec   `json:"spec,omitempty"`
+	Status UniversalCrossplaneStatus `json:"status,omitempty"`
+}
+
+// UniversalCrossplaneList contains a list of UniversalCrossplane.
+// +kubebuilder:object:root=true
+type UniversalCrossplaneList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UniversalCrossplane `json:"items"`
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package v1 contains the subset of the Crossplane pkg.crossplane.io/v1 API
+// types that the bootstrapper manages. Only the fields the bootstrapper reads
+// or writes are declared; other fields are preserved by patching rather than
+// updating where it matters.
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+// Package type metadata.
+const (
+	Group   = "pkg.crossplane.io"
+	Version = "v1"
+)
+
+var (
+	// SchemeGroupVersion is group version used to register these objects.
+	SchemeGroupVersion = schema.GroupVersion{Group: Group, Version: Version} //nolint:gochecknoglobals // Required by the scheme builder.
+
+	// SchemeBuilder is used to add go types to the GroupVersionKind scheme.
+	SchemeBuilder = &scheme.Builder{GroupVersion: SchemeGroupVersion} //nolint:gochecknoglobals // Required by the scheme builder.
+
+	// AddToScheme adds the types of this group version to a scheme.
+	AddToScheme = SchemeBuilder.AddToScheme //nolint:gochecknoglobals // Required by the scheme builder.
+)
+
+// Kinds of the packages.
+const (
+	ProviderKind      = "Provider"
+	ConfigurationKind = "Configuration"
+	FunctionKind      = "Function"
+
+	ProviderRevisionKind      = "ProviderRevision"
+	ConfigurationRevisionKind = "ConfigurationRevision"
+	FunctionRevisionKind      = "FunctionRevision"
+)
+
+// GroupVersionKinds of the packages.
+var (
+	ProviderGroupVersionKind      = SchemeGroupVersion.WithKind(ProviderKind)      //nolint:gochecknoglobals // We treat this as a constant.
+	ConfigurationGroupVersionKind = SchemeGroupVersion.WithKind(ConfigurationKind) //nolint:gochecknoglobals // We treat this as a constant.
+	FunctionGroupVersionKind      = SchemeGroupVersion.WithKind(FunctionKind)      //nolint:gochecknoglobals // We treat this as a constant.
+)
+
+func init() {
+	SchemeBuilder.Register(&Provider{}, &ProviderList{})
+	SchemeBuilder.Register(&Configuration{}, &ConfigurationList{})
+	SchemeBuilder.Register(&Function{}, &FunctionList{})
+	SchemeBuilder.Register(&ProviderRevision{}, &ProviderRevisionList{})
+	SchemeBuilder.Register(&ConfigurationRevision{}, &ConfigurationRevisionList{})
+	SchemeBuilder.Register(&FunctionRevision{}, &FunctionRevisionList{})
+}
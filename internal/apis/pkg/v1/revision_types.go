@@ -0,0 +1,136 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// LabelParentPackage is the label on a package revision that holds the name
+// of the package it is a revision of.
+const LabelParentPackage = "pkg.crossplane.io/package"
+
+// A PackageRevisionDesiredState is the desired state of a package revision.
+type PackageRevisionDesiredState string
+
+// Desired states of package revisions.
+const (
+	PackageRevisionActive   PackageRevisionDesiredState = "Active"
+	PackageRevisionInactive PackageRevisionDesiredState = "Inactive"
+)
+
+// PackageRevisionSpec specifies the desired state of a package revision.
+type PackageRevisionSpec struct {
+	// DesiredState of the package revision.
+	DesiredState PackageRevisionDesiredState `json:"desiredState"`
+
+	// Package image used by the revision.
+	Package string `json:"image"`
+
+	// Revision number.
+	Revision int64 `json:"revision"`
+}
+
+// PackageRevisionStatus is the observed state of a package revision.
+type PackageRevisionStatus struct {
+	xpv1.ConditionedStatus `json:",inline"`
+}
+
+// A ProviderRevision is a revision of a Provider.
+type ProviderRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PackageRevisionSpec   `json:"spec,omitempty"`
+	Status PackageRevisionStatus `json:"status,omitempty"`
+}
+
+// ProviderRevisionList contains a list of ProviderRevision.
+type ProviderRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProviderRevision `json:"items"`
+}
+
+// A ConfigurationRevision is a revision of a Configuration.
+type ConfigurationRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PackageRevisionSpec   `json:"spec,omitempty"`
+	Status PackageRevisionStatus `json:"status,omitempty"`
+}
+
+// ConfigurationRevisionList contains a list of ConfigurationRevision.
+type ConfigurationRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ConfigurationRevision `json:"items"`
+}
+
+// A FunctionRevision is a revision of a Function.
+type FunctionRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PackageRevisionSpec   `json:"spec,omitempty"`
+	Status PackageRevisionStatus `json:"status,omitempty"`
+}
+
+// FunctionRevisionList contains a list of FunctionRevision.
+type FunctionRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FunctionRevision `json:"items"`
+}
+
+// A PackageRevision is implemented by all package revision kinds.
+type PackageRevision interface {
+	client.Object
+
+	// GetPackageRevisionSpec returns the spec that is common to all package
+	// revisions.
+	GetPackageRevisionSpec() *PackageRevisionSpec
+	// GetPackageRevisionStatus returns the status that is common to all
+	// package revisions.
+	GetPackageRevisionStatus() *PackageRevisionStatus
+}
+
+// GetPackageRevisionSpec returns the package revision spec of the
+// ProviderRevision.
+func (r *ProviderRevision) GetPackageRevisionSpec() *PackageRevisionSpec { return &r.Spec }
+
+// GetPackageRevisionStatus returns the package revision status of the
+// ProviderRevision.
+func (r *ProviderRevision) GetPackageRevisionStatus() *PackageRevisionStatus { return &r.Status }
+
+// GetPackageRevisionSpec returns the package revision spec of the
+// ConfigurationRevision.
+func (r *ConfigurationRevision) GetPackageRevisionSpec() *PackageRevisionSpec { return &r.Spec }
+
+// GetPackageRevisionStatus returns the package revision status of the
+// ConfigurationRevision.
+func (r *ConfigurationRevision) GetPackageRevisionStatus() *PackageRevisionStatus { return &r.Status }
+
+// GetPackageRevisionSpec returns the package revision spec of the
+// FunctionRevision.
+func (r *FunctionRevision) GetPackageRevisionSpec() *PackageRevisionSpec { return &r.Spec }
+
+// GetPackageRevisionStatus returns the package revision status of the
+// FunctionRevision.
+func (r *FunctionRevision) GetPackageRevisionStatus() *PackageRevisionStatus { return &r.Status }
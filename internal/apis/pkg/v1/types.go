@@ -0,0 +1,199 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// Condition types of packages.
+const (
+	// TypeInstalled indicates whether a package has been installed.
+	TypeInstalled xpv1.ConditionType = "Installed"
+	// TypeHealthy indicates whether a package is healthy.
+	TypeHealthy xpv1.ConditionType = "Healthy"
+)
+
+// PackageSpec specifies the desired state of a package.
+type PackageSpec struct {
+	// Package is the name of the package that is being requested.
+	Package string `json:"package"`
+
+	// RevisionActivationPolicy specifies how the package controller should
+	// update from one revision to the next.
+	RevisionActivationPolicy *string `json:"revisionActivationPolicy,omitempty"`
+
+	// RevisionHistoryLimit dictates how the package controller cleans up old
+	// inactive package revisions.
+	RevisionHistoryLimit *int64 `json:"revisionHistoryLimit,omitempty"`
+
+	// PackagePullSecrets are named secrets in the same namespace that can be
+	// used to fetch packages from private registries.
+	PackagePullSecrets []corev1.LocalObjectReference `json:"packagePullSecrets,omitempty"`
+
+	// PackagePullPolicy defines the pull policy for the package.
+	PackagePullPolicy *corev1.PullPolicy `json:"packagePullPolicy,omitempty"`
+
+	// IgnoreCrossplaneConstraints indicates to the package manager whether to
+	// honor Crossplane version constraints specified by the package.
+	IgnoreCrossplaneConstraints *bool `json:"ignoreCrossplaneConstraints,omitempty"`
+
+	// SkipDependencyResolution indicates to the package manager whether to
+	// skip resolving dependencies for a package.
+	SkipDependencyResolution *bool `json:"skipDependencyResolution,omitempty"`
+}
+
+// RuntimeConfigReference references a DeploymentRuntimeConfig.
+type RuntimeConfigReference struct {
+	// Name of the DeploymentRuntimeConfig.
+	Name string `json:"name"`
+}
+
+// ControllerConfigReference references a deprecated ControllerConfig.
+type ControllerConfigReference struct {
+	// Name of the ControllerConfig.
+	Name string `json:"name"`
+}
+
+// PackageStatus is the observed state of a package.
+type PackageStatus struct {
+	xpv1.ConditionedStatus `json:",inline"`
+
+	// CurrentRevision is the name of the current package revision.
+	CurrentRevision string `json:"currentRevision,omitempty"`
+
+	// CurrentIdentifier is the most recent package source that was used to
+	// produce a revision.
+	CurrentIdentifier string `json:"currentIdentifier,omitempty"`
+}
+
+// ProviderSpec specifies the desired state of a Provider.
+type ProviderSpec struct {
+	PackageSpec `json:",inline"`
+
+	// RuntimeConfigReference to the DeploymentRuntimeConfig of the provider.
+	RuntimeConfigReference *RuntimeConfigReference `json:"runtimeConfigRef,omitempty"`
+
+	// ControllerConfigReference to the deprecated ControllerConfig of the
+	// provider.
+	ControllerConfigReference *ControllerConfigReference `json:"controllerConfigRef,omitempty"`
+}
+
+// A Provider installs a Crossplane provider package.
+type Provider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProviderSpec  `json:"spec,omitempty"`
+	Status PackageStatus `json:"status,omitempty"`
+}
+
+// ProviderList contains a list of Provider.
+type ProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Provider `json:"items"`
+}
+
+// ConfigurationSpec specifies the desired state of a Configuration.
+type ConfigurationSpec struct {
+	PackageSpec `json:",inline"`
+}
+
+// A Configuration installs a Crossplane configuration package.
+type Configuration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConfigurationSpec `json:"spec,omitempty"`
+	Status PackageStatus     `json:"status,omitempty"`
+}
+
+// ConfigurationList contains a list of Configuration.
+type ConfigurationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Configuration `json:"items"`
+}
+
+// FunctionSpec specifies the desired state of a Function.
+type FunctionSpec struct {
+	PackageSpec `json:",inline"`
+
+	// RuntimeConfigReference to the DeploymentRuntimeConfig of the function.
+	RuntimeConfigReference *RuntimeConfigReference `json:"runtimeConfigRef,omitempty"`
+}
+
+// A Function installs a Crossplane composition function package.
+type Function struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FunctionSpec  `json:"spec,omitempty"`
+	Status PackageStatus `json:"status,omitempty"`
+}
+
+// FunctionList contains a list of Function.
+type FunctionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Function `json:"items"`
+}
+
+// A Package is implemented by all package kinds.
+type Package interface {
+	client.Object
+
+	// GetPackageSpec returns the spec that is common to all packages.
+	GetPackageSpec() *PackageSpec
+	// GetPackageStatus returns the status that is common to all packages.
+	GetPackageStatus() *PackageStatus
+}
+
+// GetPackageSpec returns the package spec of the Provider.
+func (p *Provider) GetPackageSpec() *PackageSpec { return &p.Spec.PackageSpec }
+
+// GetPackageStatus returns the package status of the Provider.
+func (p *Provider) GetPackageStatus() *PackageStatus { return &p.Status }
+
+// GetPackageSpec returns the package spec of the Configuration.
+func (c *Configuration) GetPackageSpec() *PackageSpec { return &c.Spec.PackageSpec }
+
+// GetPackageStatus returns the package status of the Configuration.
+func (c *Configuration) GetPackageStatus() *PackageStatus { return &c.Status }
+
+// GetPackageSpec returns the package spec of the Function.
+func (f *Function) GetPackageSpec() *PackageSpec { return &f.Spec.PackageSpec }
+
+// GetPackageStatus returns the package status of the Function.
+func (f *Function) GetPackageStatus() *PackageStatus { return &f.Status }
+
+// New returns an empty package of the given kind, or nil if the kind is not
+// a package kind.
+func New(kind string) Package {
+	switch kind {
+	case ProviderKind:
+		return &Provider{}
+	case ConfigurationKind:
+		return &Configuration{}
+	case FunctionKind:
+		return &Function{}
+	}
+	return nil
+}
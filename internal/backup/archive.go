@@ -0,0 +1,222 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backup exports the Crossplane API objects of a cluster into an
+// archive and restores them in dependency order.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+const (
+	// FormatVersion is the version of the archive format.
+	FormatVersion = "v1"
+
+	// FileManifest is the path of the manifest in an archive.
+	FileManifest = "manifest.json"
+
+	errFmtMarshal       = "cannot marshal %s"
+	errFmtWrite         = "cannot write %s to archive"
+	errFmtRead          = "cannot read %s from archive"
+	errFmtDecode        = "cannot decode %s"
+	errFmtFormatVersion = "unsupported archive format version %q, want %q"
+	errFmtMissing       = "archive is missing %s"
+	errCloseTar         = "cannot close archive"
+	errCloseGzip        = "cannot close archive compression"
+	errOpenGzip         = "cannot decompress archive"
+)
+
+// A Manifest describes the contents of an archive and the order in which
+// they are restored.
+type Manifest struct {
+	// Version of the archive format.
+	Version string `json:"version"`
+	// CreatedAt is when the archive was created.
+	CreatedAt time.Time `json:"createdAt"`
+	// Bootstrapper is the build information of the bootstrapper that
+	// created the archive.
+	Bootstrapper version.Info `json:"bootstrapper"`
+	// Entries of the archive, sorted by the order they are restored in.
+	Entries []Entry `json:"entries"`
+}
+
+// An Entry of an archive holds the objects of one kind.
+type Entry struct {
+	// Path of the multi-document YAML file holding the objects.
+	Path string `json:"path"`
+	// Order in which the entry is restored. Entries with lower orders are
+	// restored first.
+	Order      int    `json:"order"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespaced bool   `json:"namespaced"`
+	// Paused entries are restored with reconciliation paused, and unpaused
+	// once all entries are restored.
+	Paused bool `json:"paused,omitempty"`
+	// Count is the number of objects in the entry.
+	Count int `json:"count"`
+}
+
+// An Archive of Crossplane API objects.
+type Archive struct {
+	Manifest Manifest
+	objects  map[string][]*unstructured.Unstructured
+}
+
+// Name returns the timestamped name of the archive.
+func (a *Archive) Name() string {
+	return "uxp-backup-" + a.Manifest.CreatedAt.UTC().Format("20060102T150405Z")
+}
+
+// Objects returns the objects of the given entry.
+func (a *Archive) Objects(e Entry) []*unstructured.Unstructured {
+	return a.objects[e.Path]
+}
+
+// add adds an entry with the given objects to the archive.
+func (a *Archive) add(e Entry, objs []*unstructured.Unstructured) {
+	if a.objects == nil {
+		a.objects = map[string][]*unstructured.Unstructured{}
+	}
+	e.Count = len(objs)
+	a.objects[e.Path] = objs
+	a.Manifest.Entries = append(a.Manifest.Entries, e)
+	sort.SliceStable(a.Manifest.Entries, func(i, j int) bool { return a.Manifest.Entries[i].Order < a.Manifest.Entries[j].Order })
+}
+
+// Write writes the archive as a gzipped tarball with the manifest first.
+func (a *Archive) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	m, err := json.MarshalIndent(a.Manifest, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, errFmtMarshal, FileManifest)
+	}
+	if err := writeFile(tw, FileManifest, m, a.Manifest.CreatedAt); err != nil {
+		return err
+	}
+	for _, e := range a.Manifest.Entries {
+		buf := &bytes.Buffer{}
+		for _, o := range a.objects[e.Path] {
+			y, err := yaml.Marshal(o)
+			if err != nil {
+				return errors.Wrapf(err, errFmtMarshal, e.Path)
+			}
+			buf.WriteString("---\n")
+			buf.Write(y)
+		}
+		if err := writeFile(tw, e.Path, buf.Bytes(), a.Manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, errCloseTar)
+	}
+	return errors.Wrap(gz.Close(), errCloseGzip)
+}
+
+// Read reads an archive written by Write.
+func Read(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, errOpenGzip)
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtRead, "file")
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtRead, hdr.Name)
+		}
+		files[hdr.Name] = b
+	}
+
+	m, ok := files[FileManifest]
+	if !ok {
+		return nil, errors.Errorf(errFmtMissing, FileManifest)
+	}
+	a := &Archive{objects: map[string][]*unstructured.Unstructured{}}
+	if err := json.Unmarshal(m, &a.Manifest); err != nil {
+		return nil, errors.Wrapf(err, errFmtDecode, FileManifest)
+	}
+	if a.Manifest.Version != FormatVersion {
+		return nil, errors.Errorf(errFmtFormatVersion, a.Manifest.Version, FormatVersion)
+	}
+	sort.SliceStable(a.Manifest.Entries, func(i, j int) bool { return a.Manifest.Entries[i].Order < a.Manifest.Entries[j].Order })
+	for _, e := range a.Manifest.Entries {
+		b, ok := files[e.Path]
+		if !ok {
+			return nil, errors.Errorf(errFmtMissing, e.Path)
+		}
+		objs, err := decode(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtDecode, e.Path)
+		}
+		a.objects[e.Path] = objs
+	}
+	return a, nil
+}
+
+func decode(b []byte) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	d := kyaml.NewYAMLOrJSONDecoder(bufio.NewReader(bytes.NewReader(b)), 4096)
+	for {
+		u := &unstructured.Unstructured{}
+		err := d.Decode(&u.Object)
+		if errors.Is(err, io.EOF) {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+		objs = append(objs, u)
+	}
+}
+
+func writeFile(tw *tar.Writer, path string, contents []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: path, Mode: 0o600, Size: int64(len(contents)), ModTime: modTime}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return errors.Wrapf(err, errFmtWrite, path)
+	}
+	_, err := tw.Write(contents)
+	return errors.Wrapf(err, errFmtWrite, path)
+}
@@ -0,0 +1,290 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/cluster/crds"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+func obj(apiVersion, kind, namespace, name string, fields map[string]any) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]any{}}
+	for k, v := range fields {
+		u.Object[k] = v
+	}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+func TestBundledKinds(t *testing.T) {
+	bundled, err := crds.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for _, k := range BundledKinds(bundled) {
+		got[k.Kind] = k.Order
+	}
+	want := map[string]int{
+		"DeploymentRuntimeConfig":     OrderRuntime,
+		"ControllerConfig":            OrderRuntime,
+		"ImageConfig":                 OrderRuntime,
+		"StoreConfig":                 OrderRuntime,
+		"Provider":                    OrderPackages,
+		"Function":                    OrderPackages,
+		"Configuration":               OrderPackages,
+		"CompositeResourceDefinition": OrderDefinitions,
+		"Composition":                 OrderCompositions,
+		"EnvironmentConfig":           OrderCompositions,
+		"Usage":                       OrderUsages,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("BundledKinds(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestCompositeKinds(t *testing.T) {
+	xrd := obj("apiextensions.crossplane.io/v1", "CompositeResourceDefinition", "", "xbuckets.example.org", map[string]any{
+		"spec": map[string]any{
+			"group":      "example.org",
+			"names":      map[string]any{"kind": "XBucket"},
+			"claimNames": map[string]any{"kind": "Bucket"},
+			"versions": []any{
+				map[string]any{"name": "v1alpha1"},
+				map[string]any{"name": "v1", "referenceable": true},
+			},
+		},
+	})
+	noClaim := obj("apiextensions.crossplane.io/v1", "CompositeResourceDefinition", "", "xnetworks.example.org", map[string]any{
+		"spec": map[string]any{
+			"group":    "example.org",
+			"names":    map[string]any{"kind": "XNetwork"},
+			"versions": []any{map[string]any{"name": "v1beta1"}},
+		},
+	})
+	got, err := CompositeKinds([]unstructured.Unstructured{*xrd, *noClaim})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Kind{
+		{GroupVersionKind: schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "XBucket"}, Order: OrderComposites, Paused: true},
+		{GroupVersionKind: schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Bucket"}, Namespaced: true, Order: OrderClaims, Paused: true},
+		{GroupVersionKind: schema.GroupVersionKind{Group: "example.org", Version: "v1beta1", Kind: "XNetwork"}, Order: OrderComposites, Paused: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("CompositeKinds(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	cases := map[string]struct {
+		obj  *unstructured.Unstructured
+		want bool
+	}{
+		"Entitlement":         {obj: obj("v1", "Secret", "upbound-system", meta.SecretNameEntitlement, nil), want: true},
+		"ServiceAccountToken": {obj: obj("v1", "Secret", "upbound-system", "token", map[string]any{"type": string(corev1.SecretTypeServiceAccountToken)}), want: true},
+		"HelmRelease":         {obj: obj("v1", "Secret", "upbound-system", "sh.helm.release.v1.uxp.v1", map[string]any{"type": "helm.sh/release.v1"}), want: true},
+		"Credentials":         {obj: obj("v1", "Secret", "upbound-system", "aws-creds", map[string]any{"type": string(corev1.SecretTypeOpaque)})},
+		"Provider":            {obj: obj("pkg.crossplane.io/v1", "Provider", "", meta.SecretNameEntitlement, nil)},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := Excluded(tc.obj); got != tc.want {
+				t.Errorf("Excluded(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	u := obj("v1", "Secret", "upbound-system", "aws-creds", map[string]any{
+		"data":   map[string]any{"creds": "c2VjcmV0"},
+		"status": map[string]any{"phase": "Ready"},
+	})
+	u.SetUID("uid")
+	u.SetResourceVersion("42")
+	u.SetFinalizers([]string{"finalizer.example.org"})
+	u.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "example.org/v1", Kind: "Bucket", Name: "assets", UID: "owner-uid"}})
+
+	Sanitize(u)
+
+	want := obj("v1", "Secret", "upbound-system", "aws-creds", map[string]any{"data": map[string]any{"creds": "c2VjcmV0"}})
+	if diff := cmp.Diff(want, u); diff != "" {
+		t.Errorf("Sanitize(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestExportAndRead(t *testing.T) {
+	provider := obj("pkg.crossplane.io/v1", "Provider", "", "provider-aws-s3", map[string]any{
+		"spec":   map[string]any{"package": "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0"},
+		"status": map[string]any{"currentRevision": "provider-aws-s3-abc"},
+	})
+	provider.SetUID("uid")
+	provider.SetResourceVersion("42")
+	xrd := obj("apiextensions.crossplane.io/v1", "CompositeResourceDefinition", "", "xbuckets.example.org", map[string]any{
+		"spec": map[string]any{
+			"group":      "example.org",
+			"names":      map[string]any{"kind": "XBucket"},
+			"claimNames": map[string]any{"kind": "Bucket"},
+			"versions":   []any{map[string]any{"name": "v1", "referenceable": true}},
+		},
+	})
+	claim := obj("example.org/v1", "Bucket", "team-a", "assets", nil)
+	secrets := []*unstructured.Unstructured{
+		obj("v1", "Secret", "upbound-system", "aws-creds", map[string]any{"data": map[string]any{"creds": "c2VjcmV0"}}),
+		obj("v1", "Secret", "upbound-system", meta.SecretNameEntitlement, map[string]any{"data": map[string]any{"token": "c2VjcmV0"}}),
+	}
+
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+			l := list.(*unstructured.UnstructuredList)
+			var items []*unstructured.Unstructured
+			switch l.GetKind() {
+			case "ProviderList":
+				items = []*unstructured.Unstructured{provider}
+			case "CompositeResourceDefinitionList":
+				items = []*unstructured.Unstructured{xrd}
+			case "BucketList":
+				items = []*unstructured.Unstructured{claim}
+			case "SecretList":
+				items = secrets
+			case "StoreConfigList":
+				return &kmeta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "secrets.crossplane.io", Kind: "StoreConfig"}}
+			}
+			for _, i := range items {
+				l.Items = append(l.Items, *i.DeepCopy())
+			}
+			return nil
+		},
+	}
+
+	e := NewExporter(kube, WithSecretsFrom("upbound-system"))
+	e.now = func() time.Time { return time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC) }
+	a, err := e.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Export(...): %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := a.Write(buf); err != nil {
+		t.Fatalf("Write(...): %s", err)
+	}
+	got, err := Read(buf)
+	if err != nil {
+		t.Fatalf("Read(...): %s", err)
+	}
+	if diff := cmp.Diff(a.Manifest, got.Manifest); diff != "" {
+		t.Errorf("Read(...): -want manifest, +got manifest:\n%s", diff)
+	}
+
+	objects := map[string][]string{}
+	last := 0
+	for _, e := range got.Manifest.Entries {
+		if e.Order < last {
+			t.Errorf("Read(...): entry %s is ordered before an entry it depends on", e.Path)
+		}
+		last = e.Order
+		for _, o := range got.Objects(e) {
+			objects[e.Kind] = append(objects[e.Kind], name(o))
+		}
+	}
+	want := map[string][]string{
+		"Secret":                      {"upbound-system/aws-creds"},
+		"Provider":                    {"provider-aws-s3"},
+		"CompositeResourceDefinition": {"xbuckets.example.org"},
+		"Bucket":                      {"team-a/assets"},
+	}
+	if diff := cmp.Diff(want, objects); diff != "" {
+		t.Errorf("Export(...): -want objects, +got objects:\n%s", diff)
+	}
+
+	p := got.Objects(Kind{GroupVersionKind: schema.GroupVersionKind{Group: "pkg.crossplane.io", Version: "v1", Kind: "Provider"}, Order: OrderPackages}.Entry())[0]
+	if p.GetUID() != "" || p.GetResourceVersion() != "" || p.Object["status"] != nil {
+		t.Errorf("Export(...): Provider was not sanitized: %v", p.Object)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	a := &Archive{Manifest: Manifest{Version: FormatVersion}}
+	a.add(Kind{GroupVersionKind: schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Bucket"}, Namespaced: true, Order: OrderClaims, Paused: true}.Entry(),
+		[]*unstructured.Unstructured{obj("example.org/v1", "Bucket", "team-a", "assets", nil)})
+	a.add(Kind{GroupVersionKind: corev1.SchemeGroupVersion.WithKind("Secret"), Namespaced: true, Order: OrderSecrets}.Entry(),
+		[]*unstructured.Unstructured{obj("v1", "Secret", "upbound-system", meta.SecretNameEntitlement, nil), obj("v1", "Secret", "upbound-system", "aws-creds", nil)})
+
+	cases := map[string]struct {
+		keepPaused bool
+		want       []string
+	}{
+		"Unpause": {
+			want: []string{
+				"create Namespace upbound-system",
+				"apply Secret upbound-system/aws-creds paused=",
+				"create Namespace team-a",
+				"apply Bucket team-a/assets paused=true",
+				"apply Bucket team-a/assets paused=",
+			},
+		},
+		"KeepPaused": {
+			keepPaused: true,
+			want: []string{
+				"create Namespace upbound-system",
+				"apply Secret upbound-system/aws-creds paused=",
+				"create Namespace team-a",
+				"apply Bucket team-a/assets paused=true",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			kube := &test.MockClient{
+				MockList: test.NewMockListFn(nil),
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					got = append(got, "create Namespace "+obj.GetName())
+					return nil
+				},
+				MockPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+					u := obj.(*unstructured.Unstructured)
+					got = append(got, "apply "+u.GetKind()+" "+u.GetNamespace()+"/"+u.GetName()+" paused="+u.GetAnnotations()[AnnotationKeyPaused])
+					return nil
+				},
+			}
+			if err := NewRestorer(kube, WithKeepPaused(tc.keepPaused)).Restore(context.Background(), a); err != nil {
+				t.Fatalf("Restore(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Restore(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
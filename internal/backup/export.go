@@ -0,0 +1,272 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/cluster/crds"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// Orders in which kinds are restored. Every kind depends only on kinds with
+// lower orders.
+const (
+	OrderSecrets      = 10
+	OrderRuntime      = 20
+	OrderPackages     = 30
+	OrderDefinitions  = 40
+	OrderCompositions = 50
+	OrderComposites   = 60
+	OrderClaims       = 70
+	OrderUsages       = 80
+)
+
+const (
+	errFmtList     = "cannot list %s"
+	errLoadCRDs    = "cannot load bundled CRDs"
+	errFmtParseXRD = "cannot parse CompositeResourceDefinition %s"
+)
+
+// CompositeResourceDefinitionGroupVersionKind is the kind of the
+// CompositeResourceDefinitions that define XRs and claims.
+var CompositeResourceDefinitionGroupVersionKind = schema.GroupVersionKind{Group: "apiextensions.crossplane.io", Version: "v1", Kind: "CompositeResourceDefinition"} //nolint:gochecknoglobals // We treat this as a constant.
+
+// bundledOrders are the orders of the bundled kinds. Bundled kinds that are
+// not listed are restored with the Compositions.
+var bundledOrders = map[string]int{ //nolint:gochecknoglobals // We treat this as a constant.
+	"DeploymentRuntimeConfig":     OrderRuntime,
+	"ControllerConfig":            OrderRuntime,
+	"ImageConfig":                 OrderRuntime,
+	"StoreConfig":                 OrderRuntime,
+	"Provider":                    OrderPackages,
+	"Function":                    OrderPackages,
+	"Configuration":               OrderPackages,
+	"CompositeResourceDefinition": OrderDefinitions,
+	"Usage":                       OrderUsages,
+}
+
+// A Kind of object that is backed up.
+type Kind struct {
+	schema.GroupVersionKind
+	Namespaced bool
+	Order      int
+	Paused     bool
+}
+
+// Entry returns an empty archive entry for objects of the kind.
+func (k Kind) Entry() Entry {
+	return Entry{
+		Path:       fmt.Sprintf("resources/%02d-%s-%s.yaml", k.Order, k.Group, strings.ToLower(k.Kind)),
+		Order:      k.Order,
+		APIVersion: k.GroupVersion().String(),
+		Kind:       k.Kind,
+		Namespaced: k.Namespaced,
+		Paused:     k.Paused,
+	}
+}
+
+// BundledKinds returns the kinds of the given bundled CRDs that are backed
+// up. Package revisions and locks are not, since Crossplane derives them
+// from the packages.
+func BundledKinds(bundled []*extv1.CustomResourceDefinition) []Kind {
+	kinds := make([]Kind, 0, len(bundled))
+	for _, crd := range bundled {
+		k := crd.Spec.Names.Kind
+		if strings.HasSuffix(k, "Revision") || k == "Lock" {
+			continue
+		}
+		order, ok := bundledOrders[k]
+		if !ok {
+			order = OrderCompositions
+		}
+		kinds = append(kinds, Kind{
+			GroupVersionKind: schema.GroupVersionKind{Group: crd.Spec.Group, Version: crds.StorageVersion(crd), Kind: k},
+			Namespaced:       crd.Spec.Scope == extv1.NamespaceScoped,
+			Order:            order,
+		})
+	}
+	return kinds
+}
+
+// CompositeKinds returns the kinds of the XRs and claims defined by the
+// given CompositeResourceDefinitions. They are restored paused, so that
+// they are not composed before all of them are restored.
+func CompositeKinds(xrds []unstructured.Unstructured) ([]Kind, error) {
+	kinds := make([]Kind, 0, 2*len(xrds))
+	for _, xrd := range xrds {
+		group, _, _ := unstructured.NestedString(xrd.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(xrd.Object, "spec", "names", "kind")
+		claim, _, _ := unstructured.NestedString(xrd.Object, "spec", "claimNames", "kind")
+		versions, _, _ := unstructured.NestedSlice(xrd.Object, "spec", "versions")
+		v := ""
+		for _, ver := range versions {
+			m, _ := ver.(map[string]any)
+			name, _ := m["name"].(string)
+			if ref, _ := m["referenceable"].(bool); ref {
+				v = name
+				break
+			}
+			if v == "" {
+				v = name
+			}
+		}
+		if group == "" || kind == "" || v == "" {
+			return nil, errors.Errorf(errFmtParseXRD, xrd.GetName())
+		}
+		kinds = append(kinds, Kind{GroupVersionKind: schema.GroupVersionKind{Group: group, Version: v, Kind: kind}, Order: OrderComposites, Paused: true})
+		if claim != "" {
+			kinds = append(kinds, Kind{GroupVersionKind: schema.GroupVersionKind{Group: group, Version: v, Kind: claim}, Namespaced: true, Order: OrderClaims, Paused: true})
+		}
+	}
+	return kinds, nil
+}
+
+// Excluded returns true if the given object must never be backed up or
+// restored. The entitlement and control plane token Secrets are excluded so
+// that restored clusters register anew, as are Secrets that Kubernetes and
+// Helm manage.
+func Excluded(u *unstructured.Unstructured) bool {
+	if u.GetAPIVersion() != "v1" || u.GetKind() != "Secret" {
+		return false
+	}
+	switch u.GetName() {
+	case meta.SecretNameEntitlement, meta.SecretNameControlPlaneToken:
+		return true
+	}
+	t, _, _ := unstructured.NestedString(u.Object, "type")
+	return t == string(corev1.SecretTypeServiceAccountToken) || strings.HasPrefix(t, "helm.sh/")
+}
+
+// Sanitize removes the fields of the given object that are specific to the
+// cluster it was read from, so that it can be applied to another cluster.
+// Owner references are removed too, since they refer to the UIDs of the
+// owners in the source cluster and the restored object would otherwise be
+// garbage collected.
+func Sanitize(u *unstructured.Unstructured) {
+	for _, f := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp", "deletionGracePeriodSeconds", "selfLink", "managedFields", "finalizers", "ownerReferences"} {
+		unstructured.RemoveNestedField(u.Object, "metadata", f)
+	}
+	unstructured.RemoveNestedField(u.Object, "status")
+}
+
+// ExporterOption is used to configure the Exporter.
+type ExporterOption func(*Exporter)
+
+// WithSecretsFrom specifies the namespace whose Secrets are backed up. Secrets
+// are not backed up if it is empty.
+func WithSecretsFrom(namespace string) ExporterOption {
+	return func(e *Exporter) {
+		e.secretsNamespace = namespace
+	}
+}
+
+// An Exporter exports Crossplane API objects into an archive.
+type Exporter struct {
+	client           client.Client
+	secretsNamespace string
+	now              func() time.Time
+}
+
+// NewExporter returns an Exporter that reads with the given client.
+func NewExporter(c client.Client, opts ...ExporterOption) *Exporter {
+	e := &Exporter{client: c, now: time.Now}
+	for _, f := range opts {
+		f(e)
+	}
+	return e
+}
+
+// Export exports the objects of the bundled kinds and of the XRs and claims
+// defined by CompositeResourceDefinitions. Kinds that are not served have no
+// objects. Objects that are controlled by another object are skipped, since
+// their controller recreates them.
+func (e *Exporter) Export(ctx context.Context) (*Archive, error) {
+	a := &Archive{Manifest: Manifest{Version: FormatVersion, CreatedAt: e.now().UTC().Truncate(time.Second), Bootstrapper: version.Get()}}
+
+	bundled, err := crds.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, errLoadCRDs)
+	}
+	kinds := BundledKinds(bundled)
+
+	xrds, err := e.list(ctx, Kind{GroupVersionKind: CompositeResourceDefinitionGroupVersionKind})
+	if err != nil {
+		return nil, err
+	}
+	ck, err := CompositeKinds(xrds)
+	if err != nil {
+		return nil, err
+	}
+	kinds = append(kinds, ck...)
+	if e.secretsNamespace != "" {
+		kinds = append(kinds, Kind{GroupVersionKind: corev1.SchemeGroupVersion.WithKind("Secret"), Namespaced: true, Order: OrderSecrets})
+	}
+	sort.SliceStable(kinds, func(i, j int) bool {
+		if kinds[i].Order != kinds[j].Order {
+			return kinds[i].Order < kinds[j].Order
+		}
+		return kinds[i].Group+kinds[i].Kind < kinds[j].Group+kinds[j].Kind
+	})
+
+	for _, k := range kinds {
+		items, err := e.list(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+		objs := make([]*unstructured.Unstructured, 0, len(items))
+		for i := range items {
+			u := &items[i]
+			if Excluded(u) || metav1.GetControllerOf(u) != nil {
+				continue
+			}
+			Sanitize(u)
+			objs = append(objs, u)
+		}
+		a.add(k.Entry(), objs)
+	}
+	return a, nil
+}
+
+// list returns the objects of the given kind, or none if the kind is not
+// served.
+func (e *Exporter) list(ctx context.Context, k Kind) ([]unstructured.Unstructured, error) {
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(k.GroupVersion().WithKind(k.Kind + "List"))
+	var opts []client.ListOption
+	if k.Group == "" && k.Kind == "Secret" {
+		opts = append(opts, client.InNamespace(e.secretsNamespace))
+	}
+	err := e.client.List(ctx, l, opts...)
+	if kmeta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	return l.Items, errors.Wrapf(err, errFmtList, k.Kind)
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	xpmeta "github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+// AnnotationKeyPaused pauses the reconciliation of Crossplane objects.
+const AnnotationKeyPaused = "crossplane.io/paused"
+
+const (
+	errFmtApply           = "cannot apply %s %s"
+	errFmtUnpause         = "cannot unpause %s %s"
+	errFmtWaitKind        = "%s is not served"
+	errFmtCreateNamespace = "cannot create namespace %s"
+)
+
+// RestorerOption is used to configure the Restorer.
+type RestorerOption func(*Restorer)
+
+// WithLogger specifies how the Restorer should log messages.
+func WithLogger(log logging.Logger) RestorerOption {
+	return func(r *Restorer) {
+		r.log = log
+	}
+}
+
+// WithKeepPaused specifies whether the Restorer should leave paused entries
+// paused once all entries are restored.
+func WithKeepPaused(keep bool) RestorerOption {
+	return func(r *Restorer) {
+		r.keepPaused = keep
+	}
+}
+
+// WithWaitTimeout specifies how long the Restorer should wait for the kind of
+// an entry to be served, e.g. for a package to install its CRDs.
+func WithWaitTimeout(d time.Duration) RestorerOption {
+	return func(r *Restorer) {
+		r.waitTimeout = d
+	}
+}
+
+// A Restorer restores archives.
+type Restorer struct {
+	client       client.Client
+	log          logging.Logger
+	keepPaused   bool
+	waitTimeout  time.Duration
+	pollInterval time.Duration
+}
+
+// NewRestorer returns a Restorer that applies objects with the given client.
+func NewRestorer(c client.Client, opts ...RestorerOption) *Restorer {
+	r := &Restorer{
+		client:       c,
+		log:          logging.NewNopLogger(),
+		waitTimeout:  5 * time.Minute,
+		pollInterval: 5 * time.Second,
+	}
+	for _, f := range opts {
+		f(r)
+	}
+	return r
+}
+
+// Restore applies the objects of the archive in the order of its entries,
+// waiting for the kind of each entry to be served first. Objects of paused
+// entries are applied paused and unpaused once all entries are restored,
+// unless they were paused when they were backed up. Excluded objects are
+// never restored.
+func (r *Restorer) Restore(ctx context.Context, a *Archive) error {
+	var unpause []*unstructured.Unstructured
+	namespaces := map[string]bool{}
+	for _, e := range a.Manifest.Entries {
+		objs := a.Objects(e)
+		if len(objs) == 0 {
+			continue
+		}
+		if err := r.waitFor(ctx, e); err != nil {
+			return err
+		}
+		n := 0
+		for _, o := range objs {
+			if Excluded(o) {
+				continue
+			}
+			if ns := o.GetNamespace(); ns != "" && !namespaces[ns] {
+				if err := r.ensureNamespace(ctx, ns); err != nil {
+					return err
+				}
+				namespaces[ns] = true
+			}
+			apply := o
+			if e.Paused && o.GetAnnotations()[AnnotationKeyPaused] != "true" {
+				apply = o.DeepCopy()
+				xpmeta.AddAnnotations(apply, map[string]string{AnnotationKeyPaused: "true"})
+				unpause = append(unpause, o)
+			}
+			if err := r.client.Patch(ctx, apply, client.Apply, client.FieldOwner(meta.FieldOwner), client.ForceOwnership); err != nil {
+				return errors.Wrapf(err, errFmtApply, e.Kind, name(o))
+			}
+			n++
+		}
+		r.log.Info("Restored objects", "apiVersion", e.APIVersion, "kind", e.Kind, "count", n, "paused", e.Paused)
+	}
+
+	if r.keepPaused {
+		if len(unpause) > 0 {
+			r.log.Info("Leaving restored composite resources and claims paused", "count", len(unpause), "annotation", AnnotationKeyPaused)
+		}
+		return nil
+	}
+	// Applying the objects as they were backed up removes the pause
+	// annotation we own.
+	for _, o := range unpause {
+		if err := r.client.Patch(ctx, o, client.Apply, client.FieldOwner(meta.FieldOwner), client.ForceOwnership); err != nil {
+			return errors.Wrapf(err, errFmtUnpause, o.GetKind(), name(o))
+		}
+	}
+	return nil
+}
+
+// waitFor waits for the kind of the given entry to be served.
+func (r *Restorer) waitFor(ctx context.Context, e Entry) error {
+	err := wait.PollImmediateWithContext(ctx, r.pollInterval, r.waitTimeout, func(ctx context.Context) (bool, error) {
+		l := &unstructured.UnstructuredList{}
+		l.SetAPIVersion(e.APIVersion)
+		l.SetKind(e.Kind + "List")
+		err := r.client.List(ctx, l, client.Limit(1))
+		if kmeta.IsNoMatchError(err) {
+			r.log.Debug("Waiting for kind to be served", "apiVersion", e.APIVersion, "kind", e.Kind)
+			return false, nil
+		}
+		return err == nil, err
+	})
+	return errors.Wrapf(err, errFmtWaitKind, e.Kind)
+}
+
+// ensureNamespace creates the given namespace if it does not exist.
+func (r *Restorer) ensureNamespace(ctx context.Context, ns string) error {
+	err := r.client.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+	if kerrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return errors.Wrapf(err, errFmtCreateNamespace, ns)
+}
+
+func name(o *unstructured.Unstructured) string {
+	if o.GetNamespace() != "" {
+		return o.GetNamespace() + "/" + o.GetName()
+	}
+	return o.GetName()
+}
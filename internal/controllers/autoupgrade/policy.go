@@ -0,0 +1,220 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoupgrade
+
+import (
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// Keys of the auto-upgrade ConfigMap.
+const (
+	// KeyPolicies holds the upgrade policies as a Config.
+	KeyPolicies = "policies.yaml"
+	// KeyStatus holds the state of the policies as a list of PolicyStatus,
+	// written by the controller.
+	KeyStatus = "status.yaml"
+	// KeyHistory holds the upgrades the controller made as a list of
+	// Upgrade, oldest first.
+	KeyHistory = "history.yaml"
+	// KeySummary holds a human readable summary of the policies.
+	KeySummary = "summary"
+)
+
+const (
+	errParseConfig     = "cannot parse auto-upgrade config"
+	errFmtInvalidKind  = "invalid policy %s/%s, kind must be Provider or Function"
+	errFmtNoName       = "invalid policy of kind %s, name is required"
+	errFmtDuplicate    = "invalid policy %s/%s, policies must be unique"
+	errFmtRange        = "invalid range of policy %s/%s"
+	errFmtChannel      = "invalid channel %q of policy %s/%s, must be Patch, Minor or Major"
+	errFmtWindowDay    = "invalid maintenance window day %q of policy %s/%s"
+	errFmtWindowTime   = "invalid maintenance window time %q of policy %s/%s, must be HH:MM"
+	errFmtWindowLength = "invalid maintenance window of policy %s/%s, start and end must differ"
+)
+
+// A Channel determines how far a package is upgraded automatically.
+type Channel string
+
+// Channels.
+const (
+	// ChannelPatch only upgrades to patch releases of the installed minor
+	// version.
+	ChannelPatch Channel = "Patch"
+	// ChannelMinor upgrades to minor and patch releases of the installed
+	// major version.
+	ChannelMinor Channel = "Minor"
+	// ChannelMajor upgrades to any newer release.
+	ChannelMajor Channel = "Major"
+)
+
+// A Window is a recurring maintenance window in UTC. The window wraps around
+// midnight if it ends before it starts.
+type Window struct {
+	// Days are the weekdays the window starts on, e.g. Saturday. Defaults to
+	// every day.
+	Days []string `json:"days,omitempty"`
+	// Start of the window, e.g. 02:00.
+	Start string `json:"start"`
+	// End of the window, e.g. 04:00.
+	End string `json:"end"`
+
+	days       map[time.Weekday]bool
+	start, end time.Duration
+}
+
+// Open returns true if the given time is inside the window.
+func (w *Window) Open(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	t = t.UTC()
+	day := t.Weekday()
+	since := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.end < w.start && since < w.end {
+		// We are in the part of the window after midnight, so it started
+		// the day before.
+		day, since = (day+6)%7, since+24*time.Hour
+	}
+	end := w.end
+	if end < w.start {
+		end += 24 * time.Hour
+	}
+	return (len(w.days) == 0 || w.days[day]) && since >= w.start && since < end
+}
+
+// A Policy keeps a package on the newest version allowed by its range and
+// channel.
+type Policy struct {
+	// Kind of the package, Provider or Function.
+	Kind string `json:"kind"`
+	// Name of the package object.
+	Name string `json:"name"`
+	// Range of versions the package may be upgraded to, e.g. ">=1.1.0
+	// <2.0.0". Defaults to any version.
+	Range string `json:"range,omitempty"`
+	// Channel determines how far the package is upgraded. Defaults to
+	// Patch.
+	Channel Channel `json:"channel,omitempty"`
+	// Window restricts upgrades to a maintenance window. Defaults to
+	// any time.
+	Window *Window `json:"window,omitempty"`
+
+	rng version.Range
+}
+
+// Allows returns true if the package may be upgraded from one version to
+// the other.
+func (p Policy) Allows(from, to version.Semver) bool {
+	if !from.LessThan(to) || !p.rng.Contains(to) {
+		return false
+	}
+	switch p.Channel {
+	case ChannelPatch:
+		return to.Major == from.Major && to.Minor == from.Minor
+	case ChannelMinor:
+		return to.Major == from.Major
+	}
+	return true
+}
+
+// Config is the upgrade policies.
+type Config struct {
+	Policies []Policy `json:"policies"`
+}
+
+// ParseConfig parses and validates the given auto-upgrade config. The
+// channels are set to the effective ones.
+func ParseConfig(data []byte) (Config, error) {
+	c := Config{}
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return Config{}, errors.Wrap(err, errParseConfig)
+	}
+	seen := map[string]bool{}
+	for i := range c.Policies {
+		p := &c.Policies[i]
+		if p.Name == "" {
+			return Config{}, errors.Errorf(errFmtNoName, p.Kind)
+		}
+		if p.Kind != pkgv1.ProviderKind && p.Kind != pkgv1.FunctionKind {
+			return Config{}, errors.Errorf(errFmtInvalidKind, p.Kind, p.Name)
+		}
+		if seen[p.Kind+"/"+p.Name] {
+			return Config{}, errors.Errorf(errFmtDuplicate, p.Kind, p.Name)
+		}
+		seen[p.Kind+"/"+p.Name] = true
+		r, err := version.ParseRange(p.Range)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, errFmtRange, p.Kind, p.Name)
+		}
+		p.rng = r
+		if p.Channel == "" {
+			p.Channel = ChannelPatch
+		}
+		if p.Channel != ChannelPatch && p.Channel != ChannelMinor && p.Channel != ChannelMajor {
+			return Config{}, errors.Errorf(errFmtChannel, p.Channel, p.Kind, p.Name)
+		}
+		if err := parseWindow(p); err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+func parseWindow(p *Policy) error {
+	w := p.Window
+	if w == nil {
+		return nil
+	}
+	w.days = map[time.Weekday]bool{}
+	for _, d := range w.Days {
+		wd, ok := weekday(d)
+		if !ok {
+			return errors.Errorf(errFmtWindowDay, d, p.Kind, p.Name)
+		}
+		w.days[wd] = true
+	}
+	for _, t := range []struct {
+		in  string
+		out *time.Duration
+	}{{in: w.Start, out: &w.start}, {in: w.End, out: &w.end}} {
+		hm, err := time.Parse("15:04", t.in)
+		if err != nil {
+			return errors.Errorf(errFmtWindowTime, t.in, p.Kind, p.Name)
+		}
+		*t.out = time.Duration(hm.Hour())*time.Hour + time.Duration(hm.Minute())*time.Minute
+	}
+	if w.start == w.end {
+		return errors.Errorf(errFmtWindowLength, p.Kind, p.Name)
+	}
+	return nil
+}
+
+// weekday parses full or abbreviated weekday names, ignoring case.
+func weekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s, d.String()) || strings.EqualFold(s, d.String()[:3]) {
+			return d, true
+		}
+	}
+	return 0, false
+}
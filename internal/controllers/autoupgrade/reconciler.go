@@ -0,0 +1,301 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package autoupgrade contains the controller that upgrades packages to the
+// newest version their upgrade policy allows.
+package autoupgrade
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+	"github.com/upbound/universal-crossplane/internal/version"
+	"github.com/upbound/universal-crossplane/internal/xpkg"
+)
+
+const (
+	reconcileTimeout = 5 * time.Minute
+
+	// DefaultInterval is how often tags are listed by default.
+	DefaultInterval = 1 * time.Hour
+
+	// historyLimit is the number of upgrades kept in the history.
+	historyLimit = 50
+
+	errGetConfigMap    = "cannot get auto-upgrade configmap"
+	errUpdateConfigMap = "cannot update auto-upgrade configmap"
+	errMarshalStatus   = "cannot marshal auto-upgrade status"
+	errMarshalHistory  = "cannot marshal auto-upgrade history"
+	errFmtGetPackage   = "cannot get %s %s"
+	errFmtPatchPackage = "cannot upgrade %s %s"
+
+	reasonInvalidConfig event.Reason = "InvalidConfig"
+	reasonUpgraded      event.Reason = "PackageUpgraded"
+	reasonListTags      event.Reason = "ListTagsFailed"
+)
+
+// A State of an upgrade policy.
+type State string
+
+// States.
+const (
+	// StateUpToDate means the package is on the newest allowed version.
+	StateUpToDate State = "UpToDate"
+	// StateUpgraded means the package was upgraded.
+	StateUpgraded State = "Upgraded"
+	// StatePending means a newer allowed version waits for the maintenance
+	// window.
+	StatePending State = "Pending"
+	// StateMissing means the package does not exist.
+	StateMissing State = "Missing"
+	// StateUnsupported means the package is not referenced by a semantic
+	// version tag, e.g. by digest.
+	StateUnsupported State = "Unsupported"
+	// StateFailed means the tags of the package could not be listed.
+	StateFailed State = "Failed"
+)
+
+// PolicyStatus is the state of an upgrade policy.
+type PolicyStatus struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	State   State  `json:"state"`
+	Current string `json:"current,omitempty"`
+	// Available is the newest allowed version if it is newer than the
+	// current one.
+	Available string `json:"available,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// An Upgrade the controller made.
+type Upgrade struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	Name string    `json:"name"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+}
+
+// A TagSource lists the tags of package repositories.
+type TagSource interface {
+	// Tags returns the tags of the given repository, e.g.
+	// xpkg.upbound.io/upbound/provider-aws-s3.
+	Tags(ctx context.Context, repository string) ([]string, error)
+}
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithTagSource specifies where the Reconciler lists the tags of packages.
+func WithTagSource(s TagSource) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.source = s
+	}
+}
+
+// WithInterval specifies how often the Reconciler lists the tags.
+func WithInterval(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.interval = d
+	}
+}
+
+// Reconciler upgrades the packages listed in the auto-upgrade ConfigMap to
+// the newest versions their policies allow.
+type Reconciler struct {
+	client   client.Client
+	log      logging.Logger
+	record   event.Recorder
+	source   TagSource
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client:   mgr.GetClient(),
+		log:      logging.NewNopLogger(),
+		record:   event.NewNopRecorder(),
+		source:   xpkg.NewClient(""),
+		interval: DefaultInterval,
+		now:      time.Now,
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile lists the tags of the packages of each policy, bumps the
+// packages to the newest allowed tag inside their maintenance window and
+// records the upgrades in the history of the ConfigMap.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "autoupgrade.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, req.NamespacedName, cm); err != nil {
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGetConfigMap)
+	}
+	cfg, err := ParseConfig([]byte(cm.Data[KeyPolicies]))
+	if err != nil {
+		// There is nothing to retry until the ConfigMap is fixed.
+		log.Info("Invalid auto-upgrade config", "error", err.Error())
+		r.record.Event(cm, event.Warning(reasonInvalidConfig, err))
+		return reconcile.Result{}, nil
+	}
+	var history []Upgrade
+	_ = yaml.Unmarshal([]byte(cm.Data[KeyHistory]), &history)
+
+	now := r.now()
+	statuses := make([]PolicyStatus, 0, len(cfg.Policies))
+	current := 0
+	for _, p := range cfg.Policies {
+		s, err := r.upgrade(ctx, p, now)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		switch s.State {
+		case StateUpToDate:
+			current++
+		case StateUpgraded:
+			current++
+			history = append(history, Upgrade{Time: now.UTC().Truncate(time.Second), Kind: p.Kind, Name: p.Name, From: s.Current, To: s.Available})
+			r.record.Event(cm, event.Normal(reasonUpgraded, fmt.Sprintf("Upgraded %s %s from %s to %s", p.Kind, p.Name, s.Current, s.Available)))
+			log.Info("Upgraded package", "kind", p.Kind, "name", p.Name, "from", s.Current, "to", s.Available)
+		case StateFailed:
+			r.record.Event(cm, event.Warning(reasonListTags, errors.Errorf("%s %s: %s", p.Kind, p.Name, s.Message)))
+		}
+		statuses = append(statuses, s)
+	}
+	if len(history) > historyLimit {
+		history = history[len(history)-historyLimit:]
+	}
+
+	summary := fmt.Sprintf("%d of %d packages are up to date", current, len(statuses))
+	return reconcile.Result{RequeueAfter: r.interval}, r.writeStatus(ctx, cm, statuses, history, summary)
+}
+
+// upgrade bumps the package of the given policy to the newest allowed tag if
+// the maintenance window is open.
+func (r *Reconciler) upgrade(ctx context.Context, p Policy, now time.Time) (PolicyStatus, error) {
+	s := PolicyStatus{Kind: p.Kind, Name: p.Name}
+	pkg := pkgv1.New(p.Kind)
+	err := r.client.Get(ctx, types.NamespacedName{Name: p.Name}, pkg)
+	if kerrors.IsNotFound(err) {
+		s.State = StateMissing
+		return s, nil
+	}
+	if err != nil {
+		return s, errors.Wrapf(err, errFmtGetPackage, p.Kind, p.Name)
+	}
+
+	repo, tag := xpkg.SplitPackage(pkg.GetPackageSpec().Package)
+	s.Current = tag
+	cur, err := version.Parse(tag)
+	if err != nil {
+		s.State, s.Message = StateUnsupported, fmt.Sprintf("package %s is not referenced by a semantic version tag", pkg.GetPackageSpec().Package)
+		return s, nil
+	}
+	tags, err := r.source.Tags(ctx, repo)
+	if err != nil {
+		s.State, s.Message = StateFailed, err.Error()
+		return s, nil
+	}
+
+	best, newest := cur, ""
+	for _, t := range tags {
+		// Tags that are not semantic versions and pre-releases are never
+		// upgraded to.
+		v, err := version.Parse(t)
+		if err != nil || v.IsPrerelease() || !p.Allows(cur, v) || !best.LessThan(v) {
+			continue
+		}
+		best, newest = v, t
+	}
+	switch {
+	case newest == "":
+		s.State = StateUpToDate
+		return s, nil
+	case !p.Window.Open(now):
+		s.State, s.Available = StatePending, newest
+		return s, nil
+	}
+
+	orig := pkg.DeepCopyObject().(client.Object)
+	pkg.GetPackageSpec().Package = repo + ":" + newest
+	if err := r.client.Patch(ctx, pkg, client.MergeFrom(orig)); err != nil {
+		return s, errors.Wrapf(err, errFmtPatchPackage, p.Kind, p.Name)
+	}
+	s.State, s.Available = StateUpgraded, newest
+	return s, nil
+}
+
+// writeStatus writes the state of the policies and the history to the
+// ConfigMap if they changed.
+func (r *Reconciler) writeStatus(ctx context.Context, cm *corev1.ConfigMap, statuses []PolicyStatus, history []Upgrade, summary string) error {
+	s, err := yaml.Marshal(statuses)
+	if err != nil {
+		return errors.Wrap(err, errMarshalStatus)
+	}
+	h, err := yaml.Marshal(history)
+	if err != nil {
+		return errors.Wrap(err, errMarshalHistory)
+	}
+	if cm.Data[KeyStatus] == string(s) && cm.Data[KeyHistory] == string(h) && cm.Data[KeySummary] == summary {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[KeyStatus], cm.Data[KeyHistory], cm.Data[KeySummary] = string(s), string(h), summary
+	return errors.Wrap(r.client.Update(ctx, cm), errUpdateConfigMap)
+}
@@ -0,0 +1,268 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoupgrade
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+type tagSourceFn func(ctx context.Context, repository string) ([]string, error)
+
+func (fn tagSourceFn) Tags(ctx context.Context, repository string) ([]string, error) {
+	return fn(ctx, repository)
+}
+
+func TestWindow(t *testing.T) {
+	// 2023-10-07 is a Saturday.
+	cases := map[string]struct {
+		window string
+		at     string
+		want   bool
+	}{
+		"Inside":           {window: `{start: "02:00", end: "04:00"}`, at: "2023-10-07T03:00:00Z", want: true},
+		"AtEnd":            {window: `{start: "02:00", end: "04:00"}`, at: "2023-10-07T04:00:00Z"},
+		"OtherDay":         {window: `{days: [Sunday], start: "02:00", end: "04:00"}`, at: "2023-10-07T03:00:00Z"},
+		"AfterMidnight":    {window: `{days: [Fri], start: "22:00", end: "02:00"}`, at: "2023-10-07T01:00:00Z", want: true},
+		"BeforeMidnight":   {window: `{days: [Fri], start: "22:00", end: "02:00"}`, at: "2023-10-06T23:00:00Z", want: true},
+		"WrongDayWrapped":  {window: `{days: [Sat], start: "22:00", end: "02:00"}`, at: "2023-10-07T01:00:00Z"},
+		"OtherTimeZone":    {window: `{start: "02:00", end: "04:00"}`, at: "2023-10-07T05:00:00+02:00", want: true},
+		"NoWindowIsAlways": {at: "2023-10-07T12:00:00Z", want: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			data := "policies: [{kind: Provider, name: p}]"
+			if tc.window != "" {
+				data = fmt.Sprintf("policies: [{kind: Provider, name: p, window: %s}]", tc.window)
+			}
+			cfg, err := ParseConfig([]byte(data))
+			if err != nil {
+				t.Fatalf("ParseConfig(...): %s", err)
+			}
+			at, _ := time.Parse(time.RFC3339, tc.at)
+			if got := cfg.Policies[0].Window.Open(at); got != tc.want {
+				t.Errorf("Open(%s): want %t, got %t", tc.at, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cases := map[string]struct {
+		data string
+		want error
+	}{
+		"Valid":     {data: `policies: [{kind: Function, name: f, range: "^1.2", channel: Minor}]`},
+		"Kind":      {data: `policies: [{kind: Configuration, name: c}]`, want: errors.Errorf(errFmtInvalidKind, "Configuration", "c")},
+		"Duplicate": {data: `policies: [{kind: Provider, name: p}, {kind: Provider, name: p}]`, want: errors.Errorf(errFmtDuplicate, "Provider", "p")},
+		"Channel":   {data: `policies: [{kind: Provider, name: p, channel: Nightly}]`, want: errors.Errorf(errFmtChannel, "Nightly", "Provider", "p")},
+		"Day":       {data: `policies: [{kind: Provider, name: p, window: {days: [Caturday], start: "02:00", end: "04:00"}}]`, want: errors.Errorf(errFmtWindowDay, "Caturday", "Provider", "p")},
+		"Time":      {data: `policies: [{kind: Provider, name: p, window: {start: "2am", end: "04:00"}}]`, want: errors.Errorf(errFmtWindowTime, "2am", "Provider", "p")},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tc.data))
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("ParseConfig(...): -want error, +got error:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameAutoUpgrade, Namespace: "upbound-system"}}
+	// 2023-10-07 is a Saturday.
+	now := time.Date(2023, 10, 7, 3, 0, 0, 0, time.UTC)
+	tags := tagSourceFn(func(_ context.Context, repo string) ([]string, error) {
+		if repo != "xpkg.upbound.io/upbound/provider-aws-s3" {
+			return nil, errBoom
+		}
+		return []string{"v1.1.0", "v1.1.1", "v1.1.3", "v1.2.0", "v1.2.1-rc.1", "v2.0.0", "latest"}, nil
+	})
+	previous := []Upgrade{{Time: now.Add(-24 * time.Hour), Kind: "Provider", Name: "provider-aws-s3", From: "v1.1.0", To: "v1.1.1"}}
+
+	type want struct {
+		result  reconcile.Result
+		err     error
+		patched string
+		status  []PolicyStatus
+		history []Upgrade
+	}
+	cases := map[string]struct {
+		reason   string
+		policies string
+		pkg      string
+		get      error
+		want     want
+	}{
+		"InvalidConfig": {
+			reason:   "Nothing should be done until an invalid config is fixed.",
+			policies: "policies: [{kind: Configuration, name: c}]",
+			want:     want{},
+		},
+		"Patch": {
+			reason:   "The package should be bumped to the newest patch release in range.",
+			policies: `policies: [{kind: Provider, name: provider-aws-s3, range: "<2.0.0"}]`,
+			pkg:      "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.1",
+			want: want{
+				result:  reconcile.Result{RequeueAfter: DefaultInterval},
+				patched: "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.3",
+				status:  []PolicyStatus{{Kind: "Provider", Name: "provider-aws-s3", State: StateUpgraded, Current: "v1.1.1", Available: "v1.1.3"}},
+				history: append(previous, Upgrade{Time: now, Kind: "Provider", Name: "provider-aws-s3", From: "v1.1.1", To: "v1.1.3"}),
+			},
+		},
+		"Minor": {
+			reason:   "The Minor channel should bump to the newest minor release, but never out of range.",
+			policies: `policies: [{kind: Provider, name: provider-aws-s3, range: "<2.0.0", channel: Minor}]`,
+			pkg:      "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.1",
+			want: want{
+				result:  reconcile.Result{RequeueAfter: DefaultInterval},
+				patched: "xpkg.upbound.io/upbound/provider-aws-s3:v1.2.0",
+				status:  []PolicyStatus{{Kind: "Provider", Name: "provider-aws-s3", State: StateUpgraded, Current: "v1.1.1", Available: "v1.2.0"}},
+				history: append(previous, Upgrade{Time: now, Kind: "Provider", Name: "provider-aws-s3", From: "v1.1.1", To: "v1.2.0"}),
+			},
+		},
+		"OutsideWindow": {
+			reason:   "Upgrades should wait for the maintenance window.",
+			policies: `policies: [{kind: Provider, name: provider-aws-s3, window: {days: [Sunday], start: "02:00", end: "04:00"}}]`,
+			pkg:      "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.1",
+			want: want{
+				result:  reconcile.Result{RequeueAfter: DefaultInterval},
+				status:  []PolicyStatus{{Kind: "Provider", Name: "provider-aws-s3", State: StatePending, Current: "v1.1.1", Available: "v1.1.3"}},
+				history: previous,
+			},
+		},
+		"UpToDate": {
+			reason:   "Nothing should be bumped if the package is on the newest allowed version.",
+			policies: `policies: [{kind: Provider, name: provider-aws-s3}]`,
+			pkg:      "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.3",
+			want: want{
+				result:  reconcile.Result{RequeueAfter: DefaultInterval},
+				status:  []PolicyStatus{{Kind: "Provider", Name: "provider-aws-s3", State: StateUpToDate, Current: "v1.1.3"}},
+				history: previous,
+			},
+		},
+		"Digest": {
+			reason:   "Packages referenced by digest should not be upgraded.",
+			policies: `policies: [{kind: Provider, name: provider-aws-s3}]`,
+			pkg:      "xpkg.upbound.io/upbound/provider-aws-s3@sha256:abc",
+			want: want{
+				result:  reconcile.Result{RequeueAfter: DefaultInterval},
+				status:  []PolicyStatus{{Kind: "Provider", Name: "provider-aws-s3", State: StateUnsupported, Message: "package xpkg.upbound.io/upbound/provider-aws-s3@sha256:abc is not referenced by a semantic version tag"}},
+				history: previous,
+			},
+		},
+		"ListFailed": {
+			reason:   "Failing to list tags should be reported without failing the other policies.",
+			policies: `policies: [{kind: Function, name: function-patch-and-transform}]`,
+			pkg:      "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.2.1",
+			want: want{
+				result:  reconcile.Result{RequeueAfter: DefaultInterval},
+				status:  []PolicyStatus{{Kind: "Function", Name: "function-patch-and-transform", State: StateFailed, Current: "v0.2.1", Message: errBoom.Error()}},
+				history: previous,
+			},
+		},
+		"Missing": {
+			reason:   "Missing packages should be reported.",
+			policies: `policies: [{kind: Provider, name: provider-aws-s3}]`,
+			get:      kerrors.NewNotFound(schema.GroupResource{}, "provider-aws-s3"),
+			want: want{
+				result:  reconcile.Result{RequeueAfter: DefaultInterval},
+				status:  []PolicyStatus{{Kind: "Provider", Name: "provider-aws-s3", State: StateMissing}},
+				history: previous,
+			},
+		},
+		"GetError": {
+			reason:   "Errors getting a package should be returned.",
+			policies: `policies: [{kind: Provider, name: provider-aws-s3}]`,
+			get:      errBoom,
+			want:     want{err: errors.Wrapf(errBoom, errFmtGetPackage, "Provider", "provider-aws-s3")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var patched string
+			var status []PolicyStatus
+			var history []Upgrade
+			h, _ := yaml.Marshal(previous)
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					switch o := obj.(type) {
+					case *corev1.ConfigMap:
+						o.SetName(key.Name)
+						o.SetNamespace(key.Namespace)
+						o.Data = map[string]string{KeyPolicies: tc.policies, KeyHistory: string(h)}
+					case pkgv1.Package:
+						if tc.get != nil {
+							return tc.get
+						}
+						o.SetName(key.Name)
+						o.GetPackageSpec().Package = tc.pkg
+					}
+					return nil
+				},
+				MockPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+					patched = obj.(pkgv1.Package).GetPackageSpec().Package
+					return nil
+				},
+				MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+					cm := obj.(*corev1.ConfigMap)
+					if err := yaml.Unmarshal([]byte(cm.Data[KeyStatus]), &status); err != nil {
+						return err
+					}
+					return yaml.Unmarshal([]byte(cm.Data[KeyHistory]), &history)
+				},
+			}
+			r := NewReconciler(&fake.Manager{Client: kube}, WithTagSource(tags))
+			r.now = func() time.Time { return now }
+			got, err := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patched, patched); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want patched, +got patched:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.status, status); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want status, +got status:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.history, history); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want history, +got history:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoupgrade
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/xpkg"
+)
+
+// Setup adds the controller that upgrades the packages listed in the
+// auto-upgrade ConfigMap. Tags are listed from the registries of the
+// packages, or from the given registry endpoint if it is not empty, every
+// interval.
+func Setup(mgr ctrl.Manager, l logging.Logger, endpoint string, interval time.Duration) error {
+	name := "auto-upgrade"
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithTagSource(xpkg.NewClient(endpoint)),
+		WithInterval(interval),
+	)
+
+	// Packages are not watched, since every change would list their tags.
+	// They are checked every interval instead.
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNameAutoUpgrade)))).
+		Complete(r)
+}
@@ -27,6 +27,8 @@ import (
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/upbound/universal-crossplane/internal/tracing"
 )
 
 // These constants are given by AWS Marketplace.
@@ -72,8 +74,11 @@ type Marketplace struct {
 }
 
 // Register makes sure user is entitled for this usage in an idempotent way.
-func (am *Marketplace) Register(ctx context.Context, s *v1.Secret, uid string) (string, error) {
+func (am *Marketplace) Register(ctx context.Context, s *v1.Secret, uid string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "aws.Marketplace.Register")
+	defer tracing.End(span, &err)
 	if len(s.Data[SecretKeyAWSMeteringSignature]) > 0 {
+		span.AddEvent("signature already exists in entitlement secret")
 		return string(s.Data[SecretKeyAWSMeteringSignature]), nil
 	}
 	u := &marketplacemetering.RegisterUsageInput{
@@ -93,7 +98,9 @@ func (am *Marketplace) Register(ctx context.Context, s *v1.Secret, uid string) (
 }
 
 // Verify makes sure the signature is signed by AWS Marketplace.
-func (am *Marketplace) Verify(token, uid string) (bool, error) {
+func (am *Marketplace) Verify(ctx context.Context, token, uid string) (_ bool, err error) {
+	_, span := tracing.Start(ctx, "aws.Marketplace.Verify")
+	defer tracing.End(span, &err)
 	t, err := jwt.ParseWithClaims(token, jwt.MapClaims{}, func(_ *jwt.Token) (any, error) {
 		return jwt.ParseRSAPublicKeyFromPEM([]byte(am.publicKey))
 	})
@@ -18,6 +18,7 @@ import (
 	"context"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -29,6 +30,7 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 
 	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/tracing"
 )
 
 const (
@@ -92,9 +94,12 @@ func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
 
 // Reconcile reconciles on entitlement secret and registers & verifies that usage
 // is valid in given entitlement context.
-func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
 	log := r.log.WithValues("request", req)
 
+	ctx, span := tracing.Start(ctx, "billing.Reconcile", attribute.String("request", req.String()))
+	defer tracing.End(span, &err)
+
 	log.Debug("Reconciling...")
 	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
 	defer cancel()
@@ -117,7 +122,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 		return reconcile.Result{}, errors.Wrap(err, errRegister)
 	}
 
-	verified, err := r.entitlement.Verify(token, uid)
+	verified, err := r.entitlement.Verify(ctx, token, uid)
 	if err != nil {
 		return reconcile.Result{}, errors.Wrap(err, errVerify)
 	}
@@ -40,7 +40,7 @@ func (m *MockRegisterer) Register(ctx context.Context, secret *corev1.Secret, ui
 	return m.MockRegister(ctx, secret, uid)
 }
 
-func (m *MockRegisterer) Verify(token, uid string) (bool, error) {
+func (m *MockRegisterer) Verify(_ context.Context, token, uid string) (bool, error) {
 	return m.MockVerify(token, uid)
 }
 
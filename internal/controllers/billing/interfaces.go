@@ -23,7 +23,7 @@ import (
 // Registerer can register usage of universal-crossplane with idempotent calls.
 type Registerer interface {
 	Register(ctx context.Context, secret *v1.Secret, uid string) (string, error)
-	Verify(token, uid string) (bool, error)
+	Verify(ctx context.Context, token, uid string) (bool, error)
 }
 
 // NewNopRegisterer returns a Registerer that does nothing.
@@ -40,6 +40,6 @@ func (np NopRegisterer) Register(_ context.Context, _ *v1.Secret, _ string) (str
 }
 
 // Verify does nothing.
-func (np NopRegisterer) Verify(_, _ string) (bool, error) {
+func (np NopRegisterer) Verify(_ context.Context, _, _ string) (bool, error) {
 	return true, nil
 }
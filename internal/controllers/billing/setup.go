@@ -30,6 +30,7 @@ import (
 
 	"github.com/upbound/universal-crossplane/internal/controllers/billing/aws"
 	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/tracing"
 )
 
 // SetupAWSMarketplace adds the AWS Marketplace controller that registers this
@@ -40,6 +41,7 @@ func SetupAWSMarketplace(mgr ctrl.Manager, l logging.Logger) error {
 	if err != nil {
 		return errors.Wrap(err, "cannot load default AWS config")
 	}
+	cfg.APIOptions = append(cfg.APIOptions, tracing.AWSMiddleware)
 	reg := aws.NewMarketplace(mgr.GetClient(), marketplacemetering.NewFromConfig(cfg), aws.MarketplacePublicKey)
 
 	r := NewReconciler(mgr,
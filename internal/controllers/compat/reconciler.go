@@ -0,0 +1,438 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compat contains the controller that checks whether the installed
+// packages are compatible with the running Crossplane version.
+package compat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/controllers/mirror"
+	"github.com/upbound/universal-crossplane/internal/controllers/versions"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/metrics"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+	"github.com/upbound/universal-crossplane/internal/version"
+	"github.com/upbound/universal-crossplane/internal/xpkg"
+)
+
+// Keys of the compat ConfigMap.
+const (
+	// KeyStatus holds the compatibility of the packages as a list of
+	// xpkg.CompatStatus.
+	KeyStatus = xpkg.KeyCompatStatus
+	// KeySummary holds a human readable summary of the compatibility.
+	KeySummary = "summary"
+)
+
+const (
+	reconcileTimeout = 5 * time.Minute
+	syncPeriod       = 10 * time.Minute
+
+	errGetConfigMap         = "cannot get compat configmap"
+	errCreateConfigMap      = "cannot create compat configmap"
+	errUpdateConfigMap      = "cannot update compat configmap"
+	errGetVersionsConfigMap = "cannot get versions configmap"
+	errGetMirrorConfigMap   = "cannot get registry mirror configmap"
+	errMarshalStatus        = "cannot marshal compat status"
+	errFmtList              = "cannot list %s"
+
+	reasonIncompatible event.Reason = "PackageIncompatible"
+	reasonCompatible   event.Reason = "PackageCompatible"
+)
+
+// A MetaSource returns the metadata of packages.
+type MetaSource interface {
+	// Meta returns the metadata of the given package, e.g.
+	// xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0, using the credentials
+	// of its registry in the given Keychain.
+	Meta(ctx context.Context, pkg string, k xpkg.Keychain) (xpkg.Meta, error)
+}
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithMetaSource specifies where the Reconciler reads the metadata of
+// packages.
+func WithMetaSource(s MetaSource) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.source = s
+	}
+}
+
+// Reconciler checks the Crossplane version constraints of the installed
+// packages against the running Crossplane version.
+type Reconciler struct {
+	client client.Client
+	log    logging.Logger
+	record event.Recorder
+	source MetaSource
+
+	// constraints caches the constraints of package images, which do not
+	// change.
+	constraints map[string]string
+	// reported are the packages with metrics, used to remove the metrics of
+	// deleted packages.
+	reported map[string]bool
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client:      mgr.GetClient(),
+		log:         logging.NewNopLogger(),
+		record:      event.NewNopRecorder(),
+		source:      xpkg.NewClient(""),
+		constraints: map[string]string{},
+		reported:    map[string]bool{},
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile writes the compatibility of all packages with the Crossplane
+// version in the versions ConfigMap to the compat ConfigMap and records
+// events for packages that become incompatible or compatible.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "compat.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	err = r.client.Get(ctx, req.NamespacedName, cm)
+	if kerrors.IsNotFound(err) {
+		cm.SetName(req.Name)
+		cm.SetNamespace(req.Namespace)
+		cm.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+		if err := r.client.Create(ctx, cm); err != nil {
+			return reconcile.Result{}, errors.Wrap(err, errCreateConfigMap)
+		}
+	} else if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetConfigMap)
+	}
+	prev, _ := xpkg.ParseCompatStatus(cm.Data[KeyStatus])
+	previous := make(map[string]xpkg.CompatStatus, len(prev))
+	for _, s := range prev {
+		previous[s.Kind+"/"+s.Name] = s
+	}
+
+	v, known, err := r.crossplaneVersion(ctx, req.Namespace)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	var running *version.Semver
+	if known {
+		running = &v
+	}
+	pkgs, err := r.listPackages(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	mirrors, rules, err := r.mirrors(ctx, req.Namespace)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	statuses := make([]xpkg.CompatStatus, 0, len(pkgs))
+	reported := make(map[string]bool, len(pkgs))
+	compatible := 0
+	for _, p := range pkgs {
+		key := p.Kind + "/" + p.Name
+		was, seen := previous[key]
+		s := r.check(ctx, req.Namespace, r.resolve(p, mirrors, rules), running, was)
+		switch s.State {
+		case xpkg.CompatStateCompatible:
+			compatible++
+			if seen && was.State == xpkg.CompatStateIncompatible {
+				r.record.Event(cm, event.Normal(reasonCompatible, fmt.Sprintf("%s %s is compatible with Crossplane %s", s.Kind, s.Name, running)))
+				log.Info("Package compatible", "kind", s.Kind, "name", s.Name)
+			}
+		case xpkg.CompatStateIncompatible:
+			if !seen || was.State != xpkg.CompatStateIncompatible || was.Package != s.Package {
+				r.record.Event(cm, event.Warning(reasonIncompatible, errors.Errorf("%s %s: %s", s.Kind, s.Name, s.Message)))
+				log.Info("Package incompatible", "kind", s.Kind, "name", s.Name, "message", s.Message)
+			}
+		case xpkg.CompatStateUnknown:
+			metrics.DeletePackageCompatibility(s.Kind, s.Name)
+			statuses = append(statuses, s)
+			continue
+		}
+		metrics.SetPackageCompatibility(s.Kind, s.Name, s.State == xpkg.CompatStateCompatible)
+		reported[key] = true
+		statuses = append(statuses, s)
+	}
+	for key := range r.reported {
+		if !reported[key] {
+			kind, name, _ := strings.Cut(key, "/")
+			metrics.DeletePackageCompatibility(kind, name)
+		}
+	}
+	r.reported = reported
+
+	summary := "The running Crossplane version is not known"
+	if running != nil {
+		summary = fmt.Sprintf("%d of %d packages are compatible with Crossplane %s", compatible, len(statuses), running)
+	}
+	return reconcile.Result{RequeueAfter: syncPeriod}, r.writeStatus(ctx, cm, statuses, summary)
+}
+
+// crossplaneVersion returns the running Crossplane version from the versions
+// ConfigMap and whether it is known yet.
+func (r *Reconciler) crossplaneVersion(ctx context.Context, namespace string) (version.Semver, bool, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: meta.ConfigMapNameVersions, Namespace: namespace}, cm); err != nil {
+		return version.Semver{}, false, errors.Wrap(client.IgnoreNotFound(err), errGetVersionsConfigMap)
+	}
+	v, err := version.Parse(cm.Data[versions.KeyCrossplaneVersion])
+	return v, err == nil, nil
+}
+
+// mirrors returns the registry mirror config and its rules. There are none if
+// the registry mirror ConfigMap does not exist or is invalid, which the
+// registry mirror controller reports.
+func (r *Reconciler) mirrors(ctx context.Context, namespace string) (mirror.Config, []mirror.Rule, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: meta.ConfigMapNameRegistryMirror, Namespace: namespace}, cm); err != nil {
+		return mirror.Config{}, nil, errors.Wrap(client.IgnoreNotFound(err), errGetMirrorConfigMap)
+	}
+	cfg, rules, err := mirror.ParseConfig([]byte(cm.Data[mirror.KeyMirrors]))
+	if err != nil {
+		return mirror.Config{}, nil, nil
+	}
+	return cfg, rules, nil
+}
+
+// resolve returns the given package with the reference and pull Secrets
+// Crossplane pulls it with. In the ImageConfig mode, Crossplane pulls
+// packages from the mirror they map to with the pull Secret of the mirrors.
+// In the Rewrite mode, the package references already point at the mirrors.
+func (r *Reconciler) resolve(p pkg, cfg mirror.Config, rules []mirror.Rule) pkg {
+	if cfg.Mode != mirror.ModeImageConfig || mirror.Mirrored(rules, p.Image) {
+		return p
+	}
+	mapped, ok := mirror.Map(rules, p.Image)
+	if !ok {
+		return p
+	}
+	p.Pull = mapped
+	if cfg.PullSecret != "" {
+		p.PullSecrets = append(append([]string{}, p.PullSecrets...), cfg.PullSecret)
+	}
+	return p
+}
+
+// keychain returns the credentials of the given pull Secrets in the given
+// namespace. Secrets that cannot be read are skipped, so that the package is
+// read anonymously and the registry reports why it cannot be.
+func (r *Reconciler) keychain(ctx context.Context, namespace string, secrets []string) xpkg.Keychain {
+	k := xpkg.Keychain{}
+	for _, name := range secrets {
+		s := &corev1.Secret{}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, s); err != nil {
+			r.log.Debug("Cannot get package pull secret", "name", name, "error", err)
+			continue
+		}
+		if err := k.AddSecret(s); err != nil {
+			r.log.Debug("Cannot read package pull secret", "name", name, "error", err)
+		}
+	}
+	return k
+}
+
+// check returns the compatibility of the given package with the given
+// Crossplane version, which may be nil if it is not known. The constraint is
+// read from the package metadata unless it is cached or was already read for
+// the same package image.
+func (r *Reconciler) check(ctx context.Context, namespace string, p pkg, running *version.Semver, was xpkg.CompatStatus) xpkg.CompatStatus {
+	s := xpkg.CompatStatus{Kind: p.Kind, Name: p.Name, Package: p.Image}
+	constraint, ok := r.constraints[p.Image]
+	if !ok && was.Package == p.Image && was.State != xpkg.CompatStateUnknown {
+		constraint, ok = was.Constraint, true
+	}
+	if !ok {
+		ref := p.Image
+		if p.Pull != "" {
+			ref = p.Pull
+		}
+		m, err := r.source.Meta(ctx, ref, r.keychain(ctx, namespace, p.PullSecrets))
+		if err != nil {
+			s.State, s.Message = xpkg.CompatStateUnknown, err.Error()
+			return s
+		}
+		constraint = m.CrossplaneConstraint()
+	}
+	r.constraints[p.Image] = constraint
+	s.Constraint = constraint
+
+	rng, err := version.ParseRange(constraint)
+	switch {
+	case err != nil:
+		s.State, s.Message = xpkg.CompatStateUnknown, err.Error()
+	case running == nil:
+		s.State, s.Message = xpkg.CompatStateUnknown, "the running Crossplane version is not known"
+	case !rng.Contains(running.Upstream()):
+		s.State, s.Message = xpkg.CompatStateIncompatible, fmt.Sprintf("package requires Crossplane %s but %s is running", constraint, running)
+	default:
+		s.State = xpkg.CompatStateCompatible
+	}
+	return s
+}
+
+// A pkg is a package and the image of its active revision.
+type pkg struct {
+	Kind  string
+	Name  string
+	Image string
+	// PullSecrets are the pull Secrets of the package.
+	PullSecrets []string
+	// Pull is the reference the image is pulled with if it differs from
+	// Image, e.g. because an ImageConfig points it at a mirror.
+	Pull string
+}
+
+// listPackages returns all packages sorted by kind and name. The image of a
+// package is the one of its active revision, since it is the one installed,
+// or the one of the package if it has no active revision.
+func (r *Reconciler) listPackages(ctx context.Context) ([]pkg, error) {
+	images := map[string]string{}
+	addRevision := func(kind string, rev pkgv1.PackageRevision) {
+		if rev.GetPackageRevisionSpec().DesiredState != pkgv1.PackageRevisionActive {
+			return
+		}
+		if parent := rev.GetLabels()[pkgv1.LabelParentPackage]; parent != "" {
+			images[kind+"/"+parent] = rev.GetPackageRevisionSpec().Package
+		}
+	}
+	var pkgs []pkg
+	addPackage := func(kind string, p pkgv1.Package) {
+		img := images[kind+"/"+p.GetName()]
+		if img == "" {
+			img = p.GetPackageSpec().Package
+		}
+		var secrets []string
+		for _, ref := range p.GetPackageSpec().PackagePullSecrets {
+			secrets = append(secrets, ref.Name)
+		}
+		pkgs = append(pkgs, pkg{Kind: kind, Name: p.GetName(), Image: img, PullSecrets: secrets})
+	}
+
+	prl := &pkgv1.ProviderRevisionList{}
+	if err := r.client.List(ctx, prl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "provider revisions")
+	}
+	for i := range prl.Items {
+		addRevision(pkgv1.ProviderKind, &prl.Items[i])
+	}
+	crl := &pkgv1.ConfigurationRevisionList{}
+	if err := r.client.List(ctx, crl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "configuration revisions")
+	}
+	for i := range crl.Items {
+		addRevision(pkgv1.ConfigurationKind, &crl.Items[i])
+	}
+	frl := &pkgv1.FunctionRevisionList{}
+	if err := r.client.List(ctx, frl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "function revisions")
+	}
+	for i := range frl.Items {
+		addRevision(pkgv1.FunctionKind, &frl.Items[i])
+	}
+
+	pl := &pkgv1.ProviderList{}
+	if err := r.client.List(ctx, pl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "providers")
+	}
+	for i := range pl.Items {
+		addPackage(pkgv1.ProviderKind, &pl.Items[i])
+	}
+	cl := &pkgv1.ConfigurationList{}
+	if err := r.client.List(ctx, cl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "configurations")
+	}
+	for i := range cl.Items {
+		addPackage(pkgv1.ConfigurationKind, &cl.Items[i])
+	}
+	fl := &pkgv1.FunctionList{}
+	if err := r.client.List(ctx, fl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "functions")
+	}
+	for i := range fl.Items {
+		addPackage(pkgv1.FunctionKind, &fl.Items[i])
+	}
+
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		if pkgs[i].Kind != pkgs[j].Kind {
+			return pkgs[i].Kind < pkgs[j].Kind
+		}
+		return pkgs[i].Name < pkgs[j].Name
+	})
+	return pkgs, nil
+}
+
+// writeStatus writes the compatibility of the packages to the ConfigMap if it
+// changed.
+func (r *Reconciler) writeStatus(ctx context.Context, cm *corev1.ConfigMap, statuses []xpkg.CompatStatus, summary string) error {
+	b, err := yaml.Marshal(statuses)
+	if err != nil {
+		return errors.Wrap(err, errMarshalStatus)
+	}
+	if cm.Data[KeyStatus] == string(b) && cm.Data[KeySummary] == summary {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[KeyStatus], cm.Data[KeySummary] = string(b), summary
+	return errors.Wrap(r.client.Update(ctx, cm), errUpdateConfigMap)
+}
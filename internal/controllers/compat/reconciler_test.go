@@ -0,0 +1,236 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/controllers/mirror"
+	"github.com/upbound/universal-crossplane/internal/controllers/versions"
+	"github.com/upbound/universal-crossplane/internal/eventtest"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/xpkg"
+)
+
+var errBoom = errors.New("boom")
+
+const (
+	pkgName = "provider-aws-s3"
+	// image is the image of the active revision, which differs from the
+	// package reference because it was resolved.
+	image = "xpkg.upbound.io/upbound/provider-aws-s3@sha256:abc"
+)
+
+type metaSourceFn func(ctx context.Context, pkg string, k xpkg.Keychain) (xpkg.Meta, error)
+
+func (fn metaSourceFn) Meta(ctx context.Context, pkg string, k xpkg.Keychain) (xpkg.Meta, error) {
+	return fn(ctx, pkg, k)
+}
+
+// constraint returns a MetaSource that returns the given constraint for the
+// image of the active revision.
+func constraint(c string) metaSourceFn {
+	return constraintFrom(image, xpkg.Keychain{"xpkg.upbound.io": {Username: "user", Password: "pass"}}, c)
+}
+
+// constraintFrom returns a MetaSource that returns the given constraint for
+// the given package if it is read with the given credentials.
+func constraintFrom(want string, wantKeychain xpkg.Keychain, c string) metaSourceFn {
+	return func(_ context.Context, pkg string, k xpkg.Keychain) (xpkg.Meta, error) {
+		if pkg != want {
+			return xpkg.Meta{}, errors.Errorf("unexpected package %s", pkg)
+		}
+		if diff := cmp.Diff(wantKeychain, k); diff != "" {
+			return xpkg.Meta{}, errors.Errorf("unexpected keychain: %s", diff)
+		}
+		m, err := xpkg.ParseMeta([]byte("apiVersion: meta.pkg.crossplane.io/v1\nkind: Provider\nspec:\n  crossplane:\n    version: \"" + c + "\"\n"))
+		return m, err
+	}
+}
+
+// pullSecret returns a pull Secret with the given credentials of the given
+// registry.
+func pullSecret(registry, username, password string) *corev1.Secret {
+	return &corev1.Secret{Data: map[string][]byte{
+		corev1.DockerConfigJsonKey: []byte(`{"auths": {"` + registry + `": {"username": "` + username + `", "password": "` + password + `"}}}`),
+	}}
+}
+
+func TestReconcile(t *testing.T) {
+	secrets := map[string]*corev1.Secret{
+		"regcred":    pullSecret("xpkg.upbound.io", "user", "pass"),
+		"mirrorcred": pullSecret("registry.corp", "mirror", "pass"),
+	}
+
+	// kubeWithMirrors returns a client that lists a Provider that uses the
+	// regcred pull Secret and its active revision, reports the given
+	// running Crossplane version, the given registry mirror config, and a
+	// compat ConfigMap with the given previous status.
+	kubeWithMirrors := func(running, mirrors string, previous ...xpkg.CompatStatus) *test.MockClient {
+		return &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+				if s, ok := obj.(*corev1.Secret); ok {
+					if secrets[key.Name] == nil {
+						return errBoom
+					}
+					secrets[key.Name].DeepCopyInto(s)
+					return nil
+				}
+				cm := obj.(*corev1.ConfigMap)
+				switch key.Name {
+				case meta.ConfigMapNameVersions:
+					cm.Data = map[string]string{versions.KeyCrossplaneVersion: running}
+					return nil
+				case meta.ConfigMapNameRegistryMirror:
+					if mirrors == "" {
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					}
+					cm.Data = map[string]string{mirror.KeyMirrors: mirrors}
+					return nil
+				}
+				b, _ := yaml.Marshal(previous)
+				cm.Data = map[string]string{KeyStatus: string(b)}
+				return nil
+			},
+			MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+				switch l := list.(type) {
+				case *pkgv1.ProviderList:
+					p := pkgv1.Provider{ObjectMeta: metav1.ObjectMeta{Name: pkgName}}
+					p.Spec.Package = "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0"
+					p.Spec.PackagePullSecrets = []corev1.LocalObjectReference{{Name: "regcred"}}
+					l.Items = []pkgv1.Provider{p}
+				case *pkgv1.ProviderRevisionList:
+					r := pkgv1.ProviderRevision{ObjectMeta: metav1.ObjectMeta{Name: pkgName + "-abc", Labels: map[string]string{pkgv1.LabelParentPackage: pkgName}}}
+					r.Spec.DesiredState = pkgv1.PackageRevisionActive
+					r.Spec.Package = image
+					l.Items = []pkgv1.ProviderRevision{r}
+				}
+				return nil
+			},
+		}
+	}
+	kube := func(running string, previous ...xpkg.CompatStatus) *test.MockClient {
+		return kubeWithMirrors(running, "", previous...)
+	}
+	status := func(c string, s xpkg.CompatState, msg string) xpkg.CompatStatus {
+		return xpkg.CompatStatus{Kind: pkgv1.ProviderKind, Name: pkgName, Package: image, Constraint: c, State: s, Message: msg}
+	}
+
+	type want struct {
+		err    error
+		status []xpkg.CompatStatus
+		events []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   *test.MockClient
+		source MetaSource
+		want   want
+	}{
+		"ListError": {
+			reason: "We should return an error if packages cannot be listed.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(nil), MockList: test.NewMockListFn(errBoom)},
+			want:   want{err: errors.Wrapf(errBoom, errFmtList, "provider revisions")},
+		},
+		"Compatible": {
+			reason: "Packages whose constraint allows the upstream version of the running Crossplane should be compatible.",
+			kube:   kube("1.20.4-up.1"),
+			source: constraint(">=v1.14.0-0"),
+			want:   want{status: []xpkg.CompatStatus{status(">=v1.14.0-0", xpkg.CompatStateCompatible, "")}},
+		},
+		"Incompatible": {
+			reason: "Packages that become incompatible, e.g. because they were upgraded, should be reported with an event.",
+			kube:   kube("1.20.4-up.1", xpkg.CompatStatus{Kind: pkgv1.ProviderKind, Name: pkgName, Package: "xpkg.upbound.io/upbound/provider-aws-s3:v1.0.0", Constraint: ">=v1.14.0-0", State: xpkg.CompatStateCompatible}),
+			source: constraint(">=v1.21.0"),
+			want: want{
+				status: []xpkg.CompatStatus{status(">=v1.21.0", xpkg.CompatStateIncompatible, "package requires Crossplane >=v1.21.0 but v1.20.4-up.1 is running")},
+				events: []event.Reason{reasonIncompatible},
+			},
+		},
+		"ConstraintFromStatus": {
+			reason: "The constraint of a package image that was already read should not be read again.",
+			kube:   kube("1.21.0-up.1", status(">=v1.21.0", xpkg.CompatStateIncompatible, "package requires Crossplane >=v1.21.0 but v1.20.4-up.1 is running")),
+			source: metaSourceFn(func(context.Context, string, xpkg.Keychain) (xpkg.Meta, error) { return xpkg.Meta{}, errBoom }),
+			want: want{
+				status: []xpkg.CompatStatus{status(">=v1.21.0", xpkg.CompatStateCompatible, "")},
+				events: []event.Reason{reasonCompatible},
+			},
+		},
+		"MetaError": {
+			reason: "Packages whose metadata cannot be read should be unknown.",
+			kube:   kube("1.20.4-up.1"),
+			source: metaSourceFn(func(context.Context, string, xpkg.Keychain) (xpkg.Meta, error) { return xpkg.Meta{}, errBoom }),
+			want:   want{status: []xpkg.CompatStatus{status("", xpkg.CompatStateUnknown, errBoom.Error())}},
+		},
+		"ImageConfigMirror": {
+			reason: "Packages should be read from the mirror an ImageConfig points them at, with the pull Secret of the mirrors.",
+			kube:   kubeWithMirrors("1.20.4-up.1", "pullSecret: mirrorcred\nmirrors: [\"xpkg.upbound.io/* -> registry.corp/*\"]"),
+			source: constraintFrom("registry.corp/upbound/provider-aws-s3@sha256:abc", xpkg.Keychain{
+				"xpkg.upbound.io": {Username: "user", Password: "pass"},
+				"registry.corp":   {Username: "mirror", Password: "pass"},
+			}, ">=v1.14.0-0"),
+			want: want{status: []xpkg.CompatStatus{status(">=v1.14.0-0", xpkg.CompatStateCompatible, "")}},
+		},
+		"UnknownCrossplaneVersion": {
+			reason: "Packages should be unknown until the running Crossplane version is reported.",
+			kube:   kube(""),
+			source: constraint(">=v1.14.0-0"),
+			want:   want{status: []xpkg.CompatStatus{status(">=v1.14.0-0", xpkg.CompatStateUnknown, "the running Crossplane version is not known")}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []xpkg.CompatStatus
+			tc.kube.MockUpdate = func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+				if err := yaml.Unmarshal([]byte(obj.(*corev1.ConfigMap).Data[KeyStatus]), &got); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			}
+			rec := &eventtest.Recorder{}
+			r := NewReconciler(&fake.Manager{Client: tc.kube}, WithRecorder(rec), WithMetaSource(tc.source))
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameCompat, Namespace: "upbound-system"}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.status, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want status, +got status:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.Reasons); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compat
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/xpkg"
+)
+
+// Setup adds the controller that reports whether the Crossplane packages are
+// compatible with the running Crossplane in the compat ConfigMap in the given
+// namespace. Package metadata is read from the registries of the packages, or
+// from the given registry endpoint if it is not empty, with the pull Secrets
+// of the packages and through the registry mirrors Crossplane pulls them
+// from.
+func Setup(mgr ctrl.Manager, l logging.Logger, namespace, endpoint string) error {
+	name := "compat"
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithMetaSource(xpkg.NewClient(endpoint)),
+	)
+
+	// Packages and their revisions are cluster scoped, so all changes are
+	// mapped to the ConfigMap in our namespace, as are changes to the running
+	// Crossplane version and the registry mirrors.
+	toConfigMap := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameCompat, Namespace: namespace}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNameCompat)))).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, toConfigMap, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNameVersions)))).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, toConfigMap, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNameRegistryMirror)))).
+		Watches(&source.Kind{Type: &pkgv1.Provider{}}, toConfigMap).
+		Watches(&source.Kind{Type: &pkgv1.Configuration{}}, toConfigMap).
+		Watches(&source.Kind{Type: &pkgv1.Function{}}, toConfigMap).
+		Watches(&source.Kind{Type: &pkgv1.ProviderRevision{}}, toConfigMap).
+		Watches(&source.Kind{Type: &pkgv1.ConfigurationRevision{}}, toConfigMap).
+		Watches(&source.Kind{Type: &pkgv1.FunctionRevision{}}, toConfigMap).
+		Complete(r)
+}
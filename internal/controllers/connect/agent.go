@@ -0,0 +1,147 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+// Keys of the control plane token Secret.
+const (
+	// KeyToken is the key of the token that the agent connects with.
+	KeyToken = "token"
+	// KeyEndpoint is the optional key of the Upbound Cloud endpoint the agent
+	// connects to.
+	KeyEndpoint = "endpoint"
+)
+
+const (
+	// DefaultEndpoint is the Upbound Cloud endpoint the agent connects to if
+	// the token Secret does not specify one.
+	DefaultEndpoint = "https://api.upbound.io"
+
+	// AnnotationKeyTokenHash is the annotation on the agent pod template that
+	// holds the hash of the token it is running with. Changing it rolls the
+	// agent when the token is rotated.
+	AnnotationKeyTokenHash = "connect.upbound.io/token-hash"
+
+	envToken    = "UPBOUND_TOKEN"
+	envEndpoint = "UPBOUND_ENDPOINT"
+
+	// clusterRoleCrossplaneView is the ClusterRole the Crossplane RBAC manager
+	// aggregates read access to managed and composite resources into. It
+	// does not grant access to Secrets.
+	clusterRoleCrossplaneView = "crossplane-view"
+)
+
+// ClusterRoleBindingNameView is the name of the ClusterRoleBinding that grants
+// the agent read access to managed and composite resources.
+const ClusterRoleBindingNameView = meta.DeploymentNameAgent + ":" + clusterRoleCrossplaneView
+
+// TokenHash returns the hash of the given token that is recorded on the agent.
+func TokenHash(token []byte) string {
+	h := sha256.Sum256(token)
+	return hex.EncodeToString(h[:])[:16]
+}
+
+func labels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":      meta.DeploymentNameAgent,
+		"app.kubernetes.io/component": "agent",
+		meta.LabelKeyManagedBy:        meta.LabelValueManagedBy,
+	}
+}
+
+// Render returns the objects that run the agent in the given namespace with
+// the token in the given Secret.
+func Render(s *corev1.Secret, image string) []client.Object {
+	name, ns := meta.DeploymentNameAgent, s.GetNamespace()
+	selector := map[string]string{"app.kubernetes.io/name": name}
+	endpoint := string(s.Data[KeyEndpoint])
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	sa := &corev1.ServiceAccount{
+		TypeMeta:   metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "ServiceAccount"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: labels()},
+	}
+	cr := &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels()},
+		Rules: []rbacv1.PolicyRule{
+			// The agent reports the state of the control plane to Upbound
+			// Cloud. It reads the Crossplane APIs and the CRDs, but never
+			// Secrets.
+			{APIGroups: []string{"apiextensions.crossplane.io", "pkg.crossplane.io", "secrets.crossplane.io"}, Resources: []string{"*"}, Verbs: []string{"get", "list", "watch"}},
+			{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"get", "list", "watch"}},
+			{APIGroups: []string{""}, Resources: []string{"namespaces", "events"}, Verbs: []string{"get", "list", "watch"}},
+		},
+	}
+	crb := &rbacv1.ClusterRoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels()},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: ns}},
+	}
+	// Managed and composite resources are in API groups only known at
+	// runtime, so the agent reads them through the role the RBAC manager
+	// maintains for them.
+	view := &rbacv1.ClusterRoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+		ObjectMeta: metav1.ObjectMeta{Name: ClusterRoleBindingNameView, Labels: labels()},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: clusterRoleCrossplaneView},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: ns}},
+	}
+	d := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: labels()},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: pointer.Int32(1),
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels(),
+					Annotations: map[string]string{AnnotationKeyTokenHash: TokenHash(s.Data[KeyToken])},
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: name,
+					Containers: []corev1.Container{{
+						Name:  "agent",
+						Image: image,
+						Env: []corev1.EnvVar{
+							{Name: envEndpoint, Value: endpoint},
+							{Name: envToken, ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: s.GetName()},
+								Key:                  KeyToken,
+							}}},
+						},
+					}},
+				},
+			},
+		},
+	}
+	return []client.Object{sa, cr, crb, view, d}
+}
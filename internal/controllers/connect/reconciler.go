@@ -0,0 +1,299 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package connect contains the controller that connects the cluster to
+// Upbound Cloud by running the Upbound agent.
+package connect
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+	syncPeriod       = 10 * time.Minute
+	pollPeriod       = 30 * time.Second
+
+	errGetSecret     = "cannot get control plane token secret"
+	errUpdateSecret  = "cannot update control plane token secret"
+	errGetDeployment = "cannot get agent deployment"
+	errFmtApply      = "cannot apply agent %s %s"
+	errFmtGet        = "cannot get agent %s %s"
+	errFmtDelete     = "cannot delete agent %s %s"
+	errMissingToken  = "control plane token secret has no token"
+
+	msgAgentNotReady   = "Agent is not available yet"
+	msgAgentRollingOut = "Agent is rolling out"
+	msgConnected       = "Agent is connected to Upbound Cloud"
+
+	reasonConnected    event.Reason = "Connected"
+	reasonConnecting   event.Reason = "Connecting"
+	reasonTokenRotated event.Reason = "TokenRotated"
+	reasonConnectError event.Reason = "ConnectError"
+)
+
+// Annotations on the control plane token Secret that report the connection
+// status.
+const (
+	AnnotationKeyStatus  = "connect.upbound.io/status"
+	AnnotationKeyMessage = "connect.upbound.io/message"
+)
+
+// A Status of the connection to Upbound Cloud.
+type Status string
+
+// Connection statuses.
+const (
+	StatusConnected  Status = "Connected"
+	StatusConnecting Status = "Connecting"
+	StatusError      Status = "Error"
+)
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithInventory specifies how the Reconciler should record the objects it
+// applies.
+func WithInventory(inv inventory.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.inv = inv
+	}
+}
+
+// WithAPIReader specifies how the Reconciler should read the agent objects
+// when it removes them.
+func WithAPIReader(c client.Reader) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.reader = c
+	}
+}
+
+// WithAgentImage specifies the image of the agent.
+func WithAgentImage(image string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.image = image
+	}
+}
+
+// Reconciler reconciles the Upbound agent with the control plane token
+// Secret.
+type Reconciler struct {
+	client client.Client
+	reader client.Reader
+	log    logging.Logger
+	record event.Recorder
+	inv    inventory.Recorder
+
+	image string
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client: mgr.GetClient(),
+		reader: mgr.GetClient(),
+		log:    logging.NewNopLogger(),
+		record: event.NewNopRecorder(),
+		inv:    inventory.NopRecorder{},
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile runs the agent with the token in the control plane token Secret
+// and reports the connection status on the Secret. The agent is removed when
+// the Secret is deleted.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "connect.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	s := &corev1.Secret{}
+	err = r.client.Get(ctx, types.NamespacedName{Name: meta.SecretNameControlPlaneToken, Namespace: req.Namespace}, s)
+	if kerrors.IsNotFound(err) {
+		if err := r.inv.Record(ctx, nil); err != nil {
+			return reconcile.Result{}, err
+		}
+		removed, err := r.disconnect(ctx, req.Namespace)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if removed {
+			log.Info("Removed agent since control plane token secret is deleted")
+		}
+		return reconcile.Result{}, nil
+	}
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetSecret)
+	}
+
+	if len(s.Data[KeyToken]) == 0 {
+		r.record.Event(s, event.Warning(reasonConnectError, errors.New(errMissingToken)))
+		return reconcile.Result{}, r.setStatus(ctx, s, StatusError, errMissingToken)
+	}
+
+	current := &appsv1.Deployment{}
+	err = r.client.Get(ctx, types.NamespacedName{Name: meta.DeploymentNameAgent, Namespace: req.Namespace}, current)
+	if client.IgnoreNotFound(err) != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetDeployment)
+	}
+	previousHash := current.Spec.Template.GetAnnotations()[AnnotationKeyTokenHash]
+
+	objs := Render(s, r.image)
+	refs := make([]inventory.Ref, 0, len(objs))
+	for _, o := range objs {
+		refs = append(refs, inventory.RefOf(o))
+	}
+	if err := r.inv.Record(ctx, refs); err != nil {
+		return reconcile.Result{}, err
+	}
+	for _, o := range objs {
+		if err := r.client.Patch(ctx, o, client.Apply, client.FieldOwner(meta.FieldOwner), client.ForceOwnership); err != nil {
+			r.record.Event(s, event.Warning(reasonConnectError, err))
+			return reconcile.Result{}, errors.Wrapf(err, errFmtApply, o.GetObjectKind().GroupVersionKind().Kind, o.GetName())
+		}
+	}
+
+	if previousHash != "" && previousHash != TokenHash(s.Data[KeyToken]) {
+		r.record.Event(s, event.Normal(reasonTokenRotated, "Rolling out the agent with the rotated token"))
+		log.Info("Rolling out the agent with the rotated token")
+	}
+
+	// The Deployment is the last object and is updated with the response
+	// of the API server.
+	d := objs[len(objs)-1].(*appsv1.Deployment)
+	status, msg := deploymentStatus(d)
+	if err := r.setStatus(ctx, s, status, msg); err != nil {
+		return reconcile.Result{}, err
+	}
+	if status != StatusConnected {
+		return reconcile.Result{RequeueAfter: pollPeriod}, nil
+	}
+	return reconcile.Result{RequeueAfter: syncPeriod}, nil
+}
+
+// setStatus records the connection status on the Secret if it changed.
+func (r *Reconciler) setStatus(ctx context.Context, s *corev1.Secret, status Status, msg string) error {
+	a := s.GetAnnotations()
+	if a[AnnotationKeyStatus] == string(status) && a[AnnotationKeyMessage] == msg {
+		return nil
+	}
+	if a == nil {
+		a = map[string]string{}
+	}
+	a[AnnotationKeyStatus], a[AnnotationKeyMessage] = string(status), msg
+	s.SetAnnotations(a)
+	if err := r.client.Update(ctx, s); err != nil {
+		return errors.Wrap(err, errUpdateSecret)
+	}
+	switch status {
+	case StatusConnected:
+		r.record.Event(s, event.Normal(reasonConnected, msg))
+		r.log.Info("Connected to Upbound Cloud", "namespace", s.GetNamespace())
+	case StatusConnecting:
+		r.record.Event(s, event.Normal(reasonConnecting, msg))
+	case StatusError:
+		// Errors are recorded where they happen.
+	}
+	return nil
+}
+
+// disconnect deletes the agent objects that are managed by the bootstrapper
+// in the given namespace. It returns true if any object is deleted.
+func (r *Reconciler) disconnect(ctx context.Context, ns string) (bool, error) {
+	name := meta.DeploymentNameAgent
+	objs := []struct {
+		kind string
+		nn   types.NamespacedName
+		obj  client.Object
+	}{
+		{kind: "Deployment", nn: types.NamespacedName{Name: name, Namespace: ns}, obj: &appsv1.Deployment{}},
+		{kind: "ServiceAccount", nn: types.NamespacedName{Name: name, Namespace: ns}, obj: &corev1.ServiceAccount{}},
+		{kind: "ClusterRoleBinding", nn: types.NamespacedName{Name: ClusterRoleBindingNameView}, obj: &rbacv1.ClusterRoleBinding{}},
+		{kind: "ClusterRoleBinding", nn: types.NamespacedName{Name: name}, obj: &rbacv1.ClusterRoleBinding{}},
+		{kind: "ClusterRole", nn: types.NamespacedName{Name: name}, obj: &rbacv1.ClusterRole{}},
+	}
+	removed := false
+	for _, o := range objs {
+		if err := r.reader.Get(ctx, o.nn, o.obj); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return removed, errors.Wrapf(err, errFmtGet, o.kind, o.nn.Name)
+		}
+		if o.obj.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy {
+			// Not created by us, so not ours to delete.
+			continue
+		}
+		if err := r.client.Delete(ctx, o.obj); client.IgnoreNotFound(err) != nil {
+			return removed, errors.Wrapf(err, errFmtDelete, o.kind, o.nn.Name)
+		}
+		removed = true
+	}
+	return removed, nil
+}
+
+// deploymentStatus returns the connection status derived from the agent
+// Deployment. The agent exits when it cannot connect, so an available agent
+// that is fully rolled out is connected.
+func deploymentStatus(d *appsv1.Deployment) (Status, string) {
+	st := d.Status
+	switch {
+	case st.ObservedGeneration < d.GetGeneration() || st.UpdatedReplicas < st.Replicas:
+		return StatusConnecting, msgAgentRollingOut
+	case st.AvailableReplicas == 0:
+		return StatusConnecting, msgAgentNotReady
+	}
+	return StatusConnected, msgConnected
+}
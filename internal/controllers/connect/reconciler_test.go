@@ -0,0 +1,245 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+func TestReconcile(t *testing.T) {
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "")
+	token := []byte("t0k3n")
+
+	// get returns a Secret with the given token and annotations, and an
+	// agent Deployment that runs with the given token hash.
+	get := func(token []byte, annotations map[string]string, hash string) test.MockGetFn {
+		return func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			switch o := obj.(type) {
+			case *corev1.Secret:
+				o.SetName(meta.SecretNameControlPlaneToken)
+				o.SetNamespace("upbound-system")
+				// Copied, since the reconciler updates the annotations.
+				a := make(map[string]string, len(annotations))
+				for k, v := range annotations {
+					a[k] = v
+				}
+				o.SetAnnotations(a)
+				o.Data = map[string][]byte{KeyToken: token}
+			case *appsv1.Deployment:
+				if hash == "" {
+					return notFound
+				}
+				o.Spec.Template.SetAnnotations(map[string]string{AnnotationKeyTokenHash: hash})
+			}
+			return nil
+		}
+	}
+	// available reports the agent Deployment as available.
+	available := func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+		if d, ok := obj.(*appsv1.Deployment); ok {
+			d.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+		}
+		return nil
+	}
+	connected := map[string]string{AnnotationKeyStatus: string(StatusConnected), AnnotationKeyMessage: msgConnected}
+
+	type want struct {
+		result      reconcile.Result
+		err         error
+		annotations map[string]string
+		deleted     []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   *test.MockClient
+		want   want
+	}{
+		"GetSecretError": {
+			reason: "We should return an error if the token Secret cannot be fetched.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			want:   want{err: errors.Wrap(errBoom, errGetSecret)},
+		},
+		"SecretDeleted": {
+			reason: "We should delete the agent objects we manage when the token Secret is deleted.",
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					switch obj.(type) {
+					case *corev1.Secret:
+						return notFound
+					case *corev1.ServiceAccount:
+						// Created by someone else.
+						return nil
+					}
+					obj.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+					return nil
+				},
+			},
+			want: want{deleted: []string{"*v1.Deployment", "*v1.ClusterRoleBinding", "*v1.ClusterRoleBinding", "*v1.ClusterRole"}},
+		},
+		"MissingToken": {
+			reason: "We should report an error status if the token Secret has no token.",
+			kube:   &test.MockClient{MockGet: get(nil, nil, "")},
+			want: want{annotations: map[string]string{
+				AnnotationKeyStatus:  string(StatusError),
+				AnnotationKeyMessage: errMissingToken,
+			}},
+		},
+		"ApplyError": {
+			reason: "We should return an error if the agent objects cannot be applied.",
+			kube:   &test.MockClient{MockGet: get(token, nil, ""), MockPatch: test.NewMockPatchFn(errBoom)},
+			want:   want{err: errors.Wrapf(errBoom, errFmtApply, "ServiceAccount", meta.DeploymentNameAgent)},
+		},
+		"Connecting": {
+			reason: "We should report that the agent is connecting until its Deployment is available.",
+			kube:   &test.MockClient{MockGet: get(token, nil, ""), MockPatch: test.NewMockPatchFn(nil)},
+			want: want{
+				result: reconcile.Result{RequeueAfter: pollPeriod},
+				annotations: map[string]string{
+					AnnotationKeyStatus:  string(StatusConnecting),
+					AnnotationKeyMessage: msgAgentNotReady,
+				},
+			},
+		},
+		"Connected": {
+			reason: "We should report that the agent is connected once its Deployment is available.",
+			kube:   &test.MockClient{MockGet: get(token, nil, TokenHash(token)), MockPatch: available},
+			want:   want{result: reconcile.Result{RequeueAfter: syncPeriod}, annotations: connected},
+		},
+		"AlreadyConnected": {
+			reason: "We should not update the token Secret if the status did not change.",
+			kube:   &test.MockClient{MockGet: get(token, connected, TokenHash(token)), MockPatch: available},
+			want:   want{result: reconcile.Result{RequeueAfter: syncPeriod}},
+		},
+		"TokenRotated": {
+			reason: "We should roll out the agent when the token is rotated.",
+			kube: &test.MockClient{
+				MockGet: get(token, connected, TokenHash([]byte("old"))),
+				MockPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+					if d, ok := obj.(*appsv1.Deployment); ok {
+						d.SetGeneration(2)
+						d.Status = appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, AvailableReplicas: 1}
+					}
+					return nil
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: pollPeriod},
+				annotations: map[string]string{
+					AnnotationKeyStatus:  string(StatusConnecting),
+					AnnotationKeyMessage: msgAgentRollingOut,
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var annotations map[string]string
+			var deleted []string
+			tc.kube.MockUpdate = func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+				annotations = obj.GetAnnotations()
+				return nil
+			}
+			tc.kube.MockDelete = func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+				deleted = append(deleted, typeName(obj))
+				return nil
+			}
+			r := NewReconciler(&fake.Manager{Client: tc.kube}, WithAgentImage("xpkg.upbound.io/upbound/agent:v0.1.0"))
+			got, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "upbound-system"}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.annotations, annotations); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want annotations, +got annotations:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want deleted, +got deleted:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	s := &corev1.Secret{Data: map[string][]byte{KeyToken: []byte("t0k3n")}}
+	s.SetName(meta.SecretNameControlPlaneToken)
+	s.SetNamespace("upbound-system")
+
+	objs := Render(s, "agent:v1")
+	for _, o := range objs {
+		if o.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy {
+			t.Errorf("Render(...): %s %s is not labeled as managed by the bootstrapper", typeName(o), o.GetName())
+		}
+		if o.GetObjectKind().GroupVersionKind().Kind == "" {
+			t.Errorf("Render(...): %s %s has no kind, which is required to apply it", typeName(o), o.GetName())
+		}
+		cr, ok := o.(*rbacv1.ClusterRole)
+		if !ok {
+			continue
+		}
+		for _, r := range cr.Rules {
+			for _, g := range r.APIGroups {
+				if g == "*" || (g == "" && (contains(r.Resources, "*") || contains(r.Resources, "secrets"))) {
+					t.Errorf("Render(...): ClusterRole %s grants access to Secrets: %v", cr.GetName(), r)
+				}
+			}
+		}
+	}
+	d := objs[len(objs)-1].(*appsv1.Deployment)
+	env := d.Spec.Template.Spec.Containers[0].Env
+	if diff := cmp.Diff(DefaultEndpoint, env[0].Value); diff != "" {
+		t.Errorf("Render(...): -want endpoint, +got endpoint:\n%s", diff)
+	}
+	if diff := cmp.Diff(meta.SecretNameControlPlaneToken, env[1].ValueFrom.SecretKeyRef.Name); diff != "" {
+		t.Errorf("Render(...): -want token secret, +got token secret:\n%s", diff)
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(o client.Object) string {
+	return fmt.Sprintf("%T", o)
+}
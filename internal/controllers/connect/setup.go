@@ -0,0 +1,62 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+// Setup adds the controller that runs the Upbound agent with the given image
+// when a control plane token Secret exists, and records the agent objects in
+// the given inventory.
+func Setup(mgr ctrl.Manager, l logging.Logger, image string, inv inventory.Recorder) error {
+	name := "cloud-connect"
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithInventory(inv),
+		WithAgentImage(image),
+		// The agent objects are read without the cache to avoid watching
+		// all ServiceAccounts and cluster RBAC.
+		WithAPIReader(mgr.GetAPIReader()),
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&corev1.Secret{}, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.SecretNameControlPlaneToken)))).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}},
+			handler.EnqueueRequestsFromMapFunc(toTokenSecret),
+			builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.DeploymentNameAgent)))).
+		Complete(r)
+}
+
+func toTokenSecret(o client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: meta.SecretNameControlPlaneToken, Namespace: o.GetNamespace()}}}
+}
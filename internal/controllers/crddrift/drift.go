@@ -0,0 +1,90 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crddrift
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"github.com/upbound/universal-crossplane/cluster/crds"
+)
+
+// Keys of the CRD drift ConfigMap.
+const (
+	// KeyReport holds the differences found as a list of Drift.
+	KeyReport = "report.yaml"
+	// KeySummary holds a human readable summary of the differences.
+	KeySummary = "summary"
+)
+
+// A Type of drift.
+type Type string
+
+// Types of drift.
+const (
+	// TypeMissing means the CRD does not exist in the cluster.
+	TypeMissing Type = "Missing"
+	// TypeServedVersions means the CRD serves different versions.
+	TypeServedVersions Type = "ServedVersions"
+	// TypeStorageVersion means the CRD stores a different version.
+	TypeStorageVersion Type = "StorageVersion"
+	// TypeSchema means the schema of a version of the CRD is different.
+	TypeSchema Type = "Schema"
+)
+
+// Types lists all types of drift.
+var Types = []Type{TypeMissing, TypeServedVersions, TypeStorageVersion, TypeSchema} //nolint:gochecknoglobals // We treat this as a constant.
+
+// A Drift is a difference between a CRD in the cluster and the bundled one.
+type Drift struct {
+	CRD     string `json:"crd"`
+	Type    Type   `json:"type"`
+	Message string `json:"message"`
+	// Repaired is true if the CRD was replaced with the bundled one.
+	Repaired bool `json:"repaired,omitempty"`
+}
+
+// Compare returns the differences of the given CRD in the cluster from the
+// bundled one. The CRD in the cluster is missing if it is nil.
+func Compare(bundled, live *extv1.CustomResourceDefinition) []Drift {
+	name := bundled.GetName()
+	if live == nil {
+		return []Drift{{CRD: name, Type: TypeMissing, Message: "CRD does not exist"}}
+	}
+	var drifts []Drift
+	if want, got := crds.ServedVersions(bundled), crds.ServedVersions(live); strings.Join(want, ",") != strings.Join(got, ",") {
+		drifts = append(drifts, Drift{CRD: name, Type: TypeServedVersions, Message: fmt.Sprintf("served versions are %v instead of %v", got, want)})
+	}
+	if want, got := crds.StorageVersion(bundled), crds.StorageVersion(live); want != got {
+		drifts = append(drifts, Drift{CRD: name, Type: TypeStorageVersion, Message: fmt.Sprintf("storage version is %q instead of %q", got, want)})
+	}
+	want, got := crds.SchemaHashes(bundled), crds.SchemaHashes(live)
+	var changed []string
+	for v, h := range want {
+		// Versions that are missing altogether are reported as served
+		// versions if they matter.
+		if lh, ok := got[v]; ok && lh != h {
+			changed = append(changed, v)
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		drifts = append(drifts, Drift{CRD: name, Type: TypeSchema, Message: fmt.Sprintf("schema of versions %v is different", changed)})
+	}
+	return drifts
+}
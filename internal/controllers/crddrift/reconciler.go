@@ -0,0 +1,232 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crddrift contains the controller that detects differences between
+// the Crossplane CRDs in the cluster and the ones bundled with Universal
+// Crossplane.
+package crddrift
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/metrics"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+	syncPeriod       = 10 * time.Minute
+
+	errGetConfigMap    = "cannot get crd drift configmap"
+	errCreateConfigMap = "cannot create crd drift configmap"
+	errUpdateConfigMap = "cannot update crd drift configmap"
+	errMarshalReport   = "cannot marshal crd drift report"
+	errFmtGetCRD       = "cannot get crd %s"
+	errFmtRepair       = "cannot repair crd %s"
+
+	reasonDrifted  event.Reason = "CRDDrifted"
+	reasonRepaired event.Reason = "CRDRepaired"
+	reasonResolved event.Reason = "CRDDriftResolved"
+)
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithCRDs specifies the bundled CRDs the ones in the cluster are compared
+// with.
+func WithCRDs(crds ...*extv1.CustomResourceDefinition) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.crds = crds
+	}
+}
+
+// WithRepair specifies whether CRDs that drifted should be replaced with the
+// bundled ones.
+func WithRepair(repair bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.repair = repair
+	}
+}
+
+// Reconciler compares the CRDs in the cluster with the bundled ones.
+type Reconciler struct {
+	client client.Client
+	log    logging.Logger
+	record event.Recorder
+
+	crds   []*extv1.CustomResourceDefinition
+	repair bool
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client: mgr.GetClient(),
+		log:    logging.NewNopLogger(),
+		record: event.NewNopRecorder(),
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile compares the CRDs in the cluster with the bundled ones, repairs
+// them if configured to and reports the differences in the CRD drift
+// ConfigMap.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "crddrift.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	err = r.client.Get(ctx, req.NamespacedName, cm)
+	if kerrors.IsNotFound(err) {
+		cm.SetName(req.Name)
+		cm.SetNamespace(req.Namespace)
+		cm.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+		if err := r.client.Create(ctx, cm); err != nil {
+			return reconcile.Result{}, errors.Wrap(err, errCreateConfigMap)
+		}
+	} else if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetConfigMap)
+	}
+	previous := map[Drift]bool{}
+	var prev []Drift
+	_ = yaml.Unmarshal([]byte(cm.Data[KeyReport]), &prev)
+	for _, d := range prev {
+		d.Repaired = false
+		previous[d] = true
+	}
+
+	report := []Drift{}
+	for _, want := range r.crds {
+		drifts, err := r.check(ctx, want)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		drifted := map[Type]bool{}
+		for i := range drifts {
+			d := &drifts[i]
+			drifted[d.Type] = true
+			if !previous[*d] {
+				r.record.Event(cm, event.Warning(reasonDrifted, errors.Errorf("%s: %s", d.CRD, d.Message)))
+				log.Info("CRD drifted from the bundled one", "crd", d.CRD, "type", d.Type, "message", d.Message)
+			}
+		}
+		for _, t := range Types {
+			metrics.SetCRDDrift(want.GetName(), string(t), drifted[t])
+		}
+		if len(drifts) > 0 && r.repair {
+			if err := r.apply(ctx, want); err != nil {
+				return reconcile.Result{}, err
+			}
+			for i := range drifts {
+				drifts[i].Repaired = true
+			}
+			r.record.Event(cm, event.Normal(reasonRepaired, fmt.Sprintf("Replaced %s with the bundled CRD", want.GetName())))
+			log.Info("Repaired CRD", "crd", want.GetName())
+		}
+		report = append(report, drifts...)
+	}
+
+	summary := fmt.Sprintf("%d of %d CRDs match the bundled ones", len(r.crds)-countCRDs(report), len(r.crds))
+	if len(report) == 0 && len(prev) > 0 {
+		r.record.Event(cm, event.Normal(reasonResolved, summary))
+	}
+	return reconcile.Result{RequeueAfter: syncPeriod}, r.writeReport(ctx, cm, report, summary)
+}
+
+// check returns the differences of the given bundled CRD from the one in the
+// cluster.
+func (r *Reconciler) check(ctx context.Context, want *extv1.CustomResourceDefinition) ([]Drift, error) {
+	live := &extv1.CustomResourceDefinition{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: want.GetName()}, live)
+	if kerrors.IsNotFound(err) {
+		return Compare(want, nil), nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtGetCRD, want.GetName())
+	}
+	return Compare(want, live), nil
+}
+
+// apply replaces the CRD in the cluster with the given bundled one.
+func (r *Reconciler) apply(ctx context.Context, want *extv1.CustomResourceDefinition) error {
+	crd := want.DeepCopy()
+	crd.SetGroupVersionKind(extv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"))
+	crd.Status = extv1.CustomResourceDefinitionStatus{}
+	return errors.Wrapf(r.client.Patch(ctx, crd, client.Apply, client.FieldOwner(meta.FieldOwner), client.ForceOwnership), errFmtRepair, want.GetName())
+}
+
+// writeReport writes the differences to the ConfigMap if they changed.
+func (r *Reconciler) writeReport(ctx context.Context, cm *corev1.ConfigMap, report []Drift, summary string) error {
+	b, err := yaml.Marshal(report)
+	if err != nil {
+		return errors.Wrap(err, errMarshalReport)
+	}
+	if cm.Data[KeyReport] == string(b) && cm.Data[KeySummary] == summary {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[KeyReport], cm.Data[KeySummary] = string(b), summary
+	return errors.Wrap(r.client.Update(ctx, cm), errUpdateConfigMap)
+}
+
+// countCRDs returns the number of distinct CRDs in the given report.
+func countCRDs(report []Drift) int {
+	seen := map[string]bool{}
+	for _, d := range report {
+		seen[d.CRD] = true
+	}
+	return len(seen)
+}
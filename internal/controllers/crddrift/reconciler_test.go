@@ -0,0 +1,207 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crddrift
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/cluster/crds"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+const crdName = "providers.pkg.crossplane.io"
+
+// crd returns a CRD that serves the given versions, stores the first one and
+// has a schema with the given description.
+func crd(description string, versions ...string) *extv1.CustomResourceDefinition {
+	c := &extv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: crdName}}
+	for i, v := range versions {
+		c.Spec.Versions = append(c.Spec.Versions, extv1.CustomResourceDefinitionVersion{
+			Name:    v,
+			Served:  true,
+			Storage: i == 0,
+			Schema:  &extv1.CustomResourceValidation{OpenAPIV3Schema: &extv1.JSONSchemaProps{Type: "object", Description: description}},
+		})
+	}
+	return c
+}
+
+func TestCompare(t *testing.T) {
+	cases := map[string]struct {
+		live *extv1.CustomResourceDefinition
+		want []Drift
+	}{
+		"Missing": {
+			want: []Drift{{CRD: crdName, Type: TypeMissing, Message: "CRD does not exist"}},
+		},
+		"Same": {
+			live: crd("a", "v1", "v1beta1"),
+		},
+		"ServedVersions": {
+			live: crd("a", "v1"),
+			want: []Drift{{CRD: crdName, Type: TypeServedVersions, Message: "served versions are [v1] instead of [v1 v1beta1]"}},
+		},
+		"StorageVersion": {
+			live: crd("a", "v1beta1", "v1"),
+			want: []Drift{{CRD: crdName, Type: TypeStorageVersion, Message: `storage version is "v1beta1" instead of "v1"`}},
+		},
+		"Schema": {
+			live: crd("b", "v1", "v1beta1"),
+			want: []Drift{{CRD: crdName, Type: TypeSchema, Message: "schema of versions [v1 v1beta1] is different"}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Compare(crd("a", "v1", "v1beta1"), tc.live)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Compare(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompareBundled(t *testing.T) {
+	bundled, err := crds.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range bundled {
+		if got := Compare(c, c.DeepCopy()); len(got) != 0 {
+			t.Errorf("Compare(%s, %s): want no drift, got %v", c.GetName(), c.GetName(), got)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "")
+	bundled := crd("a", "v1")
+
+	// get returns the given live CRD, or not found if it is nil, and a report
+	// with the given drifts.
+	get := func(live *extv1.CustomResourceDefinition, previous ...Drift) test.MockGetFn {
+		return func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			switch o := obj.(type) {
+			case *corev1.ConfigMap:
+				b, _ := yaml.Marshal(previous)
+				o.Data = map[string]string{KeyReport: string(b)}
+			case *extv1.CustomResourceDefinition:
+				if live == nil {
+					return notFound
+				}
+				live.DeepCopyInto(o)
+			}
+			return nil
+		}
+	}
+
+	type args struct {
+		kube   *test.MockClient
+		repair bool
+	}
+	type want struct {
+		err      error
+		report   []Drift
+		repaired bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"GetCRDError": {
+			reason: "We should return an error if a CRD cannot be fetched.",
+			args: args{kube: &test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				if _, ok := obj.(*extv1.CustomResourceDefinition); ok {
+					return errBoom
+				}
+				return get(nil)(ctx, key, obj)
+			}}},
+			want: want{err: errors.Wrapf(errBoom, errFmtGetCRD, crdName)},
+		},
+		"NoDrift": {
+			reason: "We should report no differences if the CRDs match.",
+			args:   args{kube: &test.MockClient{MockGet: get(crd("a", "v1"))}},
+			want:   want{report: []Drift{}},
+		},
+		"Drift": {
+			reason: "We should report the differences of the CRDs.",
+			args:   args{kube: &test.MockClient{MockGet: get(crd("b", "v1"))}},
+			want:   want{report: []Drift{{CRD: crdName, Type: TypeSchema, Message: "schema of versions [v1] is different"}}},
+		},
+		"Repair": {
+			reason: "We should replace CRDs that drifted if repair is enabled.",
+			args:   args{repair: true, kube: &test.MockClient{MockGet: get(nil)}},
+			want: want{
+				repaired: true,
+				report:   []Drift{{CRD: crdName, Type: TypeMissing, Message: "CRD does not exist", Repaired: true}},
+			},
+		},
+		"RepairError": {
+			reason: "We should return an error if a CRD cannot be repaired.",
+			args:   args{repair: true, kube: &test.MockClient{MockGet: get(nil), MockPatch: test.NewMockPatchFn(errBoom)}},
+			want:   want{err: errors.Wrapf(errBoom, errFmtRepair, crdName)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var report []Drift
+			repaired := false
+			if tc.args.kube.MockPatch == nil {
+				tc.args.kube.MockPatch = func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+					repaired = obj.GetObjectKind().GroupVersionKind().Kind == "CustomResourceDefinition"
+					return nil
+				}
+			}
+			tc.args.kube.MockUpdate = func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+				if err := yaml.Unmarshal([]byte(obj.(*corev1.ConfigMap).Data[KeyReport]), &report); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			}
+			r := NewReconciler(&fake.Manager{Client: tc.args.kube}, WithCRDs(bundled), WithRepair(tc.args.repair))
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameCRDDrift, Namespace: "upbound-system"}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.report, report); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want report, +got report:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.repaired, repaired); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want repaired, +got repaired:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
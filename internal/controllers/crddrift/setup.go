@@ -0,0 +1,68 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crddrift
+
+import (
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/cluster/crds"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+// Setup adds the controller that compares the CRDs in the cluster with the
+// bundled ones and reports the differences in the given namespace. CRDs that
+// drifted are replaced with the bundled ones if repair is true.
+func Setup(mgr ctrl.Manager, l logging.Logger, namespace string, repair bool) error {
+	name := "crd-drift"
+	bundled, err := crds.Load()
+	if err != nil {
+		return errors.Wrap(err, "cannot load bundled CRDs")
+	}
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithCRDs(bundled...),
+		WithRepair(repair),
+	)
+
+	names := make(map[string]bool, len(bundled))
+	for _, crd := range bundled {
+		names[crd.GetName()] = true
+	}
+	// CRDs are cluster scoped, so changes to the bundled ones are mapped to
+	// the report in our namespace.
+	toReport := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameCRDDrift, Namespace: namespace}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		Watches(&source.Kind{Type: &extv1.CustomResourceDefinition{}}, toReport, builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return names[o.GetName()]
+		}))).
+		Complete(r)
+}
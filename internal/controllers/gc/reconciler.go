@@ -0,0 +1,302 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gc contains the controller that garbage collects the objects the
+// bootstrapper controllers applied and no longer desire.
+package gc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+)
+
+// Keys of the inventory ConfigMap written by the garbage collector. Every
+// other key holds the inventory of the controller it is named after.
+const (
+	// KeyObserved holds the objects the garbage collector has seen in the
+	// inventories, so that it notices when they are dropped.
+	KeyObserved = "gc.observed"
+	// KeyPruned holds the objects pruned by the last collection, or the
+	// ones that would be pruned in dry run mode.
+	KeyPruned = "gc.pruned"
+	// KeySummary holds a human readable summary of the last collection.
+	KeySummary = "gc.summary"
+)
+
+const (
+	reconcileTimeout = 2 * time.Minute
+	syncPeriod       = 10 * time.Minute
+
+	errGetConfigMap   = "cannot get inventory configmap"
+	errPatchConfigMap = "cannot update inventory configmap"
+	errFmtGet         = "cannot get %s"
+	errFmtDelete      = "cannot delete %s"
+
+	reasonInvalidInventory event.Reason = "InvalidInventory"
+	reasonPruned           event.Reason = "Pruned"
+	reasonWouldPrune       event.Reason = "WouldPrune"
+)
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithAPIReader specifies how the Reconciler should read the objects it may
+// prune, which may live outside of the cached namespace.
+func WithAPIReader(c client.Reader) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.reader = c
+	}
+}
+
+// WithControllers specifies the controllers that run. The objects in the
+// inventories of other controllers are no longer desired.
+func WithControllers(names ...string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.enabled = make(map[string]bool, len(names))
+		for _, n := range names {
+			r.enabled[n] = true
+		}
+	}
+}
+
+// WithDryRun specifies whether the Reconciler should only report the objects
+// it would prune.
+func WithDryRun(dryRun bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.dryRun = dryRun
+	}
+}
+
+// Reconciler prunes the objects that were recorded in an inventory and are
+// no longer desired.
+type Reconciler struct {
+	client client.Client
+	reader client.Reader
+	log    logging.Logger
+	record event.Recorder
+
+	enabled map[string]bool
+	dryRun  bool
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client: mgr.GetClient(),
+		reader: mgr.GetClient(),
+		log:    logging.NewNopLogger(),
+		record: event.NewNopRecorder(),
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile prunes the objects that were observed in the inventory of a
+// controller and are no longer in the inventory of any controller that runs.
+// Only objects that are still labeled as managed by the bootstrapper and are
+// not protected from pruning are deleted.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "gc.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, req.NamespacedName, cm); err != nil {
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGetConfigMap)
+	}
+
+	desired, candidates, disabled, err := r.inventories(cm)
+	if err != nil {
+		// Pruning without knowing everything that is desired could delete
+		// objects that are still in use.
+		log.Info("Invalid inventory", "error", err.Error())
+		r.record.Event(cm, event.Warning(reasonInvalidInventory, err))
+		return reconcile.Result{}, nil
+	}
+
+	// Objects that are kept although they are no longer desired are
+	// observed again, so that they are pruned once they can be.
+	keep := make([]inventory.Ref, 0, len(desired))
+	for ref := range desired {
+		keep = append(keep, ref)
+	}
+	var pruned []inventory.Ref
+	seen := map[inventory.Ref]bool{}
+	for _, ref := range candidates {
+		if desired[ref] || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		obj, err := r.prunable(ctx, ref)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		switch {
+		case obj == nil:
+			// Already gone or no longer ours.
+		case obj.GetAnnotations()[meta.AnnotationKeyPrune] == meta.AnnotationValuePruneDisabled:
+			log.Debug("Not pruning protected object", "object", ref.String())
+			keep = append(keep, ref)
+		case r.dryRun:
+			log.Info("Would prune object that is no longer desired", "object", ref.String())
+			keep = append(keep, ref)
+			pruned = append(pruned, ref)
+		default:
+			if err := r.client.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				return reconcile.Result{}, errors.Wrapf(err, errFmtDelete, ref)
+			}
+			log.Info("Pruned object that is no longer desired", "object", ref.String())
+			pruned = append(pruned, ref)
+		}
+	}
+
+	summary := fmt.Sprintf("Pruned %d objects", len(pruned))
+	reason := reasonPruned
+	if r.dryRun {
+		summary, reason = fmt.Sprintf("Would prune %d objects (dry run)", len(pruned)), reasonWouldPrune
+	}
+	if err := r.write(ctx, cm, disabled, keep, pruned, summary); err != nil {
+		return reconcile.Result{}, err
+	}
+	if len(pruned) > 0 {
+		inventory.Sort(pruned)
+		names := make([]string, len(pruned))
+		for i, ref := range pruned {
+			names[i] = ref.String()
+		}
+		r.record.Event(cm, event.Normal(reason, fmt.Sprintf("%s that are no longer desired: %s", summary, strings.Join(names, ", "))))
+	}
+	return reconcile.Result{RequeueAfter: syncPeriod}, nil
+}
+
+// inventories returns the objects desired by the controllers that run, the
+// candidates for pruning and the controllers that do not run.
+func (r *Reconciler) inventories(cm *corev1.ConfigMap) (map[inventory.Ref]bool, []inventory.Ref, []string, error) {
+	desired := map[inventory.Ref]bool{}
+	candidates, err := inventory.Unmarshal(KeyObserved, cm.Data[KeyObserved])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var disabled []string
+	for key, data := range cm.Data {
+		if strings.HasPrefix(key, "gc.") {
+			continue
+		}
+		refs, err := inventory.Unmarshal(key, data)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !r.enabled[key] {
+			disabled = append(disabled, key)
+			candidates = append(candidates, refs...)
+			continue
+		}
+		for _, ref := range refs {
+			desired[ref] = true
+		}
+	}
+	return desired, candidates, disabled, nil
+}
+
+// prunable returns the referenced object if it exists and is managed by the
+// bootstrapper, or nil otherwise.
+func (r *Reconciler) prunable(ctx context.Context, ref inventory.Ref) (*unstructured.Unstructured, error) {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(ref.APIVersion)
+	u.SetKind(ref.Kind)
+	err := r.reader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, u)
+	if kerrors.IsNotFound(err) || kmeta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtGet, ref)
+	}
+	if u.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy {
+		// Not created by us, so not ours to delete.
+		return nil, nil
+	}
+	return u, nil
+}
+
+// write records the observed objects and the result of the collection, and
+// drops the inventories of the controllers that do not run since their
+// objects are observed now.
+func (r *Reconciler) write(ctx context.Context, cm *corev1.ConfigMap, disabled []string, observed, pruned []inventory.Ref, summary string) error {
+	o, err := inventory.Marshal(observed)
+	if err != nil {
+		return err
+	}
+	p, err := inventory.Marshal(pruned)
+	if err != nil {
+		return err
+	}
+	data := map[string]*string{}
+	if cm.Data[KeyObserved] != o {
+		data[KeyObserved] = &o
+	}
+	if cm.Data[KeyPruned] != p {
+		data[KeyPruned] = &p
+	}
+	if cm.Data[KeySummary] != summary {
+		data[KeySummary] = &summary
+	}
+	for _, key := range disabled {
+		data[key] = nil
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return errors.Wrap(inventory.Patch(ctx, r.client, cm, data), errPatchConfigMap)
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+func marshal(t *testing.T, refs ...inventory.Ref) string {
+	t.Helper()
+	s, err := inventory.Marshal(refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestReconcile(t *testing.T) {
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameInventory, Namespace: "upbound-system"}}
+
+	s3 := inventory.Ref{APIVersion: "pkg.crossplane.io/v1", Kind: "Provider", Name: "provider-aws-s3"}
+	ec2 := inventory.Ref{APIVersion: "pkg.crossplane.io/v1", Kind: "Provider", Name: "provider-aws-ec2"}
+	users := inventory.Ref{APIVersion: "pkg.crossplane.io/v1", Kind: "Provider", Name: "provider-users"}
+	agent := inventory.Ref{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "upbound-system", Name: meta.DeploymentNameAgent}
+	gone := inventory.Ref{APIVersion: "v1", Kind: "ServiceAccount", Namespace: "upbound-system", Name: meta.DeploymentNameAgent}
+
+	// The packages controller still desires the S3 provider, but no longer
+	// the EC2 provider or the users provider, which a user took over. The
+	// cloud-connect controller no longer runs, and its Deployment is
+	// protected.
+	data := func(t *testing.T) map[string]string {
+		return map[string]string{
+			"packages":      marshal(t, s3),
+			"cloud-connect": marshal(t, agent, gone),
+			KeyObserved:     marshal(t, s3, ec2, users),
+		}
+	}
+	// The cluster has everything but the agent ServiceAccount.
+	reader := &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			u := obj.(*unstructured.Unstructured)
+			labels := map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy}
+			switch key.Name {
+			case gone.Name:
+				if u.GetKind() == gone.Kind {
+					return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+				}
+				u.SetAnnotations(map[string]string{meta.AnnotationKeyPrune: meta.AnnotationValuePruneDisabled})
+			case users.Name:
+				labels = nil
+			}
+			u.SetName(key.Name)
+			u.SetNamespace(key.Namespace)
+			u.SetLabels(labels)
+			return nil
+		},
+	}
+
+	type want struct {
+		result  reconcile.Result
+		err     error
+		deleted []string
+		patch   map[string]*string
+	}
+	cases := map[string]struct {
+		reason string
+		data   func(t *testing.T) map[string]string
+		dryRun bool
+		get    error
+		want   want
+	}{
+		"NotFound": {
+			reason: "Nothing should be pruned if there is no inventory.",
+			get:    kerrors.NewNotFound(schema.GroupResource{}, meta.ConfigMapNameInventory),
+			want:   want{},
+		},
+		"GetError": {
+			reason: "Errors getting the inventory should be returned.",
+			get:    errBoom,
+			want:   want{err: errors.Wrap(errBoom, errGetConfigMap)},
+		},
+		"InvalidInventory": {
+			reason: "Nothing should be pruned if an inventory cannot be parsed.",
+			data: func(_ *testing.T) map[string]string {
+				return map[string]string{"packages": "{"}
+			},
+			want: want{},
+		},
+		"Prune": {
+			reason: "Objects that are no longer desired should be pruned, unless they are protected or no longer ours.",
+			data:   data,
+			want: want{
+				result:  reconcile.Result{RequeueAfter: syncPeriod},
+				deleted: []string{ec2.Name},
+				patch: map[string]*string{
+					KeyObserved:     pointer.String(marshal(t, s3, agent)),
+					KeyPruned:       pointer.String(marshal(t, ec2)),
+					KeySummary:      pointer.String("Pruned 1 objects"),
+					"cloud-connect": nil,
+				},
+			},
+		},
+		"DryRun": {
+			reason: "Nothing should be deleted in dry run mode, but what would be pruned should be reported.",
+			data:   data,
+			dryRun: true,
+			want: want{
+				result: reconcile.Result{RequeueAfter: syncPeriod},
+				patch: map[string]*string{
+					KeyObserved:     pointer.String(marshal(t, s3, ec2, agent)),
+					KeyPruned:       pointer.String(marshal(t, ec2)),
+					KeySummary:      pointer.String("Would prune 1 objects (dry run)"),
+					"cloud-connect": nil,
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var deleted []string
+			var patch map[string]map[string]*string
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					if tc.get != nil {
+						return tc.get
+					}
+					cm := obj.(*corev1.ConfigMap)
+					cm.SetName(key.Name)
+					cm.SetNamespace(key.Namespace)
+					cm.Data = tc.data(t)
+					return nil
+				},
+				MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+					deleted = append(deleted, obj.GetName())
+					return nil
+				},
+				MockPatch: func(_ context.Context, obj client.Object, p client.Patch, _ ...client.PatchOption) error {
+					b, _ := p.Data(obj)
+					return json.Unmarshal(b, &patch)
+				},
+			}
+			r := NewReconciler(&fake.Manager{Client: kube}, WithAPIReader(reader), WithControllers("packages", "gc"), WithDryRun(tc.dryRun))
+			got, err := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want deleted, +got deleted:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patch, patch["data"]); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want patch, +got patch:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gc
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+// Setup adds the controller that prunes the objects recorded in the
+// inventory ConfigMap that the given controllers no longer desire.
+func Setup(mgr ctrl.Manager, l logging.Logger, controllers []string, dryRun bool) error {
+	name := "gc"
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		// The pruned objects may live in any namespace and are read
+		// without the cache.
+		WithAPIReader(mgr.GetAPIReader()),
+		WithControllers(controllers...),
+		WithDryRun(dryRun),
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNameInventory)))).
+		Complete(r)
+}
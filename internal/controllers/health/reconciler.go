@@ -0,0 +1,386 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package health contains the controller that summarizes the health of the
+// Crossplane packages in the cluster.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/metrics"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+)
+
+// Keys of the health ConfigMap.
+const (
+	// KeyHealth holds the health of the packages as a list of
+	// PackageHealth.
+	KeyHealth = "health.yaml"
+	// KeySummary holds a human readable summary of the health.
+	KeySummary = "summary"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+	syncPeriod       = 5 * time.Minute
+
+	errGetConfigMap    = "cannot get health configmap"
+	errCreateConfigMap = "cannot create health configmap"
+	errUpdateConfigMap = "cannot update health configmap"
+	errMarshalHealth   = "cannot marshal package health"
+	errFmtList         = "cannot list %s"
+
+	reasonDegraded  event.Reason = "PackageDegraded"
+	reasonRecovered event.Reason = "PackageRecovered"
+)
+
+// PackageHealth is the health of a package and its active revision.
+type PackageHealth struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Package string `json:"package"`
+
+	// Installed and Healthy are the statuses of the conditions of the
+	// package.
+	Installed bool `json:"installed"`
+	Healthy   bool `json:"healthy"`
+
+	// Revision is the name of the active revision, if any, and
+	// RevisionHealthy the status of its Healthy condition.
+	Revision        string `json:"revision,omitempty"`
+	RevisionHealthy bool   `json:"revisionHealthy"`
+
+	// Message explains why the package is not healthy.
+	Message string `json:"message,omitempty"`
+
+	// LastFailure is when the package last became unhealthy and
+	// LastFailureAge how long ago that was when the report was written.
+	LastFailure    *metav1.Time `json:"lastFailure,omitempty"`
+	LastFailureAge string       `json:"lastFailureAge,omitempty"`
+}
+
+// OK returns true if the package is installed and it and its active revision
+// are healthy.
+func (h PackageHealth) OK() bool {
+	return h.Installed && h.Healthy && (h.Revision == "" || h.RevisionHealthy)
+}
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// Reconciler summarizes the health of the Crossplane packages.
+type Reconciler struct {
+	client client.Client
+	log    logging.Logger
+	record event.Recorder
+	now    func() time.Time
+
+	// reported are the packages with metrics, used to remove the metrics of
+	// deleted packages.
+	reported map[string]bool
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client:   mgr.GetClient(),
+		log:      logging.NewNopLogger(),
+		record:   event.NewNopRecorder(),
+		now:      time.Now,
+		reported: map[string]bool{},
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile writes the health of all packages to the health ConfigMap and
+// records events for packages that degrade or recover.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "health.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	err = r.client.Get(ctx, req.NamespacedName, cm)
+	if kerrors.IsNotFound(err) {
+		cm.SetName(req.Name)
+		cm.SetNamespace(req.Namespace)
+		cm.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+		if err := r.client.Create(ctx, cm); err != nil {
+			return reconcile.Result{}, errors.Wrap(err, errCreateConfigMap)
+		}
+	} else if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetConfigMap)
+	}
+	var prev []PackageHealth
+	_ = yaml.Unmarshal([]byte(cm.Data[KeyHealth]), &prev)
+	previous := make(map[string]PackageHealth, len(prev))
+	for _, h := range prev {
+		previous[h.Kind+"/"+h.Name] = h
+	}
+
+	pkgs, err := r.listPackages(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	revs, err := r.listActiveRevisions(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	now := r.now()
+	report := make([]PackageHealth, 0, len(pkgs))
+	reported := make(map[string]bool, len(pkgs))
+	healthy := 0
+	for _, p := range pkgs {
+		kind := p.GetObjectKind().GroupVersionKind().Kind
+		key := kind + "/" + p.GetName()
+		h, failing := Summarize(kind, p, revs[key])
+		was, seen := previous[key]
+		switch {
+		case failing && h.LastFailure != nil:
+		case failing && seen && !was.OK() && was.LastFailure != nil:
+			// The package is still failing, keep the original time.
+			h.LastFailure = was.LastFailure
+		case failing:
+			h.LastFailure = &metav1.Time{Time: now}
+		case seen:
+			h.LastFailure = was.LastFailure
+		}
+		if h.LastFailure != nil {
+			h.LastFailureAge = now.Sub(h.LastFailure.Time).Round(time.Second).String()
+		}
+
+		switch {
+		case h.OK():
+			healthy++
+			if seen && !was.OK() {
+				r.record.Event(cm, event.Normal(reasonRecovered, fmt.Sprintf("%s %s is healthy", kind, h.Name)))
+				log.Info("Package recovered", "kind", kind, "name", h.Name)
+			}
+		case seen && was.OK():
+			r.record.Event(cm, event.Warning(reasonDegraded, errors.Errorf("%s %s is not healthy: %s", kind, h.Name, h.Message)))
+			log.Info("Package degraded", "kind", kind, "name", h.Name, "message", h.Message)
+		}
+
+		var lastFailure time.Time
+		if h.LastFailure != nil {
+			lastFailure = h.LastFailure.Time
+		}
+		metrics.SetPackageHealth(kind, h.Name, h.OK(), lastFailure)
+		reported[key] = true
+		report = append(report, h)
+	}
+	for key := range r.reported {
+		if !reported[key] {
+			kind, name, _ := strings.Cut(key, "/")
+			metrics.DeletePackageHealth(kind, name)
+		}
+	}
+	r.reported = reported
+
+	summary := fmt.Sprintf("%d of %d packages are healthy", healthy, len(report))
+	return reconcile.Result{RequeueAfter: syncPeriod}, r.writeHealth(ctx, cm, report, summary)
+}
+
+// Summarize returns the health of the given package of the given kind and
+// its active revision, which may be nil, and whether any of their conditions
+// report a failure. The time of the last failure is set only if a condition
+// reports it.
+func Summarize(kind string, p pkgv1.Package, rev pkgv1.PackageRevision) (PackageHealth, bool) {
+	st := p.GetPackageStatus()
+	h := PackageHealth{Kind: kind, Name: p.GetName(), Package: p.GetPackageSpec().Package}
+	failed := []xpv1.Condition{}
+
+	installed := st.GetCondition(pkgv1.TypeInstalled)
+	h.Installed = installed.Status == corev1.ConditionTrue
+	if installed.Status == corev1.ConditionFalse {
+		failed = append(failed, installed)
+	}
+	healthy := st.GetCondition(pkgv1.TypeHealthy)
+	h.Healthy = healthy.Status == corev1.ConditionTrue
+	if healthy.Status == corev1.ConditionFalse {
+		failed = append(failed, healthy)
+	}
+	if rev != nil {
+		h.Revision = rev.GetName()
+		rh := rev.GetPackageRevisionStatus().GetCondition(pkgv1.TypeHealthy)
+		h.RevisionHealthy = rh.Status == corev1.ConditionTrue
+		if rh.Status == corev1.ConditionFalse {
+			failed = append(failed, rh)
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		h.Message = conditionMessage(failed[0])
+		t := failed[0].LastTransitionTime
+		for _, c := range failed[1:] {
+			if c.LastTransitionTime.Before(&t) {
+				t = c.LastTransitionTime
+			}
+		}
+		if !t.IsZero() {
+			h.LastFailure = &t
+		}
+	case !h.Installed:
+		h.Message = "package is not installed yet"
+	case !h.OK():
+		h.Message = "package health is not known yet"
+	}
+	return h, len(failed) > 0
+}
+
+// listPackages returns all packages sorted by kind and name. Their kinds are
+// set.
+func (r *Reconciler) listPackages(ctx context.Context) ([]pkgv1.Package, error) {
+	var pkgs []pkgv1.Package
+
+	pl := &pkgv1.ProviderList{}
+	if err := r.client.List(ctx, pl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "providers")
+	}
+	for i := range pl.Items {
+		pl.Items[i].SetGroupVersionKind(pkgv1.ProviderGroupVersionKind)
+		pkgs = append(pkgs, &pl.Items[i])
+	}
+	cl := &pkgv1.ConfigurationList{}
+	if err := r.client.List(ctx, cl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "configurations")
+	}
+	for i := range cl.Items {
+		cl.Items[i].SetGroupVersionKind(pkgv1.ConfigurationGroupVersionKind)
+		pkgs = append(pkgs, &cl.Items[i])
+	}
+	fl := &pkgv1.FunctionList{}
+	if err := r.client.List(ctx, fl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "functions")
+	}
+	for i := range fl.Items {
+		fl.Items[i].SetGroupVersionKind(pkgv1.FunctionGroupVersionKind)
+		pkgs = append(pkgs, &fl.Items[i])
+	}
+
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		ki, kj := pkgs[i].GetObjectKind().GroupVersionKind().Kind, pkgs[j].GetObjectKind().GroupVersionKind().Kind
+		if ki != kj {
+			return ki < kj
+		}
+		return pkgs[i].GetName() < pkgs[j].GetName()
+	})
+	return pkgs, nil
+}
+
+// listActiveRevisions returns the active package revisions keyed by the kind
+// and name of their package.
+func (r *Reconciler) listActiveRevisions(ctx context.Context) (map[string]pkgv1.PackageRevision, error) {
+	revs := map[string]pkgv1.PackageRevision{}
+	add := func(kind string, rev pkgv1.PackageRevision) {
+		if rev.GetPackageRevisionSpec().DesiredState != pkgv1.PackageRevisionActive {
+			return
+		}
+		if parent := rev.GetLabels()[pkgv1.LabelParentPackage]; parent != "" {
+			revs[kind+"/"+parent] = rev
+		}
+	}
+
+	pl := &pkgv1.ProviderRevisionList{}
+	if err := r.client.List(ctx, pl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "provider revisions")
+	}
+	for i := range pl.Items {
+		add(pkgv1.ProviderKind, &pl.Items[i])
+	}
+	cl := &pkgv1.ConfigurationRevisionList{}
+	if err := r.client.List(ctx, cl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "configuration revisions")
+	}
+	for i := range cl.Items {
+		add(pkgv1.ConfigurationKind, &cl.Items[i])
+	}
+	fl := &pkgv1.FunctionRevisionList{}
+	if err := r.client.List(ctx, fl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "function revisions")
+	}
+	for i := range fl.Items {
+		add(pkgv1.FunctionKind, &fl.Items[i])
+	}
+	return revs, nil
+}
+
+// writeHealth writes the health of the packages to the ConfigMap if it
+// changed.
+func (r *Reconciler) writeHealth(ctx context.Context, cm *corev1.ConfigMap, report []PackageHealth, summary string) error {
+	b, err := yaml.Marshal(report)
+	if err != nil {
+		return errors.Wrap(err, errMarshalHealth)
+	}
+	if cm.Data[KeyHealth] == string(b) && cm.Data[KeySummary] == summary {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[KeyHealth], cm.Data[KeySummary] = string(b), summary
+	return errors.Wrap(r.client.Update(ctx, cm), errUpdateConfigMap)
+}
+
+func conditionMessage(c xpv1.Condition) string {
+	if c.Message != "" {
+		return c.Message
+	}
+	return string(c.Reason)
+}
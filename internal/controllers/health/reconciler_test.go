@@ -0,0 +1,210 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/eventtest"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+const pkgName = "provider-aws-s3"
+
+var (
+	now     = time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	failed  = metav1.NewTime(now.Add(-1 * time.Hour))
+	earlier = metav1.NewTime(now.Add(-24 * time.Hour))
+)
+
+func provider(conditions ...xpv1.Condition) *pkgv1.Provider {
+	p := &pkgv1.Provider{ObjectMeta: metav1.ObjectMeta{Name: pkgName}}
+	p.Spec.Package = "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0"
+	p.Status.SetConditions(conditions...)
+	return p
+}
+
+func revision(conditions ...xpv1.Condition) *pkgv1.ProviderRevision {
+	r := &pkgv1.ProviderRevision{ObjectMeta: metav1.ObjectMeta{Name: pkgName + "-abc", Labels: map[string]string{pkgv1.LabelParentPackage: pkgName}}}
+	r.Spec.DesiredState = pkgv1.PackageRevisionActive
+	r.Status.SetConditions(conditions...)
+	return r
+}
+
+func installed() xpv1.Condition {
+	return xpv1.Condition{Type: pkgv1.TypeInstalled, Status: corev1.ConditionTrue}
+}
+
+func healthy() xpv1.Condition {
+	return xpv1.Condition{Type: pkgv1.TypeHealthy, Status: corev1.ConditionTrue}
+}
+
+func unhealthy() xpv1.Condition {
+	return xpv1.Condition{Type: pkgv1.TypeHealthy, Status: corev1.ConditionFalse, Reason: "UnhealthyPackageRevision", Message: "cannot pull", LastTransitionTime: failed}
+}
+
+func TestSummarize(t *testing.T) {
+	type want struct {
+		h       PackageHealth
+		failing bool
+	}
+	cases := map[string]struct {
+		p    *pkgv1.Provider
+		rev  *pkgv1.ProviderRevision
+		want want
+	}{
+		"Healthy": {
+			p:    provider(installed(), healthy()),
+			rev:  revision(healthy()),
+			want: want{h: PackageHealth{Kind: "Provider", Name: pkgName, Package: "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", Installed: true, Healthy: true, Revision: pkgName + "-abc", RevisionHealthy: true}},
+		},
+		"Installing": {
+			p:    provider(),
+			want: want{h: PackageHealth{Kind: "Provider", Name: pkgName, Package: "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", Message: "package is not installed yet"}},
+		},
+		"Unhealthy": {
+			p:   provider(installed(), unhealthy()),
+			rev: revision(unhealthy()),
+			want: want{
+				h:       PackageHealth{Kind: "Provider", Name: pkgName, Package: "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", Installed: true, Revision: pkgName + "-abc", Message: "cannot pull", LastFailure: &failed},
+				failing: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var rev pkgv1.PackageRevision
+			if tc.rev != nil {
+				rev = tc.rev
+			}
+			h, failing := Summarize(pkgv1.ProviderKind, tc.p, rev)
+			if diff := cmp.Diff(tc.want.h, h); diff != "" {
+				t.Errorf("Summarize(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.failing, failing); diff != "" {
+				t.Errorf("Summarize(...): -want failing, +got failing:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	// kube returns a client that lists the given Provider and revision and a
+	// health ConfigMap with the given previous health.
+	kube := func(p *pkgv1.Provider, rev *pkgv1.ProviderRevision, previous ...PackageHealth) *test.MockClient {
+		return &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+				b, _ := yaml.Marshal(previous)
+				obj.(*corev1.ConfigMap).Data = map[string]string{KeyHealth: string(b)}
+				return nil
+			},
+			MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+				switch l := list.(type) {
+				case *pkgv1.ProviderList:
+					l.Items = []pkgv1.Provider{*p}
+				case *pkgv1.ProviderRevisionList:
+					l.Items = []pkgv1.ProviderRevision{*rev}
+				}
+				return nil
+			},
+		}
+	}
+	healthyReport := PackageHealth{Kind: "Provider", Name: pkgName, Package: "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", Installed: true, Healthy: true, Revision: pkgName + "-abc", RevisionHealthy: true}
+	failingReport := PackageHealth{Kind: "Provider", Name: pkgName, Package: "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", Installed: true, Revision: pkgName + "-abc", Message: "cannot pull", LastFailure: &earlier}
+
+	type want struct {
+		err    error
+		report []PackageHealth
+		events []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   *test.MockClient
+		want   want
+	}{
+		"ListError": {
+			reason: "We should return an error if packages cannot be listed.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(nil), MockList: test.NewMockListFn(errBoom)},
+			want:   want{err: errors.Wrapf(errBoom, errFmtList, "providers")},
+		},
+		"Healthy": {
+			reason: "We should report healthy packages.",
+			kube:   kube(provider(installed(), healthy()), revision(healthy())),
+			want:   want{report: []PackageHealth{healthyReport}},
+		},
+		"Degraded": {
+			reason: "We should report when packages that were healthy failed and record an event.",
+			kube:   kube(provider(installed(), unhealthy()), revision(unhealthy()), healthyReport),
+			want: want{
+				report: []PackageHealth{{Kind: "Provider", Name: pkgName, Package: "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", Installed: true, Revision: pkgName + "-abc", Message: "cannot pull", LastFailure: &failed, LastFailureAge: "1h0m0s"}},
+				events: []event.Reason{reasonDegraded},
+			},
+		},
+		"Recovered": {
+			reason: "We should keep the time of the last failure of packages that recovered and record an event.",
+			kube:   kube(provider(installed(), healthy()), revision(healthy()), failingReport),
+			want: want{
+				report: []PackageHealth{{Kind: "Provider", Name: pkgName, Package: "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", Installed: true, Healthy: true, Revision: pkgName + "-abc", RevisionHealthy: true, LastFailure: &earlier, LastFailureAge: "24h0m0s"}},
+				events: []event.Reason{reasonRecovered},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var report []PackageHealth
+			tc.kube.MockUpdate = func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+				if err := yaml.Unmarshal([]byte(obj.(*corev1.ConfigMap).Data[KeyHealth]), &report); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			}
+			rec := &eventtest.Recorder{}
+			r := NewReconciler(&fake.Manager{Client: tc.kube}, WithRecorder(rec))
+			r.now = func() time.Time { return now }
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameHealth, Namespace: "upbound-system"}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.report, report); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want report, +got report:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.Reasons); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirror
+
+import (
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+// Keys of the registry mirror ConfigMap.
+const (
+	// KeyMirrors holds the mirror mapping as a Config.
+	KeyMirrors = "mirrors.yaml"
+	// KeyStatus holds the mapping of the packages as a list of
+	// PackageStatus, written by the controller.
+	KeyStatus = "status.yaml"
+	// KeySummary holds a human readable summary of the mapping.
+	KeySummary = "summary"
+)
+
+const (
+	errParseConfig    = "cannot parse registry mirror config"
+	errFmtInvalidMode = "invalid mode %q, must be ImageConfig or Rewrite"
+	errFmtInvalidRule = "invalid mirror %q, must be of the form \"<from> -> <to>\""
+	errFmtWildcard    = "invalid mirror %q, wildcards are only allowed at the end of both sides"
+)
+
+// A Mode determines how packages are pointed at the mirrors.
+type Mode string
+
+// Modes.
+const (
+	// ModeImageConfig maintains a Crossplane ImageConfig per mirror that
+	// rewrites the package images when they are pulled. Package objects are
+	// left alone. Requires a Crossplane version that supports ImageConfig
+	// rewrites.
+	ModeImageConfig Mode = "ImageConfig"
+	// ModeRewrite changes the package reference of the package objects.
+	ModeRewrite Mode = "Rewrite"
+)
+
+// Config is the registry mirror mapping.
+type Config struct {
+	// Mode determines how packages are pointed at the mirrors. Defaults to
+	// ImageConfig.
+	Mode Mode `json:"mode,omitempty"`
+	// PullSecret is the name of a Secret in the Crossplane namespace that is
+	// used to pull from the mirrors. Only used with the ImageConfig mode.
+	PullSecret string `json:"pullSecret,omitempty"`
+	// Mirrors are prefix rules of the form "<from> -> <to>", e.g.
+	// "xpkg.upbound.io/* -> registry.corp/*".
+	Mirrors []string `json:"mirrors"`
+}
+
+// A Rule maps package references that start with From to ones that start
+// with To instead.
+type Rule struct {
+	From string
+	To   string
+}
+
+// ParseConfig parses and validates the given registry mirror config. The
+// mode is set to the effective one.
+func ParseConfig(data []byte) (Config, []Rule, error) {
+	c := Config{}
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return Config{}, nil, errors.Wrap(err, errParseConfig)
+	}
+	if c.Mode == "" {
+		c.Mode = ModeImageConfig
+	}
+	if c.Mode != ModeImageConfig && c.Mode != ModeRewrite {
+		return Config{}, nil, errors.Errorf(errFmtInvalidMode, c.Mode)
+	}
+	rules := make([]Rule, 0, len(c.Mirrors))
+	for _, m := range c.Mirrors {
+		r, err := ParseRule(m)
+		if err != nil {
+			return Config{}, nil, err
+		}
+		rules = append(rules, r)
+	}
+	return c, rules, nil
+}
+
+// ParseRule parses a mirror of the form "<from> -> <to>". A trailing "*" on
+// both sides is optional and means the same as none, since all rules match
+// prefixes.
+func ParseRule(m string) (Rule, error) {
+	from, to, ok := strings.Cut(m, "->")
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if !ok || from == "" || to == "" {
+		return Rule{}, errors.Errorf(errFmtInvalidRule, m)
+	}
+	from, to = strings.TrimSuffix(from, "*"), strings.TrimSuffix(to, "*")
+	if strings.Contains(from, "*") || strings.Contains(to, "*") {
+		return Rule{}, errors.Errorf(errFmtWildcard, m)
+	}
+	return Rule{From: from, To: to}, nil
+}
+
+// Map returns the given package reference pointed at its mirror and true, or
+// false if no rule matches it. The rule with the longest matching prefix
+// wins.
+func Map(rules []Rule, pkg string) (string, bool) {
+	match := -1
+	for i, r := range rules {
+		if strings.HasPrefix(pkg, r.From) && (match < 0 || len(r.From) > len(rules[match].From)) {
+			match = i
+		}
+	}
+	if match < 0 {
+		return pkg, false
+	}
+	return rules[match].To + strings.TrimPrefix(pkg, rules[match].From), true
+}
+
+// Mirrored returns true if the given package reference already points at a
+// mirror.
+func Mirrored(rules []Rule, pkg string) bool {
+	for _, r := range rules {
+		if strings.HasPrefix(pkg, r.To) {
+			return true
+		}
+	}
+	return false
+}
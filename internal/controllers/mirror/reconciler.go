@@ -0,0 +1,369 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mirror contains the controller that points Crossplane packages at
+// registry mirrors.
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+	syncPeriod       = 10 * time.Minute
+
+	// AnnotationKeyOriginalPackage is the annotation on packages rewritten by
+	// the controller that holds their package reference before the rewrite.
+	AnnotationKeyOriginalPackage = "mirror.upbound.io/original-package"
+
+	errGetConfigMap    = "cannot get registry mirror configmap"
+	errUpdateConfigMap = "cannot update registry mirror configmap"
+	errMarshalStatus   = "cannot marshal registry mirror status"
+	errListImageConfig = "cannot list image configs"
+	errFmtApply        = "cannot apply image config %s"
+	errFmtDelete       = "cannot delete image config %s"
+	errFmtList         = "cannot list %s"
+	errFmtPatch        = "cannot patch %s %s"
+
+	errImageConfigNotSupported = "the ImageConfig API is not available in this Crossplane version, use the Rewrite mode instead"
+
+	reasonInvalidConfig      event.Reason = "InvalidConfig"
+	reasonImageConfigMissing event.Reason = "ImageConfigUnavailable"
+	reasonPackageRewritten   event.Reason = "PackageRewritten"
+	reasonPackageUnmapped    event.Reason = "PackageUnmapped"
+)
+
+// ImageConfigGroupVersionKind is the GroupVersionKind of Crossplane
+// ImageConfigs.
+var ImageConfigGroupVersionKind = schema.GroupVersionKind{Group: pkgv1.Group, Version: "v1beta1", Kind: "ImageConfig"} //nolint:gochecknoglobals // We treat this as a constant.
+
+// A State of a package.
+type State string
+
+// States of a package.
+const (
+	// StateMapped means an ImageConfig points the package at a mirror.
+	StateMapped State = "Mapped"
+	// StateRewritten means the package reference was changed to a mirror.
+	StateRewritten State = "Rewritten"
+	// StateMirrored means the package reference already points at a mirror.
+	StateMirrored State = "Mirrored"
+	// StateUnmapped means no mirror matches the package.
+	StateUnmapped State = "Unmapped"
+)
+
+// PackageStatus is how a package is pointed at a mirror.
+type PackageStatus struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	State   State  `json:"state"`
+	Mirror  string `json:"mirror,omitempty"`
+}
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithInventory specifies how the Reconciler should record the objects it
+// applies.
+func WithInventory(inv inventory.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.inv = inv
+	}
+}
+
+// Reconciler points Crossplane packages at the mirrors listed in the
+// registry mirror ConfigMap.
+type Reconciler struct {
+	client client.Client
+	log    logging.Logger
+	record event.Recorder
+	inv    inventory.Recorder
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client: mgr.GetClient(),
+		log:    logging.NewNopLogger(),
+		record: event.NewNopRecorder(),
+		inv:    inventory.NopRecorder{},
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile maintains an ImageConfig per mirror or rewrites the package
+// references, depending on the mode, and reports packages that cannot be
+// mapped in the registry mirror ConfigMap.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "mirror.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, req.NamespacedName, cm); err != nil {
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGetConfigMap)
+	}
+	cfg, rules, err := ParseConfig([]byte(cm.Data[KeyMirrors]))
+	if err != nil {
+		// There is nothing to retry until the ConfigMap is fixed.
+		log.Info("Invalid registry mirror config", "error", err.Error())
+		r.record.Event(cm, event.Warning(reasonInvalidConfig, err))
+		return reconcile.Result{}, nil
+	}
+
+	var desired []*unstructured.Unstructured
+	if cfg.Mode == ModeImageConfig {
+		desired = ImageConfigs(rules, cfg.PullSecret)
+	}
+	refs := make([]inventory.Ref, 0, len(desired))
+	for _, ic := range desired {
+		refs = append(refs, inventory.RefOf(ic))
+	}
+	if err := r.inv.Record(ctx, refs); err != nil {
+		return reconcile.Result{}, err
+	}
+	err = r.applyImageConfigs(ctx, desired)
+	switch {
+	case kmeta.IsNoMatchError(err) && cfg.Mode == ModeImageConfig:
+		// Crossplane is too old to support ImageConfigs. We still report how
+		// the packages would be mapped.
+		r.record.Event(cm, event.Warning(reasonImageConfigMissing, errors.New(errImageConfigNotSupported)))
+	case kmeta.IsNoMatchError(err):
+		// There are no ImageConfigs to clean up.
+	case err != nil:
+		return reconcile.Result{}, err
+	}
+
+	pkgs, err := r.listPackages(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	statuses := make([]PackageStatus, 0, len(pkgs))
+	unmapped := 0
+	for _, p := range pkgs {
+		kind := p.GetObjectKind().GroupVersionKind().Kind
+		s := PackageStatus{Kind: kind, Name: p.GetName(), Package: p.GetPackageSpec().Package}
+		mapped, ok := Map(rules, s.Package)
+		switch {
+		case Mirrored(rules, s.Package):
+			s.State = StateMirrored
+		case !ok:
+			s.State = StateUnmapped
+			unmapped++
+			if !contains(cm.Data[KeyStatus], s) {
+				r.record.Event(cm, event.Warning(reasonPackageUnmapped, errors.Errorf("no mirror matches %s %s from %s", kind, s.Name, s.Package)))
+			}
+		case cfg.Mode == ModeImageConfig:
+			s.State, s.Mirror = StateMapped, mapped
+		default:
+			if err := r.rewrite(ctx, p, mapped); err != nil {
+				return reconcile.Result{}, err
+			}
+			r.record.Event(cm, event.Normal(reasonPackageRewritten, fmt.Sprintf("Changed %s %s from %s to %s", kind, s.Name, s.Package, mapped)))
+			log.Info("Rewrote package", "kind", kind, "name", s.Name, "from", s.Package, "to", mapped)
+			s.State, s.Package, s.Mirror = StateRewritten, mapped, mapped
+		}
+		statuses = append(statuses, s)
+	}
+
+	summary := fmt.Sprintf("%d of %d packages are pulled from mirrors", len(statuses)-unmapped, len(statuses))
+	return reconcile.Result{RequeueAfter: syncPeriod}, r.writeStatus(ctx, cm, statuses, summary)
+}
+
+// ImageConfigs returns an ImageConfig per rule that rewrites the matching
+// images to the mirror, authenticating with the given pull Secret if it is
+// not empty.
+func ImageConfigs(rules []Rule, pullSecret string) []*unstructured.Unstructured {
+	ics := make([]*unstructured.Unstructured, 0, len(rules))
+	for _, rule := range rules {
+		ic := &unstructured.Unstructured{Object: map[string]any{
+			"spec": map[string]any{
+				"matchImages":  []any{map[string]any{"type": "Prefix", "prefix": rule.From}},
+				"rewriteImage": map[string]any{"prefix": rule.To},
+			},
+		}}
+		if pullSecret != "" {
+			_ = unstructured.SetNestedField(ic.Object, pullSecret, "spec", "registry", "authentication", "pullSecretRef", "name")
+		}
+		ic.SetGroupVersionKind(ImageConfigGroupVersionKind)
+		// Names are derived from the prefix so that they do not change when
+		// rules are reordered.
+		h := sha256.Sum256([]byte(rule.From))
+		ic.SetName("uxp-mirror-" + hex.EncodeToString(h[:])[:10])
+		ic.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+		ics = append(ics, ic)
+	}
+	return ics
+}
+
+// applyImageConfigs applies the given ImageConfigs and deletes the other ones
+// managed by the bootstrapper.
+func (r *Reconciler) applyImageConfigs(ctx context.Context, desired []*unstructured.Unstructured) error {
+	keep := map[string]bool{}
+	for _, ic := range desired {
+		if err := r.client.Patch(ctx, ic, client.Apply, client.FieldOwner(meta.FieldOwner), client.ForceOwnership); err != nil {
+			if kmeta.IsNoMatchError(err) {
+				return err
+			}
+			return errors.Wrapf(err, errFmtApply, ic.GetName())
+		}
+		keep[ic.GetName()] = true
+	}
+
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(ImageConfigGroupVersionKind.GroupVersion().WithKind("ImageConfigList"))
+	if err := r.client.List(ctx, l, client.MatchingLabels{meta.LabelKeyManagedBy: meta.LabelValueManagedBy}); err != nil {
+		if kmeta.IsNoMatchError(err) {
+			return err
+		}
+		return errors.Wrap(err, errListImageConfig)
+	}
+	for i := range l.Items {
+		ic := &l.Items[i]
+		if keep[ic.GetName()] {
+			continue
+		}
+		if err := r.client.Delete(ctx, ic); client.IgnoreNotFound(err) != nil {
+			return errors.Wrapf(err, errFmtDelete, ic.GetName())
+		}
+	}
+	return nil
+}
+
+// rewrite points the given package at the given mirror, remembering the
+// original package reference.
+func (r *Reconciler) rewrite(ctx context.Context, p pkgv1.Package, mirror string) error {
+	// Patching rather than updating preserves the fields we do not know.
+	orig := p.DeepCopyObject().(client.Object)
+	a := p.GetAnnotations()
+	if a == nil {
+		a = map[string]string{}
+	}
+	if _, ok := a[AnnotationKeyOriginalPackage]; !ok {
+		a[AnnotationKeyOriginalPackage] = p.GetPackageSpec().Package
+	}
+	p.SetAnnotations(a)
+	p.GetPackageSpec().Package = mirror
+	return errors.Wrapf(r.client.Patch(ctx, p, client.MergeFrom(orig)), errFmtPatch, p.GetObjectKind().GroupVersionKind().Kind, p.GetName())
+}
+
+// listPackages returns all packages. Their kinds are set.
+func (r *Reconciler) listPackages(ctx context.Context) ([]pkgv1.Package, error) {
+	var pkgs []pkgv1.Package
+
+	pl := &pkgv1.ProviderList{}
+	if err := r.client.List(ctx, pl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "providers")
+	}
+	for i := range pl.Items {
+		pl.Items[i].SetGroupVersionKind(pkgv1.ProviderGroupVersionKind)
+		pkgs = append(pkgs, &pl.Items[i])
+	}
+	cl := &pkgv1.ConfigurationList{}
+	if err := r.client.List(ctx, cl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "configurations")
+	}
+	for i := range cl.Items {
+		cl.Items[i].SetGroupVersionKind(pkgv1.ConfigurationGroupVersionKind)
+		pkgs = append(pkgs, &cl.Items[i])
+	}
+	fl := &pkgv1.FunctionList{}
+	if err := r.client.List(ctx, fl); err != nil {
+		return nil, errors.Wrapf(err, errFmtList, "functions")
+	}
+	for i := range fl.Items {
+		fl.Items[i].SetGroupVersionKind(pkgv1.FunctionGroupVersionKind)
+		pkgs = append(pkgs, &fl.Items[i])
+	}
+	return pkgs, nil
+}
+
+// writeStatus writes the mapping of the packages to the ConfigMap if it
+// changed.
+func (r *Reconciler) writeStatus(ctx context.Context, cm *corev1.ConfigMap, statuses []PackageStatus, summary string) error {
+	b, err := yaml.Marshal(statuses)
+	if err != nil {
+		return errors.Wrap(err, errMarshalStatus)
+	}
+	if cm.Data[KeyStatus] == string(b) && cm.Data[KeySummary] == summary {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[KeyStatus], cm.Data[KeySummary] = string(b), summary
+	return errors.Wrap(r.client.Update(ctx, cm), errUpdateConfigMap)
+}
+
+// contains returns true if the given status is already recorded in the
+// given serialized statuses.
+func contains(data string, s PackageStatus) bool {
+	var statuses []PackageStatus
+	if err := yaml.Unmarshal([]byte(data), &statuses); err != nil {
+		return false
+	}
+	for _, cur := range statuses {
+		if cur == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirror
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+func TestParseConfig(t *testing.T) {
+	type want struct {
+		cfg   Config
+		rules []Rule
+		err   error
+	}
+	cases := map[string]struct {
+		data string
+		want want
+	}{
+		"Defaults": {
+			data: `mirrors: ["xpkg.upbound.io/* -> registry.corp/*", "xpkg.upbound.io/crossplane-contrib/ -> registry.corp/contrib/"]`,
+			want: want{
+				cfg:   Config{Mode: ModeImageConfig, Mirrors: []string{"xpkg.upbound.io/* -> registry.corp/*", "xpkg.upbound.io/crossplane-contrib/ -> registry.corp/contrib/"}},
+				rules: []Rule{{From: "xpkg.upbound.io/", To: "registry.corp/"}, {From: "xpkg.upbound.io/crossplane-contrib/", To: "registry.corp/contrib/"}},
+			},
+		},
+		"InvalidMode": {
+			data: "mode: Copy",
+			want: want{err: errors.Errorf(errFmtInvalidMode, "Copy")},
+		},
+		"InvalidRule": {
+			data: `mirrors: ["xpkg.upbound.io/*"]`,
+			want: want{err: errors.Errorf(errFmtInvalidRule, "xpkg.upbound.io/*")},
+		},
+		"InvalidWildcard": {
+			data: `mirrors: ["xpkg.*.io/ -> registry.corp/"]`,
+			want: want{err: errors.Errorf(errFmtWildcard, "xpkg.*.io/ -> registry.corp/")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, rules, err := ParseConfig([]byte(tc.data))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("ParseConfig(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.cfg, cfg); diff != "" {
+				t.Errorf("ParseConfig(...): -want config, +got config:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.rules, rules); diff != "" {
+				t.Errorf("ParseConfig(...): -want rules, +got rules:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMap(t *testing.T) {
+	rules := []Rule{{From: "xpkg.upbound.io/", To: "registry.corp/"}, {From: "xpkg.upbound.io/crossplane-contrib/", To: "registry.corp/contrib/"}}
+	type want struct {
+		pkg string
+		ok  bool
+	}
+	cases := map[string]struct {
+		pkg  string
+		want want
+	}{
+		"Prefix": {
+			pkg:  "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0",
+			want: want{pkg: "registry.corp/upbound/provider-aws-s3:v1.1.0", ok: true},
+		},
+		"LongestPrefix": {
+			pkg:  "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.2.1",
+			want: want{pkg: "registry.corp/contrib/function-patch-and-transform:v0.2.1", ok: true},
+		},
+		"NoMatch": {
+			pkg:  "index.docker.io/crossplane/provider-helm:v0.15.0",
+			want: want{pkg: "index.docker.io/crossplane/provider-helm:v0.15.0"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			pkg, ok := Map(rules, tc.pkg)
+			if diff := cmp.Diff(tc.want, want{pkg: pkg, ok: ok}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("Map(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	aws := "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0"
+	helm := "index.docker.io/crossplane/provider-helm:v0.15.0"
+
+	// kube returns a client with the given mirror config and Providers
+	// installed from the given packages.
+	kube := func(config string, pkgs ...string) *test.MockClient {
+		return &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+				obj.(*corev1.ConfigMap).Data = map[string]string{KeyMirrors: config}
+				return nil
+			},
+			MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+				if l, ok := list.(*pkgv1.ProviderList); ok {
+					for _, p := range pkgs {
+						pr := pkgv1.Provider{ObjectMeta: metav1.ObjectMeta{Name: p}}
+						pr.Spec.Package = p
+						l.Items = append(l.Items, pr)
+					}
+				}
+				return nil
+			},
+		}
+	}
+
+	type want struct {
+		err      error
+		statuses []PackageStatus
+		applied  []string
+		patched  []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   *test.MockClient
+		want   want
+	}{
+		"ApplyError": {
+			reason: "We should return an error if an ImageConfig cannot be applied.",
+			kube: func() *test.MockClient {
+				k := kube(`mirrors: ["xpkg.upbound.io/* -> registry.corp/*"]`, aws)
+				k.MockPatch = test.NewMockPatchFn(errBoom)
+				return k
+			}(),
+			want: want{err: errors.Wrapf(errBoom, errFmtApply, ImageConfigs([]Rule{{From: "xpkg.upbound.io/", To: "registry.corp/"}}, "")[0].GetName())},
+		},
+		"ImageConfig": {
+			reason: "We should maintain an ImageConfig per mirror and report packages that cannot be mapped.",
+			kube:   kube(`mirrors: ["xpkg.upbound.io/* -> registry.corp/*"]`, aws, helm),
+			want: want{
+				applied: []string{"ImageConfig"},
+				statuses: []PackageStatus{
+					{Kind: "Provider", Name: aws, Package: aws, State: StateMapped, Mirror: "registry.corp/upbound/provider-aws-s3:v1.1.0"},
+					{Kind: "Provider", Name: helm, Package: helm, State: StateUnmapped},
+				},
+			},
+		},
+		"ImageConfigNotSupported": {
+			reason: "We should still report the mapping if ImageConfigs are not supported.",
+			kube: func() *test.MockClient {
+				k := kube(`mirrors: ["xpkg.upbound.io/* -> registry.corp/*"]`, aws)
+				k.MockPatch = test.NewMockPatchFn(&kmeta.NoKindMatchError{GroupKind: ImageConfigGroupVersionKind.GroupKind()})
+				return k
+			}(),
+			want: want{
+				statuses: []PackageStatus{{Kind: "Provider", Name: aws, Package: aws, State: StateMapped, Mirror: "registry.corp/upbound/provider-aws-s3:v1.1.0"}},
+			},
+		},
+		"Rewrite": {
+			reason: "We should rewrite the references of packages that match a mirror in the Rewrite mode.",
+			kube:   kube("mode: Rewrite\nmirrors: [\"xpkg.upbound.io/* -> registry.corp/*\"]", aws, "registry.corp/upbound/provider-gcp:v1.0.0"),
+			want: want{
+				patched: []string{"registry.corp/upbound/provider-aws-s3:v1.1.0"},
+				statuses: []PackageStatus{
+					{Kind: "Provider", Name: aws, Package: "registry.corp/upbound/provider-aws-s3:v1.1.0", State: StateRewritten, Mirror: "registry.corp/upbound/provider-aws-s3:v1.1.0"},
+					{Kind: "Provider", Name: "registry.corp/upbound/provider-gcp:v1.0.0", Package: "registry.corp/upbound/provider-gcp:v1.0.0", State: StateMirrored},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var statuses []PackageStatus
+			var applied, patched []string
+			if tc.kube.MockPatch == nil {
+				tc.kube.MockPatch = func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+					switch o := obj.(type) {
+					case *unstructured.Unstructured:
+						applied = append(applied, o.GetKind())
+					case *pkgv1.Provider:
+						patched = append(patched, o.Spec.Package)
+						if o.GetAnnotations()[AnnotationKeyOriginalPackage] == "" {
+							t.Errorf("Patch(...): rewritten package is not annotated with its original package")
+						}
+					}
+					return nil
+				}
+			}
+			tc.kube.MockUpdate = func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+				if err := yaml.Unmarshal([]byte(obj.(*corev1.ConfigMap).Data[KeyStatus]), &statuses); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			}
+			r := NewReconciler(&fake.Manager{Client: tc.kube})
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameRegistryMirror, Namespace: "upbound-system"}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.statuses, statuses); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want statuses, +got statuses:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.applied, applied); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want applied, +got applied:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patched, patched); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want patched, +got patched:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
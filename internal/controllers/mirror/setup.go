@@ -0,0 +1,60 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirror
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+// Setup adds the controller that points Crossplane packages at the mirrors
+// listed in the registry mirror ConfigMap in the given namespace. The
+// ImageConfigs it maintains are recorded in the given inventory.
+func Setup(mgr ctrl.Manager, l logging.Logger, namespace string, inv inventory.Recorder) error {
+	name := "registry-mirror"
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithInventory(inv),
+	)
+
+	// Packages are cluster scoped, so changes to them are mapped to the
+	// ConfigMap in our namespace.
+	toConfigMap := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameRegistryMirror, Namespace: namespace}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNameRegistryMirror)))).
+		Watches(&source.Kind{Type: &pkgv1.Provider{}}, toConfigMap).
+		Watches(&source.Kind{Type: &pkgv1.Configuration{}}, toConfigMap).
+		Watches(&source.Kind{Type: &pkgv1.Function{}}, toConfigMap).
+		Complete(r)
+}
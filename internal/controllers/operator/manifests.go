@@ -0,0 +1,269 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/apis/operator/v1alpha1"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+//go:embed manifests/*.yaml
+var manifests embed.FS
+
+// Components of Universal Crossplane.
+const (
+	ComponentCrossplane  = meta.DeploymentNameCrossplane
+	ComponentRBACManager = meta.DeploymentNameRBACManager
+)
+
+// DefaultRepository is the repository of the Crossplane image if the
+// UniversalCrossplane does not specify one.
+const DefaultRepository = "xpkg.upbound.io/upbound/crossplane"
+
+const (
+	errFmtReadManifest   = "cannot read manifest %s"
+	errFmtRenderManifest = "cannot render manifest %s"
+	errFmtParseManifest  = "cannot parse manifest %s"
+)
+
+// A manifest of a component. Its object is removed if it is not enabled.
+type manifest struct {
+	file      string
+	component string
+	enabled   func(p Parameters) bool
+}
+
+func always(Parameters) bool { return true }
+
+func webhooks(p Parameters) bool { return p.Webhooks }
+
+func rbacManager(p Parameters) bool { return p.RBACManager.Deploy }
+
+func aggregatedRoles(p Parameters) bool {
+	return p.RBACManager.Deploy && !p.RBACManager.SkipAggregatedClusterRoles
+}
+
+// Manifests in the order they are applied. The Deployments come last in their
+// component so that everything they depend on exists when they start.
+var files = []manifest{ //nolint:gochecknoglobals // We treat this as a constant.
+	{file: "manifests/crossplane-serviceaccount.yaml", component: ComponentCrossplane, enabled: always},
+	{file: "manifests/crossplane-clusterrole.yaml", component: ComponentCrossplane, enabled: always},
+	{file: "manifests/crossplane-system-clusterrole.yaml", component: ComponentCrossplane, enabled: always},
+	{file: "manifests/crossplane-clusterrolebinding.yaml", component: ComponentCrossplane, enabled: always},
+	{file: "manifests/crossplane-root-ca-secret.yaml", component: ComponentCrossplane, enabled: always},
+	{file: "manifests/crossplane-tls-server-secret.yaml", component: ComponentCrossplane, enabled: always},
+	{file: "manifests/crossplane-tls-client-secret.yaml", component: ComponentCrossplane, enabled: always},
+	{file: "manifests/crossplane-service.yaml", component: ComponentCrossplane, enabled: webhooks},
+	{file: "manifests/crossplane-deployment.yaml", component: ComponentCrossplane, enabled: always},
+	{file: "manifests/rbac-manager-serviceaccount.yaml", component: ComponentRBACManager, enabled: rbacManager},
+	{file: "manifests/rbac-manager-clusterrole.yaml", component: ComponentRBACManager, enabled: rbacManager},
+	{file: "manifests/rbac-manager-clusterrolebinding.yaml", component: ComponentRBACManager, enabled: rbacManager},
+	{file: "manifests/rbac-manager-allowed-provider-permissions.yaml", component: ComponentRBACManager, enabled: rbacManager},
+	{file: "manifests/rbac-manager-admin-clusterrole.yaml", component: ComponentRBACManager, enabled: rbacManager},
+	{file: "manifests/rbac-manager-admin-clusterrolebinding.yaml", component: ComponentRBACManager, enabled: rbacManager},
+	{file: "manifests/rbac-manager-edit-clusterrole.yaml", component: ComponentRBACManager, enabled: rbacManager},
+	{file: "manifests/rbac-manager-view-clusterrole.yaml", component: ComponentRBACManager, enabled: rbacManager},
+	{file: "manifests/rbac-manager-browse-clusterrole.yaml", component: ComponentRBACManager, enabled: rbacManager},
+	{file: "manifests/rbac-manager-aggregate-to-admin-clusterrole.yaml", component: ComponentRBACManager, enabled: aggregatedRoles},
+	{file: "manifests/rbac-manager-aggregate-to-edit-clusterrole.yaml", component: ComponentRBACManager, enabled: aggregatedRoles},
+	{file: "manifests/rbac-manager-aggregate-to-view-clusterrole.yaml", component: ComponentRBACManager, enabled: aggregatedRoles},
+	{file: "manifests/rbac-manager-aggregate-to-browse-clusterrole.yaml", component: ComponentRBACManager, enabled: aggregatedRoles},
+	{file: "manifests/rbac-manager-deployment.yaml", component: ComponentRBACManager, enabled: rbacManager},
+}
+
+// DeploymentParameters are the parameters of the Crossplane or RBAC manager
+// Deployment.
+type DeploymentParameters struct {
+	Deploy                     bool
+	SkipAggregatedClusterRoles bool
+	Replicas                   int32
+	LeaderElection             bool
+	Args                       []string
+	Env                        map[string]string
+	Resources                  corev1.ResourceRequirements
+	NodeSelector               map[string]string
+	Tolerations                []corev1.Toleration
+	Affinity                   *corev1.Affinity
+}
+
+// CacheParameters are the parameters of the package cache.
+type CacheParameters struct {
+	Medium    corev1.StorageMedium
+	SizeLimit string
+	PVC       string
+}
+
+// Parameters of the embedded manifests.
+type Parameters struct {
+	// Namespace Universal Crossplane is installed in.
+	Namespace string
+	// Version of Universal Crossplane, i.e. the tag of Image.
+	Version           string
+	Image             string
+	PullPolicy        corev1.PullPolicy
+	ImagePullSecrets  []string
+	PriorityClassName string
+	Webhooks          bool
+	Metrics           bool
+	PackageCache      CacheParameters
+	Crossplane        DeploymentParameters
+	RBACManager       DeploymentParameters
+}
+
+// NewParameters returns the parameters of the given spec, defaulted like the
+// values of the Helm chart. Version is used if the spec has no image tag.
+func NewParameters(namespace, version string, spec v1alpha1.UniversalCrossplaneSpec) Parameters {
+	p := Parameters{
+		Namespace:         namespace,
+		Version:           orDefault(spec.Image.Tag, version),
+		PullPolicy:        corev1.PullPolicy(orDefault(string(spec.Image.PullPolicy), string(corev1.PullIfNotPresent))),
+		ImagePullSecrets:  spec.ImagePullSecrets,
+		PriorityClassName: spec.PriorityClassName,
+		Webhooks:          spec.Webhooks.Enabled == nil || *spec.Webhooks.Enabled,
+		Metrics:           spec.Metrics.Enabled,
+		PackageCache: CacheParameters{
+			Medium:    spec.PackageCache.Medium,
+			SizeLimit: "20Mi",
+			PVC:       spec.PackageCache.PVC,
+		},
+		Crossplane: DeploymentParameters{
+			Deploy:         true,
+			Replicas:       1,
+			LeaderElection: spec.LeaderElection == nil || *spec.LeaderElection,
+			Args:           spec.Args,
+			Env:            envVars(spec.ExtraEnvVarsCrossplane),
+			Resources:      resources("500m", "1024Mi", "100m", "256Mi"),
+			NodeSelector:   spec.NodeSelector,
+			Tolerations:    spec.Tolerations,
+			Affinity:       spec.Affinity,
+		},
+		RBACManager: DeploymentParameters{
+			Deploy:                     spec.RBACManager.Deploy == nil || *spec.RBACManager.Deploy,
+			SkipAggregatedClusterRoles: spec.RBACManager.SkipAggregatedClusterRoles,
+			Replicas:                   1,
+			LeaderElection:             spec.RBACManager.LeaderElection == nil || *spec.RBACManager.LeaderElection,
+			Args:                       spec.RBACManager.Args,
+			Env:                        envVars(spec.ExtraEnvVarsRBACManager),
+			Resources:                  resources("100m", "512Mi", "100m", "256Mi"),
+			NodeSelector:               spec.RBACManager.NodeSelector,
+			Tolerations:                spec.RBACManager.Tolerations,
+			Affinity:                   spec.RBACManager.Affinity,
+		},
+	}
+	p.Image = orDefault(spec.Image.Repository, DefaultRepository) + ":" + p.Version
+	if spec.PackageCache.SizeLimit != nil {
+		p.PackageCache.SizeLimit = spec.PackageCache.SizeLimit.String()
+	}
+	if spec.Replicas != nil {
+		p.Crossplane.Replicas = *spec.Replicas
+	}
+	if spec.RBACManager.Replicas != nil {
+		p.RBACManager.Replicas = *spec.RBACManager.Replicas
+	}
+	if spec.ResourcesCrossplane != nil {
+		p.Crossplane.Resources = *spec.ResourcesCrossplane
+	}
+	if spec.ResourcesRBACManager != nil {
+		p.RBACManager.Resources = *spec.ResourcesRBACManager
+	}
+	return p
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// envVars replaces any "." in the names of the given environment variables
+// with "_", like the Helm chart does.
+func envVars(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[strings.ReplaceAll(k, ".", "_")] = v
+	}
+	return out
+}
+
+func resources(cpuLimit, memoryLimit, cpuRequest, memoryRequest string) corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpuLimit),
+			corev1.ResourceMemory: resource.MustParse(memoryLimit),
+		},
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpuRequest),
+			corev1.ResourceMemory: resource.MustParse(memoryRequest),
+		},
+	}
+}
+
+// An Object rendered from the embedded manifests.
+type Object struct {
+	*unstructured.Unstructured
+
+	// Component the object belongs to.
+	Component string
+	// Enabled is false if the object should not exist with the parameters it
+	// was rendered with.
+	Enabled bool
+}
+
+// Render returns the objects of Universal Crossplane with the given
+// parameters, in the order they should be applied.
+func Render(p Parameters) ([]Object, error) {
+	funcs := template.FuncMap{
+		// JSON is valid YAML, so structured values are rendered inline.
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+	objs := make([]Object, 0, len(files))
+	for _, f := range files {
+		raw, err := manifests.ReadFile(f.file)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtReadManifest, f.file)
+		}
+		t, err := template.New(f.file).Option("missingkey=error").Funcs(funcs).Parse(string(raw))
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtParseManifest, f.file)
+		}
+		b := &bytes.Buffer{}
+		if err := t.Execute(b, p); err != nil {
+			return nil, errors.Wrapf(err, errFmtRenderManifest, f.file)
+		}
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(b.Bytes(), &u.Object); err != nil {
+			return nil, errors.Wrapf(err, errFmtParseManifest, f.file)
+		}
+		objs = append(objs, Object{Unstructured: u, Component: f.component, Enabled: f.enabled(p)})
+	}
+	return objs, nil
+}
@@ -0,0 +1,391 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package operator contains the controller of the operator mode of the
+// bootstrapper, which installs and upgrades Universal Crossplane as a
+// UniversalCrossplane specifies.
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/apis/operator/v1alpha1"
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/kube"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/metrics"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+const (
+	// LabelKeyVersion is the label that holds the version of a component.
+	LabelKeyVersion = "app.kubernetes.io/version"
+
+	reconcileTimeout = 1 * time.Minute
+	syncPeriod       = 10 * time.Minute
+	pollPeriod       = 30 * time.Second
+
+	errGet            = "cannot get universalcrossplane"
+	errUpdateStatus   = "cannot update universalcrossplane status"
+	errNoVersion      = "no image tag is specified and the bootstrapper has no bundled Crossplane version"
+	errFmtVersion     = "cannot parse version %q"
+	errFmtUpgrade     = "cannot upgrade from %s to %s"
+	errRender         = "cannot render universal crossplane manifests"
+	errFmtApply       = "cannot apply %s %s"
+	errFmtGet         = "cannot get %s %s"
+	errFmtDelete      = "cannot delete %s %s"
+	errFmtConvert     = "cannot convert deployment %s"
+	errFmtNotReadyYet = "%s is not ready: %s"
+	errFmtName        = "only the UniversalCrossplane named %s is reconciled"
+
+	errFmtRollingOut = "rolling out version %s"
+
+	reasonInstalled          event.Reason = "Installed"
+	reasonUpgrading          event.Reason = "Upgrading"
+	reasonUpgraded           event.Reason = "Upgraded"
+	reasonUpgradeUnsupported event.Reason = "UpgradeNotSupported"
+	reasonApplyFailed        event.Reason = "ApplyFailed"
+	reasonConflicting        event.Reason = "Conflicting"
+)
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithInventory specifies how the Reconciler should record the objects it
+// applies.
+func WithInventory(inv inventory.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.inv = inv
+	}
+}
+
+// WithAPIReader specifies how the Reconciler should read the objects it
+// removes.
+func WithAPIReader(c client.Reader) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.reader = c
+	}
+}
+
+// WithVersion specifies the version of Universal Crossplane that is installed
+// if a UniversalCrossplane does not specify an image tag.
+func WithVersion(v string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.version = v
+	}
+}
+
+// Reconciler installs and upgrades the components of Universal Crossplane as
+// a UniversalCrossplane specifies.
+type Reconciler struct {
+	client client.Client
+	reader client.Reader
+	log    logging.Logger
+	record event.Recorder
+	inv    inventory.Recorder
+
+	namespace string
+	version   string
+}
+
+// NewReconciler returns a new reconciler that installs Universal Crossplane
+// in the given namespace.
+func NewReconciler(mgr manager.Manager, namespace string, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client:    mgr.GetClient(),
+		reader:    mgr.GetClient(),
+		log:       logging.NewNopLogger(),
+		record:    event.NewNopRecorder(),
+		inv:       inventory.NopRecorder{},
+		namespace: namespace,
+		version:   version.CrossplaneVersion,
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile renders the manifests of Universal Crossplane with the spec of a
+// UniversalCrossplane, applies them with server-side apply and reports the
+// readiness and version of each component in its status.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "operator.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	xp := &v1alpha1.UniversalCrossplane{}
+	if err := r.client.Get(ctx, req.NamespacedName, xp); err != nil {
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGet)
+	}
+	if xp.GetDeletionTimestamp() != nil {
+		// The objects we applied are owned by the UniversalCrossplane, so
+		// they are garbage collected.
+		return reconcile.Result{}, nil
+	}
+	prev := xp.Status.DeepCopy()
+	xp.Status.ObservedGeneration = xp.GetGeneration()
+
+	if xp.GetName() != v1alpha1.UniversalCrossplaneName {
+		// Universal Crossplane is installed once per cluster, so any other
+		// UniversalCrossplane would conflict with it.
+		err := errors.Errorf(errFmtName, v1alpha1.UniversalCrossplaneName)
+		c := xpv1.ReconcileError(err)
+		if !xp.Status.GetCondition(xpv1.TypeSynced).Equal(c) {
+			r.record.Event(xp, event.Warning(reasonConflicting, err))
+		}
+		xp.Status.SetConditions(c)
+		return reconcile.Result{}, r.updateStatus(ctx, xp, prev)
+	}
+
+	p := NewParameters(r.namespace, r.version, xp.Spec)
+	want, err := r.checkVersion(xp.Status.Version, p.Version)
+	if err != nil {
+		// The spec has to change before we can make progress, which we will
+		// be notified of.
+		log.Info("Not applying Universal Crossplane", "error", err.Error())
+		xp.Status.SetConditions(xpv1.ReconcileError(err))
+		if xp.Status.Version != "" && xp.Status.Version != p.Version {
+			r.record.Event(xp, event.Warning(reasonUpgradeUnsupported, err))
+		}
+		return reconcile.Result{}, r.updateStatus(ctx, xp, prev)
+	}
+	upgrading := xp.Status.Version != "" && xp.Status.Version != want.String()
+	if upgrading && xp.Status.GetCondition(v1alpha1.TypeUpgrading).Status != corev1.ConditionTrue {
+		r.record.Event(xp, event.Normal(reasonUpgrading, fmt.Sprintf("Upgrading Universal Crossplane from %s to %s", xp.Status.Version, p.Version)))
+		log.Info("Upgrading Universal Crossplane", "from", xp.Status.Version, "to", p.Version)
+	}
+
+	objs, err := Render(p)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errRender)
+	}
+	if err := r.apply(ctx, xp, objs); err != nil {
+		r.record.Event(xp, event.Warning(reasonApplyFailed, err))
+		xp.Status.SetConditions(xpv1.ReconcileError(err))
+		if uerr := r.updateStatus(ctx, xp, prev); uerr != nil {
+			return reconcile.Result{}, uerr
+		}
+		return reconcile.Result{}, err
+	}
+
+	ready, err := r.observe(xp, objs, p.Version)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	switch {
+	case ready && xp.Status.Version == "":
+		xp.Status.Version = p.Version
+		r.record.Event(xp, event.Normal(reasonInstalled, fmt.Sprintf("Installed Universal Crossplane %s", p.Version)))
+		log.Info("Installed Universal Crossplane", "version", p.Version)
+	case ready && upgrading:
+		r.record.Event(xp, event.Normal(reasonUpgraded, fmt.Sprintf("Upgraded Universal Crossplane from %s to %s", xp.Status.Version, p.Version)))
+		log.Info("Upgraded Universal Crossplane", "from", xp.Status.Version, "to", p.Version)
+		xp.Status.Version = p.Version
+	}
+
+	xp.Status.SetConditions(xpv1.ReconcileSuccess(), upgradingCondition(xp.Status.Version, p.Version, ready))
+	if ready {
+		xp.Status.SetConditions(xpv1.Available())
+	} else {
+		xp.Status.SetConditions(xpv1.Unavailable().WithMessage(notReady(xp.Status.Components)))
+	}
+	if err := r.updateStatus(ctx, xp, prev); err != nil {
+		return reconcile.Result{}, err
+	}
+	if !ready {
+		return reconcile.Result{RequeueAfter: pollPeriod}, nil
+	}
+	return reconcile.Result{RequeueAfter: syncPeriod}, nil
+}
+
+// checkVersion returns the version to install, or an error if it is not valid
+// or cannot be upgraded to from the installed version.
+func (r *Reconciler) checkVersion(installed, want string) (version.Semver, error) {
+	if want == "" {
+		return version.Semver{}, errors.New(errNoVersion)
+	}
+	to, err := version.Parse(want)
+	if err != nil {
+		return version.Semver{}, errors.Wrapf(err, errFmtVersion, want)
+	}
+	if installed == "" || installed == want {
+		return to, nil
+	}
+	from, err := version.Parse(installed)
+	if err != nil {
+		return version.Semver{}, errors.Wrapf(err, errFmtVersion, installed)
+	}
+	return to, errors.Wrapf(version.CheckUpgrade(from, to), errFmtUpgrade, installed, want)
+}
+
+// apply applies the enabled objects, controlled by the UniversalCrossplane,
+// and deletes the disabled objects we created.
+func (r *Reconciler) apply(ctx context.Context, xp *v1alpha1.UniversalCrossplane, objs []Object) error {
+	ref := metav1.NewControllerRef(xp, v1alpha1.UniversalCrossplaneGroupVersionKind)
+	refs := make([]inventory.Ref, 0, len(objs))
+	for _, o := range objs {
+		if o.Enabled {
+			refs = append(refs, inventory.RefOf(o))
+		}
+	}
+	if err := r.inv.Record(ctx, refs); err != nil {
+		return err
+	}
+	for _, o := range objs {
+		if !o.Enabled {
+			continue
+		}
+		o.SetOwnerReferences([]metav1.OwnerReference{*ref})
+		if err := r.client.Patch(ctx, o.Unstructured, client.Apply, client.FieldOwner(meta.FieldOwner), client.ForceOwnership); err != nil {
+			return errors.Wrapf(err, errFmtApply, o.GetKind(), o.GetName())
+		}
+	}
+	for i := len(objs) - 1; i >= 0; i-- {
+		want := objs[i]
+		if want.Enabled {
+			continue
+		}
+		o := &unstructured.Unstructured{}
+		o.SetGroupVersionKind(want.GroupVersionKind())
+		if err := r.reader.Get(ctx, types.NamespacedName{Name: want.GetName(), Namespace: want.GetNamespace()}, o); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, errFmtGet, want.GetKind(), want.GetName())
+		}
+		if o.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy {
+			// Not created by us, so not ours to delete.
+			continue
+		}
+		if err := r.client.Delete(ctx, o); client.IgnoreNotFound(err) != nil {
+			return errors.Wrapf(err, errFmtDelete, want.GetKind(), want.GetName())
+		}
+	}
+	return nil
+}
+
+// observe sets the status of the components from their applied Deployments,
+// which were updated with the response of the API server. A component runs
+// the given version once its Deployment is rolled out, and the version it
+// ran before until then. It returns true if all components are ready and run
+// the given version.
+func (r *Reconciler) observe(xp *v1alpha1.UniversalCrossplane, objs []Object, want string) (bool, error) {
+	running := make(map[string]string, len(xp.Status.Components))
+	for _, cs := range xp.Status.Components {
+		running[cs.Name] = cs.Version
+	}
+	ready := true
+	xp.Status.Components = nil
+	for _, o := range objs {
+		if o.GetKind() != "Deployment" {
+			continue
+		}
+		if !o.Enabled {
+			metrics.DeleteComponentHealthy(o.Component)
+			continue
+		}
+		d := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, d); err != nil {
+			return false, errors.Wrapf(err, errFmtConvert, o.GetName())
+		}
+		healthy, reason := kube.Healthy(d)
+		metrics.SetComponentHealthy(o.Component, healthy)
+		cs := v1alpha1.ComponentStatus{Name: o.Component, Version: running[o.Component], Ready: healthy}
+		switch {
+		case !kube.RolledOut(d):
+			cs.Ready = false
+			cs.Message = fmt.Sprintf(errFmtRollingOut, want)
+		case !healthy:
+			cs.Version = want
+			cs.Message = reason
+		default:
+			cs.Version = want
+		}
+		xp.Status.Components = append(xp.Status.Components, cs)
+		ready = ready && cs.Ready
+	}
+	return ready, nil
+}
+
+// upgradingCondition returns whether the components are upgraded from the
+// installed to the wanted version.
+func upgradingCondition(installed, want string, ready bool) xpv1.Condition {
+	c := xpv1.Condition{Type: v1alpha1.TypeUpgrading, Status: corev1.ConditionFalse, Reason: v1alpha1.ReasonUpToDate, LastTransitionTime: metav1.Now()}
+	if installed != "" && installed != want && !ready {
+		c.Status, c.Reason = corev1.ConditionTrue, v1alpha1.ReasonUpgrading
+		c.Message = fmt.Sprintf("Upgrading from %s to %s", installed, want)
+	}
+	return c
+}
+
+// notReady explains which components are not ready.
+func notReady(cs []v1alpha1.ComponentStatus) string {
+	msgs := make([]string, 0, len(cs))
+	for _, c := range cs {
+		if !c.Ready {
+			msgs = append(msgs, fmt.Sprintf(errFmtNotReadyYet, c.Name, c.Message))
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// updateStatus updates the status of the UniversalCrossplane if it changed.
+func (r *Reconciler) updateStatus(ctx context.Context, xp *v1alpha1.UniversalCrossplane, prev *v1alpha1.UniversalCrossplaneStatus) error {
+	if equality.Semantic.DeepEqual(prev, &xp.Status) {
+		return nil
+	}
+	return errors.Wrap(r.client.Status().Update(ctx, xp), errUpdateStatus)
+}
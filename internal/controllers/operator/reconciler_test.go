@@ -0,0 +1,399 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/apis/operator/v1alpha1"
+	"github.com/upbound/universal-crossplane/internal/eventtest"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+const (
+	namespace = "upbound-system"
+	bundled   = "v1.20.4-up.1"
+)
+
+func TestNewParameters(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		spec   v1alpha1.UniversalCrossplaneSpec
+		check  func(p Parameters) string
+	}{
+		"Defaults": {
+			reason: "An empty spec should install the bundled version with the defaults of the Helm chart.",
+			check: func(p Parameters) string {
+				want := Parameters{Image: DefaultRepository + ":" + bundled, Version: bundled, Webhooks: true, PackageCache: CacheParameters{SizeLimit: "20Mi"}}
+				got := Parameters{Image: p.Image, Version: p.Version, Webhooks: p.Webhooks, PackageCache: p.PackageCache}
+				if p.RBACManager.Deploy != true || p.Crossplane.Replicas != 1 || !p.Crossplane.LeaderElection {
+					return "RBAC manager, replicas or leader election not defaulted"
+				}
+				return cmp.Diff(want, got)
+			},
+		},
+		"Overrides": {
+			reason: "The spec should override the defaults.",
+			spec: v1alpha1.UniversalCrossplaneSpec{
+				Replicas:               pointer.Int32(2),
+				Image:                  v1alpha1.ImageSpec{Repository: "registry.example.org/crossplane", Tag: "v1.20.5-up.1"},
+				Webhooks:               v1alpha1.WebhooksSpec{Enabled: pointer.Bool(false)},
+				PackageCache:           v1alpha1.CacheSpec{SizeLimit: resource.NewQuantity(1<<30, resource.BinarySI)},
+				ExtraEnvVarsCrossplane: map[string]string{"sample.key": "value"},
+				RBACManager:            v1alpha1.RBACManagerSpec{Deploy: pointer.Bool(false)},
+			},
+			check: func(p Parameters) string {
+				want := Parameters{Image: "registry.example.org/crossplane:v1.20.5-up.1", Version: "v1.20.5-up.1", PackageCache: CacheParameters{SizeLimit: "1Gi"}}
+				got := Parameters{Image: p.Image, Version: p.Version, Webhooks: p.Webhooks, PackageCache: p.PackageCache}
+				if p.RBACManager.Deploy || p.Crossplane.Replicas != 2 {
+					return "RBAC manager or replicas not overridden"
+				}
+				return cmp.Diff(want, got) + cmp.Diff(map[string]string{"sample_key": "value"}, p.Crossplane.Env)
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := tc.check(NewParameters(namespace, bundled, tc.spec)); diff != "" {
+				t.Errorf("\nReason: %s\nNewParameters(...): %s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		spec     v1alpha1.UniversalCrossplaneSpec
+		disabled []string
+	}{
+		"Defaults": {
+			reason: "All objects should be enabled by default.",
+		},
+		"NoWebhooks": {
+			reason: "The webhook Service should be disabled without webhooks.",
+			spec:   v1alpha1.UniversalCrossplaneSpec{Webhooks: v1alpha1.WebhooksSpec{Enabled: pointer.Bool(false)}},
+			disabled: []string{
+				"Service/crossplane-webhooks",
+			},
+		},
+		"SkipAggregatedClusterRoles": {
+			reason: "The aggregated ClusterRoles should be disabled if they are skipped.",
+			spec:   v1alpha1.UniversalCrossplaneSpec{RBACManager: v1alpha1.RBACManagerSpec{SkipAggregatedClusterRoles: true}},
+			disabled: []string{
+				"ClusterRole/crossplane:aggregate-to-admin",
+				"ClusterRole/crossplane:aggregate-to-edit",
+				"ClusterRole/crossplane:aggregate-to-view",
+				"ClusterRole/crossplane:aggregate-to-browse",
+			},
+		},
+		"NumericValues": {
+			reason: "Values that look like numbers should be rendered as strings.",
+			spec: v1alpha1.UniversalCrossplaneSpec{
+				Image:             v1alpha1.ImageSpec{Tag: "1.20"},
+				PriorityClassName: "100",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			version := orDefault(tc.spec.Image.Tag, bundled)
+			objs, err := Render(NewParameters(namespace, bundled, tc.spec))
+			if err != nil {
+				t.Fatalf("Render(...): %s", err)
+			}
+			var disabled []string
+			for _, o := range objs {
+				if o.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy {
+					t.Errorf("Render(...): %s %s is not labeled as managed by the bootstrapper", o.GetKind(), o.GetName())
+				}
+				if o.GetLabels()[LabelKeyVersion] != version {
+					t.Errorf("Render(...): %s %s is not labeled with the version %q", o.GetKind(), o.GetName(), version)
+				}
+				if o.GetKind() == "Deployment" {
+					pc, _, _ := unstructured.NestedString(o.Object, "spec", "template", "spec", "priorityClassName")
+					if pc != tc.spec.PriorityClassName {
+						t.Errorf("Render(...): Deployment %s has priority class %q, want %q", o.GetName(), pc, tc.spec.PriorityClassName)
+					}
+				}
+				if !o.Enabled {
+					disabled = append(disabled, o.GetKind()+"/"+o.GetName())
+				}
+			}
+			if diff := cmp.Diff(tc.disabled, disabled); diff != "" {
+				t.Errorf("\nReason: %s\nRender(...): -want disabled, +got disabled:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "")
+
+	// get returns a UniversalCrossplane with the given spec that installed
+	// the given version, and reports any other object as created by us.
+	get := func(installed string, spec v1alpha1.UniversalCrossplaneSpec) test.MockGetFn {
+		return func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			if xp, ok := obj.(*v1alpha1.UniversalCrossplane); ok {
+				xp.SetName(key.Name)
+				xp.Spec = spec
+				xp.Status.Version = installed
+				return nil
+			}
+			obj.SetName(key.Name)
+			obj.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+			return nil
+		}
+	}
+	// deployments reports the applied Deployments with the given generation
+	// and status.
+	deployments := func(generation int64, status map[string]any) test.MockPatchFn {
+		return func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+			if u := obj.(*unstructured.Unstructured); u.GetKind() == "Deployment" {
+				u.SetGeneration(generation)
+				u.Object["status"] = runtime.DeepCopyJSON(status)
+			}
+			return nil
+		}
+	}
+	// available reports the applied Deployments as rolled out and available.
+	available := deployments(1, map[string]any{"observedGeneration": int64(1), "replicas": int64(1), "updatedReplicas": int64(1), "availableReplicas": int64(1)})
+	// rollingOut reports the applied Deployments as available while their
+	// new spec is not observed yet.
+	rollingOut := deployments(2, map[string]any{"observedGeneration": int64(1), "replicas": int64(1), "updatedReplicas": int64(1), "availableReplicas": int64(1)})
+	// upgraded returns a UniversalCrossplane whose components run the given
+	// version.
+	upgraded := func(installed string) test.MockGetFn {
+		return func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+			if xp, ok := obj.(*v1alpha1.UniversalCrossplane); ok {
+				xp.Status.Components = []v1alpha1.ComponentStatus{{Name: ComponentCrossplane, Version: installed, Ready: true}, {Name: ComponentRBACManager, Version: installed, Ready: true}}
+			}
+			return get(installed, v1alpha1.UniversalCrossplaneSpec{})(ctx, key, obj)
+		}
+	}
+	noRBACManager := v1alpha1.UniversalCrossplaneSpec{RBACManager: v1alpha1.RBACManagerSpec{Deploy: pointer.Bool(false)}}
+
+	type want struct {
+		result  reconcile.Result
+		err     error
+		version string
+		running map[string]string
+		reason  xpv1.ConditionReason
+		events  []event.Reason
+		deleted []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   *test.MockClient
+		// name of the UniversalCrossplane, which is uxp if empty.
+		name string
+		// bundled is the version that is installed if the spec has no tag.
+		bundled string
+		want    want
+	}{
+		"GetError": {
+			reason: "We should return an error if the UniversalCrossplane cannot be fetched.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			want:   want{err: errors.Wrap(errBoom, errGet)},
+		},
+		"NotFound": {
+			reason: "We should do nothing if the UniversalCrossplane does not exist.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(notFound)},
+			want:   want{},
+		},
+		"Conflicting": {
+			reason:  "We should report an error in the status and not apply anything for a UniversalCrossplane with another name.",
+			name:    "other",
+			bundled: bundled,
+			kube:    &test.MockClient{MockGet: get("", v1alpha1.UniversalCrossplaneSpec{}), MockPatch: test.NewMockPatchFn(errBoom)},
+			want: want{
+				reason: xpv1.ReasonReconcileError,
+				events: []event.Reason{reasonConflicting},
+			},
+		},
+		"NoVersion": {
+			reason: "We should report an error in the status if no version is known.",
+			kube:   &test.MockClient{MockGet: get("", v1alpha1.UniversalCrossplaneSpec{})},
+			want:   want{reason: xpv1.ReasonReconcileError},
+		},
+		"UpgradeNotSupported": {
+			reason:  "We should report an error in the status and not apply anything if the upgrade is not supported.",
+			bundled: bundled,
+			kube:    &test.MockClient{MockGet: get("v1.18.0-up.1", v1alpha1.UniversalCrossplaneSpec{})},
+			want: want{
+				version: "v1.18.0-up.1",
+				reason:  xpv1.ReasonReconcileError,
+				events:  []event.Reason{reasonUpgradeUnsupported},
+			},
+		},
+		"ApplyError": {
+			reason:  "We should return an error and report it in the status if an object cannot be applied.",
+			bundled: bundled,
+			kube:    &test.MockClient{MockGet: get("", v1alpha1.UniversalCrossplaneSpec{}), MockPatch: test.NewMockPatchFn(errBoom)},
+			want: want{
+				err:    errors.Wrapf(errBoom, errFmtApply, "ServiceAccount", "crossplane"),
+				reason: xpv1.ReasonReconcileError,
+				events: []event.Reason{reasonApplyFailed},
+			},
+		},
+		"InstallNotReadyYet": {
+			reason:  "We should install and check again soon if the components are not ready yet.",
+			bundled: bundled,
+			kube:    &test.MockClient{MockGet: get("", v1alpha1.UniversalCrossplaneSpec{}), MockPatch: test.NewMockPatchFn(nil)},
+			want: want{
+				result: reconcile.Result{RequeueAfter: pollPeriod},
+				reason: xpv1.ReasonReconcileSuccess,
+			},
+		},
+		"Installed": {
+			reason:  "We should report the installed version once all components are ready.",
+			bundled: bundled,
+			kube:    &test.MockClient{MockGet: get("", v1alpha1.UniversalCrossplaneSpec{}), MockPatch: available},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: syncPeriod},
+				version: bundled,
+				reason:  xpv1.ReasonReconcileSuccess,
+				events:  []event.Reason{reasonInstalled},
+			},
+		},
+		"UpgradeRollingOut": {
+			reason:  "We should not report the upgrade while the components still roll out the new version.",
+			bundled: bundled,
+			kube:    &test.MockClient{MockGet: upgraded("v1.20.0-up.1"), MockPatch: rollingOut},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: pollPeriod},
+				version: "v1.20.0-up.1",
+				running: map[string]string{ComponentCrossplane: "v1.20.0-up.1", ComponentRBACManager: "v1.20.0-up.1"},
+				reason:  xpv1.ReasonReconcileSuccess,
+				events:  []event.Reason{reasonUpgrading},
+			},
+		},
+		"Upgraded": {
+			reason:  "We should report the upgrade once all components run the new version.",
+			bundled: bundled,
+			kube:    &test.MockClient{MockGet: upgraded("v1.20.0-up.1"), MockPatch: available},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: syncPeriod},
+				version: bundled,
+				running: map[string]string{ComponentCrossplane: bundled, ComponentRBACManager: bundled},
+				reason:  xpv1.ReasonReconcileSuccess,
+				events:  []event.Reason{reasonUpgrading, reasonUpgraded},
+			},
+		},
+		"RBACManagerDisabled": {
+			reason:  "We should remove the RBAC manager in reverse order if it is disabled.",
+			bundled: bundled,
+			kube:    &test.MockClient{MockGet: get(bundled, noRBACManager), MockPatch: available},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: syncPeriod},
+				version: bundled,
+				reason:  xpv1.ReasonReconcileSuccess,
+				deleted: []string{
+					"crossplane-rbac-manager",
+					"crossplane:aggregate-to-browse",
+					"crossplane:aggregate-to-view",
+					"crossplane:aggregate-to-edit",
+					"crossplane:aggregate-to-admin",
+					"crossplane-browse",
+					"crossplane-view",
+					"crossplane-edit",
+					"crossplane-admin",
+					"crossplane-admin",
+					"crossplane:allowed-provider-permissions",
+					"crossplane-rbac-manager",
+					"crossplane-rbac-manager",
+					"rbac-manager",
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var status *v1alpha1.UniversalCrossplaneStatus
+			var deleted []string
+			tc.kube.MockStatusUpdate = func(_ context.Context, obj client.Object, _ ...client.SubResourceUpdateOption) error {
+				status = obj.(*v1alpha1.UniversalCrossplane).Status.DeepCopy()
+				return nil
+			}
+			tc.kube.MockDelete = func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+				deleted = append(deleted, obj.GetName())
+				return nil
+			}
+			rec := &eventtest.Recorder{}
+			r := NewReconciler(&fake.Manager{Client: tc.kube}, namespace, WithRecorder(rec), WithVersion(tc.bundled))
+			name := tc.name
+			if name == "" {
+				name = v1alpha1.UniversalCrossplaneName
+			}
+			got, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.Reasons); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want deleted, +got deleted:\n%s", tc.reason, diff)
+			}
+			if status == nil {
+				if tc.want.reason != "" {
+					t.Errorf("\nReason: %s\nr.Reconcile(...): status was not updated", tc.reason)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want.version, status.Version); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want version, +got version:\n%s", tc.reason, diff)
+			}
+			if tc.want.running != nil {
+				running := map[string]string{}
+				for _, cs := range status.Components {
+					running[cs.Name] = cs.Version
+				}
+				if diff := cmp.Diff(tc.want.running, running); diff != "" {
+					t.Errorf("\nReason: %s\nr.Reconcile(...): -want component versions, +got component versions:\n%s", tc.reason, diff)
+				}
+			}
+			if diff := cmp.Diff(tc.want.reason, status.GetCondition(xpv1.TypeSynced).Reason); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want synced reason, +got synced reason:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/apis/operator/v1alpha1"
+	"github.com/upbound/universal-crossplane/internal/inventory"
+)
+
+// Setup adds the controller that installs Universal Crossplane in the given
+// namespace as UniversalCrossplanes specify, and records the applied objects
+// in the given inventory.
+func Setup(mgr ctrl.Manager, l logging.Logger, namespace string, inv inventory.Recorder) error {
+	name := "operator"
+	r := NewReconciler(mgr, namespace,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithInventory(inv),
+		WithAPIReader(mgr.GetAPIReader()),
+	)
+
+	// The Deployments are owned by the UniversalCrossplane, so their rollouts
+	// update its status.
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&v1alpha1.UniversalCrossplane{}).
+		Owns(&appsv1.Deployment{}).
+		Complete(r)
+}
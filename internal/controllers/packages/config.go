@@ -0,0 +1,117 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages
+
+import (
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+)
+
+// Keys of the packages ConfigMap.
+const (
+	// KeyPackages holds the desired packages as a Config.
+	KeyPackages = "packages.yaml"
+	// KeyStatus holds the progress of the packages as a list of
+	// PackageStatus, written by the controller.
+	KeyStatus = "status.yaml"
+	// KeySummary holds a human readable summary of the progress.
+	KeySummary = "summary"
+)
+
+const (
+	errParseConfig      = "cannot parse packages config"
+	errFmtInvalidPolicy = "invalid policy %q, must be create-only or enforce"
+	errFmtInvalidKind   = "invalid kind %q of package %q, must be Provider, Configuration or Function"
+	errFmtMissingField  = "package %d is missing %s"
+	errFmtDuplicate     = "%s %s is listed more than once"
+)
+
+// A Policy determines how changes users make to installed packages are
+// treated.
+type Policy string
+
+// Policies.
+const (
+	// PolicyCreateOnly creates missing packages but never changes existing
+	// ones. Differences are reported.
+	PolicyCreateOnly Policy = "create-only"
+	// PolicyEnforce creates missing packages and reverts changes to the
+	// package source of existing ones.
+	PolicyEnforce Policy = "enforce"
+)
+
+// Config is the list of packages that should be installed.
+type Config struct {
+	// Policy is the default policy of the packages. Defaults to create-only.
+	Policy Policy `json:"policy,omitempty"`
+	// Packages that should be installed.
+	Packages []Package `json:"packages"`
+}
+
+// A Package that should be installed.
+type Package struct {
+	// Kind of the package, one of Provider, Configuration or Function.
+	Kind string `json:"kind"`
+	// Name of the package object.
+	Name string `json:"name"`
+	// Package is the OCI reference of the package, including its version.
+	Package string `json:"package"`
+	// Policy overrides the default policy for this package.
+	Policy Policy `json:"policy,omitempty"`
+}
+
+// ParseConfig parses and validates the given packages config. The policy of
+// every package is set to the effective one.
+func ParseConfig(data []byte) (Config, error) {
+	c := Config{}
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return Config{}, errors.Wrap(err, errParseConfig)
+	}
+	if c.Policy == "" {
+		c.Policy = PolicyCreateOnly
+	}
+	if !valid(c.Policy) {
+		return Config{}, errors.Errorf(errFmtInvalidPolicy, c.Policy)
+	}
+	seen := map[string]bool{}
+	for i := range c.Packages {
+		p := &c.Packages[i]
+		switch {
+		case p.Name == "":
+			return Config{}, errors.Errorf(errFmtMissingField, i, "name")
+		case p.Package == "":
+			return Config{}, errors.Errorf(errFmtMissingField, i, "package")
+		case pkgv1.New(p.Kind) == nil:
+			return Config{}, errors.Errorf(errFmtInvalidKind, p.Kind, p.Name)
+		case seen[p.Kind+"/"+p.Name]:
+			return Config{}, errors.Errorf(errFmtDuplicate, p.Kind, p.Name)
+		}
+		seen[p.Kind+"/"+p.Name] = true
+		if p.Policy == "" {
+			p.Policy = c.Policy
+		}
+		if !valid(p.Policy) {
+			return Config{}, errors.Errorf(errFmtInvalidPolicy, p.Policy)
+		}
+	}
+	return c, nil
+}
+
+func valid(p Policy) bool {
+	return p == PolicyCreateOnly || p == PolicyEnforce
+}
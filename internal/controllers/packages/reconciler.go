@@ -0,0 +1,299 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package packages contains the controller that installs the default
+// Crossplane packages.
+package packages
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+	syncPeriod       = 10 * time.Minute
+	pollPeriod       = 30 * time.Second
+
+	// AnnotationKeyConfig is the annotation on the packages created by the
+	// controller that holds the ConfigMap they are listed in.
+	AnnotationKeyConfig = "packages.upbound.io/config"
+
+	errGetConfigMap    = "cannot get packages configmap"
+	errUpdateConfigMap = "cannot update packages configmap"
+	errMarshalStatus   = "cannot marshal packages status"
+	errFmtGet          = "cannot get %s %s"
+	errFmtCreate       = "cannot create %s %s"
+	errFmtPatch        = "cannot patch %s %s"
+
+	reasonInvalidConfig   event.Reason = "InvalidConfig"
+	reasonCreated         event.Reason = "PackageCreated"
+	reasonEnforced        event.Reason = "PackageEnforced"
+	reasonDiverged        event.Reason = "PackageDiverged"
+	reasonPackagesHealthy event.Reason = "PackagesHealthy"
+)
+
+// A Phase of a package.
+type Phase string
+
+// Phases of a package.
+const (
+	PhaseInstalling Phase = "Installing"
+	PhaseHealthy    Phase = "Healthy"
+	PhaseUnhealthy  Phase = "Unhealthy"
+	// PhaseDiverged means the package is installed from a different source
+	// than the desired one and the create-only policy prevents changing it.
+	PhaseDiverged Phase = "Diverged"
+)
+
+// PackageStatus is the progress of a package.
+type PackageStatus struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	Phase   Phase  `json:"phase"`
+	Message string `json:"message,omitempty"`
+}
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithInventory specifies how the Reconciler should record the objects it
+// applies.
+func WithInventory(inv inventory.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.inv = inv
+	}
+}
+
+// Reconciler installs the packages listed in the packages ConfigMap.
+type Reconciler struct {
+	client client.Client
+	log    logging.Logger
+	record event.Recorder
+	inv    inventory.Recorder
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client: mgr.GetClient(),
+		log:    logging.NewNopLogger(),
+		record: event.NewNopRecorder(),
+		inv:    inventory.NopRecorder{},
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile makes sure the packages listed in the packages ConfigMap exist
+// according to their policy and writes their progress to the ConfigMap.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "packages.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, req.NamespacedName, cm); err != nil {
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGetConfigMap)
+	}
+	cfg, err := ParseConfig([]byte(cm.Data[KeyPackages]))
+	if err != nil {
+		// There is nothing to retry until the ConfigMap is fixed.
+		log.Info("Invalid packages config", "error", err.Error())
+		r.record.Event(cm, event.Warning(reasonInvalidConfig, err))
+		return reconcile.Result{}, nil
+	}
+
+	refs := make([]inventory.Ref, 0, len(cfg.Packages))
+	for _, p := range cfg.Packages {
+		refs = append(refs, inventory.Ref{APIVersion: pkgv1.SchemeGroupVersion.String(), Kind: p.Kind, Name: p.Name})
+	}
+	if err := r.inv.Record(ctx, refs); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	statuses := make([]PackageStatus, 0, len(cfg.Packages))
+	healthy, progressing := 0, false
+	for _, p := range cfg.Packages {
+		s, err := r.install(ctx, cm, p)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		switch s.Phase {
+		case PhaseHealthy:
+			healthy++
+		case PhaseInstalling, PhaseUnhealthy:
+			progressing = true
+		case PhaseDiverged:
+		}
+		statuses = append(statuses, s)
+	}
+
+	summary := fmt.Sprintf("%d of %d packages are healthy", healthy, len(statuses))
+	previous := cm.Data[KeySummary]
+	if err := r.writeStatus(ctx, cm, statuses, summary); err != nil {
+		return reconcile.Result{}, err
+	}
+	if progressing {
+		return reconcile.Result{RequeueAfter: pollPeriod}, nil
+	}
+	if healthy == len(statuses) && previous != summary {
+		r.record.Event(cm, event.Normal(reasonPackagesHealthy, summary))
+		log.Info("All packages are healthy", "count", healthy)
+	}
+	return reconcile.Result{RequeueAfter: syncPeriod}, nil
+}
+
+// install makes sure the given package exists according to its policy and
+// returns its progress.
+func (r *Reconciler) install(ctx context.Context, cm *corev1.ConfigMap, p Package) (PackageStatus, error) {
+	s := PackageStatus{Kind: p.Kind, Name: p.Name, Package: p.Package}
+	pkg := pkgv1.New(p.Kind)
+	err := r.client.Get(ctx, types.NamespacedName{Name: p.Name}, pkg)
+	if kerrors.IsNotFound(err) {
+		pkg.SetName(p.Name)
+		pkg.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+		pkg.SetAnnotations(map[string]string{AnnotationKeyConfig: cm.GetNamespace() + "/" + cm.GetName()})
+		pkg.GetPackageSpec().Package = p.Package
+		if err := r.client.Create(ctx, pkg); err != nil {
+			return s, errors.Wrapf(err, errFmtCreate, p.Kind, p.Name)
+		}
+		r.record.Event(cm, event.Normal(reasonCreated, fmt.Sprintf("Created %s %s from %s", p.Kind, p.Name, p.Package)))
+		s.Phase, s.Message = PhaseInstalling, "Created"
+		return s, nil
+	}
+	if err != nil {
+		return s, errors.Wrapf(err, errFmtGet, p.Kind, p.Name)
+	}
+
+	if current := pkg.GetPackageSpec().Package; current != p.Package {
+		if p.Policy != PolicyEnforce {
+			s.Phase, s.Message = PhaseDiverged, fmt.Sprintf("Installed from %s", current)
+			if !contains(cm.Data[KeyStatus], s) {
+				r.record.Event(cm, event.Warning(reasonDiverged, errors.Errorf("%s %s is installed from %s instead of %s", p.Kind, p.Name, current, p.Package)))
+			}
+			return s, nil
+		}
+		// Patching rather than updating preserves the fields we do not know.
+		orig := pkg.DeepCopyObject().(client.Object)
+		pkg.GetPackageSpec().Package = p.Package
+		if err := r.client.Patch(ctx, pkg, client.MergeFrom(orig)); err != nil {
+			return s, errors.Wrapf(err, errFmtPatch, p.Kind, p.Name)
+		}
+		r.record.Event(cm, event.Normal(reasonEnforced, fmt.Sprintf("Changed %s %s from %s to %s", p.Kind, p.Name, current, p.Package)))
+		s.Phase, s.Message = PhaseInstalling, fmt.Sprintf("Changed from %s", current)
+		return s, nil
+	}
+
+	s.Phase, s.Message = phase(pkg.GetPackageStatus())
+	return s, nil
+}
+
+// phase returns the phase of a package derived from its conditions.
+func phase(st *pkgv1.PackageStatus) (Phase, string) {
+	installed := st.GetCondition(pkgv1.TypeInstalled)
+	if installed.Status != corev1.ConditionTrue {
+		return PhaseInstalling, conditionMessage(installed)
+	}
+	h := st.GetCondition(pkgv1.TypeHealthy)
+	switch h.Status {
+	case corev1.ConditionTrue:
+		return PhaseHealthy, ""
+	case corev1.ConditionFalse:
+		return PhaseUnhealthy, conditionMessage(h)
+	case corev1.ConditionUnknown:
+	}
+	return PhaseInstalling, conditionMessage(h)
+}
+
+func conditionMessage(c xpv1.Condition) string {
+	if c.Message != "" {
+		return c.Message
+	}
+	return string(c.Reason)
+}
+
+// writeStatus writes the progress of the packages to the ConfigMap if it
+// changed.
+func (r *Reconciler) writeStatus(ctx context.Context, cm *corev1.ConfigMap, statuses []PackageStatus, summary string) error {
+	b, err := yaml.Marshal(statuses)
+	if err != nil {
+		return errors.Wrap(err, errMarshalStatus)
+	}
+	if cm.Data[KeyStatus] == string(b) && cm.Data[KeySummary] == summary {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[KeyStatus], cm.Data[KeySummary] = string(b), summary
+	return errors.Wrap(r.client.Update(ctx, cm), errUpdateConfigMap)
+}
+
+// contains returns true if the given status is already recorded in the
+// given serialized statuses.
+func contains(data string, s PackageStatus) bool {
+	var statuses []PackageStatus
+	if err := yaml.Unmarshal([]byte(data), &statuses); err != nil {
+		return false
+	}
+	for _, cur := range statuses {
+		if cur == s {
+			return true
+		}
+	}
+	return false
+}
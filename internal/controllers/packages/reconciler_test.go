@@ -0,0 +1,240 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+func TestParseConfig(t *testing.T) {
+	type want struct {
+		cfg Config
+		err error
+	}
+	cases := map[string]struct {
+		data string
+		want want
+	}{
+		"DefaultPolicy": {
+			data: `
+packages:
+- kind: Provider
+  name: provider-aws-s3
+  package: xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0
+- kind: Function
+  name: function-patch-and-transform
+  package: xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.2.1
+  policy: enforce
+`,
+			want: want{cfg: Config{Policy: PolicyCreateOnly, Packages: []Package{
+				{Kind: "Provider", Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", Policy: PolicyCreateOnly},
+				{Kind: "Function", Name: "function-patch-and-transform", Package: "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.2.1", Policy: PolicyEnforce},
+			}}},
+		},
+		"InvalidPolicy": {
+			data: "policy: always",
+			want: want{err: errors.Errorf(errFmtInvalidPolicy, "always")},
+		},
+		"InvalidKind": {
+			data: "packages: [{kind: Composition, name: a, package: b}]",
+			want: want{err: errors.Errorf(errFmtInvalidKind, "Composition", "a")},
+		},
+		"MissingPackage": {
+			data: "packages: [{kind: Provider, name: a}]",
+			want: want{err: errors.Errorf(errFmtMissingField, 0, "package")},
+		},
+		"Duplicate": {
+			data: "packages: [{kind: Provider, name: a, package: b}, {kind: Provider, name: a, package: c}]",
+			want: want{err: errors.Errorf(errFmtDuplicate, "Provider", "a")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseConfig([]byte(tc.data))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("ParseConfig(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.cfg, got); diff != "" {
+				t.Errorf("ParseConfig(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "")
+	desired := "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0"
+	healthy := xpv1.ConditionedStatus{Conditions: []xpv1.Condition{
+		{Type: pkgv1.TypeInstalled, Status: corev1.ConditionTrue},
+		{Type: pkgv1.TypeHealthy, Status: corev1.ConditionTrue},
+	}}
+
+	// get returns the packages ConfigMap with the given policy and a
+	// Provider installed from the given package, or not found if it is
+	// empty.
+	get := func(policy Policy, installed string, st xpv1.ConditionedStatus) test.MockGetFn {
+		return func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			switch o := obj.(type) {
+			case *corev1.ConfigMap:
+				o.Data = map[string]string{KeyPackages: "policy: " + string(policy) + "\npackages: [{kind: Provider, name: provider-aws-s3, package: '" + desired + "'}]"}
+			case *pkgv1.Provider:
+				if installed == "" {
+					return notFound
+				}
+				o.Spec.Package = installed
+				o.Status.ConditionedStatus = st
+			}
+			return nil
+		}
+	}
+
+	type want struct {
+		result   reconcile.Result
+		err      error
+		statuses []PackageStatus
+		created  string
+		patched  string
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   *test.MockClient
+		want   want
+	}{
+		"ConfigMapNotFound": {
+			reason: "We should do nothing if the packages ConfigMap does not exist.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(notFound)},
+			want:   want{},
+		},
+		"GetPackageError": {
+			reason: "We should return an error if a package cannot be fetched.",
+			kube: &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+				if _, ok := obj.(*pkgv1.Provider); ok {
+					return errBoom
+				}
+				return get(PolicyCreateOnly, "", healthy)(context.Background(), client.ObjectKey{}, obj)
+			}},
+			want: want{err: errors.Wrapf(errBoom, errFmtGet, "Provider", "provider-aws-s3")},
+		},
+		"Create": {
+			reason: "We should create missing packages.",
+			kube:   &test.MockClient{MockGet: get(PolicyCreateOnly, "", healthy)},
+			want: want{
+				result:   reconcile.Result{RequeueAfter: pollPeriod},
+				created:  desired,
+				statuses: []PackageStatus{{Kind: "Provider", Name: "provider-aws-s3", Package: desired, Phase: PhaseInstalling, Message: "Created"}},
+			},
+		},
+		"CreateOnlyDiverged": {
+			reason: "We should report but not change packages that users changed with the create-only policy.",
+			kube:   &test.MockClient{MockGet: get(PolicyCreateOnly, "xpkg.upbound.io/upbound/provider-aws-s3:v1.2.0", healthy)},
+			want: want{
+				result:   reconcile.Result{RequeueAfter: syncPeriod},
+				statuses: []PackageStatus{{Kind: "Provider", Name: "provider-aws-s3", Package: desired, Phase: PhaseDiverged, Message: "Installed from xpkg.upbound.io/upbound/provider-aws-s3:v1.2.0"}},
+			},
+		},
+		"Enforce": {
+			reason: "We should revert packages that users changed with the enforce policy.",
+			kube:   &test.MockClient{MockGet: get(PolicyEnforce, "xpkg.upbound.io/upbound/provider-aws-s3:v1.2.0", healthy)},
+			want: want{
+				result:   reconcile.Result{RequeueAfter: pollPeriod},
+				patched:  desired,
+				statuses: []PackageStatus{{Kind: "Provider", Name: "provider-aws-s3", Package: desired, Phase: PhaseInstalling, Message: "Changed from xpkg.upbound.io/upbound/provider-aws-s3:v1.2.0"}},
+			},
+		},
+		"Healthy": {
+			reason: "We should report healthy packages and check again later.",
+			kube:   &test.MockClient{MockGet: get(PolicyEnforce, desired, healthy)},
+			want: want{
+				result:   reconcile.Result{RequeueAfter: syncPeriod},
+				statuses: []PackageStatus{{Kind: "Provider", Name: "provider-aws-s3", Package: desired, Phase: PhaseHealthy}},
+			},
+		},
+		"Unhealthy": {
+			reason: "We should report unhealthy packages with the reason.",
+			kube: &test.MockClient{MockGet: get(PolicyEnforce, desired, xpv1.ConditionedStatus{Conditions: []xpv1.Condition{
+				{Type: pkgv1.TypeInstalled, Status: corev1.ConditionTrue},
+				{Type: pkgv1.TypeHealthy, Status: corev1.ConditionFalse, Reason: "UnhealthyPackageRevision", Message: "cannot pull"},
+			}})},
+			want: want{
+				result:   reconcile.Result{RequeueAfter: pollPeriod},
+				statuses: []PackageStatus{{Kind: "Provider", Name: "provider-aws-s3", Package: desired, Phase: PhaseUnhealthy, Message: "cannot pull"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var statuses []PackageStatus
+			var created, patched string
+			tc.kube.MockCreate = func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+				p := obj.(*pkgv1.Provider)
+				if p.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy {
+					t.Errorf("Create(...): package is not labeled as managed by the bootstrapper")
+				}
+				created = p.Spec.Package
+				return nil
+			}
+			tc.kube.MockPatch = func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+				patched = obj.(*pkgv1.Provider).Spec.Package
+				return nil
+			}
+			tc.kube.MockUpdate = func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+				if err := yaml.Unmarshal([]byte(obj.(*corev1.ConfigMap).Data[KeyStatus]), &statuses); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			}
+			r := NewReconciler(&fake.Manager{Client: tc.kube})
+			got, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNamePackages, Namespace: "upbound-system"}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.statuses, statuses); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want statuses, +got statuses:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patched, patched); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want patched, +got patched:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pullsecrets
+
+import (
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+// Keys of the pull secrets ConfigMap.
+const (
+	// KeyConfig holds the pull secrets to propagate as a Config.
+	KeyConfig = "pull-secrets.yaml"
+	// KeyStatus holds the propagation of the pull secrets as a list of
+	// SecretStatus, written by the controller.
+	KeyStatus = "status.yaml"
+	// KeySummary holds a human readable summary of the propagation.
+	KeySummary = "summary"
+)
+
+// DefaultRuntimeConfig is the DeploymentRuntimeConfig Crossplane uses for
+// packages that do not reference one.
+const DefaultRuntimeConfig = "default"
+
+const (
+	errParseConfig     = "cannot parse pull secrets config"
+	errFmtEmptyName    = "invalid %s, names must not be empty"
+	errFmtDuplicate    = "invalid %s %q, names must be unique"
+	errNoSecrets       = "invalid config, at least one secret is required"
+	fieldSecrets       = "secret"
+	fieldNamespace     = "namespace"
+	fieldRuntimeConfig = "runtime config"
+)
+
+// Config is the pull secrets to propagate.
+type Config struct {
+	// Secrets are the names of the pull Secrets in the Crossplane namespace.
+	Secrets []string `json:"secrets"`
+	// Namespaces are the other namespaces package runtimes run in, which the
+	// Secrets are copied to.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// RuntimeConfigs are the DeploymentRuntimeConfigs whose pods reference
+	// the Secrets. Defaults to the default DeploymentRuntimeConfig.
+	RuntimeConfigs []string `json:"runtimeConfigs,omitempty"`
+}
+
+// ParseConfig parses and validates the given pull secrets config. The
+// runtime configs are set to the effective ones.
+func ParseConfig(data []byte) (Config, error) {
+	c := Config{}
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return Config{}, errors.Wrap(err, errParseConfig)
+	}
+	if len(c.Secrets) == 0 {
+		return Config{}, errors.New(errNoSecrets)
+	}
+	if c.RuntimeConfigs == nil {
+		c.RuntimeConfigs = []string{DefaultRuntimeConfig}
+	}
+	if err := unique(fieldSecrets, c.Secrets); err != nil {
+		return Config{}, err
+	}
+	if err := unique(fieldNamespace, c.Namespaces); err != nil {
+		return Config{}, err
+	}
+	if err := unique(fieldRuntimeConfig, c.RuntimeConfigs); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func unique(field string, names []string) error {
+	seen := map[string]bool{}
+	for _, n := range names {
+		if n == "" {
+			return errors.Errorf(errFmtEmptyName, field)
+		}
+		if seen[n] {
+			return errors.Errorf(errFmtDuplicate, field, n)
+		}
+		seen[n] = true
+	}
+	return nil
+}
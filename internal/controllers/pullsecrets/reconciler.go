@@ -0,0 +1,440 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pullsecrets contains the controller that propagates image pull
+// Secrets to the Crossplane package runtimes.
+package pullsecrets
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+	syncPeriod       = 10 * time.Minute
+
+	// AnnotationKeySource is the annotation on the copied Secrets that holds
+	// the Secret they are copied from.
+	AnnotationKeySource = "pullsecrets.upbound.io/source"
+	// AnnotationKeyInjected is the annotation on DeploymentRuntimeConfigs
+	// that holds the comma separated pull Secrets the controller added, so
+	// that the ones added by users are left alone.
+	AnnotationKeyInjected = "pullsecrets.upbound.io/injected"
+
+	// RuntimeContainerName is the name of the container that runs a package
+	// in the Deployments Crossplane creates from DeploymentRuntimeConfigs.
+	RuntimeContainerName = "package-runtime"
+
+	errGetConfigMap     = "cannot get pull secrets configmap"
+	errUpdateConfigMap  = "cannot update pull secrets configmap"
+	errMarshalStatus    = "cannot marshal pull secrets status"
+	errFmtGetSecret     = "cannot get secret %s/%s"
+	errFmtDeleteSecret  = "cannot delete secret %s/%s"
+	errFmtApplySecret   = "cannot apply secret %s/%s"
+	errFmtGetRuntime    = "cannot get deployment runtime config %s"
+	errFmtCreateRuntime = "cannot create deployment runtime config %s"
+	errFmtPatchRuntime  = "cannot patch deployment runtime config %s"
+
+	reasonInvalidConfig      event.Reason = "InvalidConfig"
+	reasonSecretMissing      event.Reason = "PullSecretMissing"
+	reasonSecretConflict     event.Reason = "PullSecretConflict"
+	reasonSecretSynced       event.Reason = "PullSecretSynced"
+	reasonRuntimeConfigSet   event.Reason = "RuntimeConfigUpdated"
+	reasonRuntimeUnavailable event.Reason = "RuntimeConfigUnavailable"
+)
+
+// RuntimeConfigGroupVersionKind is the GroupVersionKind of Crossplane
+// DeploymentRuntimeConfigs.
+var RuntimeConfigGroupVersionKind = schema.GroupVersionKind{Group: pkgv1.Group, Version: "v1beta1", Kind: "DeploymentRuntimeConfig"} //nolint:gochecknoglobals // We treat this as a constant.
+
+// A State of a pull Secret in a namespace or of a DeploymentRuntimeConfig.
+type State string
+
+// States.
+const (
+	// StateSynced means the Secret exists in the namespace with the data of
+	// the source Secret.
+	StateSynced State = "Synced"
+	// StateSourceMissing means the source Secret does not exist.
+	StateSourceMissing State = "SourceMissing"
+	// StateNamespaceMissing means the namespace does not exist.
+	StateNamespaceMissing State = "NamespaceMissing"
+	// StateConflict means a Secret with the same name that is not managed
+	// by the bootstrapper exists in the namespace.
+	StateConflict State = "Conflict"
+	// StateInjected means the DeploymentRuntimeConfig references the pull
+	// Secrets.
+	StateInjected State = "Injected"
+	// StateUnavailable means DeploymentRuntimeConfigs are not supported by
+	// the Crossplane version.
+	StateUnavailable State = "Unavailable"
+)
+
+// SecretStatus is the propagation of a pull Secret to a namespace.
+type SecretStatus struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	State     State  `json:"state"`
+}
+
+// RuntimeConfigStatus is whether a DeploymentRuntimeConfig references the
+// pull Secrets.
+type RuntimeConfigStatus struct {
+	Name  string `json:"name"`
+	State State  `json:"state"`
+}
+
+// Status is the propagation of the pull Secrets.
+type Status struct {
+	Secrets        []SecretStatus        `json:"secrets"`
+	RuntimeConfigs []RuntimeConfigStatus `json:"runtimeConfigs"`
+}
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithInventory specifies how the Reconciler should record the objects it
+// applies.
+func WithInventory(inv inventory.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.inv = inv
+	}
+}
+
+// WithAPIReader specifies how the Reconciler should read the copied Secrets
+// and the DeploymentRuntimeConfigs, which are not cached.
+func WithAPIReader(c client.Reader) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.reader = c
+	}
+}
+
+// Reconciler copies the pull Secrets listed in the pull secrets ConfigMap to
+// the namespaces package runtimes run in and references them in the
+// DeploymentRuntimeConfigs.
+type Reconciler struct {
+	client client.Client
+	reader client.Reader
+	log    logging.Logger
+	record event.Recorder
+	inv    inventory.Recorder
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client: mgr.GetClient(),
+		reader: mgr.GetClient(),
+		log:    logging.NewNopLogger(),
+		record: event.NewNopRecorder(),
+		inv:    inventory.NopRecorder{},
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile copies the pull Secrets to the configured namespaces, keeps the
+// copies in sync with their sources, references the Secrets in the
+// configured DeploymentRuntimeConfigs and reports the propagation in the
+// pull secrets ConfigMap.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "pullsecrets.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, req.NamespacedName, cm); err != nil {
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGetConfigMap)
+	}
+	cfg, err := ParseConfig([]byte(cm.Data[KeyConfig]))
+	if err != nil {
+		// There is nothing to retry until the ConfigMap is fixed.
+		log.Info("Invalid pull secrets config", "error", err.Error())
+		r.record.Event(cm, event.Warning(reasonInvalidConfig, err))
+		return reconcile.Result{}, nil
+	}
+	previous := &Status{}
+	_ = yaml.Unmarshal([]byte(cm.Data[KeyStatus]), previous)
+
+	status := &Status{}
+	var sources []*corev1.Secret
+	for _, name := range cfg.Secrets {
+		s := &corev1.Secret{}
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: name}, s)
+		if kerrors.IsNotFound(err) {
+			st := SecretStatus{Name: name, Namespace: req.Namespace, State: StateSourceMissing}
+			if !previous.has(st) {
+				r.record.Event(cm, event.Warning(reasonSecretMissing, errors.Errorf("pull secret %s does not exist in namespace %s", name, req.Namespace)))
+			}
+			status.Secrets = append(status.Secrets, st)
+			continue
+		}
+		if err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, errFmtGetSecret, req.Namespace, name)
+		}
+		sources = append(sources, s)
+	}
+
+	refs := make([]inventory.Ref, 0, len(sources)*len(cfg.Namespaces))
+	for _, s := range sources {
+		for _, ns := range cfg.Namespaces {
+			if ns != req.Namespace {
+				refs = append(refs, inventory.Ref{APIVersion: "v1", Kind: "Secret", Namespace: ns, Name: s.GetName()})
+			}
+		}
+	}
+	if err := r.inv.Record(ctx, refs); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	for _, s := range sources {
+		status.Secrets = append(status.Secrets, SecretStatus{Name: s.GetName(), Namespace: req.Namespace, State: StateSynced})
+		for _, ns := range cfg.Namespaces {
+			if ns == req.Namespace {
+				continue
+			}
+			st, err := r.copy(ctx, cm, s, ns)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if st.State == StateConflict && !previous.has(st) {
+				r.record.Event(cm, event.Warning(reasonSecretConflict, errors.Errorf("secret %s/%s exists and is not managed by the bootstrapper", ns, s.GetName())))
+			}
+			status.Secrets = append(status.Secrets, st)
+		}
+	}
+
+	for _, name := range cfg.RuntimeConfigs {
+		st, err := r.inject(ctx, cm, name, cfg.Secrets)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if st.State == StateUnavailable && !previous.hasRuntimeConfig(st) {
+			r.record.Event(cm, event.Warning(reasonRuntimeUnavailable, errors.New("DeploymentRuntimeConfigs are not available in this Crossplane version")))
+		}
+		status.RuntimeConfigs = append(status.RuntimeConfigs, st)
+	}
+
+	synced := 0
+	for _, st := range status.Secrets {
+		if st.State == StateSynced {
+			synced++
+		}
+	}
+	summary := fmt.Sprintf("%d of %d pull secrets are synced", synced, len(status.Secrets))
+	return reconcile.Result{RequeueAfter: syncPeriod}, r.writeStatus(ctx, cm, status, summary)
+}
+
+// copy makes sure the given namespace has a copy of the given Secret that is
+// managed by the bootstrapper.
+func (r *Reconciler) copy(ctx context.Context, cm *corev1.ConfigMap, src *corev1.Secret, ns string) (SecretStatus, error) {
+	st := SecretStatus{Name: src.GetName(), Namespace: ns, State: StateSynced}
+	cur := &corev1.Secret{}
+	err := r.reader.Get(ctx, types.NamespacedName{Namespace: ns, Name: src.GetName()}, cur)
+	switch {
+	case kerrors.IsNotFound(err):
+	case err != nil:
+		return st, errors.Wrapf(err, errFmtGetSecret, ns, src.GetName())
+	case cur.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy:
+		// Not created by us, so not ours to overwrite.
+		st.State = StateConflict
+		return st, nil
+	case cur.Type == src.Type && reflect.DeepEqual(cur.Data, src.Data):
+		return st, nil
+	case cur.Type != src.Type:
+		// The type of a Secret cannot be changed.
+		if err := r.client.Delete(ctx, cur); client.IgnoreNotFound(err) != nil {
+			return st, errors.Wrapf(err, errFmtDeleteSecret, ns, src.GetName())
+		}
+	}
+
+	s := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        src.GetName(),
+			Namespace:   ns,
+			Labels:      map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy},
+			Annotations: map[string]string{AnnotationKeySource: src.GetNamespace() + "/" + src.GetName()},
+		},
+		Type: src.Type,
+		Data: src.Data,
+	}
+	err = r.client.Patch(ctx, s, client.Apply, client.FieldOwner(meta.FieldOwner), client.ForceOwnership)
+	if kerrors.IsNotFound(err) {
+		st.State = StateNamespaceMissing
+		return st, nil
+	}
+	if err != nil {
+		return st, errors.Wrapf(err, errFmtApplySecret, ns, src.GetName())
+	}
+	r.record.Event(cm, event.Normal(reasonSecretSynced, fmt.Sprintf("Synced pull secret %s to namespace %s", src.GetName(), ns)))
+	r.log.Info("Synced pull secret", "name", src.GetName(), "namespace", ns)
+	return st, nil
+}
+
+// inject makes sure the pods of the given DeploymentRuntimeConfig reference
+// the given pull Secrets, keeping the ones users added. The
+// DeploymentRuntimeConfig is created if it does not exist.
+func (r *Reconciler) inject(ctx context.Context, cm *corev1.ConfigMap, name string, secrets []string) (RuntimeConfigStatus, error) {
+	st := RuntimeConfigStatus{Name: name, State: StateInjected}
+	rc := &unstructured.Unstructured{}
+	rc.SetGroupVersionKind(RuntimeConfigGroupVersionKind)
+	err := r.reader.Get(ctx, types.NamespacedName{Name: name}, rc)
+	if kmeta.IsNoMatchError(err) {
+		st.State = StateUnavailable
+		return st, nil
+	}
+	if err != nil && !kerrors.IsNotFound(err) {
+		return st, errors.Wrapf(err, errFmtGetRuntime, name)
+	}
+	exists := err == nil
+	orig := rc.DeepCopy()
+
+	injected := map[string]bool{}
+	for _, s := range strings.Split(rc.GetAnnotations()[AnnotationKeyInjected], ",") {
+		injected[s] = true
+	}
+	want := map[string]bool{}
+	for _, s := range secrets {
+		want[s] = true
+	}
+	refs, _, _ := unstructured.NestedSlice(rc.Object, "spec", "deploymentTemplate", "spec", "template", "spec", "imagePullSecrets")
+	desired := make([]any, 0, len(refs)+len(secrets))
+	for _, ref := range refs {
+		n, _, _ := unstructured.NestedString(ref.(map[string]any), "name")
+		if !injected[n] && !want[n] {
+			desired = append(desired, ref)
+		}
+	}
+	for _, s := range secrets {
+		desired = append(desired, map[string]any{"name": s})
+	}
+	if exists && reflect.DeepEqual(refs, desired) && rc.GetAnnotations()[AnnotationKeyInjected] == strings.Join(secrets, ",") {
+		return st, nil
+	}
+
+	a := rc.GetAnnotations()
+	if a == nil {
+		a = map[string]string{}
+	}
+	a[AnnotationKeyInjected] = strings.Join(secrets, ",")
+	rc.SetAnnotations(a)
+	// The selector of the deployment template is required.
+	if _, found, _ := unstructured.NestedFieldNoCopy(rc.Object, "spec", "deploymentTemplate", "spec", "selector"); !found {
+		_ = unstructured.SetNestedMap(rc.Object, map[string]any{}, "spec", "deploymentTemplate", "spec", "selector")
+	}
+	if err := unstructured.SetNestedSlice(rc.Object, desired, "spec", "deploymentTemplate", "spec", "template", "spec", "imagePullSecrets"); err != nil {
+		return st, errors.Wrapf(err, errFmtPatchRuntime, name)
+	}
+
+	if !exists {
+		// Crossplane requires the containers of the pod template, and merges
+		// the package runtime container by name.
+		rc.SetName(name)
+		_ = unstructured.SetNestedSlice(rc.Object, []any{map[string]any{"name": RuntimeContainerName}}, "spec", "deploymentTemplate", "spec", "template", "spec", "containers")
+		if err := r.client.Create(ctx, rc); err != nil {
+			return st, errors.Wrapf(err, errFmtCreateRuntime, name)
+		}
+	} else if err := r.client.Patch(ctx, rc, client.MergeFrom(orig)); err != nil {
+		return st, errors.Wrapf(err, errFmtPatchRuntime, name)
+	}
+	r.record.Event(cm, event.Normal(reasonRuntimeConfigSet, fmt.Sprintf("Referenced pull secrets %s in deployment runtime config %s", strings.Join(secrets, ", "), name)))
+	r.log.Info("Referenced pull secrets in deployment runtime config", "name", name, "secrets", secrets)
+	return st, nil
+}
+
+// writeStatus writes the propagation of the pull Secrets to the ConfigMap if
+// it changed.
+func (r *Reconciler) writeStatus(ctx context.Context, cm *corev1.ConfigMap, status *Status, summary string) error {
+	b, err := yaml.Marshal(status)
+	if err != nil {
+		return errors.Wrap(err, errMarshalStatus)
+	}
+	if cm.Data[KeyStatus] == string(b) && cm.Data[KeySummary] == summary {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[KeyStatus], cm.Data[KeySummary] = string(b), summary
+	return errors.Wrap(r.client.Update(ctx, cm), errUpdateConfigMap)
+}
+
+// has returns true if the given Secret status was already recorded.
+func (s *Status) has(st SecretStatus) bool {
+	for _, cur := range s.Secrets {
+		if cur == st {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRuntimeConfig returns true if the given DeploymentRuntimeConfig status
+// was already recorded.
+func (s *Status) hasRuntimeConfig(st RuntimeConfigStatus) bool {
+	for _, cur := range s.RuntimeConfigs {
+		if cur == st {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pullsecrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+func TestParseConfig(t *testing.T) {
+	type want struct {
+		cfg Config
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Defaults": {
+			reason: "The default DeploymentRuntimeConfig should be used if none are configured.",
+			data:   "secrets: [regcred]",
+			want:   want{cfg: Config{Secrets: []string{"regcred"}, RuntimeConfigs: []string{DefaultRuntimeConfig}}},
+		},
+		"NoSecrets": {
+			reason: "At least one Secret should be required.",
+			data:   "namespaces: [crossplane-system]",
+			want:   want{err: errors.New(errNoSecrets)},
+		},
+		"Duplicate": {
+			reason: "Namespaces should be unique.",
+			data:   "secrets: [regcred]\nnamespaces: [a, a]",
+			want:   want{err: errors.Errorf(errFmtDuplicate, fieldNamespace, "a")},
+		},
+		"Empty": {
+			reason: "Runtime config names should not be empty.",
+			data:   "secrets: [regcred]\nruntimeConfigs: ['']",
+			want:   want{err: errors.Errorf(errFmtEmptyName, fieldRuntimeConfig)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseConfig([]byte(tc.data))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseConfig(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cfg, got); diff != "" {
+				t.Errorf("\n%s\nParseConfig(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNamePullSecrets, Namespace: "upbound-system"}}
+	cfg := "secrets: [regcred]\nnamespaces: [upbound-system, crossplane-system]"
+	data := map[string][]byte{".dockerconfigjson": []byte("new")}
+	managed := map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy}
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "")
+
+	// rc returns a DeploymentRuntimeConfig that references the given pull
+	// Secrets, the given ones of which were injected.
+	rc := func(injected string, secrets ...string) func(u *unstructured.Unstructured) error {
+		return func(u *unstructured.Unstructured) error {
+			refs := make([]any, 0, len(secrets))
+			for _, s := range secrets {
+				refs = append(refs, map[string]any{"name": s})
+			}
+			u.SetName(DefaultRuntimeConfig)
+			u.SetAnnotations(map[string]string{AnnotationKeyInjected: injected})
+			_ = unstructured.SetNestedSlice(u.Object, refs, "spec", "deploymentTemplate", "spec", "template", "spec", "imagePullSecrets")
+			return nil
+		}
+	}
+
+	type want struct {
+		result  reconcile.Result
+		err     error
+		applied []string
+		rc      []any
+		created *unstructured.Unstructured
+		status  *Status
+	}
+	cases := map[string]struct {
+		reason string
+		cfg    string
+		source error
+		copy   func(s *corev1.Secret) error
+		rc     func(u *unstructured.Unstructured) error
+		want   want
+	}{
+		"InvalidConfig": {
+			reason: "Nothing should be done until an invalid config is fixed.",
+			cfg:    "secrets: []",
+			want:   want{},
+		},
+		"GetSourceError": {
+			reason: "Errors getting a source Secret should be returned.",
+			cfg:    cfg,
+			source: errBoom,
+			want:   want{err: errors.Wrapf(errBoom, errFmtGetSecret, "upbound-system", "regcred")},
+		},
+		"Create": {
+			reason: "Missing copies and DeploymentRuntimeConfigs should be created.",
+			cfg:    cfg,
+			copy:   func(*corev1.Secret) error { return notFound },
+			rc:     func(*unstructured.Unstructured) error { return notFound },
+			want: want{
+				result:  reconcile.Result{RequeueAfter: syncPeriod},
+				applied: []string{"crossplane-system/regcred"},
+				rc:      []any{map[string]any{"name": "regcred"}},
+				created: &unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": RuntimeConfigGroupVersionKind.GroupVersion().String(),
+					"kind":       RuntimeConfigGroupVersionKind.Kind,
+					"metadata": map[string]any{
+						"name":        DefaultRuntimeConfig,
+						"annotations": map[string]any{AnnotationKeyInjected: "regcred"},
+					},
+					"spec": map[string]any{"deploymentTemplate": map[string]any{"spec": map[string]any{
+						"selector": map[string]any{},
+						"template": map[string]any{"spec": map[string]any{
+							"containers":       []any{map[string]any{"name": RuntimeContainerName}},
+							"imagePullSecrets": []any{map[string]any{"name": "regcred"}},
+						}},
+					}}},
+				}},
+				status: &Status{
+					Secrets: []SecretStatus{
+						{Name: "regcred", Namespace: "upbound-system", State: StateSynced},
+						{Name: "regcred", Namespace: "crossplane-system", State: StateSynced},
+					},
+					RuntimeConfigs: []RuntimeConfigStatus{{Name: DefaultRuntimeConfig, State: StateInjected}},
+				},
+			},
+		},
+		"Rotate": {
+			reason: "Copies should be updated when their source is rotated, and pull Secrets users added should be kept.",
+			cfg:    cfg,
+			copy: func(s *corev1.Secret) error {
+				s.SetLabels(managed)
+				s.Data = map[string][]byte{".dockerconfigjson": []byte("old")}
+				return nil
+			},
+			rc: rc("oldcred", "usercred", "oldcred"),
+			want: want{
+				result:  reconcile.Result{RequeueAfter: syncPeriod},
+				applied: []string{"crossplane-system/regcred"},
+				rc:      []any{map[string]any{"name": "usercred"}, map[string]any{"name": "regcred"}},
+				status: &Status{
+					Secrets: []SecretStatus{
+						{Name: "regcred", Namespace: "upbound-system", State: StateSynced},
+						{Name: "regcred", Namespace: "crossplane-system", State: StateSynced},
+					},
+					RuntimeConfigs: []RuntimeConfigStatus{{Name: DefaultRuntimeConfig, State: StateInjected}},
+				},
+			},
+		},
+		"Conflict": {
+			reason: "Secrets the bootstrapper does not manage should not be overwritten.",
+			cfg:    cfg,
+			copy:   func(*corev1.Secret) error { return nil },
+			rc:     rc("regcred", "regcred"),
+			want: want{
+				result: reconcile.Result{RequeueAfter: syncPeriod},
+				status: &Status{
+					Secrets: []SecretStatus{
+						{Name: "regcred", Namespace: "upbound-system", State: StateSynced},
+						{Name: "regcred", Namespace: "crossplane-system", State: StateConflict},
+					},
+					RuntimeConfigs: []RuntimeConfigStatus{{Name: DefaultRuntimeConfig, State: StateInjected}},
+				},
+			},
+		},
+		"SourceMissingAndNoRuntimeConfigs": {
+			reason: "Missing sources should be reported, and runtime configs reported as unavailable if Crossplane does not support them.",
+			cfg:    cfg,
+			source: notFound,
+			rc: func(*unstructured.Unstructured) error {
+				return &kmeta.NoKindMatchError{GroupKind: RuntimeConfigGroupVersionKind.GroupKind()}
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: syncPeriod},
+				status: &Status{
+					Secrets:        []SecretStatus{{Name: "regcred", Namespace: "upbound-system", State: StateSourceMissing}},
+					RuntimeConfigs: []RuntimeConfigStatus{{Name: DefaultRuntimeConfig, State: StateUnavailable}},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var applied []string
+			var refs []any
+			var created *unstructured.Unstructured
+			var status *Status
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					switch o := obj.(type) {
+					case *corev1.ConfigMap:
+						o.SetName(key.Name)
+						o.SetNamespace(key.Namespace)
+						o.Data = map[string]string{KeyConfig: tc.cfg}
+					case *corev1.Secret:
+						if tc.source != nil {
+							return tc.source
+						}
+						o.SetName(key.Name)
+						o.SetNamespace(key.Namespace)
+						o.Data = data
+					}
+					return nil
+				},
+				MockPatch: func(_ context.Context, obj client.Object, p client.Patch, _ ...client.PatchOption) error {
+					if p == client.Apply {
+						applied = append(applied, obj.GetNamespace()+"/"+obj.GetName())
+						return nil
+					}
+					refs, _, _ = unstructured.NestedSlice(obj.(*unstructured.Unstructured).Object, "spec", "deploymentTemplate", "spec", "template", "spec", "imagePullSecrets")
+					return nil
+				},
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					created = obj.(*unstructured.Unstructured)
+					refs, _, _ = unstructured.NestedSlice(created.Object, "spec", "deploymentTemplate", "spec", "template", "spec", "imagePullSecrets")
+					return nil
+				},
+				MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+					status = &Status{}
+					return yaml.Unmarshal([]byte(obj.(*corev1.ConfigMap).Data[KeyStatus]), status)
+				},
+			}
+			reader := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					switch o := obj.(type) {
+					case *corev1.Secret:
+						o.SetName(key.Name)
+						o.SetNamespace(key.Namespace)
+						return tc.copy(o)
+					case *unstructured.Unstructured:
+						return tc.rc(o)
+					}
+					return nil
+				},
+			}
+			r := NewReconciler(&fake.Manager{Client: kube}, WithAPIReader(reader))
+			got, err := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.applied, applied); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want applied, +got applied:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.rc, refs); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want pull secrets, +got pull secrets:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want created runtime config, +got created runtime config:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.status, status); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want status, +got status:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pullsecrets
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+// Setup adds the controller that propagates the image pull Secrets listed in
+// the pull secrets ConfigMap in the given namespace. The Secrets it copies
+// are recorded in the given inventory.
+func Setup(mgr ctrl.Manager, l logging.Logger, namespace string, inv inventory.Recorder) error {
+	name := "pull-secrets"
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithInventory(inv),
+		// The copies live in other namespaces and DeploymentRuntimeConfigs
+		// may not exist, so neither is cached.
+		WithAPIReader(mgr.GetAPIReader()),
+	)
+
+	// Rotating a source Secret is mapped to the ConfigMap so that the copies
+	// follow. Changes to the copies and DeploymentRuntimeConfigs are undone
+	// on the next sync.
+	toConfigMap := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNamePullSecrets, Namespace: namespace}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNamePullSecrets)))).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, toConfigMap).
+		Complete(r)
+}
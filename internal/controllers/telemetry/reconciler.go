@@ -0,0 +1,225 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package telemetry contains the opt-in controller that reports anonymized
+// usage of Universal Crossplane.
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// Keys of the telemetry ConfigMap.
+const (
+	// KeyReport holds the last report, exactly as it was or would have
+	// been sent.
+	KeyReport = "report.json"
+	// KeyLastReported holds when the last report was generated, in RFC 3339
+	// format.
+	KeyLastReported = "lastReported"
+	// KeySummary holds a human readable summary of where the last report
+	// went.
+	KeySummary = "summary"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+
+	// DefaultInterval is how often a report is generated by default.
+	DefaultInterval = 24 * time.Hour
+
+	errGetConfigMap    = "cannot get telemetry configmap"
+	errCreateConfigMap = "cannot create telemetry configmap"
+	errUpdateConfigMap = "cannot update telemetry configmap"
+	errCount           = "cannot count crossplane objects"
+	errMarshalReport   = "cannot marshal telemetry report"
+
+	reasonSent       event.Reason = "TelemetrySent"
+	reasonSendFailed event.Reason = "CannotSendTelemetry"
+)
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithAPIReader specifies how the Reconciler should read the objects it
+// counts. An uncached reader avoids caching every managed resource.
+func WithAPIReader(c client.Reader) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.counter = NewCounter(c)
+	}
+}
+
+// WithSink specifies where the Reconciler should send reports. Reports are
+// only written to the telemetry ConfigMap if there is no sink.
+func WithSink(s Sink) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.sink = s
+	}
+}
+
+// WithDryRun specifies whether the Reconciler should only write reports to
+// the telemetry ConfigMap instead of sending them to its sink.
+func WithDryRun(dryRun bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.dryRun = dryRun
+	}
+}
+
+// WithInterval specifies how often the Reconciler should report.
+func WithInterval(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.interval = d
+	}
+}
+
+// Reconciler periodically reports the anonymized usage of Universal
+// Crossplane.
+type Reconciler struct {
+	client   client.Client
+	counter  *Counter
+	sink     Sink
+	dryRun   bool
+	interval time.Duration
+	log      logging.Logger
+	record   event.Recorder
+	now      func() time.Time
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client:   mgr.GetClient(),
+		counter:  NewCounter(mgr.GetClient()),
+		interval: DefaultInterval,
+		log:      logging.NewNopLogger(),
+		record:   event.NewNopRecorder(),
+		now:      time.Now,
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile generates a report if the interval passed since the last one,
+// sends it to the sink unless this is a dry run, and writes it to the
+// telemetry ConfigMap.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "telemetry.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	err = r.client.Get(ctx, req.NamespacedName, cm)
+	if kerrors.IsNotFound(err) {
+		cm.SetName(req.Name)
+		cm.SetNamespace(req.Namespace)
+		cm.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+		if err := r.client.Create(ctx, cm); err != nil {
+			return reconcile.Result{}, errors.Wrap(err, errCreateConfigMap)
+		}
+	} else if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetConfigMap)
+	}
+
+	now := r.now()
+	if last, err := time.Parse(time.RFC3339, cm.Data[KeyLastReported]); err == nil {
+		if next := last.Add(r.interval); now.Before(next) {
+			log.Debug("Not due to report yet", "next", next)
+			return reconcile.Result{RequeueAfter: next.Sub(now)}, nil
+		}
+	}
+
+	report := &Report{
+		SchemaVersion:     SchemaVersion,
+		GeneratedAt:       now.UTC().Truncate(time.Second),
+		UXPVersion:        version.Version,
+		CrossplaneVersion: version.CrossplaneVersion,
+	}
+	if err := r.counter.Count(ctx, report); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errCount)
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errMarshalReport)
+	}
+
+	var summary string
+	switch {
+	case r.dryRun && r.sink != nil:
+		summary = fmt.Sprintf("Dry run: report was not sent to %s", r.sink)
+		log.Info("Dry run, not sending telemetry report", "endpoint", r.sink.String(), "report", string(b))
+	case r.dryRun:
+		summary = "Dry run: report was not sent"
+		log.Info("Dry run, not sending telemetry report", "report", string(b))
+	case r.sink != nil:
+		if err := r.sink.Send(ctx, b); err != nil {
+			r.record.Event(cm, event.Warning(reasonSendFailed, err))
+			return reconcile.Result{}, err
+		}
+		summary = fmt.Sprintf("Report was sent to %s", r.sink)
+		r.record.Event(cm, event.Normal(reasonSent, summary))
+		log.Debug("Sent telemetry report", "endpoint", r.sink.String())
+	default:
+		summary = "Report was written to this ConfigMap only"
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[KeyReport] = string(b)
+	cm.Data[KeyLastReported] = report.GeneratedAt.Format(time.RFC3339)
+	cm.Data[KeySummary] = summary
+	if err := r.client.Update(ctx, cm); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errUpdateConfigMap)
+	}
+	return reconcile.Result{RequeueAfter: r.interval}, nil
+}
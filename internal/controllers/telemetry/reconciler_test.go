@@ -0,0 +1,217 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+var errBoom = errors.New("boom")
+
+func TestReconcile(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameTelemetry, Namespace: "upbound-system"}}
+
+	// reader returns a reader of a cluster with two Providers, one
+	// Composition, no Functions and three managed resources in two groups.
+	// XRDs are not served, and the managed resources of a third group may not
+	// be listed yet.
+	reader := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			obj.SetUID("cluster-uid")
+			return nil
+		},
+		MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+			switch l := list.(type) {
+			case *extv1.CustomResourceDefinitionList:
+				crd := func(group, kind string, categories ...string) extv1.CustomResourceDefinition {
+					return extv1.CustomResourceDefinition{Spec: extv1.CustomResourceDefinitionSpec{
+						Group:    group,
+						Names:    extv1.CustomResourceDefinitionNames{Kind: kind, Categories: categories},
+						Versions: []extv1.CustomResourceDefinitionVersion{{Name: "v1beta1", Storage: true}},
+					}}
+				}
+				l.Items = []extv1.CustomResourceDefinition{
+					crd("s3.aws.upbound.io", "Bucket", "crossplane", "managed", "aws"),
+					crd("ec2.aws.upbound.io", "VPC", "crossplane", "managed", "aws"),
+					crd("ec2.aws.upbound.io", "Subnet", "crossplane", "managed", "aws"),
+					crd("rds.aws.upbound.io", "Instance", "crossplane", "managed", "aws"),
+					crd("pkg.crossplane.io", "Lock"),
+				}
+			case *metav1.PartialObjectMetadataList:
+				n := map[string]int{"ProviderList": 2, "CompositionList": 1, "BucketList": 1, "VPCList": 1, "SubnetList": 1}
+				gvk := l.GroupVersionKind()
+				switch gvk.Kind {
+				case "CompositeResourceDefinitionList":
+					return &kmeta.NoKindMatchError{GroupKind: gvk.GroupKind()}
+				case "InstanceList":
+					return kerrors.NewForbidden(schema.GroupResource{Group: gvk.Group, Resource: "instances"}, "", errBoom)
+				}
+				l.Items = make([]metav1.PartialObjectMetadata, n[gvk.Kind])
+			}
+			return nil
+		},
+	}
+
+	report := Report{
+		SchemaVersion:     SchemaVersion,
+		ClusterID:         ClusterID("cluster-uid"),
+		GeneratedAt:       now,
+		UXPVersion:        version.Version,
+		CrossplaneVersion: version.CrossplaneVersion,
+		Providers:         2,
+		Compositions:      1,
+		ManagedResources:  map[string]int{"s3.aws.upbound.io": 1, "ec2.aws.upbound.io": 2},
+	}
+
+	type want struct {
+		result  reconcile.Result
+		err     error
+		report  *Report
+		summary string
+		sent    bool
+	}
+
+	cases := map[string]struct {
+		reason     string
+		lastReport string
+		dryRun     bool
+		sendErr    error
+		want       want
+	}{
+		"NotDue": {
+			reason:     "We should not report before the interval passed since the last report.",
+			lastReport: now.Add(-1 * time.Hour).Format(time.RFC3339),
+			want:       want{result: reconcile.Result{RequeueAfter: 23 * time.Hour}},
+		},
+		"Sent": {
+			reason:     "We should send the report and write it to the ConfigMap once the interval passed.",
+			lastReport: now.Add(-25 * time.Hour).Format(time.RFC3339),
+			want: want{
+				result:  reconcile.Result{RequeueAfter: DefaultInterval},
+				report:  &report,
+				summary: "Report was sent to ",
+				sent:    true,
+			},
+		},
+		"DryRun": {
+			reason: "We should only write the report to the ConfigMap in a dry run.",
+			dryRun: true,
+			want: want{
+				result:  reconcile.Result{RequeueAfter: DefaultInterval},
+				report:  &report,
+				summary: "Dry run: report was not sent to ",
+			},
+		},
+		"SendError": {
+			reason:  "We should return an error and not record the report if it cannot be sent.",
+			sendErr: errBoom,
+			want: want{
+				err:  errBoom,
+				sent: true,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *Report
+			var summary string
+			sent := false
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sent = true
+				b, _ := io.ReadAll(r.Body)
+				if diff := cmp.Diff(tc.want.report, decode(t, b)); tc.sendErr == nil && diff != "" {
+					t.Errorf("Send(...): -want report, +got report:\n%s", diff)
+				}
+				if tc.sendErr != nil {
+					http.Error(w, tc.sendErr.Error(), http.StatusInternalServerError)
+				}
+			}))
+			defer srv.Close()
+
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					if tc.lastReport != "" {
+						obj.(*corev1.ConfigMap).Data = map[string]string{KeyLastReported: tc.lastReport}
+					}
+					return nil
+				},
+				MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+					cm := obj.(*corev1.ConfigMap)
+					got = decode(t, []byte(cm.Data[KeyReport]))
+					summary = cm.Data[KeySummary]
+					return nil
+				},
+			}
+			r := NewReconciler(&fake.Manager{Client: kube}, WithAPIReader(reader), WithSink(NewHTTPSink(srv.URL)), WithDryRun(tc.dryRun))
+			r.now = func() time.Time { return now }
+			res, err := r.Reconcile(context.Background(), req)
+
+			wantErr := tc.want.err
+			if wantErr != nil {
+				wantErr = errors.Errorf(errFmtStatusCode, http.StatusInternalServerError, tc.sendErr.Error())
+			}
+			if diff := cmp.Diff(wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, res); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.report, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want report, +got report:\n%s", tc.reason, diff)
+			}
+			if tc.want.summary != "" && summary != tc.want.summary+srv.URL {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): want summary %q, got %q", tc.reason, tc.want.summary+srv.URL, summary)
+			}
+			if diff := cmp.Diff(tc.want.sent, sent); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want sent, +got sent:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func decode(t *testing.T, b []byte) *Report {
+	t.Helper()
+	r := &Report{}
+	if err := json.Unmarshal(b, r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
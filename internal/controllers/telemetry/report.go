@@ -0,0 +1,183 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/cluster/crds"
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+)
+
+// SchemaVersion is the version of the report schema. It changes whenever
+// fields are removed from or change meaning in the Report.
+const SchemaVersion = "v1"
+
+const (
+	// categoryManaged is the category of the CRDs of managed resources.
+	categoryManaged = "managed"
+	// clusterIDSalt is hashed with the cluster identity so that the cluster
+	// ID cannot be matched with the UIDs of the cluster.
+	clusterIDSalt = "uxp-telemetry"
+	// listLimit is the number of objects counted per list request.
+	listLimit = 500
+
+	errGetNamespace = "cannot get kube-system namespace to identify the cluster"
+	errListCRDs     = "cannot list CRDs to find managed resources"
+	errFmtCount     = "cannot count %s"
+)
+
+// GroupVersionKinds of the Crossplane types counted in a report.
+var (
+	CompositeResourceDefinitionGroupVersionKind = schema.GroupVersionKind{Group: "apiextensions.crossplane.io", Version: "v1", Kind: "CompositeResourceDefinition"} //nolint:gochecknoglobals // We treat this as a constant.
+	CompositionGroupVersionKind                 = schema.GroupVersionKind{Group: "apiextensions.crossplane.io", Version: "v1", Kind: "Composition"}                 //nolint:gochecknoglobals // We treat this as a constant.
+)
+
+// A Report of how Universal Crossplane is used. It must never contain names,
+// namespaces or any other information identifying the cluster or its users.
+type Report struct {
+	// SchemaVersion is the version of the report schema.
+	SchemaVersion string `json:"schemaVersion"`
+	// ClusterID is a salted hash of the UID of the kube-system namespace.
+	ClusterID string `json:"clusterID"`
+	// GeneratedAt is when the report was generated.
+	GeneratedAt time.Time `json:"generatedAt"`
+
+	// UXPVersion and CrossplaneVersion are the versions the bootstrapper
+	// was built for.
+	UXPVersion        string `json:"uxpVersion"`
+	CrossplaneVersion string `json:"crossplaneVersion"`
+
+	Providers                    int `json:"providers"`
+	Configurations               int `json:"configurations"`
+	Functions                    int `json:"functions"`
+	CompositeResourceDefinitions int `json:"compositeResourceDefinitions"`
+	Compositions                 int `json:"compositions"`
+
+	// ManagedResources is the number of managed resources by API group.
+	ManagedResources map[string]int `json:"managedResources"`
+}
+
+// ClusterID returns the anonymized identity of the cluster with the given
+// kube-system namespace UID.
+func ClusterID(uid types.UID) string {
+	h := sha256.Sum256([]byte(clusterIDSalt + ":" + string(uid)))
+	return hex.EncodeToString(h[:])
+}
+
+// A Counter counts the Crossplane objects in a cluster.
+type Counter struct {
+	reader client.Reader
+}
+
+// NewCounter returns a Counter that reads from the given reader. Objects are
+// counted by listing their metadata, so the reader should not be cached.
+func NewCounter(r client.Reader) *Counter {
+	return &Counter{reader: r}
+}
+
+// Count fills in the cluster ID and counts of the given report.
+func (c *Counter) Count(ctx context.Context, r *Report) error {
+	ns := &corev1.Namespace{}
+	if err := c.reader.Get(ctx, types.NamespacedName{Name: metav1.NamespaceSystem}, ns); err != nil {
+		return errors.Wrap(err, errGetNamespace)
+	}
+	r.ClusterID = ClusterID(ns.GetUID())
+
+	counts := []struct {
+		gvk schema.GroupVersionKind
+		n   *int
+	}{
+		{gvk: pkgv1.ProviderGroupVersionKind, n: &r.Providers},
+		{gvk: pkgv1.ConfigurationGroupVersionKind, n: &r.Configurations},
+		{gvk: pkgv1.FunctionGroupVersionKind, n: &r.Functions},
+		{gvk: CompositeResourceDefinitionGroupVersionKind, n: &r.CompositeResourceDefinitions},
+		{gvk: CompositionGroupVersionKind, n: &r.Compositions},
+	}
+	for _, cnt := range counts {
+		n, err := c.count(ctx, cnt.gvk)
+		if err != nil {
+			return err
+		}
+		*cnt.n = n
+	}
+
+	l := &extv1.CustomResourceDefinitionList{}
+	if err := c.reader.List(ctx, l); err != nil {
+		return errors.Wrap(err, errListCRDs)
+	}
+	r.ManagedResources = map[string]int{}
+	for i := range l.Items {
+		crd := &l.Items[i]
+		if !isManaged(crd) {
+			continue
+		}
+		n, err := c.count(ctx, schema.GroupVersionKind{Group: crd.Spec.Group, Version: crds.StorageVersion(crd), Kind: crd.Spec.Names.Kind})
+		if kerrors.IsForbidden(errors.Cause(err)) {
+			// Managed resources are only readable once the RBAC manager
+			// granted access to them, so we skip them until then.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		r.ManagedResources[crd.Spec.Group] += n
+	}
+	return nil
+}
+
+// count returns the number of objects of the given kind, which is zero if
+// the kind is not served.
+func (c *Counter) count(ctx context.Context, gvk schema.GroupVersionKind) (int, error) {
+	l := &metav1.PartialObjectMetadataList{}
+	l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	n := 0
+	for {
+		err := c.reader.List(ctx, l, client.Limit(listLimit), client.Continue(l.GetContinue()))
+		if kmeta.IsNoMatchError(err) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, errFmtCount, gvk.Kind)
+		}
+		n += len(l.Items)
+		if l.GetContinue() == "" {
+			return n, nil
+		}
+	}
+}
+
+func isManaged(crd *extv1.CustomResourceDefinition) bool {
+	for _, c := range crd.Spec.Names.Categories {
+		if c == categoryManaged {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+// Setup adds the controller that reports the anonymized usage of Universal
+// Crossplane every interval. Reports are posted to the given endpoint, or
+// only written to the telemetry ConfigMap if it is empty or this is a dry
+// run.
+func Setup(mgr ctrl.Manager, l logging.Logger, endpoint string, dryRun bool, interval time.Duration) error {
+	name := "telemetry"
+	opts := []ReconcilerOption{
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithAPIReader(mgr.GetAPIReader()),
+		WithDryRun(dryRun),
+		WithInterval(interval),
+	}
+	if endpoint != "" {
+		opts = append(opts, WithSink(NewHTTPSink(endpoint)))
+	}
+	r := NewReconciler(mgr, opts...)
+
+	// The bootstrapper Deployment triggers the first reconcile, since the
+	// telemetry ConfigMap may not exist yet. Later reports are scheduled by
+	// requeueing.
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}},
+			handler.EnqueueRequestsFromMapFunc(toTelemetryConfigMap),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+				return o.GetName() == meta.DeploymentNameBootstrapper
+			}))).
+		Complete(r)
+}
+
+func toTelemetryConfigMap(o client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameTelemetry, Namespace: o.GetNamespace()}}}
+}
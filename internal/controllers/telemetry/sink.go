@@ -0,0 +1,81 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+const (
+	sendTimeout = 30 * time.Second
+
+	errNewRequest    = "cannot build telemetry request"
+	errSend          = "cannot send telemetry report"
+	errFmtStatusCode = "telemetry endpoint responded with %d: %s"
+)
+
+// A Sink receives telemetry reports.
+type Sink interface {
+	// Send sends the given JSON encoded report.
+	Send(ctx context.Context, report []byte) error
+
+	// String describes where reports are sent.
+	String() string
+}
+
+// HTTPSink posts reports to an HTTP endpoint.
+type HTTPSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPSink returns a Sink that posts reports to the given endpoint.
+func NewHTTPSink(endpoint string) *HTTPSink {
+	return &HTTPSink{endpoint: endpoint, client: &http.Client{Timeout: sendTimeout}}
+}
+
+// Send posts the report and returns an error if the endpoint does not
+// accept it.
+func (s *HTTPSink) Send(ctx context.Context, report []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(report))
+	if err != nil {
+		return errors.Wrap(err, errNewRequest)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "uxp-bootstrapper/"+version.Version)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, errSend)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Nothing to do if closing the body fails.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf(errFmtStatusCode, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// String returns the endpoint of the sink.
+func (s *HTTPSink) String() string {
+	return s.endpoint
+}
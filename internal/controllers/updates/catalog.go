@@ -0,0 +1,213 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updates
+
+import (
+	"bytes"
+	"context"
+	_ "embed" // Required for go:embed.
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+const (
+	fetchTimeout = 30 * time.Second
+
+	// maxCatalogSize is the largest catalog that is read.
+	maxCatalogSize = 1 << 20
+
+	// dateFormat is the format of the dates in the catalog.
+	dateFormat = "2006-01-02"
+
+	errParseCatalog     = "cannot parse release catalog"
+	errNewRequest       = "cannot build catalog request"
+	errFetch            = "cannot fetch release catalog"
+	errFmtStatusCode    = "catalog endpoint responded with %d: %s"
+	errFmtVersion       = "invalid release %q"
+	errFmtEndOfLife     = "invalid end of life version %q, must be MAJOR.MINOR"
+	errFmtEndOfLifeDate = "invalid end of life date %q of %s, must be YYYY-MM-DD"
+)
+
+// bundled is the catalog bundled with this release. It is used if no other
+// catalog is available, so it only knows about the releases up to this one.
+//
+//go:embed catalog.yaml
+var bundled []byte
+
+// A Release of Universal Crossplane.
+type Release struct {
+	// Version of the release, e.g. v1.20.4-up.2.
+	Version string `json:"version"`
+	// Security is true if the release fixes vulnerabilities.
+	Security bool `json:"security,omitempty"`
+	// Advisories are the vulnerabilities the release fixes, e.g. CVE IDs.
+	Advisories []string `json:"advisories,omitempty"`
+
+	v version.Semver
+}
+
+// EndOfLife is when a minor version of Universal Crossplane stops receiving
+// patches.
+type EndOfLife struct {
+	// Version is the minor version, e.g. 1.18.
+	Version string `json:"version"`
+	// Date is the first day the minor version is not supported, e.g.
+	// 2025-05-01.
+	Date string `json:"date"`
+
+	major, minor int
+	date         time.Time
+}
+
+// A Catalog lists the releases of Universal Crossplane.
+type Catalog struct {
+	Releases  []Release   `json:"releases"`
+	EndOfLife []EndOfLife `json:"endOfLife,omitempty"`
+}
+
+// ParseCatalog parses and validates the given release catalog.
+func ParseCatalog(data []byte) (Catalog, error) {
+	c := Catalog{}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return Catalog{}, errors.Wrap(err, errParseCatalog)
+	}
+	for i := range c.Releases {
+		r := &c.Releases[i]
+		v, err := version.Parse(r.Version)
+		if err != nil {
+			return Catalog{}, errors.Wrapf(err, errFmtVersion, r.Version)
+		}
+		r.v = v
+	}
+	for i := range c.EndOfLife {
+		e := &c.EndOfLife[i]
+		if _, err := fmt.Sscanf(e.Version, "%d.%d", &e.major, &e.minor); err != nil || fmt.Sprintf("%d.%d", e.major, e.minor) != e.Version {
+			return Catalog{}, errors.Errorf(errFmtEndOfLife, e.Version)
+		}
+		d, err := time.Parse(dateFormat, e.Date)
+		if err != nil {
+			return Catalog{}, errors.Errorf(errFmtEndOfLifeDate, e.Date, e.Version)
+		}
+		e.date = d
+	}
+	return c, nil
+}
+
+// Bundled returns the catalog bundled with this release.
+func Bundled() (Catalog, error) {
+	return ParseCatalog(bundled)
+}
+
+// Availability of updates for a version of Universal Crossplane.
+type Availability struct {
+	// LatestPatch is the newest patch release of the same minor version, if
+	// it is newer.
+	LatestPatch string
+	// Latest is the newest release, if it is newer.
+	Latest string
+	// Security is true if any newer patch release of the same minor version
+	// fixes vulnerabilities, and Advisories lists them.
+	Security   bool
+	Advisories []string
+	// EndOfLife is when the minor version stops being supported, or zero if
+	// it is not known.
+	EndOfLife time.Time
+}
+
+// Check returns the updates available for the given version.
+func (c Catalog) Check(current version.Semver) Availability {
+	a := Availability{}
+	latest, patch := current, current
+	for _, r := range c.Releases {
+		if !current.LessThan(r.v) {
+			continue
+		}
+		if latest.LessThan(r.v) {
+			latest = r.v
+			a.Latest = r.Version
+		}
+		if r.v.Major != current.Major || r.v.Minor != current.Minor {
+			continue
+		}
+		if patch.LessThan(r.v) {
+			patch = r.v
+			a.LatestPatch = r.Version
+		}
+		if r.Security {
+			a.Security = true
+			a.Advisories = append(a.Advisories, r.Advisories...)
+		}
+	}
+	for _, e := range c.EndOfLife {
+		if e.major == current.Major && e.minor == current.Minor {
+			a.EndOfLife = e.date
+		}
+	}
+	return a
+}
+
+// A Fetcher fetches a release catalog.
+type Fetcher interface {
+	// Fetch returns the raw catalog.
+	Fetch(ctx context.Context) ([]byte, error)
+
+	// String describes where the catalog is fetched from.
+	String() string
+}
+
+// HTTPFetcher fetches the catalog from an HTTP URL, e.g. a mirror inside
+// the network of the cluster.
+type HTTPFetcher struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPFetcher returns a Fetcher that gets the catalog from the given URL.
+func NewHTTPFetcher(url string) *HTTPFetcher {
+	return &HTTPFetcher{url: url, client: &http.Client{Timeout: fetchTimeout}}
+}
+
+// Fetch gets the catalog.
+func (f *HTTPFetcher) Fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewRequest)
+	}
+	req.Header.Set("User-Agent", "uxp-bootstrapper/"+version.Version)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, errFetch)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Nothing to do if closing the body fails.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, errors.Errorf(errFmtStatusCode, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxCatalogSize))
+	return b, errors.Wrap(err, errFetch)
+}
+
+// String returns the URL of the catalog.
+func (f *HTTPFetcher) String() string {
+	return f.url
+}
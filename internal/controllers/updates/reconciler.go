@@ -0,0 +1,347 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package updates contains the controller that reports the updates of
+// Universal Crossplane that are available according to a release catalog.
+package updates
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/controllers/versions"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/metrics"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// Keys of the updates ConfigMap.
+const (
+	// KeyCatalog holds a mirrored release catalog. It takes precedence over
+	// the catalog URL and the bundled catalog.
+	KeyCatalog = "catalog.yaml"
+	// KeyStatus holds the updates available as a Status, written by the
+	// controller.
+	KeyStatus = "status.yaml"
+	// KeySummary holds a human readable summary of the updates available.
+	KeySummary = "summary"
+)
+
+// Sources of the catalog.
+const (
+	SourceConfigMap = "ConfigMap"
+	SourceBundled   = "Bundled"
+)
+
+// Condition types.
+const (
+	// TypeUpdateAvailable is true if a newer patch release of the running
+	// minor version is available.
+	TypeUpdateAvailable xpv1.ConditionType = "UpdateAvailable"
+	// TypeEndOfLife is true if the running minor version is not supported
+	// anymore.
+	TypeEndOfLife xpv1.ConditionType = "EndOfLife"
+)
+
+// Condition reasons.
+const (
+	ReasonUpToDate       xpv1.ConditionReason = "UpToDate"
+	ReasonPatchAvailable xpv1.ConditionReason = "PatchAvailable"
+	ReasonSecurityPatch  xpv1.ConditionReason = "SecurityPatchAvailable"
+	ReasonSupported      xpv1.ConditionReason = "Supported"
+	ReasonEndOfLifeSoon  xpv1.ConditionReason = "EndOfLifeSoon"
+	ReasonEndOfLife      xpv1.ConditionReason = "EndOfLife"
+	ReasonUnknown        xpv1.ConditionReason = "Unknown"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+
+	// DefaultInterval is how often the catalog is checked by default.
+	DefaultInterval = 6 * time.Hour
+
+	// endOfLifeWarning is how long before its end of life a minor version is
+	// reported as reaching it soon.
+	endOfLifeWarning = 30 * 24 * time.Hour
+
+	errGetConfigMap    = "cannot get updates configmap"
+	errUpdateConfigMap = "cannot update updates configmap"
+	errGetVersions     = "cannot get versions configmap"
+	errMarshalStatus   = "cannot marshal updates status"
+	errFmtCurrent      = "cannot determine the running Universal Crossplane version from %q"
+
+	reasonCatalogUnavailable event.Reason = "CatalogUnavailable"
+	reasonUpdateAvailable    event.Reason = "UpdateAvailable"
+	reasonSecurityUpdate     event.Reason = "SecurityUpdateAvailable"
+	reasonEndOfLife          event.Reason = "EndOfLife"
+)
+
+// Status of the updates of the running Universal Crossplane.
+type Status struct {
+	// Current is the running version.
+	Current string `json:"current"`
+	// Catalog is where the catalog was read from, ConfigMap, Bundled or its
+	// URL.
+	Catalog string `json:"catalog"`
+	// LatestPatch is the newest patch release of the running minor version,
+	// if it is newer.
+	LatestPatch string `json:"latestPatch,omitempty"`
+	// Latest is the newest release, if it is newer.
+	Latest string `json:"latest,omitempty"`
+	// Advisories are the vulnerabilities fixed by the newer patch releases.
+	Advisories []string `json:"advisories,omitempty"`
+	// EndOfLife is the date the running minor version stops being supported.
+	EndOfLife string `json:"endOfLife,omitempty"`
+
+	xpv1.ConditionedStatus `json:",inline"`
+}
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithFetcher specifies where the Reconciler fetches the catalog from if the
+// ConfigMap has none. The bundled catalog is used if it is nil or fails.
+func WithFetcher(f Fetcher) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.fetcher = f
+	}
+}
+
+// WithInterval specifies how often the Reconciler checks the catalog.
+func WithInterval(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.interval = d
+	}
+}
+
+// Reconciler compares the running Universal Crossplane version with a
+// release catalog.
+type Reconciler struct {
+	client   client.Client
+	log      logging.Logger
+	record   event.Recorder
+	fetcher  Fetcher
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client:   mgr.GetClient(),
+		log:      logging.NewNopLogger(),
+		record:   event.NewNopRecorder(),
+		interval: DefaultInterval,
+		now:      time.Now,
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile writes the updates available for the running Universal
+// Crossplane to the updates ConfigMap and records events when an update
+// becomes available or the running minor version reaches its end of life.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "updates.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, req.NamespacedName, cm); err != nil {
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGetConfigMap)
+	}
+	prev := Status{}
+	_ = yaml.Unmarshal([]byte(cm.Data[KeyStatus]), &prev)
+
+	current, err := r.current(ctx, req.Namespace)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	cat, source := r.catalog(ctx, cm, log)
+	a := cat.Check(current)
+
+	now := r.now()
+	s := Status{Current: current.String(), Catalog: source, LatestPatch: a.LatestPatch, Latest: a.Latest, Advisories: a.Advisories}
+	s.SetConditions(prev.Conditions...)
+	s.SetConditions(updateAvailable(a, now), endOfLife(current, a.EndOfLife, now))
+	if !a.EndOfLife.IsZero() {
+		s.EndOfLife = a.EndOfLife.Format(dateFormat)
+	}
+
+	upd, eol := s.GetCondition(TypeUpdateAvailable), s.GetCondition(TypeEndOfLife)
+	if upd.Status == corev1.ConditionTrue && !upd.Equal(prev.GetCondition(TypeUpdateAvailable)) {
+		e := event.Normal(reasonUpdateAvailable, upd.Message)
+		if a.Security {
+			e = event.Warning(reasonSecurityUpdate, errors.New(upd.Message))
+		}
+		r.record.Event(cm, e)
+		log.Info("Update available", "current", s.Current, "latestPatch", s.LatestPatch, "security", a.Security)
+	}
+	if eol.Status == corev1.ConditionTrue && !eol.Equal(prev.GetCondition(TypeEndOfLife)) {
+		r.record.Event(cm, event.Warning(reasonEndOfLife, errors.New(eol.Message)))
+		log.Info("End of life", "current", s.Current, "endOfLife", s.EndOfLife)
+	}
+	metrics.SetUpdates(a.LatestPatch != "", a.Security, fmt.Sprintf("%d.%d", current.Major, current.Minor), a.EndOfLife)
+
+	return reconcile.Result{RequeueAfter: r.interval}, r.writeStatus(ctx, cm, s, summary(s))
+}
+
+// current returns the running Universal Crossplane version from the versions
+// ConfigMap, or the version bundled with this release if it is not reported.
+func (r *Reconciler) current(ctx context.Context, namespace string) (version.Semver, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: meta.ConfigMapNameVersions, Namespace: namespace}, cm); client.IgnoreNotFound(err) != nil {
+		return version.Semver{}, errors.Wrap(err, errGetVersions)
+	}
+	raw := cm.Data[versions.KeyCrossplaneVersion]
+	if raw == "" {
+		raw = version.CrossplaneVersion
+	}
+	v, err := version.Parse(raw)
+	return v, errors.Wrapf(err, errFmtCurrent, raw)
+}
+
+// catalog returns the catalog in the ConfigMap, the fetched catalog or the
+// bundled catalog, whichever is available first, and where it came from.
+// Catalogs that are not available are reported with events.
+func (r *Reconciler) catalog(ctx context.Context, cm *corev1.ConfigMap, log logging.Logger) (Catalog, string) {
+	if raw, ok := cm.Data[KeyCatalog]; ok {
+		c, err := ParseCatalog([]byte(raw))
+		if err == nil {
+			return c, SourceConfigMap
+		}
+		log.Info("Invalid release catalog in ConfigMap", "error", err.Error())
+		r.record.Event(cm, event.Warning(reasonCatalogUnavailable, errors.Wrap(err, SourceConfigMap)))
+	}
+	if r.fetcher != nil {
+		b, err := r.fetcher.Fetch(ctx)
+		if err == nil {
+			var c Catalog
+			if c, err = ParseCatalog(b); err == nil {
+				return c, r.fetcher.String()
+			}
+		}
+		log.Info("Cannot fetch release catalog", "url", r.fetcher.String(), "error", err.Error())
+		r.record.Event(cm, event.Warning(reasonCatalogUnavailable, errors.Wrap(err, r.fetcher.String())))
+	}
+	// The bundled catalog is validated by the tests, so it always parses.
+	c, _ := Bundled()
+	return c, SourceBundled
+}
+
+func updateAvailable(a Availability, now time.Time) xpv1.Condition {
+	c := xpv1.Condition{Type: TypeUpdateAvailable, Status: corev1.ConditionFalse, Reason: ReasonUpToDate, LastTransitionTime: metav1.NewTime(now)}
+	switch {
+	case a.LatestPatch != "" && a.Security:
+		c.Status, c.Reason = corev1.ConditionTrue, ReasonSecurityPatch
+		c.Message = fmt.Sprintf("Patch release %s fixes vulnerabilities", a.LatestPatch)
+		if len(a.Advisories) > 0 {
+			c.Message += ": " + strings.Join(a.Advisories, ", ")
+		}
+	case a.LatestPatch != "":
+		c.Status, c.Reason = corev1.ConditionTrue, ReasonPatchAvailable
+		c.Message = fmt.Sprintf("Patch release %s is available", a.LatestPatch)
+	}
+	return c
+}
+
+func endOfLife(current version.Semver, eol, now time.Time) xpv1.Condition {
+	c := xpv1.Condition{Type: TypeEndOfLife, LastTransitionTime: metav1.NewTime(now)}
+	minor := fmt.Sprintf("%d.%d", current.Major, current.Minor)
+	switch {
+	case eol.IsZero():
+		c.Status, c.Reason = corev1.ConditionUnknown, ReasonUnknown
+		c.Message = fmt.Sprintf("The end of life of %s is not known", minor)
+	case !now.Before(eol):
+		c.Status, c.Reason = corev1.ConditionTrue, ReasonEndOfLife
+		c.Message = fmt.Sprintf("%s reached its end of life on %s", minor, eol.Format(dateFormat))
+	case eol.Sub(now) < endOfLifeWarning:
+		c.Status, c.Reason = corev1.ConditionFalse, ReasonEndOfLifeSoon
+		c.Message = fmt.Sprintf("%s reaches its end of life on %s", minor, eol.Format(dateFormat))
+	default:
+		c.Status, c.Reason = corev1.ConditionFalse, ReasonSupported
+		c.Message = fmt.Sprintf("%s is supported until %s", minor, eol.Format(dateFormat))
+	}
+	return c
+}
+
+func summary(s Status) string {
+	parts := []string{fmt.Sprintf("Universal Crossplane %s", s.Current)}
+	if c := s.GetCondition(TypeUpdateAvailable); c.Status == corev1.ConditionTrue {
+		parts = append(parts, c.Message)
+	} else {
+		parts = append(parts, "is up to date")
+	}
+	if c := s.GetCondition(TypeEndOfLife); c.Status != corev1.ConditionUnknown {
+		parts = append(parts, c.Message)
+	}
+	if s.Latest != "" && s.Latest != s.LatestPatch {
+		parts = append(parts, fmt.Sprintf("%s is the latest release", s.Latest))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// writeStatus writes the status to the ConfigMap if it changed.
+func (r *Reconciler) writeStatus(ctx context.Context, cm *corev1.ConfigMap, s Status, summary string) error {
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, errMarshalStatus)
+	}
+	if cm.Data[KeyStatus] == string(b) && cm.Data[KeySummary] == summary {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[KeyStatus], cm.Data[KeySummary] = string(b), summary
+	return errors.Wrap(r.client.Update(ctx, cm), errUpdateConfigMap)
+}
@@ -0,0 +1,313 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updates
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/controllers/versions"
+	"github.com/upbound/universal-crossplane/internal/eventtest"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+var errBoom = errors.New("boom")
+
+const catalog = `
+releases:
+- version: v1.20.4-up.1
+- version: v1.20.5-up.1
+  security: true
+  advisories: [CVE-2025-0001]
+- version: v1.20.6-up.1
+- version: v1.21.0-up.1
+endOfLife:
+- version: "1.20"
+  date: "2025-06-01"
+`
+
+func TestBundled(t *testing.T) {
+	if _, err := Bundled(); err != nil {
+		t.Errorf("Bundled(): %s", err)
+	}
+}
+
+func parseErr(v string) error {
+	_, err := version.Parse(v)
+	return err
+}
+
+func TestParseCatalog(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   error
+	}{
+		"Valid": {
+			reason: "A catalog with valid versions and dates should be parsed.",
+			data:   catalog,
+		},
+		"InvalidVersion": {
+			reason: "Releases must be semantic versions.",
+			data:   "releases: [{version: latest}]",
+			want:   errors.Wrapf(parseErr("latest"), errFmtVersion, "latest"),
+		},
+		"InvalidEndOfLifeVersion": {
+			reason: "End of life versions must be minor versions.",
+			data:   "endOfLife: [{version: 1.20.1, date: 2025-06-01}]",
+			want:   errors.Errorf(errFmtEndOfLife, "1.20.1"),
+		},
+		"InvalidEndOfLifeDate": {
+			reason: "End of life dates must be dates.",
+			data:   `endOfLife: [{version: "1.20", date: June}]`,
+			want:   errors.Errorf(errFmtEndOfLifeDate, "June", "1.20"),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseCatalog([]byte(tc.data))
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseCatalog(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c, err := ParseCatalog([]byte(catalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eol := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := map[string]struct {
+		reason  string
+		current string
+		want    Availability
+	}{
+		"SecurityPatch": {
+			reason:  "Newer patch releases that fix vulnerabilities should be reported with their advisories.",
+			current: "v1.20.4-up.1",
+			want:    Availability{LatestPatch: "v1.20.6-up.1", Latest: "v1.21.0-up.1", Security: true, Advisories: []string{"CVE-2025-0001"}, EndOfLife: eol},
+		},
+		"Patch": {
+			reason:  "Security fixes that were already applied should not be reported.",
+			current: "v1.20.5-up.1",
+			want:    Availability{LatestPatch: "v1.20.6-up.1", Latest: "v1.21.0-up.1", EndOfLife: eol},
+		},
+		"LatestPatch": {
+			reason:  "Only newer minor versions should be reported for the latest patch release.",
+			current: "v1.20.6-up.1",
+			want:    Availability{Latest: "v1.21.0-up.1", EndOfLife: eol},
+		},
+		"Latest": {
+			reason:  "Nothing should be reported for the latest release, whose end of life is not known.",
+			current: "v1.21.0-up.1",
+			want:    Availability{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := c.Check(version.MustParse(tc.current))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nCheck(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+type fetcherFn func(ctx context.Context) ([]byte, error)
+
+func (fn fetcherFn) Fetch(ctx context.Context) ([]byte, error) {
+	return fn(ctx)
+}
+
+func (fn fetcherFn) String() string {
+	return "https://example.org/catalog.yaml"
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/catalog.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(catalog))
+	}))
+	defer srv.Close()
+
+	got, err := NewHTTPFetcher(srv.URL + "/catalog.yaml").Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch(...): %s", err)
+	}
+	if diff := cmp.Diff(catalog, string(got)); diff != "" {
+		t.Errorf("Fetch(...): -want, +got:\n%s", diff)
+	}
+	_, err = NewHTTPFetcher(srv.URL + "/missing.yaml").Fetch(context.Background())
+	if diff := cmp.Diff(errors.Errorf(errFmtStatusCode, http.StatusNotFound, []byte{}), err, test.EquateErrors()); diff != "" {
+		t.Errorf("Fetch(...): -want error, +got error:\n%s", diff)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	now := time.Date(2025, 5, 15, 0, 0, 0, 0, time.UTC)
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+
+	// kube returns a client that reports the given running version and an
+	// updates ConfigMap with the given data.
+	kube := func(running string, data map[string]string) *test.MockClient {
+		return &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+				cm := obj.(*corev1.ConfigMap)
+				if key.Name == meta.ConfigMapNameVersions {
+					cm.Data = map[string]string{versions.KeyCrossplaneVersion: running}
+					return nil
+				}
+				cm.Data = data
+				return nil
+			},
+		}
+	}
+	previous := func(s Status) string {
+		b, _ := yaml.Marshal(s)
+		return string(b)
+	}
+	security := xpv1.Condition{Type: TypeUpdateAvailable, Status: corev1.ConditionTrue, Reason: ReasonSecurityPatch, Message: "Patch release v1.20.6-up.1 fixes vulnerabilities: CVE-2025-0001", LastTransitionTime: metav1.NewTime(now)}
+	eolSoon := xpv1.Condition{Type: TypeEndOfLife, Status: corev1.ConditionFalse, Reason: ReasonEndOfLifeSoon, Message: "1.20 reaches its end of life on 2025-06-01", LastTransitionTime: metav1.NewTime(now)}
+
+	type want struct {
+		err    error
+		status *Status
+		events []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason  string
+		kube    *test.MockClient
+		fetcher Fetcher
+		want    want
+	}{
+		"GetError": {
+			reason: "We should return an error if the ConfigMap cannot be read.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			want:   want{err: errors.Wrap(errBoom, errGetConfigMap)},
+		},
+		"ConfigMapCatalog": {
+			reason: "A catalog mirrored into the ConfigMap should take precedence over the fetched one, and new security updates should be reported with an event.",
+			kube:   kube("v1.20.4-up.1", map[string]string{KeyCatalog: catalog}),
+			fetcher: fetcherFn(func(context.Context) ([]byte, error) {
+				return nil, errors.New("the fetched catalog should not be used")
+			}),
+			want: want{
+				status: &Status{
+					Current: "v1.20.4-up.1", Catalog: SourceConfigMap, LatestPatch: "v1.20.6-up.1", Latest: "v1.21.0-up.1", Advisories: []string{"CVE-2025-0001"}, EndOfLife: "2025-06-01",
+					ConditionedStatus: xpv1.ConditionedStatus{Conditions: []xpv1.Condition{security, eolSoon}},
+				},
+				events: []event.Reason{reasonSecurityUpdate},
+			},
+		},
+		"FetchedCatalog": {
+			reason: "Updates that were already reported should not be reported again, and should keep their transition time.",
+			kube: kube("v1.20.5-up.1", map[string]string{KeyStatus: previous(Status{ConditionedStatus: xpv1.ConditionedStatus{Conditions: []xpv1.Condition{
+				{Type: TypeUpdateAvailable, Status: corev1.ConditionTrue, Reason: ReasonPatchAvailable, Message: "Patch release v1.20.6-up.1 is available", LastTransitionTime: earlier},
+			}}})}),
+			fetcher: fetcherFn(func(context.Context) ([]byte, error) { return []byte(catalog), nil }),
+			want: want{
+				status: &Status{
+					Current: "v1.20.5-up.1", Catalog: "https://example.org/catalog.yaml", LatestPatch: "v1.20.6-up.1", Latest: "v1.21.0-up.1", EndOfLife: "2025-06-01",
+					ConditionedStatus: xpv1.ConditionedStatus{Conditions: []xpv1.Condition{
+						{Type: TypeUpdateAvailable, Status: corev1.ConditionTrue, Reason: ReasonPatchAvailable, Message: "Patch release v1.20.6-up.1 is available", LastTransitionTime: earlier},
+						eolSoon,
+					}},
+				},
+			},
+		},
+		"BundledCatalog": {
+			reason: "The bundled catalog should be used if the catalog cannot be fetched, which should be reported with an event.",
+			kube:   kube("v1.20.4-up.1", nil),
+			fetcher: fetcherFn(func(context.Context) ([]byte, error) {
+				return nil, errBoom
+			}),
+			want: want{
+				status: &Status{
+					Current: "v1.20.4-up.1", Catalog: SourceBundled,
+					ConditionedStatus: xpv1.ConditionedStatus{Conditions: []xpv1.Condition{
+						{Type: TypeUpdateAvailable, Status: corev1.ConditionFalse, Reason: ReasonUpToDate, LastTransitionTime: metav1.NewTime(now)},
+						{Type: TypeEndOfLife, Status: corev1.ConditionUnknown, Reason: ReasonUnknown, Message: "The end of life of 1.20 is not known", LastTransitionTime: metav1.NewTime(now)},
+					}},
+				},
+				events: []event.Reason{reasonCatalogUnavailable},
+			},
+		},
+		"EndOfLife": {
+			reason: "Minor versions past their end of life should be reported with an event.",
+			kube:   kube("v1.20.6-up.1", map[string]string{KeyCatalog: catalog + "- version: \"1.20\"\n  date: \"2025-05-01\"\n"}),
+			want: want{
+				status: &Status{
+					Current: "v1.20.6-up.1", Catalog: SourceConfigMap, Latest: "v1.21.0-up.1", EndOfLife: "2025-05-01",
+					ConditionedStatus: xpv1.ConditionedStatus{Conditions: []xpv1.Condition{
+						{Type: TypeUpdateAvailable, Status: corev1.ConditionFalse, Reason: ReasonUpToDate, LastTransitionTime: metav1.NewTime(now)},
+						{Type: TypeEndOfLife, Status: corev1.ConditionTrue, Reason: ReasonEndOfLife, Message: "1.20 reached its end of life on 2025-05-01", LastTransitionTime: metav1.NewTime(now)},
+					}},
+				},
+				events: []event.Reason{reasonEndOfLife},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *Status
+			tc.kube.MockUpdate = func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+				got = &Status{}
+				if err := yaml.Unmarshal([]byte(obj.(*corev1.ConfigMap).Data[KeyStatus]), got); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			}
+			rec := &eventtest.Recorder{}
+			r := NewReconciler(&fake.Manager{Client: tc.kube}, WithRecorder(rec), WithFetcher(tc.fetcher))
+			r.now = func() time.Time { return now }
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameUpdates, Namespace: "upbound-system"}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.status, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want status, +got status:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.Reasons); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
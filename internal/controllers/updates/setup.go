@@ -0,0 +1,64 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updates
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+// Setup adds the controller that reports the updates available for the
+// running Universal Crossplane in the updates ConfigMap in the given
+// namespace. The catalog is read from the ConfigMap, from the given URL if it
+// is not empty, or from the catalog bundled with this release, whichever is
+// available first.
+func Setup(mgr ctrl.Manager, l logging.Logger, namespace, url string, interval time.Duration) error {
+	name := "updates"
+	opts := []ReconcilerOption{
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithInterval(interval),
+	}
+	if url != "" {
+		opts = append(opts, WithFetcher(NewHTTPFetcher(url)))
+	}
+	r := NewReconciler(mgr, opts...)
+
+	// Changes to the running Universal Crossplane version are mapped to the
+	// ConfigMap in our namespace.
+	toConfigMap := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameUpdates, Namespace: namespace}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNameUpdates)))).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, toConfigMap, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNameVersions)))).
+		Complete(r)
+}
@@ -0,0 +1,307 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package versions contains the controller that reports the versions of the
+// running Universal Crossplane components.
+package versions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+	"github.com/upbound/universal-crossplane/internal/xpkg"
+)
+
+const (
+	reconcileTimeout = 1 * time.Minute
+	syncPeriod       = 10 * time.Minute
+
+	errGetConfigMap    = "cannot get versions configmap"
+	errCreateConfigMap = "cannot create versions configmap"
+	errUpdateConfigMap = "cannot update versions configmap"
+	errFmtGetDeploy    = "cannot get deployment %s"
+	errFmtListPods     = "cannot list pods of deployment %s"
+
+	reasonVersionDrift    event.Reason = "VersionDrift"
+	reasonVersionsInSync  event.Reason = "VersionsInSync"
+	reasonVersionsUpdated event.Reason = "VersionsUpdated"
+)
+
+// Keys of the versions ConfigMap that are not specific to a component.
+const (
+	KeyUXPVersion   = "uxpVersion"
+	KeyVersionDrift = "versionDrift"
+)
+
+// KeyCrossplaneVersion is the key of the versions ConfigMap that holds the
+// version of the running Crossplane, i.e. the VersionKey of the crossplane
+// component.
+const KeyCrossplaneVersion = "crossplaneVersion"
+
+// A Component of Universal Crossplane that runs as a Deployment.
+type Component struct {
+	// Key is the prefix of the ConfigMap keys of the component.
+	Key string
+	// Deployment is the name of the Deployment of the component.
+	Deployment string
+	// Expected is the version of the component that is bundled with this
+	// release. Drift is not reported if it is empty.
+	Expected string
+}
+
+// VersionKey returns the ConfigMap key that holds the version of the
+// component.
+func (c Component) VersionKey() string { return c.Key + "Version" }
+
+// ImageKey returns the ConfigMap key that holds the image of the component.
+func (c Component) ImageKey() string { return c.Key + "Image" }
+
+// DigestKey returns the ConfigMap key that holds the image digest of the
+// component.
+func (c Component) DigestKey() string { return c.Key + "Digest" }
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithComponents specifies the components whose versions are reported.
+func WithComponents(c ...Component) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.components = c
+	}
+}
+
+// WithUXPVersion specifies the version of Universal Crossplane.
+func WithUXPVersion(v string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.uxpVersion = v
+	}
+}
+
+// Reconciler reconciles the versions ConfigMap with the running components.
+type Reconciler struct {
+	client client.Client
+	log    logging.Logger
+	record event.Recorder
+
+	components []Component
+	uxpVersion string
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client: mgr.GetClient(),
+		log:    logging.NewNopLogger(),
+		record: event.NewNopRecorder(),
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile writes the versions, images and digests of the running components
+// to the versions ConfigMap and reports drift from the expected versions.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "versions.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	data := map[string]string{}
+	if r.uxpVersion != "" {
+		data[KeyUXPVersion] = strings.TrimPrefix(r.uxpVersion, "v")
+	}
+	var drift []string
+	for _, c := range r.components {
+		s, err := r.observe(ctx, req.Namespace, c)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if s == nil {
+			continue
+		}
+		data[c.VersionKey()] = s.version
+		data[c.ImageKey()] = s.image
+		if s.digest != "" {
+			data[c.DigestKey()] = s.digest
+		}
+		if e := strings.TrimPrefix(c.Expected, "v"); e != "" && e != s.version {
+			drift = append(drift, fmt.Sprintf("%s is running %s instead of %s", c.Deployment, s.version, e))
+		}
+	}
+	sort.Strings(drift)
+	data[KeyVersionDrift] = strings.Join(drift, "; ")
+
+	cm := &corev1.ConfigMap{}
+	err = r.client.Get(ctx, types.NamespacedName{Name: meta.ConfigMapNameVersions, Namespace: req.Namespace}, cm)
+	if kerrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      meta.ConfigMapNameVersions,
+				Namespace: req.Namespace,
+				Labels:    map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy},
+			},
+			Data: data,
+		}
+		r.reportDrift(cm, "", data[KeyVersionDrift])
+		return reconcile.Result{RequeueAfter: syncPeriod}, errors.Wrap(r.client.Create(ctx, cm), errCreateConfigMap)
+	}
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetConfigMap)
+	}
+
+	previous := cm.Data[KeyVersionDrift]
+	if !merge(cm, data, r.components) {
+		log.Debug("Versions are up to date")
+		return reconcile.Result{RequeueAfter: syncPeriod}, nil
+	}
+	if err := r.client.Update(ctx, cm); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errUpdateConfigMap)
+	}
+	r.reportDrift(cm, previous, data[KeyVersionDrift])
+	r.record.Event(cm, event.Normal(reasonVersionsUpdated, "Updated versions of running components"))
+	log.Info("Updated versions of running components")
+	return reconcile.Result{RequeueAfter: syncPeriod}, nil
+}
+
+func (r *Reconciler) reportDrift(cm *corev1.ConfigMap, previous, current string) {
+	switch {
+	case current != "" && current != previous:
+		r.record.Event(cm, event.Warning(reasonVersionDrift, errors.New(current)))
+		r.log.Info("Running components drifted from expected versions", "drift", current)
+	case current == "" && previous != "":
+		r.record.Event(cm, event.Normal(reasonVersionsInSync, "Running components match expected versions"))
+	}
+}
+
+// merge writes the given data to the ConfigMap, removing the keys of
+// components that are not running anymore. It returns true if the ConfigMap
+// is changed.
+func merge(cm *corev1.ConfigMap, data map[string]string, components []Component) bool {
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	changed := false
+	for _, c := range components {
+		for _, k := range []string{c.VersionKey(), c.ImageKey(), c.DigestKey()} {
+			if _, ok := data[k]; ok {
+				continue
+			}
+			if _, ok := cm.Data[k]; ok {
+				delete(cm.Data, k)
+				changed = true
+			}
+		}
+	}
+	for k, v := range data {
+		if cur, ok := cm.Data[k]; ok && cur == v {
+			continue
+		}
+		cm.Data[k] = v
+		changed = true
+	}
+	return changed
+}
+
+type status struct {
+	version string
+	image   string
+	digest  string
+}
+
+// observe returns the running status of the given component, or nil if it is
+// not deployed.
+func (r *Reconciler) observe(ctx context.Context, ns string, c Component) (*status, error) {
+	d := &appsv1.Deployment{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: c.Deployment, Namespace: ns}, d); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, errFmtGetDeploy, c.Deployment)
+	}
+	if len(d.Spec.Template.Spec.Containers) == 0 {
+		return nil, nil
+	}
+	ctr := d.Spec.Template.Spec.Containers[0]
+	s := &status{image: ctr.Image, version: strings.TrimPrefix(xpkg.ImageTag(ctr.Image), "v")}
+	if d.Spec.Selector == nil {
+		return s, nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.client.List(ctx, pods, client.InNamespace(ns), client.MatchingLabels(d.Spec.Selector.MatchLabels)); err != nil {
+		return nil, errors.Wrapf(err, errFmtListPods, c.Deployment)
+	}
+	s.digest = digest(pods.Items, ctr.Name, ctr.Image)
+	return s, nil
+}
+
+// digest returns the image digest of the given container of the first ready
+// pod running the given image.
+func digest(pods []corev1.Pod, container, image string) string {
+	sort.Slice(pods, func(i, j int) bool { return pods[i].GetName() < pods[j].GetName() })
+	for _, p := range pods {
+		for _, cs := range p.Status.ContainerStatuses {
+			if cs.Name != container || !cs.Ready || cs.Image == "" {
+				continue
+			}
+			if xpkg.ImageTag(cs.Image) != xpkg.ImageTag(image) {
+				// The pod is running a previous revision.
+				continue
+			}
+			if i := strings.LastIndex(cs.ImageID, "@"); i >= 0 {
+				return cs.ImageID[i+1:]
+			}
+		}
+	}
+	return ""
+}
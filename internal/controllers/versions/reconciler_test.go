@@ -0,0 +1,207 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package versions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+var errBoom = errors.New("boom")
+
+func deployment(image string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "crossplane"}},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "universal-crossplane", Image: image}}}},
+		},
+	}
+}
+
+func pod(image, imageID string) corev1.Pod {
+	return corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+		Name: "universal-crossplane", Ready: true, Image: image, ImageID: imageID,
+	}}}}
+}
+
+func TestReconcile(t *testing.T) {
+	crossplane := Component{Key: "crossplane", Deployment: meta.DeploymentNameCrossplane, Expected: "v1.20.4-up.1"}
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "")
+
+	type args struct {
+		kube client.Client
+	}
+	type want struct {
+		err  error
+		data map[string]string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"GetDeploymentError": {
+			reason: "We should return an error if a Deployment cannot be fetched.",
+			args: args{
+				kube: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			},
+			want: want{err: errors.Wrapf(errBoom, errFmtGetDeploy, meta.DeploymentNameCrossplane)},
+		},
+		"CreateConfigMap": {
+			reason: "We should create the ConfigMap if it does not exist.",
+			args: args{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *appsv1.Deployment:
+							deployment("upbound/crossplane:v1.20.4-up.1").DeepCopyInto(o)
+							return nil
+						case *corev1.ConfigMap:
+							return notFound
+						}
+						return nil
+					},
+					MockList: func(_ context.Context, obj client.ObjectList, _ ...client.ListOption) error {
+						obj.(*corev1.PodList).Items = []corev1.Pod{pod("upbound/crossplane:v1.20.4-up.1", "docker.io/upbound/crossplane@sha256:abc")}
+						return nil
+					},
+					MockCreate: test.NewMockCreateFn(nil),
+				},
+			},
+			want: want{data: map[string]string{
+				KeyUXPVersion:       "1.20.4-up.1",
+				KeyVersionDrift:     "",
+				"crossplaneVersion": "1.20.4-up.1",
+				"crossplaneImage":   "upbound/crossplane:v1.20.4-up.1",
+				"crossplaneDigest":  "sha256:abc",
+			}},
+		},
+		"ReportDrift": {
+			reason: "We should report drift if a component does not run the expected version.",
+			args: args{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *appsv1.Deployment:
+							deployment("upbound/crossplane:v1.20.3-up.1").DeepCopyInto(o)
+						case *corev1.ConfigMap:
+							o.Data = map[string]string{"crossplaneVersion": "1.20.4-up.1", "crossplaneDigest": "sha256:old"}
+						}
+						return nil
+					},
+					MockList:   test.NewMockListFn(nil),
+					MockUpdate: test.NewMockUpdateFn(nil),
+				},
+			},
+			want: want{data: map[string]string{
+				KeyUXPVersion:       "1.20.4-up.1",
+				KeyVersionDrift:     "crossplane is running 1.20.3-up.1 instead of 1.20.4-up.1",
+				"crossplaneVersion": "1.20.3-up.1",
+				"crossplaneImage":   "upbound/crossplane:v1.20.3-up.1",
+			}},
+		},
+		"ComponentRemoved": {
+			reason: "We should remove the keys of components that are not deployed.",
+			args: args{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *appsv1.Deployment:
+							return notFound
+						case *corev1.ConfigMap:
+							o.Data = map[string]string{KeyUXPVersion: "1.20.4-up.1", "crossplaneVersion": "1.20.4-up.1"}
+						}
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+				},
+			},
+			want: want{data: map[string]string{
+				KeyUXPVersion:   "1.20.4-up.1",
+				KeyVersionDrift: "",
+			}},
+		},
+		"UpdateError": {
+			reason: "We should return an error if the ConfigMap cannot be updated.",
+			args: args{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						if _, ok := obj.(*appsv1.Deployment); ok {
+							return notFound
+						}
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(errBoom),
+				},
+			},
+			want: want{
+				err:  errors.Wrap(errBoom, errUpdateConfigMap),
+				data: map[string]string{KeyUXPVersion: "1.20.4-up.1", KeyVersionDrift: ""},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got map[string]string
+			if mc, ok := tc.args.kube.(*test.MockClient); ok {
+				mc.MockCreate = capture(mc.MockCreate, &got)
+				if mc.MockUpdate != nil {
+					update := mc.MockUpdate
+					mc.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+						got = obj.(*corev1.ConfigMap).Data
+						return update(ctx, obj, opts...)
+					}
+				}
+			}
+			r := NewReconciler(&fake.Manager{Client: tc.args.kube}, WithComponents(crossplane), WithUXPVersion("v1.20.4-up.1"))
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "upbound-system"}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.data, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want data, +got data:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func capture(fn test.MockCreateFn, data *map[string]string) test.MockCreateFn {
+	if fn == nil {
+		return nil
+	}
+	return func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+		*data = obj.(*corev1.ConfigMap).Data
+		return fn(ctx, obj, opts...)
+	}
+}
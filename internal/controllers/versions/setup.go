@@ -0,0 +1,83 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package versions
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// DefaultComponents returns the components of Universal Crossplane whose
+// versions are reported.
+func DefaultComponents() []Component {
+	return []Component{
+		{Key: "crossplane", Deployment: meta.DeploymentNameCrossplane, Expected: version.CrossplaneVersion},
+		{Key: "rbacManager", Deployment: meta.DeploymentNameRBACManager, Expected: version.CrossplaneVersion},
+		{Key: "bootstrapper", Deployment: meta.DeploymentNameBootstrapper, Expected: version.Version},
+		{Key: "xgql", Deployment: meta.DeploymentNameXGQL, Expected: version.XGQLVersion},
+	}
+}
+
+// Setup adds the controller that reconciles the versions ConfigMap with the
+// running components.
+func Setup(mgr ctrl.Manager, l logging.Logger) error {
+	name := "versions"
+	components := DefaultComponents()
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithComponents(components...),
+		WithUXPVersion(version.Version),
+	)
+
+	deployments := make([]string, len(components))
+	for i, c := range components {
+		deployments[i] = c.Deployment
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(resource.NewPredicates(resource.IsNamed(meta.ConfigMapNameVersions)))).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}},
+			handler.EnqueueRequestsFromMapFunc(toVersionsConfigMap),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+				for _, d := range deployments {
+					if o.GetName() == d {
+						return true
+					}
+				}
+				return false
+			}))).
+		Complete(r)
+}
+
+func toVersionsConfigMap(o client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: meta.ConfigMapNameVersions, Namespace: o.GetNamespace()}}}
+}
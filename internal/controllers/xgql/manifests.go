@@ -0,0 +1,84 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgql
+
+import (
+	"bytes"
+	"embed"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+//go:embed manifests/*.yaml
+var manifests embed.FS
+
+// Manifests in the order they are applied. The Deployment comes last so that
+// everything it depends on exists when it starts.
+var files = []string{ //nolint:gochecknoglobals // We treat this as a constant.
+	"manifests/serviceaccount.yaml",
+	"manifests/clusterrole.yaml",
+	"manifests/clusterrolebinding.yaml",
+	"manifests/service.yaml",
+	"manifests/deployment.yaml",
+}
+
+const (
+	errFmtReadManifest   = "cannot read manifest %s"
+	errFmtRenderManifest = "cannot render manifest %s"
+	errFmtParseManifest  = "cannot parse manifest %s"
+)
+
+// Parameters of the embedded manifests.
+type Parameters struct {
+	// Namespace XGQL is installed in.
+	Namespace string
+	// Image of XGQL.
+	Image string
+	// Version of XGQL.
+	Version string
+	// TLSChecksum is the checksum of the certificate XGQL serves. XGQL is
+	// restarted when it changes.
+	TLSChecksum string
+}
+
+// Render returns the objects that run XGQL with the given parameters, in the
+// order they should be applied.
+func Render(p Parameters) ([]*unstructured.Unstructured, error) {
+	objs := make([]*unstructured.Unstructured, 0, len(files))
+	for _, f := range files {
+		raw, err := manifests.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtReadManifest, f)
+		}
+		t, err := template.New(f).Option("missingkey=error").Parse(string(raw))
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtParseManifest, f)
+		}
+		b := &bytes.Buffer{}
+		if err := t.Execute(b, p); err != nil {
+			return nil, errors.Wrapf(err, errFmtRenderManifest, f)
+		}
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(b.Bytes(), &u.Object); err != nil {
+			return nil, errors.Wrapf(err, errFmtParseManifest, f)
+		}
+		objs = append(objs, u)
+	}
+	return objs, nil
+}
@@ -0,0 +1,279 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xgql contains the controller that manages the lifecycle of XGQL,
+// the GraphQL API of Crossplane.
+package xgql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/kube"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/metrics"
+	"github.com/upbound/universal-crossplane/internal/tracing"
+)
+
+const (
+	// Name of the XGQL component and its Deployment.
+	Name = meta.DeploymentNameXGQL
+
+	// LabelKeyVersion is the label that holds the version of XGQL.
+	LabelKeyVersion = "app.kubernetes.io/version"
+
+	reconcileTimeout = 1 * time.Minute
+	syncPeriod       = 10 * time.Minute
+	pollPeriod       = 30 * time.Second
+
+	errRender           = "cannot render xgql manifests"
+	errGetDeployment    = "cannot get xgql deployment"
+	errConvert          = "cannot convert xgql deployment"
+	errFmtApply         = "cannot apply xgql %s %s"
+	errFmtGet           = "cannot get xgql %s %s"
+	errFmtDelete        = "cannot delete xgql %s %s"
+	errFmtNotHealthyYet = "xgql %s is not healthy: %s"
+
+	reasonInstalled   event.Reason = "Installed"
+	reasonUpgraded    event.Reason = "Upgraded"
+	reasonHealthy     event.Reason = "ComponentHealthy"
+	reasonUnhealthy   event.Reason = "ComponentUnhealthy"
+	reasonApplyFailed event.Reason = "ApplyFailed"
+)
+
+// ReconcilerOption is used to configure the Reconciler.
+type ReconcilerOption func(*Reconciler)
+
+// WithLogger specifies how the Reconciler should log messages.
+func WithLogger(log logging.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.log = log
+	}
+}
+
+// WithRecorder specifies how the Reconciler should record Kubernetes events.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.record = er
+	}
+}
+
+// WithInventory specifies how the Reconciler should record the objects it
+// applies.
+func WithInventory(inv inventory.Recorder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.inv = inv
+	}
+}
+
+// WithAPIReader specifies how the Reconciler should read the XGQL objects
+// when it removes them.
+func WithAPIReader(c client.Reader) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.reader = c
+	}
+}
+
+// WithEnabled specifies whether XGQL should be installed. XGQL is removed if
+// it is not enabled.
+func WithEnabled(e bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.enabled = e
+	}
+}
+
+// WithImage specifies the image and version of XGQL.
+func WithImage(image, version string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.image = image
+		r.version = version
+	}
+}
+
+// Reconciler installs, upgrades and removes XGQL.
+type Reconciler struct {
+	client client.Client
+	reader client.Reader
+	log    logging.Logger
+	record event.Recorder
+	inv    inventory.Recorder
+
+	enabled bool
+	image   string
+	version string
+
+	// healthy is the last observed health of XGQL, used to record events
+	// only when it changes.
+	healthy *bool
+
+	now func() time.Time
+}
+
+// NewReconciler returns a new reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client:  mgr.GetClient(),
+		reader:  mgr.GetClient(),
+		log:     logging.NewNopLogger(),
+		record:  event.NewNopRecorder(),
+		inv:     inventory.NopRecorder{},
+		enabled: true,
+		now:     time.Now,
+	}
+
+	for _, f := range opts {
+		f(r)
+	}
+
+	return r
+}
+
+// Reconcile applies the embedded XGQL manifests if XGQL is enabled and
+// removes them otherwise, and reports the health of XGQL.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, err error) {
+	log := r.log.WithValues("request", req)
+	ctx, span := tracing.Start(ctx, "xgql.Reconcile")
+	defer tracing.End(span, &err)
+
+	log.Debug("Reconciling...")
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	if !r.enabled {
+		objs, err := Render(Parameters{Namespace: req.Namespace, Image: r.image, Version: r.version})
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, errRender)
+		}
+		if err := r.inv.Record(ctx, nil); err != nil {
+			return reconcile.Result{}, err
+		}
+		// The TLS Secret is created before everything else, so it is
+		// removed last.
+		removed, err := r.uninstall(ctx, append([]*unstructured.Unstructured{tlsSecret(req.Namespace)}, objs...))
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		metrics.DeleteComponentHealthy(Name)
+		r.healthy = nil
+		if removed {
+			log.Info("Removed xgql since it is disabled")
+		}
+		return reconcile.Result{}, nil
+	}
+
+	sum, err := r.ensureTLS(ctx, req.Namespace)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	objs, err := Render(Parameters{Namespace: req.Namespace, Image: r.image, Version: r.version, TLSChecksum: sum})
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errRender)
+	}
+
+	current := &appsv1.Deployment{}
+	err = r.client.Get(ctx, types.NamespacedName{Name: Name, Namespace: req.Namespace}, current)
+	if client.IgnoreNotFound(err) != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetDeployment)
+	}
+	// previous is empty if XGQL is not installed.
+	previous := current.GetLabels()[LabelKeyVersion]
+
+	refs := make([]inventory.Ref, 0, len(objs))
+	for _, o := range objs {
+		refs = append(refs, inventory.RefOf(o))
+	}
+	if err := r.inv.Record(ctx, refs); err != nil {
+		return reconcile.Result{}, err
+	}
+	for _, o := range objs {
+		if err := r.client.Patch(ctx, o, client.Apply, client.FieldOwner(meta.FieldOwner), client.ForceOwnership); err != nil {
+			err = errors.Wrapf(err, errFmtApply, o.GetKind(), o.GetName())
+			if previous != "" {
+				r.record.Event(current, event.Warning(reasonApplyFailed, err))
+			}
+			return reconcile.Result{}, err
+		}
+	}
+
+	// The Deployment is applied last and is updated with the response of the
+	// API server, including its status.
+	d := &appsv1.Deployment{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(objs[len(objs)-1].Object, d); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errConvert)
+	}
+	switch {
+	case previous == "":
+		r.record.Event(d, event.Normal(reasonInstalled, fmt.Sprintf("Installed xgql %s", r.version)))
+		log.Info("Installed xgql", "version", r.version)
+	case previous != r.version:
+		r.record.Event(d, event.Normal(reasonUpgraded, fmt.Sprintf("Upgraded xgql from %s to %s", previous, r.version)))
+		log.Info("Upgraded xgql", "from", previous, "to", r.version)
+	}
+
+	healthy, reason := kube.Healthy(d)
+	metrics.SetComponentHealthy(Name, healthy)
+	if r.healthy == nil || *r.healthy != healthy {
+		if healthy {
+			r.record.Event(d, event.Normal(reasonHealthy, fmt.Sprintf("xgql %s is healthy", r.version)))
+		} else {
+			r.record.Event(d, event.Warning(reasonUnhealthy, errors.Errorf(errFmtNotHealthyYet, r.version, reason)))
+		}
+		r.healthy = &healthy
+	}
+	if !healthy {
+		return reconcile.Result{RequeueAfter: pollPeriod}, nil
+	}
+	return reconcile.Result{RequeueAfter: syncPeriod}, nil
+}
+
+// uninstall deletes the given objects if they are managed by the
+// bootstrapper, in reverse order. It returns true if any object is deleted.
+func (r *Reconciler) uninstall(ctx context.Context, objs []*unstructured.Unstructured) (bool, error) {
+	removed := false
+	for i := len(objs) - 1; i >= 0; i-- {
+		want := objs[i]
+		o := &unstructured.Unstructured{}
+		o.SetGroupVersionKind(want.GroupVersionKind())
+		if err := r.reader.Get(ctx, types.NamespacedName{Name: want.GetName(), Namespace: want.GetNamespace()}, o); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return removed, errors.Wrapf(err, errFmtGet, want.GetKind(), want.GetName())
+		}
+		if o.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy {
+			// Not created by us, so not ours to delete.
+			continue
+		}
+		if err := r.client.Delete(ctx, o); client.IgnoreNotFound(err) != nil {
+			return removed, errors.Wrapf(err, errFmtDelete, want.GetKind(), want.GetName())
+		}
+		removed = true
+	}
+	return removed, nil
+}
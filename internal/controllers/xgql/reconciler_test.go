@@ -0,0 +1,253 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/metrics"
+)
+
+var errBoom = errors.New("boom")
+
+func TestRender(t *testing.T) {
+	objs, err := Render(Parameters{Namespace: "upbound-system", Image: Image + ":v0.2.0", Version: "v0.2.0"})
+	if err != nil {
+		t.Fatalf("Render(...): %s", err)
+	}
+	kinds := make([]string, len(objs))
+	for i, o := range objs {
+		kinds[i] = o.GetKind()
+		if o.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy {
+			t.Errorf("Render(...): %s %s is not labeled as managed by the bootstrapper", o.GetKind(), o.GetName())
+		}
+		if o.GetLabels()[LabelKeyVersion] != "v0.2.0" {
+			t.Errorf("Render(...): %s %s is not labeled with the version", o.GetKind(), o.GetName())
+		}
+	}
+	want := []string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Service", "Deployment"}
+	if diff := cmp.Diff(want, kinds); diff != "" {
+		t.Errorf("Render(...): -want kinds, +got kinds:\n%s", diff)
+	}
+	image, _, _ := unstructured.NestedSlice(objs[4].Object, "spec", "template", "spec", "containers")
+	if diff := cmp.Diff(Image+":v0.2.0", image[0].(map[string]any)["image"]); diff != "" {
+		t.Errorf("Render(...): -want image, +got image:\n%s", diff)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "")
+	now := time.Now()
+
+	keyPair := func(t *testing.T, notBefore time.Time) []byte {
+		t.Helper()
+		crt, _, err := metrics.SelfSignedKeyPair(Name, []string{Name}, notBefore, metrics.DefaultSelfSignedValidity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crt
+	}
+	valid := keyPair(t, now)
+	expiring := keyPair(t, now.Add(-metrics.DefaultSelfSignedValidity*3/4))
+
+	// installed returns an XGQL Deployment of the given version, or not
+	// found if it is empty, and a managed TLS Secret with the given
+	// certificate, or not found if it is nil.
+	installed := func(version string, crt []byte) test.MockGetFn {
+		return func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			if s, ok := obj.(*corev1.Secret); ok {
+				if crt == nil {
+					return notFound
+				}
+				s.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+				s.Data = map[string][]byte{corev1.TLSCertKey: crt}
+				return nil
+			}
+			if version == "" {
+				return notFound
+			}
+			obj.SetLabels(map[string]string{LabelKeyVersion: version, meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+			return nil
+		}
+	}
+	// available reports the applied Deployment as available.
+	available := func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+		if obj.(*unstructured.Unstructured).GetKind() == "Deployment" {
+			_ = unstructured.SetNestedField(obj.(*unstructured.Unstructured).Object, int64(1), "status", "availableReplicas")
+		}
+		return nil
+	}
+
+	type args struct {
+		kube    *test.MockClient
+		enabled bool
+	}
+	type want struct {
+		result  reconcile.Result
+		err     error
+		tls     string
+		applied []string
+		deleted []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"GetTLSSecretError": {
+			reason: "We should return an error if the XGQL TLS Secret cannot be fetched.",
+			args:   args{enabled: true, kube: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)}},
+			want:   want{err: errors.Wrap(errBoom, errGetTLSSecret)},
+		},
+		"CreateTLSSecretError": {
+			reason: "We should return an error if the XGQL TLS Secret cannot be created.",
+			args:   args{enabled: true, kube: &test.MockClient{MockGet: installed("", nil), MockCreate: test.NewMockCreateFn(errBoom)}},
+			want:   want{err: errors.Wrap(errBoom, errCreateTLSSecret)},
+		},
+		"GetDeploymentError": {
+			reason: "We should return an error if the XGQL Deployment cannot be fetched.",
+			args: args{enabled: true, kube: &test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				if _, ok := obj.(*corev1.Secret); ok {
+					return installed("", valid)(ctx, key, obj)
+				}
+				return errBoom
+			}}},
+			want: want{err: errors.Wrap(errBoom, errGetDeployment)},
+		},
+		"ApplyError": {
+			reason: "We should return an error if an XGQL object cannot be applied.",
+			args:   args{enabled: true, kube: &test.MockClient{MockGet: installed("", valid), MockPatch: test.NewMockPatchFn(errBoom)}},
+			want:   want{err: errors.Wrapf(errBoom, errFmtApply, "ServiceAccount", Name)},
+		},
+		"InstallNotHealthyYet": {
+			reason: "We should generate a TLS certificate, install XGQL and check again soon if it is not healthy yet.",
+			args:   args{enabled: true, kube: &test.MockClient{MockGet: installed("", nil), MockPatch: test.NewMockPatchFn(nil)}},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: pollPeriod},
+				tls:     "Created",
+				applied: []string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Service", "Deployment"},
+			},
+		},
+		"UpgradeHealthy": {
+			reason: "We should upgrade XGQL and check again later if it is healthy.",
+			args:   args{enabled: true, kube: &test.MockClient{MockGet: installed("v0.1.0", valid), MockPatch: available}},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: syncPeriod},
+				applied: []string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Service", "Deployment"},
+			},
+		},
+		"RotateTLSCertificate": {
+			reason: "We should rotate the TLS certificate once two thirds of its validity have passed.",
+			args:   args{enabled: true, kube: &test.MockClient{MockGet: installed("v0.2.0", expiring), MockPatch: available}},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: syncPeriod},
+				tls:     "Updated",
+				applied: []string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Service", "Deployment"},
+			},
+		},
+		"UnmanagedTLSSecret": {
+			reason: "We should use a TLS Secret that is not managed by the bootstrapper as is.",
+			args: args{enabled: true, kube: &test.MockClient{MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				if s, ok := obj.(*corev1.Secret); ok {
+					s.Data = map[string][]byte{corev1.TLSCertKey: expiring}
+					return nil
+				}
+				return installed("v0.2.0", nil)(ctx, key, obj)
+			}, MockPatch: available}},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: syncPeriod},
+				applied: []string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Service", "Deployment"},
+			},
+		},
+		"Disabled": {
+			reason: "We should remove XGQL and its TLS Secret in reverse order if it is disabled.",
+			args:   args{enabled: false, kube: &test.MockClient{MockGet: installed("v0.2.0", valid)}},
+			want:   want{deleted: []string{"Deployment", "Service", "ClusterRoleBinding", "ClusterRole", "ServiceAccount", "Secret"}},
+		},
+		"DisabledNotInstalled": {
+			reason: "We should do nothing if XGQL is disabled and not installed.",
+			args:   args{enabled: false, kube: &test.MockClient{MockGet: installed("", nil)}},
+			want:   want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var applied, deleted []string
+			tls := ""
+			create := tc.args.kube.MockCreate
+			tc.args.kube.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+				if create != nil {
+					if err := create(ctx, obj, opts...); err != nil {
+						return err
+					}
+				}
+				tls = "Created"
+				return nil
+			}
+			tc.args.kube.MockUpdate = func(_ context.Context, _ client.Object, _ ...client.UpdateOption) error {
+				tls = "Updated"
+				return nil
+			}
+			patch := tc.args.kube.MockPatch
+			tc.args.kube.MockPatch = func(ctx context.Context, obj client.Object, p client.Patch, opts ...client.PatchOption) error {
+				if err := patch(ctx, obj, p, opts...); err != nil {
+					return err
+				}
+				applied = append(applied, obj.GetObjectKind().GroupVersionKind().Kind)
+				return nil
+			}
+			tc.args.kube.MockDelete = func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+				deleted = append(deleted, obj.GetObjectKind().GroupVersionKind().Kind)
+				return nil
+			}
+			r := NewReconciler(&fake.Manager{Client: tc.args.kube}, WithEnabled(tc.args.enabled), WithImage(Image+":v0.2.0", "v0.2.0"))
+			got, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: Name, Namespace: "upbound-system"}})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.tls, tls); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want TLS Secret change, +got TLS Secret change:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.applied, applied); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want applied, +got applied:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\nReason: %s\nr.Reconcile(...): -want deleted, +got deleted:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
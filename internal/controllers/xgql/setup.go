@@ -0,0 +1,71 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgql
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/upbound/universal-crossplane/internal/inventory"
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// Image is the repository of the XGQL image.
+const Image = "xpkg.upbound.io/upbound/xgql"
+
+// Setup adds the controller that installs XGQL if it is enabled and removes
+// it otherwise. The installed version is pinned to the one bundled with this
+// release, and the applied objects are recorded in the given inventory.
+func Setup(mgr ctrl.Manager, l logging.Logger, enabled bool, inv inventory.Recorder) error {
+	name := "xgql"
+	if version.XGQLVersion == "" {
+		return errors.New("xgql version is not set in this build")
+	}
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithInventory(inv),
+		WithAPIReader(mgr.GetAPIReader()),
+		WithEnabled(enabled),
+		WithImage(Image+":"+version.XGQLVersion, version.XGQLVersion),
+	)
+
+	// The bootstrapper Deployment triggers the first reconcile, since XGQL
+	// may not be installed yet.
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}},
+			handler.EnqueueRequestsFromMapFunc(toXGQL),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+				return o.GetName() == Name || o.GetName() == meta.DeploymentNameBootstrapper
+			}))).
+		Complete(r)
+}
+
+func toXGQL(o client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: Name, Namespace: o.GetNamespace()}}}
+}
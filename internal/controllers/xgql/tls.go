@@ -0,0 +1,111 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgql
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/metrics"
+)
+
+const (
+	// TLSSecretName is the name of the Secret XGQL serves its certificate
+	// from.
+	TLSSecretName = "xgql-tls"
+
+	errGetTLSSecret    = "cannot get xgql TLS secret"
+	errGenerateTLS     = "cannot generate xgql TLS certificate"
+	errCreateTLSSecret = "cannot create xgql TLS secret"
+	errUpdateTLSSecret = "cannot update xgql TLS secret"
+)
+
+// ensureTLS makes sure the TLS Secret of XGQL holds a valid certificate and
+// returns its checksum. A self-signed certificate is generated if the Secret
+// does not exist, and rotated once two thirds of its validity has passed. A
+// Secret that is not managed by the bootstrapper is used as is.
+func (r *Reconciler) ensureTLS(ctx context.Context, namespace string) (string, error) {
+	s := &corev1.Secret{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: TLSSecretName, Namespace: namespace}, s)
+	if client.IgnoreNotFound(err) != nil {
+		return "", errors.Wrap(err, errGetTLSSecret)
+	}
+	exists := err == nil
+	if exists && (s.GetLabels()[meta.LabelKeyManagedBy] != meta.LabelValueManagedBy || !needsRotation(s.Data[corev1.TLSCertKey], r.now())) {
+		return checksum(s.Data[corev1.TLSCertKey]), nil
+	}
+
+	hosts := []string{Name, fmt.Sprintf("%s.%s", Name, namespace), fmt.Sprintf("%s.%s.svc", Name, namespace), fmt.Sprintf("%s.%s.svc.cluster.local", Name, namespace)}
+	crt, key, err := metrics.SelfSignedKeyPair(Name, hosts, r.now(), metrics.DefaultSelfSignedValidity)
+	if err != nil {
+		return "", errors.Wrap(err, errGenerateTLS)
+	}
+	s.SetName(TLSSecretName)
+	s.SetNamespace(namespace)
+	s.SetLabels(map[string]string{
+		"app.kubernetes.io/name":      Name,
+		"app.kubernetes.io/component": Name,
+		meta.LabelKeyManagedBy:        meta.LabelValueManagedBy,
+	})
+	s.Type = corev1.SecretTypeTLS
+	s.Data = map[string][]byte{corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: key}
+	if !exists {
+		return checksum(crt), errors.Wrap(r.client.Create(ctx, s), errCreateTLSSecret)
+	}
+	r.log.Info("Rotated xgql TLS certificate", "secret", TLSSecretName)
+	return checksum(crt), errors.Wrap(r.client.Update(ctx, s), errUpdateTLSSecret)
+}
+
+// needsRotation returns true if the given PEM encoded certificate cannot be
+// parsed or two thirds of its validity have passed.
+func needsRotation(crt []byte, now time.Time) bool {
+	b, _ := pem.Decode(crt)
+	if b == nil {
+		return true
+	}
+	c, err := x509.ParseCertificate(b.Bytes)
+	if err != nil {
+		return true
+	}
+	return now.After(c.NotAfter.Add(-c.NotAfter.Sub(c.NotBefore) / 3))
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// tlsSecret returns the TLS Secret to remove when XGQL is uninstalled.
+func tlsSecret(namespace string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("Secret")
+	u.SetName(TLSSecretName)
+	u.SetNamespace(namespace)
+	return u
+}
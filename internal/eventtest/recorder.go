@@ -0,0 +1,38 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventtest contains an event recorder for controller tests.
+package eventtest
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+)
+
+// A Recorder records the reasons of the events it is given.
+type Recorder struct {
+	Reasons []event.Reason
+}
+
+// Event records the reason of the given event.
+func (r *Recorder) Event(_ runtime.Object, e event.Event) {
+	r.Reasons = append(r.Reasons, e.Reason)
+}
+
+// WithAnnotations returns the Recorder itself, since annotations are not
+// recorded.
+func (r *Recorder) WithAnnotations(...string) event.Recorder {
+	return r
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inventory records the objects the bootstrapper controllers apply,
+// so that the ones that are no longer desired can be garbage collected.
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+const (
+	errGetInventory      = "cannot get inventory configmap"
+	errCreateInventory   = "cannot create inventory configmap"
+	errFmtPatchInventory = "cannot record inventory of %s"
+	errFmtParse          = "cannot parse inventory of %s"
+	errMarshal           = "cannot marshal inventory"
+)
+
+// A Ref identifies an object a controller applied.
+type Ref struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// RefOf returns a reference to the given object, whose kind must be set.
+func RefOf(o client.Object) Ref {
+	apiVersion, kind := o.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+	return Ref{APIVersion: apiVersion, Kind: kind, Namespace: o.GetNamespace(), Name: o.GetName()}
+}
+
+func (r Ref) String() string {
+	if r.Namespace != "" {
+		return r.Kind + " " + r.Namespace + "/" + r.Name
+	}
+	return r.Kind + " " + r.Name
+}
+
+// Sort sorts the given references by API version, kind, namespace and name.
+func Sort(refs []Ref) {
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.APIVersion != b.APIVersion {
+			return a.APIVersion < b.APIVersion
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
+
+// Marshal returns the serialized form of the given references.
+func Marshal(refs []Ref) (string, error) {
+	sorted := make([]Ref, len(refs))
+	copy(sorted, refs)
+	Sort(sorted)
+	b, err := yaml.Marshal(sorted)
+	return string(b), errors.Wrap(err, errMarshal)
+}
+
+// Unmarshal parses the references recorded for the given controller.
+func Unmarshal(controller, data string) ([]Ref, error) {
+	var refs []Ref
+	return refs, errors.Wrapf(yaml.Unmarshal([]byte(data), &refs), errFmtParse, controller)
+}
+
+// A Recorder records the objects a controller desires.
+type Recorder interface {
+	// Record replaces the objects recorded for the controller.
+	Record(ctx context.Context, refs []Ref) error
+}
+
+// NopRecorder does not record anything.
+type NopRecorder struct{}
+
+// Record does nothing.
+func (NopRecorder) Record(_ context.Context, _ []Ref) error { return nil }
+
+// A ConfigMapRecorder records the objects of a controller under its own key
+// of the inventory ConfigMap.
+type ConfigMapRecorder struct {
+	client     client.Client
+	key        types.NamespacedName
+	controller string
+}
+
+// NewConfigMapRecorder returns a Recorder that records the inventory of the
+// given controller in the given namespace.
+func NewConfigMapRecorder(c client.Client, namespace, controller string) *ConfigMapRecorder {
+	return &ConfigMapRecorder{client: c, key: types.NamespacedName{Namespace: namespace, Name: meta.ConfigMapNameInventory}, controller: controller}
+}
+
+// Record replaces the objects recorded for the controller if they changed.
+// The ConfigMap is patched rather than updated so that controllers recording
+// at the same time do not conflict.
+func (r *ConfigMapRecorder) Record(ctx context.Context, refs []Ref) error {
+	controller := r.controller
+	data, err := Marshal(refs)
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{}
+	err = r.client.Get(ctx, r.key, cm)
+	if kerrors.IsNotFound(err) {
+		cm.SetNamespace(r.key.Namespace)
+		cm.SetName(r.key.Name)
+		cm.SetLabels(map[string]string{meta.LabelKeyManagedBy: meta.LabelValueManagedBy})
+		cm.Data = map[string]string{controller: data}
+		if err := r.client.Create(ctx, cm); !kerrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, errCreateInventory)
+		}
+		// Another controller created it first, so we patch it below.
+		cm.Data = nil
+	} else if err != nil {
+		return errors.Wrap(err, errGetInventory)
+	}
+	if v, ok := cm.Data[controller]; ok && v == data {
+		return nil
+	}
+	return errors.Wrapf(Patch(ctx, r.client, cm, map[string]*string{controller: &data}), errFmtPatchInventory, controller)
+}
+
+// Patch merges the given data into the given inventory ConfigMap. Keys whose
+// value is nil are removed.
+func Patch(ctx context.Context, c client.Client, cm *corev1.ConfigMap, data map[string]*string) error {
+	p, err := json.Marshal(map[string]any{"data": data})
+	if err != nil {
+		return errors.Wrap(err, errMarshal)
+	}
+	return c.Patch(ctx, cm, client.RawPatch(types.MergePatchType, p))
+}
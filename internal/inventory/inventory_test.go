@@ -0,0 +1,130 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+func TestRecord(t *testing.T) {
+	errBoom := errors.New("boom")
+	refs := []Ref{
+		{APIVersion: "pkg.crossplane.io/v1", Kind: "Provider", Name: "provider-aws-s3"},
+		{APIVersion: "pkg.crossplane.io/v1", Kind: "Configuration", Name: "platform-ref-aws"},
+	}
+	recorded := `- apiVersion: pkg.crossplane.io/v1
+  kind: Configuration
+  name: platform-ref-aws
+- apiVersion: pkg.crossplane.io/v1
+  kind: Provider
+  name: provider-aws-s3
+`
+
+	type want struct {
+		err     error
+		created map[string]string
+		patch   string
+	}
+	cases := map[string]struct {
+		reason string
+		get    func(cm *corev1.ConfigMap) error
+		create error
+		want   want
+	}{
+		"Create": {
+			reason: "The inventory ConfigMap should be created if it does not exist.",
+			get: func(*corev1.ConfigMap) error {
+				return kerrors.NewNotFound(schema.GroupResource{}, meta.ConfigMapNameInventory)
+			},
+			want: want{created: map[string]string{"packages": recorded}},
+		},
+		"CreatedConcurrently": {
+			reason: "The inventory should be patched if another controller created the ConfigMap first.",
+			get: func(*corev1.ConfigMap) error {
+				return kerrors.NewNotFound(schema.GroupResource{}, meta.ConfigMapNameInventory)
+			},
+			create: kerrors.NewAlreadyExists(schema.GroupResource{}, meta.ConfigMapNameInventory),
+			want: want{
+				created: map[string]string{"packages": recorded},
+				patch:   `{"data":{"packages":"- apiVersion: pkg.crossplane.io/v1\n  kind: Configuration\n  name: platform-ref-aws\n- apiVersion: pkg.crossplane.io/v1\n  kind: Provider\n  name: provider-aws-s3\n"}}`,
+			},
+		},
+		"Unchanged": {
+			reason: "Nothing should be written if the inventory did not change.",
+			get: func(cm *corev1.ConfigMap) error {
+				cm.Data = map[string]string{"packages": recorded}
+				return nil
+			},
+			want: want{},
+		},
+		"Changed": {
+			reason: "Only the key of the controller should be patched if its inventory changed.",
+			get: func(cm *corev1.ConfigMap) error {
+				cm.Data = map[string]string{"packages": "[]\n", "xgql": "[]\n"}
+				return nil
+			},
+			want: want{patch: `{"data":{"packages":"- apiVersion: pkg.crossplane.io/v1\n  kind: Configuration\n  name: platform-ref-aws\n- apiVersion: pkg.crossplane.io/v1\n  kind: Provider\n  name: provider-aws-s3\n"}}`},
+		},
+		"GetError": {
+			reason: "Errors getting the inventory should be returned.",
+			get: func(*corev1.ConfigMap) error {
+				return errBoom
+			},
+			want: want{err: errors.Wrap(errBoom, errGetInventory)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created map[string]string
+			var patch string
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					return tc.get(obj.(*corev1.ConfigMap))
+				},
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					created = obj.(*corev1.ConfigMap).Data
+					return tc.create
+				},
+				MockPatch: func(_ context.Context, obj client.Object, p client.Patch, _ ...client.PatchOption) error {
+					b, _ := p.Data(obj)
+					patch = string(b)
+					return nil
+				},
+			}
+			err := NewConfigMapRecorder(kube, "upbound-system", "packages").Record(context.Background(), refs)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nRecord(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nRecord(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patch, patch); diff != "" {
+				t.Errorf("\n%s\nRecord(...): -want patch, +got patch:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kube contains helpers to read the state of Kubernetes objects.
+package kube
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Healthy returns true if the given Deployment is fully rolled out and
+// available, or false and the reason it is not.
+func Healthy(d *appsv1.Deployment) (bool, string) {
+	st := d.Status
+	switch {
+	case st.ObservedGeneration < d.GetGeneration():
+		return false, "deployment spec is not observed yet"
+	case st.UpdatedReplicas < st.Replicas:
+		return false, fmt.Sprintf("%d of %d replicas are updated", st.UpdatedReplicas, st.Replicas)
+	case st.AvailableReplicas == 0:
+		return false, "no replicas are available"
+	}
+	return true, ""
+}
+
+// RolledOut returns true if the given Deployment observed its spec and all of
+// its replicas run it.
+func RolledOut(d *appsv1.Deployment) bool {
+	want := int32(1)
+	if d.Spec.Replicas != nil {
+		want = *d.Spec.Replicas
+	}
+	st := d.Status
+	return st.ObservedGeneration >= d.GetGeneration() && st.UpdatedReplicas >= want && st.Replicas <= st.UpdatedReplicas
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestHealthy(t *testing.T) {
+	cases := map[string]struct {
+		d    *appsv1.Deployment
+		want bool
+	}{
+		"Available": {
+			d:    &appsv1.Deployment{Status: appsv1.DeploymentStatus{AvailableReplicas: 1}},
+			want: true,
+		},
+		"RollingOut": {
+			d:    &appsv1.Deployment{Status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 2}},
+			want: false,
+		},
+		"Unavailable": {
+			d:    &appsv1.Deployment{Status: appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1}},
+			want: false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got, reason := Healthy(tc.d); got != tc.want {
+				t.Errorf("Healthy(...): want %t, got %t: %s", tc.want, got, reason)
+			}
+		})
+	}
+}
+
+func TestRolledOut(t *testing.T) {
+	cases := map[string]struct {
+		d    *appsv1.Deployment
+		want bool
+	}{
+		"RolledOut": {
+			d:    &appsv1.Deployment{Status: appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1}},
+			want: true,
+		},
+		"NotObserved": {
+			d: func() *appsv1.Deployment {
+				d := &appsv1.Deployment{Status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1}}
+				d.SetGeneration(2)
+				return d
+			}(),
+			want: false,
+		},
+		"NotScaledUp": {
+			d:    &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: pointer.Int32(2)}, Status: appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1}},
+			want: false,
+		},
+		"OldReplicas": {
+			d:    &appsv1.Deployment{Status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1}},
+			want: false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := RolledOut(tc.d); got != tc.want {
+				t.Errorf("RolledOut(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logger builds the structured logger used across the bootstrapper.
+package logger
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	crzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+// Supported log formats.
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+const (
+	errFmtUnknownFormat = "unknown log format %q, must be one of json or console"
+	errFmtUnknownLevel  = "unknown log level %q, must be one of debug, info, error or a positive verbosity"
+	errFmtControllerLvl = "cannot parse log level of controller %s"
+)
+
+// Options configures the logger.
+type Options struct {
+	// Debug enables debug mode. It defaults the format to console and the
+	// level to debug, and records stacktraces from warnings onwards.
+	Debug bool
+	// Format is either json or console. Defaults to json unless in debug
+	// mode.
+	Format string
+	// Level is the default log level. It is one of debug, info, error or a
+	// positive logr verbosity. Defaults to info unless in debug mode.
+	Level string
+	// ControllerLevels overrides Level for the loggers with the given names,
+	// e.g. the name of a controller.
+	ControllerLevels map[string]string
+	// Sampling enables sampling of repeated log lines.
+	Sampling bool
+	// Output is where logs are written. Defaults to stderr.
+	Output io.Writer
+}
+
+// New returns a new logr.Logger configured with the given options. The
+// returned logger redacts sensitive values, see Redact.
+func New(o Options) (logr.Logger, error) {
+	zo := crzap.Options{Development: o.Debug, DestWriter: o.Output}
+
+	switch o.Format {
+	case "":
+	case FormatJSON:
+		zo.NewEncoder = func(opts ...crzap.EncoderConfigOption) zapcore.Encoder {
+			cfg := zap.NewProductionEncoderConfig()
+			for _, f := range opts {
+				f(&cfg)
+			}
+			return zapcore.NewJSONEncoder(cfg)
+		}
+	case FormatConsole:
+		zo.NewEncoder = func(opts ...crzap.EncoderConfigOption) zapcore.Encoder {
+			cfg := zap.NewDevelopmentEncoderConfig()
+			for _, f := range opts {
+				f(&cfg)
+			}
+			return zapcore.NewConsoleEncoder(cfg)
+		}
+	default:
+		return logr.Logger{}, errors.Errorf(errFmtUnknownFormat, o.Format)
+	}
+
+	def := zapcore.InfoLevel
+	if o.Debug {
+		def = zapcore.DebugLevel
+	}
+	if o.Level != "" {
+		l, err := ParseLevel(o.Level)
+		if err != nil {
+			return logr.Logger{}, err
+		}
+		def = l
+	}
+	lf := &levelFilter{def: def, names: make(map[string]zapcore.Level, len(o.ControllerLevels))}
+	for name, lvl := range o.ControllerLevels {
+		l, err := ParseLevel(lvl)
+		if err != nil {
+			return logr.Logger{}, errors.Wrapf(err, errFmtControllerLvl, name)
+		}
+		lf.names[name] = l
+	}
+
+	// The level filter decides what gets logged, so the underlying core has
+	// to let everything through. This also stops controller-runtime from
+	// adding its own sampler, which we control with Options.Sampling.
+	all := zap.NewAtomicLevelAt(zapcore.Level(-127))
+	zo.Level = &all
+	zo.ZapOpts = append(zo.ZapOpts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		c = &redactingCore{Core: c}
+		if o.Sampling {
+			c = zapcore.NewSamplerWithOptions(c, time.Second, 100, 100)
+		}
+		return &filteringCore{Core: c, filter: lf}
+	}))
+	return crzap.New(crzap.UseFlagOptions(&zo)), nil
+}
+
+// ParseLevel parses the given string into a zap level. In addition to the
+// zap level names, it accepts a positive integer logr verbosity.
+func ParseLevel(s string) (zapcore.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, errors.Errorf(errFmtUnknownLevel, s)
+	}
+	return zapcore.Level(-v), nil
+}
+
+// levelFilter decides the minimum level of a logger by its name.
+type levelFilter struct {
+	def   zapcore.Level
+	names map[string]zapcore.Level
+}
+
+func (f *levelFilter) min() zapcore.Level {
+	m := f.def
+	for _, l := range f.names {
+		if l < m {
+			m = l
+		}
+	}
+	return m
+}
+
+// levelFor returns the level of the most specific overridden segment of the
+// given dot separated logger name, e.g. "bootstrapper.aws-marketplace".
+func (f *levelFilter) levelFor(name string) zapcore.Level {
+	if len(f.names) == 0 || name == "" {
+		return f.def
+	}
+	segments := strings.Split(name, ".")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if l, ok := f.names[segments[i]]; ok {
+			return l
+		}
+	}
+	return f.def
+}
+
+// filteringCore drops entries whose level is below the one configured for
+// the logger they are written by.
+type filteringCore struct {
+	zapcore.Core
+	filter *levelFilter
+}
+
+func (c *filteringCore) Enabled(l zapcore.Level) bool {
+	return l >= c.filter.min() && c.Core.Enabled(l)
+}
+
+func (c *filteringCore) With(fields []zapcore.Field) zapcore.Core {
+	return &filteringCore{Core: c.Core.With(fields), filter: c.filter}
+}
+
+func (c *filteringCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if e.Level < c.filter.levelFor(e.LoggerName) {
+		return ce
+	}
+	return c.Core.Check(e, ce)
+}
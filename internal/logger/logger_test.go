@@ -0,0 +1,144 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNew(t *testing.T) {
+	type want struct {
+		messages []string
+		absent   []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		opts   Options
+		log    func(l logr.Logger)
+		want   want
+	}{
+		"DefaultLevel": {
+			reason: "Debug messages should not be logged at the default level.",
+			log: func(l logr.Logger) {
+				l.Info("info")
+				l.V(1).Info("debug")
+			},
+			want: want{messages: []string{"info"}},
+		},
+		"ControllerLevel": {
+			reason: "Debug messages of a controller with a debug level override should be logged.",
+			opts:   Options{ControllerLevels: map[string]string{"aws-marketplace": "debug"}},
+			log: func(l logr.Logger) {
+				l.WithName("bootstrapper").V(1).Info("other")
+				l.WithName("bootstrapper").WithName("aws-marketplace").V(1).Info("controller")
+			},
+			want: want{messages: []string{"controller"}},
+		},
+		"ControllerLevelQuieter": {
+			reason: "Info messages of a controller with an error level override should not be logged.",
+			opts:   Options{ControllerLevels: map[string]string{"aws-marketplace": "error"}},
+			log: func(l logr.Logger) {
+				l.WithName("aws-marketplace").Info("controller")
+				l.Info("other")
+			},
+			want: want{messages: []string{"other"}},
+		},
+		"RedactSensitiveKeys": {
+			reason: "Values of sensitive keys should never be logged.",
+			log: func(l logr.Logger) {
+				l.WithValues("awsMeteringSignature", "s3cr3t").Info("with", "token", "t0k3n")
+			},
+			want: want{messages: []string{"with"}, absent: []string{"s3cr3t", "t0k3n"}},
+		},
+		"RedactSecrets": {
+			reason: "Data of Secrets should never be logged.",
+			opts:   Options{Debug: true, Format: FormatJSON},
+			log: func(l logr.Logger) {
+				l.Info("secret", "secret", &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "upbound-entitlement"},
+					Data:       map[string][]byte{"awsMeteringSignature": []byte("s3cr3t")},
+				})
+			},
+			want: want{messages: []string{"secret"}, absent: []string{"s3cr3t", "czNjcjN0"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			tc.opts.Output = b
+			l, err := New(tc.opts)
+			if err != nil {
+				t.Fatalf("New(...): %s", err)
+			}
+			tc.log(l)
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+				if line == "" {
+					continue
+				}
+				e := map[string]any{}
+				if err := json.Unmarshal([]byte(line), &e); err != nil {
+					t.Fatalf("cannot unmarshal log line %q: %s", line, err)
+				}
+				got = append(got, e["msg"].(string))
+			}
+			if diff := cmp.Diff(tc.want.messages, got); diff != "" {
+				t.Errorf("\nReason: %s\nNew(...): -want messages, +got messages:\n%s", tc.reason, diff)
+			}
+			for _, s := range tc.want.absent {
+				if strings.Contains(b.String(), s) {
+					t.Errorf("\nReason: %s\nNew(...): %q should not be in the logs:\n%s", tc.reason, s, b.String())
+				}
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]struct {
+		in      string
+		want    int8
+		wantErr bool
+	}{
+		"Debug":     {in: "debug", want: -1},
+		"Info":      {in: "INFO", want: 0},
+		"Error":     {in: "error", want: 2},
+		"Verbosity": {in: "3", want: -3},
+		"Negative":  {in: "-3", wantErr: true},
+		"Unknown":   {in: "loud", wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseLevel(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseLevel(%q): want error %t, got %v", tc.in, tc.wantErr, err)
+			}
+			if err == nil && int8(got) != tc.want {
+				t.Errorf("ParseLevel(%q): want %d, got %d", tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Redacted is what sensitive values are replaced with.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeyParts are the case-insensitive substrings of log keys whose
+// values are never logged, e.g. awsMeteringSignature.
+var sensitiveKeyParts = []string{"token", "signature", "password", "credentials", "privatekey"} //nolint:gochecknoglobals // We treat this as a constant.
+
+// IsSensitiveKey returns true if the value of the given key should never be
+// logged.
+func IsSensitiveKey(key string) bool {
+	k := strings.ToLower(key)
+	for _, p := range sensitiveKeyParts {
+		if strings.Contains(k, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns Redacted for non-empty values so that it's still visible
+// whether the value was set.
+func Redact(v string) string {
+	if v == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// RedactSecret returns a copy of the given Secret whose data values are
+// redacted.
+func RedactSecret(s *corev1.Secret) *corev1.Secret {
+	if s == nil {
+		return nil
+	}
+	r := s.DeepCopy()
+	for k := range r.Data {
+		r.Data[k] = []byte(Redacted)
+	}
+	for k := range r.StringData {
+		r.StringData[k] = Redacted
+	}
+	// The last applied configuration may contain the data as well.
+	delete(r.Annotations, corev1.LastAppliedConfigAnnotation)
+	return r
+}
+
+// redactingCore redacts sensitive fields before they are encoded.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactingCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *redactingCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(e, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := fields
+	for i, f := range fields {
+		r, ok := redactField(f)
+		if !ok {
+			continue
+		}
+		if &out[0] == &fields[0] {
+			// Do not modify the slice of the caller.
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		out[i] = r
+	}
+	return out
+}
+
+func redactField(f zapcore.Field) (zapcore.Field, bool) {
+	if IsSensitiveKey(f.Key) {
+		return zap.String(f.Key, Redacted), true
+	}
+	switch s := f.Interface.(type) {
+	case *corev1.Secret:
+		return zap.Any(f.Key, RedactSecret(s)), true
+	case corev1.Secret:
+		return zap.Any(f.Key, RedactSecret(&s)), true
+	}
+	return f, false
+}
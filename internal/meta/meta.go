@@ -20,7 +20,64 @@ const (
 	LabelKeyManagedBy = "upbound.io/managed-by"
 	// LabelValueManagedBy is the value for the label indicating resource is managed by bootstrapper.
 	LabelValueManagedBy = "bootstrapper"
+	// AnnotationKeyPrune is the annotation that protects an object managed by
+	// the bootstrapper from garbage collection when set to
+	// AnnotationValuePruneDisabled.
+	AnnotationKeyPrune = "upbound.io/prune"
+	// AnnotationValuePruneDisabled is the value of AnnotationKeyPrune that
+	// protects an object from garbage collection.
+	AnnotationValuePruneDisabled = "disabled"
+	// FieldOwner is the field manager of the objects the bootstrapper applies.
+	FieldOwner = "upbound-bootstrapper"
 	// SecretNameEntitlement is the name of the Secret that contains the tokens
 	// stored for entitlement of usage of Universal Crossplane.
 	SecretNameEntitlement = "upbound-entitlement"
+	// ConfigMapNameVersions is the name of the ConfigMap that reports the
+	// versions of the Universal Crossplane components.
+	ConfigMapNameVersions = "universal-crossplane-config"
+	// ConfigMapNamePackages is the name of the ConfigMap that lists the
+	// packages that are installed by default.
+	ConfigMapNamePackages = "universal-crossplane-packages"
+	// ConfigMapNameCRDDrift is the name of the ConfigMap that reports the
+	// differences between the CRDs in the cluster and the bundled ones.
+	ConfigMapNameCRDDrift = "universal-crossplane-crd-drift"
+	// ConfigMapNameHealth is the name of the ConfigMap that summarizes the
+	// health of the Crossplane packages.
+	ConfigMapNameHealth = "universal-crossplane-health"
+	// ConfigMapNameRegistryMirror is the name of the ConfigMap that maps
+	// package registries to their mirrors.
+	ConfigMapNameRegistryMirror = "universal-crossplane-registry-mirror"
+	// ConfigMapNameTelemetry is the name of the ConfigMap that holds the last
+	// telemetry report.
+	ConfigMapNameTelemetry = "universal-crossplane-telemetry"
+	// ConfigMapNameInventory is the name of the ConfigMap that records the
+	// objects each controller applied.
+	ConfigMapNameInventory = "universal-crossplane-inventory"
+	// ConfigMapNamePullSecrets is the name of the ConfigMap that lists the
+	// image pull Secrets to propagate to the package runtimes.
+	ConfigMapNamePullSecrets = "universal-crossplane-pull-secrets"
+	// ConfigMapNameAutoUpgrade is the name of the ConfigMap that holds the
+	// package upgrade policies and the history of upgrades.
+	ConfigMapNameAutoUpgrade = "universal-crossplane-auto-upgrade"
+	// ConfigMapNameCompat is the name of the ConfigMap that reports whether
+	// the installed packages are compatible with the running Crossplane.
+	ConfigMapNameCompat = "universal-crossplane-compat"
+	// ConfigMapNameUpdates is the name of the ConfigMap that holds a mirrored
+	// release catalog and reports the updates available.
+	ConfigMapNameUpdates = "universal-crossplane-updates"
+	// SecretNameControlPlaneToken is the name of the Secret that contains the
+	// token used to connect the cluster to Upbound Cloud.
+	SecretNameControlPlaneToken = "upbound-control-plane-token"
+
+	// DeploymentNameCrossplane is the name of the Crossplane Deployment.
+	DeploymentNameCrossplane = "crossplane"
+	// DeploymentNameRBACManager is the name of the Crossplane RBAC manager
+	// Deployment.
+	DeploymentNameRBACManager = "crossplane-rbac-manager"
+	// DeploymentNameBootstrapper is the name of the bootstrapper Deployment.
+	DeploymentNameBootstrapper = "upbound-bootstrapper"
+	// DeploymentNameXGQL is the name of the XGQL Deployment.
+	DeploymentNameXGQL = "xgql"
+	// DeploymentNameAgent is the name of the Upbound agent Deployment.
+	DeploymentNameAgent = "upbound-agent"
 )
@@ -0,0 +1,171 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+const (
+	// DefaultDecisionTTL is how long authorization decisions are cached.
+	DefaultDecisionTTL = 1 * time.Minute
+
+	errCreateTokenReview = "cannot create token review"
+	errCreateSAR         = "cannot create subject access review"
+)
+
+// A Decision is the result of authorizing a request.
+type Decision int
+
+// Authorization decisions.
+const (
+	// DecisionAllowed means the request is authenticated and authorized.
+	DecisionAllowed Decision = iota
+	// DecisionUnauthenticated means the token is not valid.
+	DecisionUnauthenticated
+	// DecisionForbidden means the user is not allowed to access the path.
+	DecisionForbidden
+)
+
+// An Authorizer authenticates and authorizes requests to the metrics server.
+type Authorizer interface {
+	Authorize(ctx context.Context, token, verb, path string) (Decision, error)
+}
+
+// AuthorizerFn is a function that satisfies Authorizer.
+type AuthorizerFn func(ctx context.Context, token, verb, path string) (Decision, error)
+
+// Authorize calls the AuthorizerFn.
+func (fn AuthorizerFn) Authorize(ctx context.Context, token, verb, path string) (Decision, error) {
+	return fn(ctx, token, verb, path)
+}
+
+// NewKubernetesAuthorizer returns an Authorizer that authenticates bearer
+// tokens with TokenReviews and authorizes the users they belong to with
+// SubjectAccessReviews for non-resource URLs, like kube-rbac-proxy does.
+// Decisions are cached for DefaultDecisionTTL.
+func NewKubernetesAuthorizer(c client.Client) *KubernetesAuthorizer {
+	return &KubernetesAuthorizer{client: c, ttl: DefaultDecisionTTL, now: time.Now, cache: map[[sha256.Size]byte]cachedDecision{}}
+}
+
+type cachedDecision struct {
+	decision Decision
+	expires  time.Time
+}
+
+// KubernetesAuthorizer authorizes requests using the Kubernetes API server.
+type KubernetesAuthorizer struct {
+	client client.Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedDecision
+}
+
+// Authorize authenticates the given token and authorizes its user to access
+// the given path with the given verb.
+func (a *KubernetesAuthorizer) Authorize(ctx context.Context, token, verb, path string) (Decision, error) {
+	key := sha256.Sum256([]byte(verb + " " + path + " " + token))
+	a.mu.Lock()
+	if c, ok := a.cache[key]; ok && a.now().Before(c.expires) {
+		a.mu.Unlock()
+		return c.decision, nil
+	}
+	a.mu.Unlock()
+
+	d, err := a.authorize(ctx, token, verb, path)
+	if err != nil {
+		return d, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	for k, c := range a.cache {
+		if now.After(c.expires) {
+			delete(a.cache, k)
+		}
+	}
+	a.cache[key] = cachedDecision{decision: d, expires: now.Add(a.ttl)}
+	return d, nil
+}
+
+func (a *KubernetesAuthorizer) authorize(ctx context.Context, token, verb, path string) (Decision, error) {
+	tr := &authnv1.TokenReview{Spec: authnv1.TokenReviewSpec{Token: token}}
+	if err := a.client.Create(ctx, tr); err != nil {
+		return DecisionUnauthenticated, errors.Wrap(err, errCreateTokenReview)
+	}
+	if !tr.Status.Authenticated {
+		return DecisionUnauthenticated, nil
+	}
+
+	u := tr.Status.User
+	sar := &authzv1.SubjectAccessReview{Spec: authzv1.SubjectAccessReviewSpec{
+		User:                  u.Username,
+		UID:                   u.UID,
+		Groups:                u.Groups,
+		NonResourceAttributes: &authzv1.NonResourceAttributes{Path: path, Verb: verb},
+	}}
+	if len(u.Extra) > 0 {
+		sar.Spec.Extra = make(map[string]authzv1.ExtraValue, len(u.Extra))
+		for k, v := range u.Extra {
+			sar.Spec.Extra[k] = authzv1.ExtraValue(v)
+		}
+	}
+	if err := a.client.Create(ctx, sar); err != nil {
+		return DecisionForbidden, errors.Wrap(err, errCreateSAR)
+	}
+	if !sar.Status.Allowed {
+		return DecisionForbidden, nil
+	}
+	return DecisionAllowed, nil
+}
+
+func withAuth(h http.Handler, a Authorizer, log logging.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if token == "" || token == r.Header.Get("Authorization") {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		d, err := a.Authorize(r.Context(), token, strings.ToLower(r.Method), r.URL.Path)
+		if err != nil {
+			log.Info("Cannot authorize metrics request", "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		switch d {
+		case DecisionAllowed:
+			h.ServeHTTP(w, r)
+		case DecisionUnauthenticated:
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		case DecisionForbidden:
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+var errBoom = errors.New("boom")
+
+func TestHandler(t *testing.T) {
+	allow := func(d Decision, err error) Authorizer {
+		return AuthorizerFn(func(_ context.Context, _, _, _ string) (Decision, error) { return d, err })
+	}
+
+	cases := map[string]struct {
+		reason string
+		auth   Authorizer
+		header string
+		want   int
+	}{
+		"NoAuth": {
+			reason: "Metrics should be served to anyone if no Authorizer is configured.",
+			want:   http.StatusOK,
+		},
+		"NoToken": {
+			reason: "Requests without a bearer token should be rejected.",
+			auth:   allow(DecisionAllowed, nil),
+			want:   http.StatusUnauthorized,
+		},
+		"NotBearer": {
+			reason: "Requests without a bearer token should be rejected.",
+			auth:   allow(DecisionAllowed, nil),
+			header: "Basic Zm9vOmJhcg==",
+			want:   http.StatusUnauthorized,
+		},
+		"Unauthenticated": {
+			reason: "Requests with an invalid token should be rejected.",
+			auth:   allow(DecisionUnauthenticated, nil),
+			header: "Bearer t0k3n",
+			want:   http.StatusUnauthorized,
+		},
+		"Forbidden": {
+			reason: "Requests of users that are not allowed to get metrics should be rejected.",
+			auth:   allow(DecisionForbidden, nil),
+			header: "Bearer t0k3n",
+			want:   http.StatusForbidden,
+		},
+		"AuthorizerError": {
+			reason: "Requests should fail if they cannot be authorized.",
+			auth:   allow(DecisionForbidden, errBoom),
+			header: "Bearer t0k3n",
+			want:   http.StatusInternalServerError,
+		},
+		"Allowed": {
+			reason: "Metrics should be served to authorized users.",
+			auth:   allow(DecisionAllowed, nil),
+			header: "Bearer t0k3n",
+			want:   http.StatusOK,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts := []ServerOption{WithGatherer(prometheus.NewRegistry())}
+			if tc.auth != nil {
+				opts = append(opts, WithAuthorizer(tc.auth))
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, Path, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			NewServer(":0", opts...).Handler().ServeHTTP(rec, req)
+			if diff := cmp.Diff(tc.want, rec.Code); diff != "" {
+				t.Errorf("\nReason: %s\nServeHTTP(...): -want status, +got status:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestKubernetesAuthorizer(t *testing.T) {
+	type want struct {
+		decision Decision
+		err      error
+	}
+
+	review := func(authenticated, allowed bool, err error) test.MockCreateFn {
+		return func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+			switch o := obj.(type) {
+			case *authnv1.TokenReview:
+				o.Status.Authenticated = authenticated
+				o.Status.User.Username = "system:serviceaccount:monitoring:prometheus"
+			case *authzv1.SubjectAccessReview:
+				if o.Spec.NonResourceAttributes.Path != Path || o.Spec.User != "system:serviceaccount:monitoring:prometheus" {
+					return errors.New("unexpected subject access review")
+				}
+				o.Status.Allowed = allowed
+				return err
+			}
+			return nil
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		create test.MockCreateFn
+		want   want
+	}{
+		"TokenReviewError": {
+			reason: "An error should be returned if the token cannot be reviewed.",
+			create: test.NewMockCreateFn(errBoom),
+			want:   want{decision: DecisionUnauthenticated, err: errors.Wrap(errBoom, errCreateTokenReview)},
+		},
+		"Unauthenticated": {
+			reason: "Invalid tokens should not be authenticated.",
+			create: review(false, true, nil),
+			want:   want{decision: DecisionUnauthenticated},
+		},
+		"SubjectAccessReviewError": {
+			reason: "An error should be returned if access cannot be reviewed.",
+			create: review(true, false, errBoom),
+			want:   want{decision: DecisionForbidden, err: errors.Wrap(errBoom, errCreateSAR)},
+		},
+		"Forbidden": {
+			reason: "Users without access to the path should be forbidden.",
+			create: review(true, false, nil),
+			want:   want{decision: DecisionForbidden},
+		},
+		"Allowed": {
+			reason: "Users with access to the path should be allowed.",
+			create: review(true, true, nil),
+			want:   want{decision: DecisionAllowed},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a := NewKubernetesAuthorizer(&test.MockClient{MockCreate: tc.create})
+			d, err := a.Authorize(context.Background(), "t0k3n", "get", Path)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\na.Authorize(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.decision, d); diff != "" {
+				t.Errorf("\nReason: %s\na.Authorize(...): -want decision, +got decision:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+const errRegisterBuildInfo = "cannot register build info metric"
+
+// RegisterBuildInfo registers the uxp_build_info metric, which is always 1
+// and carries the build information of the binary as labels.
+func RegisterBuildInfo(reg prometheus.Registerer, i version.Info) error {
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "uxp_build_info",
+		Help: "Build information of the Universal Crossplane bootstrapper. Always 1.",
+	}, []string{"version", "commit", "build_date", "dirty", "go_version", "platform", "crossplane_version"})
+	g.WithLabelValues(i.Version, i.Commit, i.BuildDate, strconv.FormatBool(i.Dirty), i.GoVersion, i.Platform, i.CrossplaneVersion).Set(1)
+	return errors.Wrap(reg.Register(g), errRegisterBuildInfo)
+}
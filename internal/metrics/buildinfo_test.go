@@ -0,0 +1,66 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+func TestRegisterBuildInfo(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		info   version.Info
+		want   string
+	}{
+		"Released": {
+			reason: "The build information should be exposed as labels of a metric that is always 1.",
+			info:   version.Info{Version: "v1.20.4-up.1", Commit: "0123abc", BuildDate: "2021-05-18T00:00:00Z", GoVersion: "go1.19", Platform: "linux/amd64", CrossplaneVersion: "v1.20.4-up.1"},
+			want: `
+# HELP uxp_build_info Build information of the Universal Crossplane bootstrapper. Always 1.
+# TYPE uxp_build_info gauge
+uxp_build_info{build_date="2021-05-18T00:00:00Z",commit="0123abc",crossplane_version="v1.20.4-up.1",dirty="false",go_version="go1.19",platform="linux/amd64",version="v1.20.4-up.1"} 1
+`,
+		},
+		"Dirty": {
+			reason: "Builds from a dirty working tree should be labeled as such.",
+			info:   version.Info{Version: "0.0.0", Dirty: true, GoVersion: "go1.19", Platform: "linux/amd64"},
+			want: `
+# HELP uxp_build_info Build information of the Universal Crossplane bootstrapper. Always 1.
+# TYPE uxp_build_info gauge
+uxp_build_info{build_date="",commit="",crossplane_version="",dirty="true",go_version="go1.19",platform="linux/amd64",version="0.0.0"} 1
+`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			if err := RegisterBuildInfo(reg, tc.info); err != nil {
+				t.Fatalf("\nReason: %s\nRegisterBuildInfo(...): %s", tc.reason, err)
+			}
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(tc.want), "uxp_build_info"); err != nil {
+				t.Errorf("\nReason: %s\nRegisterBuildInfo(...): %s", tc.reason, err)
+			}
+			if err := RegisterBuildInfo(reg, tc.info); err == nil {
+				t.Errorf("\nReason: %s\nRegisterBuildInfo(...): want error registering twice", tc.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+const (
+	// DefaultRefreshInterval is how often certificates are checked for
+	// rotation.
+	DefaultRefreshInterval = 1 * time.Minute
+	// DefaultSelfSignedValidity is how long self-signed certificates are
+	// valid for. They are rotated once two thirds of it has passed.
+	DefaultSelfSignedValidity = 90 * 24 * time.Hour
+
+	errGetCertSecret  = "cannot get metrics certificate secret"
+	errParseKeyPair   = "cannot parse metrics certificate key pair"
+	errGenerateKey    = "cannot generate private key"
+	errGenerateSerial = "cannot generate serial number"
+	errCreateCert     = "cannot create self-signed certificate"
+	errMarshalKey     = "cannot marshal private key"
+	errNoCertificate  = "no metrics certificate is loaded"
+)
+
+// A CertificateSource provides the certificate the metrics server serves
+// with.
+type CertificateSource interface {
+	// Start loads the initial certificate and keeps it up to date until the
+	// supplied context is done.
+	Start(ctx context.Context) error
+	// GetCertificate returns the current certificate. It satisfies
+	// tls.Config.GetCertificate.
+	GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+type certificateStore struct {
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func (c *certificateStore) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cert == nil {
+		return nil, errors.New(errNoCertificate)
+	}
+	return c.cert, nil
+}
+
+func (c *certificateStore) set(cert *tls.Certificate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = cert
+}
+
+func (c *certificateStore) get() *tls.Certificate {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert
+}
+
+// NewSecretCertificateSource returns a CertificateSource that reads the
+// certificate from the tls.crt and tls.key keys of the given Secret and
+// reloads it when it changes.
+func NewSecretCertificateSource(r client.Reader, nn types.NamespacedName, l logging.Logger) *SecretCertificateSource {
+	return &SecretCertificateSource{reader: r, secret: nn, interval: DefaultRefreshInterval, log: l}
+}
+
+// SecretCertificateSource serves the certificate stored in a Secret.
+type SecretCertificateSource struct {
+	certificateStore
+
+	reader   client.Reader
+	secret   types.NamespacedName
+	interval time.Duration
+	log      logging.Logger
+
+	crt, key []byte
+}
+
+// Start loads the certificate and reloads it when the Secret changes.
+func (s *SecretCertificateSource) Start(ctx context.Context) error {
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+	go func() {
+		t := time.NewTicker(s.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := s.load(ctx); err != nil {
+					s.log.Info("Cannot reload metrics certificate, serving the previous one", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *SecretCertificateSource) load(ctx context.Context) error {
+	sec := &corev1.Secret{}
+	if err := s.reader.Get(ctx, s.secret, sec); err != nil {
+		return errors.Wrap(err, errGetCertSecret)
+	}
+	crt, key := sec.Data[corev1.TLSCertKey], sec.Data[corev1.TLSPrivateKeyKey]
+	if s.get() != nil && bytes.Equal(crt, s.crt) && bytes.Equal(key, s.key) {
+		return nil
+	}
+	cert, err := tls.X509KeyPair(crt, key)
+	if err != nil {
+		return errors.Wrap(err, errParseKeyPair)
+	}
+	s.set(&cert)
+	s.crt, s.key = crt, key
+	s.log.Debug("Loaded metrics certificate", "secret", s.secret)
+	return nil
+}
+
+// NewSelfSignedCertificateSource returns a CertificateSource that generates
+// a self-signed certificate for the given hosts and rotates it before it
+// expires.
+func NewSelfSignedCertificateSource(hosts []string, l logging.Logger) *SelfSignedCertificateSource {
+	return &SelfSignedCertificateSource{hosts: hosts, validity: DefaultSelfSignedValidity, interval: DefaultRefreshInterval, log: l}
+}
+
+// SelfSignedCertificateSource serves a self-signed certificate.
+type SelfSignedCertificateSource struct {
+	certificateStore
+
+	hosts    []string
+	validity time.Duration
+	interval time.Duration
+	log      logging.Logger
+}
+
+// Start generates the certificate and rotates it before it expires.
+func (s *SelfSignedCertificateSource) Start(ctx context.Context) error {
+	if err := s.rotate(); err != nil {
+		return err
+	}
+	go func() {
+		t := time.NewTicker(s.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if !s.needsRotation(time.Now()) {
+					continue
+				}
+				if err := s.rotate(); err != nil {
+					s.log.Info("Cannot rotate self-signed metrics certificate", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *SelfSignedCertificateSource) needsRotation(now time.Time) bool {
+	c := s.get()
+	if c == nil || c.Leaf == nil {
+		return true
+	}
+	return now.After(c.Leaf.NotAfter.Add(-s.validity / 3))
+}
+
+func (s *SelfSignedCertificateSource) rotate() error {
+	cert, err := SelfSignedCertificate(s.hosts, time.Now(), s.validity)
+	if err != nil {
+		return err
+	}
+	s.set(cert)
+	s.log.Debug("Generated self-signed metrics certificate", "notAfter", cert.Leaf.NotAfter)
+	return nil
+}
+
+// SelfSignedCertificate returns a new self-signed certificate for the given
+// hosts that is valid from now for the given duration.
+func SelfSignedCertificate(hosts []string, now time.Time, validity time.Duration) (*tls.Certificate, error) {
+	crt, key, err := SelfSignedKeyPair("uxp-bootstrapper-metrics", hosts, now, validity)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(crt, key)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseKeyPair)
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	return &cert, errors.Wrap(err, errParseKeyPair)
+}
+
+// SelfSignedKeyPair returns the PEM encoded certificate and private key of a
+// new self-signed certificate with the given common name for the given hosts
+// that is valid from now for the given duration.
+func SelfSignedKeyPair(cn string, hosts []string, now time.Time, validity time.Duration) (crt, key []byte, err error) {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errGenerateKey)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errGenerateSerial)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"Upbound Inc"}},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+			continue
+		}
+		tmpl.DNSNames = append(tmpl.DNSNames, h)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &pk.PublicKey, pk)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errCreateCert)
+	}
+	kb, err := x509.MarshalECPrivateKey(pk)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errMarshalKey)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+// keyPair returns a PEM encoded self-signed certificate and key for the given
+// host.
+func keyPair(t *testing.T, host string) (crt, key []byte) {
+	t.Helper()
+	c, err := SelfSignedCertificate([]string{host}, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("SelfSignedCertificate(...): %s", err)
+	}
+	kb, err := x509.MarshalECPrivateKey(c.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey(...): %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Certificate[0]}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb})
+}
+
+// secret returns a client that gets a Secret with the given data.
+func secret(data map[string][]byte) *test.MockClient {
+	return &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+		obj.(*corev1.Secret).Data = data
+		return nil
+	}}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	now := time.Now()
+	cert, err := SelfSignedCertificate([]string{"bootstrapper.upbound-system.svc", "10.0.0.1"}, now, time.Hour)
+	if err != nil {
+		t.Fatalf("SelfSignedCertificate(...): %s", err)
+	}
+	if diff := cmp.Diff([]string{"bootstrapper.upbound-system.svc"}, cert.Leaf.DNSNames); diff != "" {
+		t.Errorf("SelfSignedCertificate(...): -want DNS names, +got DNS names:\n%s", diff)
+	}
+	if len(cert.Leaf.IPAddresses) != 1 || !cert.Leaf.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("SelfSignedCertificate(...): want IP address 10.0.0.1, got %v", cert.Leaf.IPAddresses)
+	}
+	if !cert.Leaf.NotAfter.Equal(now.Add(time.Hour).Truncate(time.Second)) {
+		t.Errorf("SelfSignedCertificate(...): want not after %s, got %s", now.Add(time.Hour), cert.Leaf.NotAfter)
+	}
+}
+
+func TestSelfSignedCertificateSource(t *testing.T) {
+	s := NewSelfSignedCertificateSource([]string{"localhost"}, logging.NewNopLogger())
+	if _, err := s.GetCertificate(nil); err == nil {
+		t.Errorf("GetCertificate(...): want error before Start")
+	}
+	if !s.needsRotation(time.Now()) {
+		t.Errorf("needsRotation(...): want true before Start")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start(...): %s", err)
+	}
+	cert, err := s.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate(...): %s", err)
+	}
+
+	cases := map[string]struct {
+		reason string
+		now    time.Time
+		want   bool
+	}{
+		"Fresh": {
+			reason: "A newly generated certificate should not be rotated.",
+			now:    time.Now(),
+			want:   false,
+		},
+		"TwoThirdsPassed": {
+			reason: "A certificate should be rotated once two thirds of its validity have passed.",
+			now:    cert.Leaf.NotAfter.Add(-DefaultSelfSignedValidity/3 + time.Minute),
+			want:   true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := s.needsRotation(tc.now); got != tc.want {
+				t.Errorf("\nReason: %s\nneedsRotation(...): want %t, got %t", tc.reason, tc.want, got)
+			}
+		})
+	}
+
+	if err := s.rotate(); err != nil {
+		t.Fatalf("rotate(): %s", err)
+	}
+	rotated, _ := s.GetCertificate(nil)
+	if rotated.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) == 0 {
+		t.Errorf("rotate(): want a new certificate")
+	}
+}
+
+func TestSecretCertificateSourceLoad(t *testing.T) {
+	crt, key := keyPair(t, "a.example.org")
+	nn := types.NamespacedName{Namespace: "upbound-system", Name: "metrics-tls"}
+
+	cases := map[string]struct {
+		reason string
+		kube   client.Reader
+		want   error
+	}{
+		"GetError": {
+			reason: "We should return an error if the Secret cannot be fetched.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			want:   errors.Wrap(errBoom, errGetCertSecret),
+		},
+		"MissingKeyPair": {
+			reason: "We should return an error if the Secret has no key pair.",
+			kube:   secret(nil),
+			want:   errors.Wrap(errors.New("tls: failed to find any PEM data in certificate input"), errParseKeyPair),
+		},
+		"InvalidKeyPair": {
+			reason: "We should return an error if the certificate does not match the key.",
+			kube: func() client.Reader {
+				_, other := keyPair(t, "b.example.org")
+				return secret(map[string][]byte{corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: other})
+			}(),
+			want: errors.Wrap(errors.New("tls: private key does not match public key"), errParseKeyPair),
+		},
+		"Loaded": {
+			reason: "We should load a valid key pair.",
+			kube:   secret(map[string][]byte{corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: key}),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := NewSecretCertificateSource(tc.kube, nn, logging.NewNopLogger())
+			err := s.load(context.Background())
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\ns.load(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if _, gerr := s.GetCertificate(nil); (gerr == nil) != (tc.want == nil) {
+				t.Errorf("\nReason: %s\ns.GetCertificate(...): want a certificate only if it was loaded, got error %v", tc.reason, gerr)
+			}
+		})
+	}
+}
+
+func TestSecretCertificateSourceReload(t *testing.T) {
+	crt, key := keyPair(t, "a.example.org")
+	data := map[string][]byte{corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: key}
+	s := NewSecretCertificateSource(secret(data), types.NamespacedName{Name: "metrics-tls"}, logging.NewNopLogger())
+	if err := s.load(context.Background()); err != nil {
+		t.Fatalf("s.load(...): %s", err)
+	}
+	first, _ := s.GetCertificate(nil)
+
+	// An unchanged Secret keeps the certificate.
+	if err := s.load(context.Background()); err != nil {
+		t.Fatalf("s.load(...): %s", err)
+	}
+	if got, _ := s.GetCertificate(nil); got != first {
+		t.Errorf("s.load(...): want the same certificate if the Secret did not change")
+	}
+
+	// A rotated Secret replaces it.
+	data[corev1.TLSCertKey], data[corev1.TLSPrivateKeyKey] = keyPair(t, "b.example.org")
+	if err := s.load(context.Background()); err != nil {
+		t.Fatalf("s.load(...): %s", err)
+	}
+	got, _ := s.GetCertificate(nil)
+	if leaf, err := x509.ParseCertificate(got.Certificate[0]); err != nil || leaf.DNSNames[0] != "b.example.org" {
+		t.Errorf("s.load(...): want the rotated certificate, got %v (%v)", leaf, err)
+	}
+
+	// An invalid Secret keeps serving the previous certificate.
+	data[corev1.TLSPrivateKeyKey] = []byte("garbage")
+	if err := s.load(context.Background()); err == nil {
+		t.Errorf("s.load(...): want error for an invalid key pair")
+	}
+	if after, _ := s.GetCertificate(nil); after != got {
+		t.Errorf("s.load(...): want the previous certificate after an invalid update")
+	}
+}
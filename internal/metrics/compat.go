@@ -0,0 +1,50 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const errRegisterPackageCompatibility = "cannot register package compatibility metrics"
+
+// PackageCompatible reports whether a Crossplane package is compatible with
+// the running Crossplane version. It is 1 if the package is compatible, 0 if
+// it is not and there is no series if compatibility is not known.
+var PackageCompatible = prometheus.NewGaugeVec(prometheus.GaugeOpts{ //nolint:gochecknoglobals // Metrics are registered once per process.
+	Name: "uxp_package_compatible",
+	Help: "Whether a Crossplane package is compatible with the running Crossplane version.",
+}, []string{"kind", "name"})
+
+// RegisterPackageCompatibility registers the uxp_package_compatible metric.
+func RegisterPackageCompatibility(reg prometheus.Registerer) error {
+	return errors.Wrap(reg.Register(PackageCompatible), errRegisterPackageCompatibility)
+}
+
+// SetPackageCompatibility records whether the given package is compatible.
+func SetPackageCompatibility(kind, name string, compatible bool) {
+	v := 0.0
+	if compatible {
+		v = 1
+	}
+	PackageCompatible.WithLabelValues(kind, name).Set(v)
+}
+
+// DeletePackageCompatibility removes the compatibility of the given package.
+func DeletePackageCompatibility(kind, name string) {
+	PackageCompatible.DeleteLabelValues(kind, name)
+}
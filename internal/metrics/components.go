@@ -0,0 +1,50 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const errRegisterComponentHealth = "cannot register component health metric"
+
+// ComponentHealthy reports whether a component that is installed by the
+// bootstrapper is healthy. It is 1 if the component is healthy, 0 if it is
+// not and the series is removed when the component is uninstalled.
+var ComponentHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{ //nolint:gochecknoglobals // Metrics are registered once per process.
+	Name: "uxp_component_healthy",
+	Help: "Whether a Universal Crossplane component installed by the bootstrapper is healthy.",
+}, []string{"component"})
+
+// RegisterComponentHealth registers the uxp_component_healthy metric.
+func RegisterComponentHealth(reg prometheus.Registerer) error {
+	return errors.Wrap(reg.Register(ComponentHealthy), errRegisterComponentHealth)
+}
+
+// SetComponentHealthy records the health of the given component.
+func SetComponentHealthy(component string, healthy bool) {
+	v := 0.0
+	if healthy {
+		v = 1
+	}
+	ComponentHealthy.WithLabelValues(component).Set(v)
+}
+
+// DeleteComponentHealthy removes the health of the given component.
+func DeleteComponentHealthy(component string) {
+	ComponentHealthy.DeleteLabelValues(component)
+}
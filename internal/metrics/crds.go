@@ -0,0 +1,45 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const errRegisterCRDDrift = "cannot register crd drift metric"
+
+// CRDDrift reports whether a CRD in the cluster differs from the one bundled
+// with Universal Crossplane. It is 1 if the CRD has drifted in the given way
+// and 0 if it has not.
+var CRDDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{ //nolint:gochecknoglobals // Metrics are registered once per process.
+	Name: "uxp_crd_drift",
+	Help: "Whether a Crossplane CRD differs from the one bundled with Universal Crossplane.",
+}, []string{"crd", "type"})
+
+// RegisterCRDDrift registers the uxp_crd_drift metric.
+func RegisterCRDDrift(reg prometheus.Registerer) error {
+	return errors.Wrap(reg.Register(CRDDrift), errRegisterCRDDrift)
+}
+
+// SetCRDDrift records whether the given CRD has drifted in the given way.
+func SetCRDDrift(crd, typ string, drifted bool) {
+	v := 0.0
+	if drifted {
+		v = 1
+	}
+	CRDDrift.WithLabelValues(crd, typ).Set(v)
+}
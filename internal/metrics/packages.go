@@ -0,0 +1,73 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const errRegisterPackageHealth = "cannot register package health metrics"
+
+var (
+	// PackageHealthy reports whether a Crossplane package is installed and
+	// healthy. It is 1 if the package is healthy, 0 if it is not and the
+	// series is removed when the package is deleted.
+	PackageHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{ //nolint:gochecknoglobals // Metrics are registered once per process.
+		Name: "uxp_package_healthy",
+		Help: "Whether a Crossplane package is installed and healthy.",
+	}, []string{"kind", "name"})
+
+	// PackageLastFailure reports when a Crossplane package last became
+	// unhealthy, as a Unix timestamp. There is no series for packages that
+	// have not failed since the bootstrapper started observing them.
+	PackageLastFailure = prometheus.NewGaugeVec(prometheus.GaugeOpts{ //nolint:gochecknoglobals // Metrics are registered once per process.
+		Name: "uxp_package_last_failure_timestamp_seconds",
+		Help: "Unix time when a Crossplane package last became unhealthy.",
+	}, []string{"kind", "name"})
+)
+
+// RegisterPackageHealth registers the uxp_package_healthy and
+// uxp_package_last_failure_timestamp_seconds metrics.
+func RegisterPackageHealth(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{PackageHealthy, PackageLastFailure} {
+		if err := reg.Register(c); err != nil {
+			return errors.Wrap(err, errRegisterPackageHealth)
+		}
+	}
+	return nil
+}
+
+// SetPackageHealth records the health of the given package and when it last
+// failed. The time of the last failure is not recorded if it is zero.
+func SetPackageHealth(kind, name string, healthy bool, lastFailure time.Time) {
+	v := 0.0
+	if healthy {
+		v = 1
+	}
+	PackageHealthy.WithLabelValues(kind, name).Set(v)
+	if !lastFailure.IsZero() {
+		PackageLastFailure.WithLabelValues(kind, name).Set(float64(lastFailure.Unix()))
+	}
+}
+
+// DeletePackageHealth removes the health of the given package.
+func DeletePackageHealth(kind, name string) {
+	PackageHealthy.DeleteLabelValues(kind, name)
+	PackageLastFailure.DeleteLabelValues(kind, name)
+}
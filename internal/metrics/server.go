@@ -0,0 +1,151 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics contains the secure metrics server of the bootstrapper.
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+const (
+	// Path is where metrics are served.
+	Path = "/metrics"
+
+	shutdownTimeout   = 10 * time.Second
+	readHeaderTimeout = 10 * time.Second
+
+	errListen = "cannot listen on metrics address"
+	errServe  = "cannot serve metrics"
+)
+
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// WithCertificateSource makes the Server serve HTTPS with certificates from
+// the given source.
+func WithCertificateSource(cs CertificateSource) ServerOption {
+	return func(s *Server) {
+		s.certs = cs
+	}
+}
+
+// WithAuthorizer makes the Server authenticate and authorize every request
+// with the given Authorizer.
+func WithAuthorizer(a Authorizer) ServerOption {
+	return func(s *Server) {
+		s.auth = a
+	}
+}
+
+// WithLogger specifies how the Server should log messages.
+func WithLogger(l logging.Logger) ServerOption {
+	return func(s *Server) {
+		s.log = l
+	}
+}
+
+// WithGatherer specifies the Prometheus gatherer to serve metrics from.
+// Defaults to the controller-runtime registry.
+func WithGatherer(g prometheus.Gatherer) ServerOption {
+	return func(s *Server) {
+		s.gatherer = g
+	}
+}
+
+// Server serves the metrics registered to the controller-runtime registry,
+// optionally over TLS and with authentication and authorization of clients.
+// It implements manager.Runnable.
+type Server struct {
+	addr     string
+	gatherer prometheus.Gatherer
+	certs    CertificateSource
+	auth     Authorizer
+	log      logging.Logger
+}
+
+// NewServer returns a new metrics Server that listens on the given address.
+func NewServer(addr string, opts ...ServerOption) *Server {
+	s := &Server{
+		addr:     addr,
+		gatherer: crmetrics.Registry,
+		log:      logging.NewNopLogger(),
+	}
+	for _, f := range opts {
+		f(s)
+	}
+	return s
+}
+
+// Handler returns the HTTP handler of the Server.
+func (s *Server) Handler() http.Handler {
+	var h http.Handler = promhttp.HandlerFor(s.gatherer, promhttp.HandlerOpts{ErrorHandling: promhttp.HTTPErrorOnError})
+	if s.auth != nil {
+		h = withAuth(h, s.auth, s.log)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(Path, h)
+	return mux
+}
+
+// NeedLeaderElection returns false since metrics should be served by every
+// replica.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves metrics until the supplied context is done.
+func (s *Server) Start(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return errors.Wrap(err, errListen)
+	}
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: readHeaderTimeout}
+	if s.certs != nil {
+		if err := s.certs.Start(ctx); err != nil {
+			return err
+		}
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: s.certs.GetCertificate,
+		}
+		l = tls.NewListener(l, srv.TLSConfig)
+	}
+
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(sctx); err != nil { //nolint:contextcheck // The parent context is already done.
+			s.log.Info("Cannot shutdown metrics server gracefully", "error", err)
+		}
+	}()
+
+	s.log.Info("Serving metrics", "address", s.addr, "secure", s.certs != nil, "auth", s.auth != nil)
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, errServe)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const errRegisterUpdates = "cannot register update metrics"
+
+var (
+	// UpdateAvailable reports whether a newer patch release of the running
+	// Universal Crossplane minor version is available. The security label
+	// is "true" if the update fixes vulnerabilities.
+	UpdateAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{ //nolint:gochecknoglobals // Metrics are registered once per process.
+		Name: "uxp_update_available",
+		Help: "Whether a newer patch release of the running Universal Crossplane is available.",
+	}, []string{"security"})
+
+	// EndOfLife reports when the running Universal Crossplane minor version
+	// stops being supported, as a Unix timestamp. There is no series if the
+	// date is not known.
+	EndOfLife = prometheus.NewGaugeVec(prometheus.GaugeOpts{ //nolint:gochecknoglobals // Metrics are registered once per process.
+		Name: "uxp_end_of_life_timestamp_seconds",
+		Help: "Unix time when the running Universal Crossplane minor version stops being supported.",
+	}, []string{"version"})
+)
+
+// RegisterUpdates registers the uxp_update_available and
+// uxp_end_of_life_timestamp_seconds metrics.
+func RegisterUpdates(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{UpdateAvailable, EndOfLife} {
+		if err := reg.Register(c); err != nil {
+			return errors.Wrap(err, errRegisterUpdates)
+		}
+	}
+	return nil
+}
+
+// SetUpdates records whether an update of the running minor version is
+// available and when that minor version reaches its end of life. The end of
+// life is not recorded if it is zero.
+func SetUpdates(available, security bool, minor string, endOfLife time.Time) {
+	v := 0.0
+	if available {
+		v = 1
+	}
+	s := "false"
+	if security {
+		s = "true"
+	}
+	UpdateAvailable.Reset()
+	UpdateAvailable.WithLabelValues(s).Set(v)
+	EndOfLife.Reset()
+	if !endOfLife.IsZero() {
+		EndOfLife.WithLabelValues(minor).Set(float64(endOfLife.Unix()))
+	}
+}
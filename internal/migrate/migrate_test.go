@@ -0,0 +1,433 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/preflight"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+func TestDiffValues(t *testing.T) {
+	oss := map[string]any{
+		"replicas":     1,
+		"image":        map[string]any{"repository": "crossplane/crossplane", "tag": ""},
+		"args":         []any{},
+		"metrics":      map[string]any{"enabled": false},
+		"resourcesXYZ": map[string]any{"limits": map[string]any{"cpu": "100m"}},
+	}
+	uxp := map[string]any{
+		"replicas":          1,
+		"image":             map[string]any{"repository": "xpkg.upbound.io/upbound/crossplane", "tag": "v1.14.0-up.1"},
+		"args":              []any{},
+		"metrics":           map[string]any{"enabled": false},
+		"customAnnotations": map[string]any{},
+	}
+	config := map[string]any{
+		"replicas":          2,
+		"image":             map[string]any{"tag": "v1.14.0"},
+		"args":              []any{"--debug"},
+		"customAnnotations": map[string]any{"iam.amazonaws.com/role": "crossplane"},
+		"provider":          map[string]any{"packages": []any{"xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0"}},
+	}
+
+	diffs, carried := DiffValues(oss, config, uxp)
+	wantDiffs := []ValueDiff{
+		{Type: ValueCarried, Key: "args", OSS: []any{"--debug"}, UXP: []any{"--debug"}},
+		{Type: ValueCarried, Key: "customAnnotations.iam.amazonaws.com/role", OSS: "crossplane", UXP: "crossplane"},
+		{Type: ValueDefaultChanged, Key: "image.repository", OSS: "crossplane/crossplane", UXP: "xpkg.upbound.io/upbound/crossplane"},
+		{Type: ValueReplaced, Key: "image.tag", OSS: "v1.14.0", UXP: "v1.14.0-up.1"},
+		{Type: ValueUnsupported, Key: "provider.packages", OSS: []any{"xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0"}},
+		{Type: ValueCarried, Key: "replicas", OSS: 2, UXP: 2},
+	}
+	if diff := cmp.Diff(wantDiffs, diffs); diff != "" {
+		t.Errorf("DiffValues(...): -want diffs, +got diffs:\n%s", diff)
+	}
+	wantCarried := map[string]any{
+		"replicas":          2,
+		"args":              []any{"--debug"},
+		"customAnnotations": map[string]any{"iam.amazonaws.com/role": "crossplane"},
+	}
+	if diff := cmp.Diff(wantCarried, carried); diff != "" {
+		t.Errorf("DiffValues(...): -want carried, +got carried:\n%s", diff)
+	}
+}
+
+func TestVersionParity(t *testing.T) {
+	target := version.MustParse("v1.14.0-up.1")
+	cases := map[string]struct {
+		installed string
+		want      bool
+	}{
+		"Same":        {installed: "v1.14.0", want: true},
+		"Older":       {installed: "v1.13.2"},
+		"AlreadyUXP":  {installed: "v1.14.0-up.1"},
+		"NotDetected": {installed: ""},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := VersionParity(tc.installed, target); got.Passed != tc.want {
+				t.Errorf("VersionParity(%q, ...): want passed %t, got %+v", tc.installed, tc.want, got)
+			}
+		})
+	}
+}
+
+// encode encodes a release the way Helm stores it in a Secret.
+func encode(t *testing.T, r map[string]any) []byte {
+	t.Helper()
+	j, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	_, _ = gz.Write(j)
+	_ = gz.Close()
+	return []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+func TestPlanAndApply(t *testing.T) {
+	manifest := `---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: crossplane
+  namespace: crossplane-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: crossplane
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: crossplane
+  namespace: crossplane-system
+`
+	release := encode(t, map[string]any{
+		"name":      "crossplane",
+		"namespace": "crossplane-system",
+		"version":   3,
+		"chart": map[string]any{
+			"metadata": map[string]any{"name": "crossplane", "version": "1.14.0", "appVersion": "1.14.0"},
+			"values":   map[string]any{"replicas": 1},
+		},
+		"config":   map[string]any{"replicas": 2},
+		"manifest": manifest,
+	})
+
+	// The ServiceAccount is owned by the release, the ClusterRole was created
+	// by other means and the Deployment lacks the managed-by label.
+	live := map[string]struct {
+		labels      map[string]string
+		annotations map[string]string
+	}{
+		"ServiceAccount": {labels: map[string]string{LabelKeyManagedBy: LabelValueHelm}, annotations: map[string]string{AnnotationKeyReleaseName: "crossplane", AnnotationKeyReleaseNamespace: "crossplane-system"}},
+		"ClusterRole":    {},
+		"Deployment":     {annotations: map[string]string{AnnotationKeyReleaseName: "crossplane", AnnotationKeyReleaseNamespace: "crossplane-system"}},
+	}
+
+	var patched []string
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			l := list.(*corev1.SecretList)
+			l.Items = []corev1.Secret{{Data: map[string][]byte{keyRelease: release}}}
+			l.Items[0].SetLabels(map[string]string{labelVersion: "3"})
+			return nil
+		},
+		MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			switch o := obj.(type) {
+			case *appsv1.Deployment:
+				o.Spec.Template.Spec.Containers = []corev1.Container{{Image: "crossplane/crossplane:v1.14.0"}}
+			case *unstructured.Unstructured:
+				l := live[o.GetKind()]
+				o.SetLabels(l.labels)
+				o.SetAnnotations(l.annotations)
+			default:
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			return nil
+		},
+		MockPatch: func(_ context.Context, obj client.Object, p client.Patch, _ ...client.PatchOption) error {
+			b, _ := p.Data(obj)
+			patched = append(patched, obj.GetObjectKind().GroupVersionKind().Kind+" "+string(b))
+			return nil
+		},
+	}
+
+	plan, err := NewPlanner(kube, "crossplane-system", "crossplane", version.MustParse("v1.14.0-up.1"), nil, map[string]any{"replicas": 1}).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan(...): %s", err)
+	}
+	if !plan.Ready() {
+		t.Errorf("Plan(...): want ready plan, got:\n%s", plan)
+	}
+	wantActions := []Action{
+		{
+			Type: ActionAdopt, APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "crossplane",
+			Labels:      map[string]string{LabelKeyManagedBy: LabelValueHelm},
+			Annotations: map[string]string{AnnotationKeyReleaseName: "crossplane", AnnotationKeyReleaseNamespace: "crossplane-system"},
+		},
+		{
+			Type: ActionRelabel, APIVersion: "apps/v1", Kind: "Deployment", Namespace: "crossplane-system", Name: "crossplane",
+			Labels: map[string]string{LabelKeyManagedBy: LabelValueHelm},
+		},
+	}
+	if diff := cmp.Diff(wantActions, plan.Actions); diff != "" {
+		t.Errorf("Plan(...): -want actions, +got actions:\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]any{"replicas": float64(2)}, plan.CarriedValues); diff != "" {
+		t.Errorf("Plan(...): -want carried values, +got carried values:\n%s", diff)
+	}
+
+	if err := Apply(context.Background(), kube, plan); err != nil {
+		t.Fatalf("Apply(...): %s", err)
+	}
+	wantPatched := []string{
+		`ClusterRole {"metadata":{"annotations":{"meta.helm.sh/release-name":"crossplane","meta.helm.sh/release-namespace":"crossplane-system"},"labels":{"app.kubernetes.io/managed-by":"Helm"}}}`,
+		`Deployment {"metadata":{"labels":{"app.kubernetes.io/managed-by":"Helm"}}}`,
+	}
+	if diff := cmp.Diff(wantPatched, patched); diff != "" {
+		t.Errorf("Apply(...): -want patched, +got patched:\n%s", diff)
+	}
+
+	plan.Checks = append(plan.Checks, preflight.Result{Name: CheckVersionParity})
+	if diff := cmp.Diff(errors.New(errNotReady), Apply(context.Background(), kube, plan), test.EquateErrors()); diff != "" {
+		t.Errorf("Apply(...): -want error, +got error:\n%s", diff)
+	}
+}
+
+func TestRuntimeConfigs(t *testing.T) {
+	cc := func(name string, spec map[string]any) unstructured.Unstructured {
+		u := unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+		u.SetGroupVersionKind(ControllerConfigGroupVersionKind)
+		u.SetName(name)
+		return u
+	}
+	debug := cc("debug", map[string]any{
+		"metadata":           map[string]any{"labels": map[string]any{"team": "platform"}},
+		"replicas":           int64(2),
+		"args":               []any{"--debug"},
+		"podSecurityContext": map[string]any{"fsGroup": int64(2000)},
+		"serviceAccountName": "provider",
+	})
+	done := cc("done", map[string]any{"nodeSelector": map[string]any{"pool": "crossplane"}})
+	taken := cc("taken", map[string]any{"image": "registry.corp/provider-gcp:v1.0.0"})
+	provider := func(name, cc, rc string) pkgv1.Provider {
+		p := pkgv1.Provider{}
+		p.SetName(name)
+		p.Spec.ControllerConfigReference = &pkgv1.ControllerConfigReference{Name: cc}
+		if rc != "" {
+			p.Spec.RuntimeConfigReference = &pkgv1.RuntimeConfigReference{Name: rc}
+		}
+		return p
+	}
+
+	// The default DeploymentRuntimeConfig references the pull Secrets the
+	// pull-secrets controller injected.
+	defaultSpec := map[string]any{
+		"deploymentTemplate": map[string]any{"spec": map[string]any{
+			"selector": map[string]any{},
+			"template": map[string]any{"spec": map[string]any{
+				"containers":       []any{map[string]any{"name": RuntimeContainerName}},
+				"imagePullSecrets": []any{map[string]any{"name": "regcred"}},
+			}},
+		}},
+	}
+
+	var created []string
+	var patched []string
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+			switch l := list.(type) {
+			case *unstructured.UnstructuredList:
+				l.Items = []unstructured.Unstructured{debug, done, taken}
+			case *pkgv1.ProviderList:
+				l.Items = []pkgv1.Provider{
+					provider("provider-aws-s3", "debug", "default"),
+					provider("provider-helm", "debug", "custom"),
+					provider("provider-azure", "done", ""),
+					provider("provider-gcp", "taken", ""),
+					{},
+				}
+			}
+			return nil
+		},
+		MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			u := obj.(*unstructured.Unstructured)
+			switch key.Name {
+			case "default":
+				u.Object["spec"] = defaultSpec
+			case "done":
+				rc, _ := ConvertControllerConfig(&done)
+				u.Object["spec"] = mergeSpec(defaultSpec, rc.Object["spec"].(map[string]any))
+			case "taken":
+				u.Object["spec"] = map[string]any{}
+			default:
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			return nil
+		},
+		MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+			created = append(created, obj.GetName())
+			return nil
+		},
+		MockPatch: func(_ context.Context, obj client.Object, p client.Patch, _ ...client.PatchOption) error {
+			b, _ := p.Data(obj)
+			patched = append(patched, obj.GetName()+" "+string(b))
+			return nil
+		},
+	}
+
+	m, err := PlanRuntimeConfigs(context.Background(), kube)
+	if err != nil {
+		t.Fatalf("PlanRuntimeConfigs(...): %s", err)
+	}
+	wantCreate := map[string]any{
+		"apiVersion": "pkg.crossplane.io/v1beta1",
+		"kind":       "DeploymentRuntimeConfig",
+		"metadata":   map[string]any{"name": "debug"},
+		"spec": map[string]any{
+			"deploymentTemplate": map[string]any{"spec": map[string]any{
+				"replicas": int64(2),
+				"selector": map[string]any{},
+				"template": map[string]any{
+					"metadata": map[string]any{"labels": map[string]any{"team": "platform"}},
+					"spec": map[string]any{
+						"securityContext":  map[string]any{"fsGroup": int64(2000)},
+						"containers":       []any{map[string]any{"name": RuntimeContainerName, "args": []any{"--debug"}}},
+						"imagePullSecrets": []any{map[string]any{"name": "regcred"}},
+					},
+				},
+			}},
+			"serviceAccountTemplate": map[string]any{"metadata": map[string]any{"name": "provider"}},
+		},
+	}
+	if len(m.Create) != 1 {
+		t.Fatalf("PlanRuntimeConfigs(...): want 1 DeploymentRuntimeConfig to create, got %d", len(m.Create))
+	}
+	if diff := cmp.Diff(wantCreate, m.Create[0].Object); diff != "" {
+		t.Errorf("PlanRuntimeConfigs(...): -want created, +got created:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"done"}, m.Existing); diff != "" {
+		t.Errorf("PlanRuntimeConfigs(...): -want existing, +got existing:\n%s", diff)
+	}
+	wantRepoint := []ProviderRepoint{
+		{Name: "provider-aws-s3", ControllerConfig: "debug", RuntimeConfig: "default"},
+		{Name: "provider-helm", ControllerConfig: "debug", RuntimeConfig: "custom"},
+		{Name: "provider-azure", ControllerConfig: "done"},
+	}
+	if diff := cmp.Diff(wantRepoint, m.Repoint); diff != "" {
+		t.Errorf("PlanRuntimeConfigs(...): -want repoint, +got repoint:\n%s", diff)
+	}
+	if want := "! Provider provider-helm: the settings of DeploymentRuntimeConfig custom are not carried over to debug\n"; !strings.Contains(m.Diff(), want) {
+		t.Errorf("m.Diff(): want %q in:\n%s", want, m.Diff())
+	}
+	if diff := cmp.Diff([]RuntimeConfigConflict{{Name: "taken", Providers: []string{"provider-gcp"}}}, m.Conflicts); diff != "" {
+		t.Errorf("PlanRuntimeConfigs(...): -want conflicts, +got conflicts:\n%s", diff)
+	}
+
+	if err := ApplyRuntimeConfigs(context.Background(), kube, m); err != nil {
+		t.Fatalf("ApplyRuntimeConfigs(...): %s", err)
+	}
+	if diff := cmp.Diff([]string{"debug"}, created); diff != "" {
+		t.Errorf("ApplyRuntimeConfigs(...): -want created, +got created:\n%s", diff)
+	}
+	wantPatched := []string{
+		`provider-aws-s3 {"spec":{"controllerConfigRef":null,"runtimeConfigRef":{"name":"debug"}}}`,
+		`provider-helm {"spec":{"controllerConfigRef":null,"runtimeConfigRef":{"name":"debug"}}}`,
+		`provider-azure {"spec":{"controllerConfigRef":null,"runtimeConfigRef":{"name":"done"}}}`,
+	}
+	if diff := cmp.Diff(wantPatched, patched); diff != "" {
+		t.Errorf("ApplyRuntimeConfigs(...): -want patched, +got patched:\n%s", diff)
+	}
+}
+
+func TestConvertControllerConfig(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		spec   map[string]any
+		want   map[string]any
+	}{
+		"NoContainerSettings": {
+			reason: "The package runtime container should be added even if the ControllerConfig has no container settings.",
+			spec: map[string]any{
+				"serviceAccountName": "provider",
+				"replicas":           int64(1),
+				"nodeSelector":       map[string]any{"pool": "crossplane"},
+				"tolerations":        []any{map[string]any{"key": "dedicated", "operator": "Exists"}},
+			},
+			want: map[string]any{
+				"deploymentTemplate": map[string]any{"spec": map[string]any{
+					"replicas": int64(1),
+					"selector": map[string]any{},
+					"template": map[string]any{"spec": map[string]any{
+						"nodeSelector": map[string]any{"pool": "crossplane"},
+						"tolerations":  []any{map[string]any{"key": "dedicated", "operator": "Exists"}},
+						"containers":   []any{map[string]any{"name": RuntimeContainerName}},
+					}},
+				}},
+				"serviceAccountTemplate": map[string]any{"metadata": map[string]any{"name": "provider"}},
+			},
+		},
+		"Empty": {
+			reason: "An empty ControllerConfig should be converted to a DeploymentRuntimeConfig with just the package runtime container.",
+			spec:   map[string]any{},
+			want: map[string]any{
+				"deploymentTemplate": map[string]any{"spec": map[string]any{
+					"selector": map[string]any{},
+					"template": map[string]any{"spec": map[string]any{
+						"containers": []any{map[string]any{"name": RuntimeContainerName}},
+					}},
+				}},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cc := &unstructured.Unstructured{Object: map[string]any{"spec": tc.spec}}
+			cc.SetGroupVersionKind(ControllerConfigGroupVersionKind)
+			cc.SetName("cc")
+			got, err := ConvertControllerConfig(cc)
+			if err != nil {
+				t.Fatalf("\n%s\nConvertControllerConfig(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got.Object["spec"]); diff != "" {
+				t.Errorf("\n%s\nConvertControllerConfig(...): -want spec, +got spec:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
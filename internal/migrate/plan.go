@@ -0,0 +1,293 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrate plans and applies the migration of an open source
+// Crossplane Helm release to Universal Crossplane, and of deprecated
+// ControllerConfigs to DeploymentRuntimeConfigs.
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/preflight"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// The ownership metadata Helm requires to adopt an object into a release.
+const (
+	LabelKeyManagedBy             = "app.kubernetes.io/managed-by"
+	LabelValueHelm                = "Helm"
+	AnnotationKeyReleaseName      = "meta.helm.sh/release-name"
+	AnnotationKeyReleaseNamespace = "meta.helm.sh/release-namespace"
+)
+
+// CheckVersionParity is the name of the check that the installed open source
+// Crossplane has the version Universal Crossplane is based on.
+const CheckVersionParity = "VersionParity"
+
+const (
+	errRunPreflight = "cannot run preflight checks"
+	errFmtGetObject = "cannot get %s %s"
+	errFmtPatch     = "cannot patch %s %s"
+	errMarshalPatch = "cannot marshal patch"
+	errNotReady     = "plan is not ready to be applied, see its failed checks"
+)
+
+// An ActionType is the type of change an action makes to an object.
+type ActionType string
+
+// Action types.
+const (
+	// ActionAdopt adds the object to the release.
+	ActionAdopt ActionType = "Adopt"
+	// ActionRelabel labels an object of the release as managed by Helm.
+	ActionRelabel ActionType = "Relabel"
+)
+
+// An Action is a change to an object that is required before the release
+// can be upgraded to Universal Crossplane.
+type Action struct {
+	Type       ActionType `json:"type"`
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Name       string     `json:"name"`
+	// Labels and Annotations are added to the object.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// A Plan to migrate an open source Crossplane release to Universal
+// Crossplane. It is meant to be reviewed before it is applied.
+type Plan struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Namespace string    `json:"namespace"`
+	Release   string    `json:"release"`
+	// Chart is the open source chart of the release, e.g. crossplane-1.14.0.
+	Chart string `json:"chart"`
+
+	Installed string `json:"installed,omitempty"`
+	Target    string `json:"target"`
+
+	Checks  []preflight.Result `json:"checks"`
+	Values  []ValueDiff        `json:"values,omitempty"`
+	Actions []Action           `json:"actions,omitempty"`
+
+	// CarriedValues are the values to upgrade the release with.
+	CarriedValues map[string]any `json:"carriedValues,omitempty"`
+}
+
+// Ready returns true if all checks passed.
+func (p *Plan) Ready() bool {
+	for _, c := range p.Checks {
+		if !c.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a human readable summary of the plan.
+func (p *Plan) String() string {
+	b := &strings.Builder{}
+	installed := p.Installed
+	if installed == "" {
+		installed = "none"
+	}
+	fmt.Fprintf(b, "Release %s/%s of chart %s\nInstalled Crossplane: %s\nTarget Crossplane: %s\n\n", p.Namespace, p.Release, p.Chart, installed, p.Target)
+	for _, c := range p.Checks {
+		status := "PASS"
+		if !c.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(b, "[%s] %s: %s\n", status, c.Name, c.Message)
+	}
+	if len(p.Values) > 0 {
+		fmt.Fprintln(b, "\nValues:")
+		for _, v := range p.Values {
+			fmt.Fprintf(b, "  [%s] %s: %v -> %v\n", v.Type, v.Key, v.OSS, v.UXP)
+		}
+	}
+	if len(p.Actions) > 0 {
+		fmt.Fprintln(b, "\nActions:")
+		for _, a := range p.Actions {
+			fmt.Fprintf(b, "  [%s] %s %s\n", a.Type, a.Kind, objectName(a.Namespace, a.Name))
+		}
+	}
+	return b.String()
+}
+
+// A Planner plans migrations.
+type Planner struct {
+	client    client.Client
+	namespace string
+	release   string
+	target    version.Semver
+	crds      []*extv1.CustomResourceDefinition
+	values    map[string]any
+	now       func() time.Time
+}
+
+// NewPlanner returns a Planner that plans to migrate the given release in the
+// given namespace to the Universal Crossplane target version with the given
+// bundled CRDs and default chart values.
+func NewPlanner(c client.Client, namespace, release string, target version.Semver, crds []*extv1.CustomResourceDefinition, values map[string]any) *Planner {
+	return &Planner{client: c, namespace: namespace, release: release, target: target, crds: crds, values: values, now: time.Now}
+}
+
+// Plan inspects the release and returns the plan to migrate it. An error is
+// returned only if the release cannot be inspected; problems that prevent
+// the migration are failed checks of the plan.
+func (p *Planner) Plan(ctx context.Context) (*Plan, error) {
+	rel, err := GetRelease(ctx, p.client, p.namespace, p.release)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{
+		CreatedAt: p.now().UTC().Truncate(time.Second),
+		Namespace: p.namespace,
+		Release:   p.release,
+		Chart:     rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version,
+		Target:    p.target.String(),
+	}
+
+	r, err := preflight.NewChecker(p.client, p.namespace, p.target, p.crds).Run(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errRunPreflight)
+	}
+	plan.Installed = r.Installed
+	plan.Checks = append(r.Results, VersionParity(r.Installed, p.target))
+
+	plan.Values, plan.CarriedValues = DiffValues(rel.Chart.Values, rel.Config, p.values)
+
+	objs, err := rel.Objects()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range objs {
+		a, err := p.action(ctx, rel, o)
+		if err != nil {
+			return nil, err
+		}
+		if a != nil {
+			plan.Actions = append(plan.Actions, *a)
+		}
+	}
+	return plan, nil
+}
+
+// VersionParity checks that the installed Crossplane is open source
+// Crossplane of the version the target Universal Crossplane is based on.
+func VersionParity(installed string, target version.Semver) preflight.Result {
+	res := preflight.Result{Name: CheckVersionParity}
+	if installed == "" {
+		res.Message = "Cannot determine the installed Crossplane version"
+		return res
+	}
+	v, err := version.Parse(installed)
+	switch {
+	case err != nil:
+		res.Message = fmt.Sprintf("Cannot parse the installed Crossplane version %q", installed)
+	case v.IsUXP():
+		res.Message = fmt.Sprintf("Universal Crossplane %s is already installed", v)
+	case !v.Equal(target.Upstream()):
+		res.Message = fmt.Sprintf("Crossplane %s must be upgraded to %s before migrating to Universal Crossplane %s", v, target.Upstream(), target)
+	default:
+		res.Passed, res.Message = true, fmt.Sprintf("Universal Crossplane %s is based on the installed Crossplane %s", target, v)
+	}
+	return res
+}
+
+// action returns the action required for Helm to upgrade the given object of
+// the release, or nil if none is required or the object does not exist.
+func (p *Planner) action(ctx context.Context, rel *Release, o *unstructured.Unstructured) (*Action, error) {
+	ns := o.GetNamespace()
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(o.GroupVersionKind())
+	err := p.client.Get(ctx, types.NamespacedName{Namespace: ns, Name: o.GetName()}, live)
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtGetObject, o.GetKind(), objectName(ns, o.GetName()))
+	}
+
+	a := &Action{APIVersion: o.GetAPIVersion(), Kind: o.GetKind(), Namespace: ns, Name: o.GetName()}
+	ann := live.GetAnnotations()
+	if ann[AnnotationKeyReleaseName] != rel.Name || ann[AnnotationKeyReleaseNamespace] != rel.Namespace {
+		a.Type = ActionAdopt
+		a.Annotations = map[string]string{AnnotationKeyReleaseName: rel.Name, AnnotationKeyReleaseNamespace: rel.Namespace}
+	}
+	if live.GetLabels()[LabelKeyManagedBy] != LabelValueHelm {
+		if a.Type == "" {
+			a.Type = ActionRelabel
+		}
+		a.Labels = map[string]string{LabelKeyManagedBy: LabelValueHelm}
+	}
+	if a.Type == "" {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// Apply applies the actions of the given plan. Plans whose checks failed are
+// never applied.
+func Apply(ctx context.Context, c client.Client, plan *Plan) error {
+	if !plan.Ready() {
+		return errors.New(errNotReady)
+	}
+	for _, a := range plan.Actions {
+		// Null labels or annotations would remove all of them, so only
+		// those that are added are patched.
+		md := map[string]any{}
+		if len(a.Labels) > 0 {
+			md["labels"] = a.Labels
+		}
+		if len(a.Annotations) > 0 {
+			md["annotations"] = a.Annotations
+		}
+		patch, err := json.Marshal(map[string]any{"metadata": md})
+		if err != nil {
+			return errors.Wrap(err, errMarshalPatch)
+		}
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(a.APIVersion)
+		u.SetKind(a.Kind)
+		u.SetNamespace(a.Namespace)
+		u.SetName(a.Name)
+		if err := c.Patch(ctx, u, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return errors.Wrapf(err, errFmtPatch, a.Kind, objectName(a.Namespace, a.Name))
+		}
+	}
+	return nil
+}
+
+func objectName(namespace, name string) string {
+	if namespace != "" {
+		return namespace + "/" + name
+	}
+	return name
+}
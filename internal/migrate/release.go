@@ -0,0 +1,143 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+// Labels of the Secrets that Helm stores releases in.
+const (
+	labelOwner   = "owner"
+	labelName    = "name"
+	labelStatus  = "status"
+	labelVersion = "version"
+
+	ownerHelm      = "helm"
+	statusDeployed = "deployed"
+
+	// chartNameCrossplane is the name of the open source Crossplane chart.
+	chartNameCrossplane = "crossplane"
+
+	keyRelease = "release"
+)
+
+const (
+	errListReleases   = "cannot list helm release secrets"
+	errFmtNoRelease   = "no deployed helm release %s in namespace %s"
+	errDecodeRelease  = "cannot decode helm release"
+	errDecodeManifest = "cannot decode helm release manifest"
+	errFmtChartNotOSS = "helm release %s is of chart %s, not the open source crossplane chart"
+)
+
+// A Release of a Helm chart, as stored by Helm.
+type Release struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Chart     struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+		// Values are the default values of the chart.
+		Values map[string]any `json:"values"`
+	} `json:"chart"`
+	// Config are the values the user supplied.
+	Config map[string]any `json:"config"`
+	// Manifest is the rendered multi-document YAML of the release.
+	Manifest string `json:"manifest"`
+}
+
+// Objects returns the objects of the release manifest.
+func (r *Release) Objects() ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	d := kyaml.NewYAMLOrJSONDecoder(bufio.NewReader(bytes.NewReader([]byte(r.Manifest))), 4096)
+	for {
+		u := &unstructured.Unstructured{}
+		err := d.Decode(&u.Object)
+		if errors.Is(err, io.EOF) {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, errDecodeManifest)
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+		objs = append(objs, u)
+	}
+}
+
+// GetRelease returns the latest deployed revision of the given Helm release.
+// The release must be of the open source Crossplane chart.
+func GetRelease(ctx context.Context, c client.Reader, namespace, name string) (*Release, error) {
+	l := &corev1.SecretList{}
+	if err := c.List(ctx, l, client.InNamespace(namespace), client.MatchingLabels{labelOwner: ownerHelm, labelName: name, labelStatus: statusDeployed}); err != nil {
+		return nil, errors.Wrap(err, errListReleases)
+	}
+	if len(l.Items) == 0 {
+		return nil, errors.Errorf(errFmtNoRelease, name, namespace)
+	}
+	sort.SliceStable(l.Items, func(i, j int) bool {
+		vi, _ := strconv.Atoi(l.Items[i].GetLabels()[labelVersion])
+		vj, _ := strconv.Atoi(l.Items[j].GetLabels()[labelVersion])
+		return vi > vj
+	})
+	r, err := DecodeRelease(l.Items[0].Data[keyRelease])
+	if err != nil {
+		return nil, err
+	}
+	if r.Chart.Metadata.Name != chartNameCrossplane {
+		return nil, errors.Errorf(errFmtChartNotOSS, name, r.Chart.Metadata.Name)
+	}
+	return r, nil
+}
+
+// DecodeRelease decodes a release the way Helm encodes it in a Secret, i.e.
+// as base64 encoded, optionally gzipped, JSON.
+func DecodeRelease(data []byte) (*Release, error) {
+	b, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, errors.Wrap(err, errDecodeRelease)
+	}
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrap(err, errDecodeRelease)
+		}
+		if b, err = io.ReadAll(gz); err != nil {
+			return nil, errors.Wrap(err, errDecodeRelease)
+		}
+	}
+	r := &Release{}
+	return r, errors.Wrap(json.Unmarshal(b, r), errDecodeRelease)
+}
@@ -0,0 +1,340 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/controllers/pullsecrets"
+)
+
+// RuntimeContainerName is the name of the package runtime container that
+// Crossplane merges the container of a DeploymentRuntimeConfig into.
+const RuntimeContainerName = pullsecrets.RuntimeContainerName
+
+const (
+	errListControllerConfigs = "cannot list controller configs"
+	errListProviders         = "cannot list providers"
+	errFmtConvert            = "cannot convert controller config %s"
+	errFmtGetRuntimeConfig   = "cannot get deployment runtime config %s"
+	errFmtCreateRuntime      = "cannot create deployment runtime config %s"
+	errFmtRepointProvider    = "cannot repoint provider %s"
+)
+
+// ControllerConfigGroupVersionKind is the GroupVersionKind of the deprecated
+// Crossplane ControllerConfigs.
+var ControllerConfigGroupVersionKind = schema.GroupVersionKind{Group: pkgv1.Group, Version: "v1alpha1", Kind: "ControllerConfig"} //nolint:gochecknoglobals // We treat this as a constant.
+
+// A ProviderRepoint changes the Provider from a ControllerConfig to the
+// DeploymentRuntimeConfig it was converted to.
+type ProviderRepoint struct {
+	Name             string `json:"name"`
+	ControllerConfig string `json:"controllerConfig"`
+	// RuntimeConfig is the DeploymentRuntimeConfig the Provider referenced,
+	// if any. It is replaced.
+	RuntimeConfig string `json:"runtimeConfig,omitempty"`
+}
+
+// A RuntimeConfigConflict is a ControllerConfig that cannot be converted
+// because a different DeploymentRuntimeConfig of the same name exists.
+type RuntimeConfigConflict struct {
+	Name string `json:"name"`
+	// Providers are the Providers that keep referencing the ControllerConfig.
+	Providers []string `json:"providers,omitempty"`
+}
+
+// A RuntimeConfigMigration converts ControllerConfigs to
+// DeploymentRuntimeConfigs of the same name and repoints the Providers that
+// reference them. The ControllerConfigs themselves are left alone.
+type RuntimeConfigMigration struct {
+	// Create are the DeploymentRuntimeConfigs to create.
+	Create []*unstructured.Unstructured `json:"create,omitempty"`
+	// Existing are the ControllerConfigs that already have an equivalent
+	// DeploymentRuntimeConfig.
+	Existing []string `json:"existing,omitempty"`
+	// Repoint are the Providers to repoint.
+	Repoint []ProviderRepoint `json:"repoint,omitempty"`
+	// Conflicts are the ControllerConfigs that cannot be converted.
+	Conflicts []RuntimeConfigConflict `json:"conflicts,omitempty"`
+}
+
+// Empty returns true if there is nothing to migrate.
+func (m *RuntimeConfigMigration) Empty() bool {
+	return len(m.Create) == 0 && len(m.Repoint) == 0
+}
+
+// Diff returns the changes of the migration as a human readable diff.
+func (m *RuntimeConfigMigration) Diff() string {
+	b := &strings.Builder{}
+	for _, rc := range m.Create {
+		fmt.Fprintf(b, "+ DeploymentRuntimeConfig %s\n", rc.GetName())
+		y, _ := yaml.Marshal(rc.Object)
+		for _, l := range strings.Split(strings.TrimSuffix(string(y), "\n"), "\n") {
+			fmt.Fprintf(b, "+   %s\n", l)
+		}
+	}
+	for _, p := range m.Repoint {
+		if p.RuntimeConfig != "" && p.RuntimeConfig != pullsecrets.DefaultRuntimeConfig && p.RuntimeConfig != p.ControllerConfig {
+			fmt.Fprintf(b, "! Provider %s: the settings of DeploymentRuntimeConfig %s are not carried over to %s\n", p.Name, p.RuntimeConfig, p.ControllerConfig)
+		}
+		fmt.Fprintf(b, "~ Provider %s\n", p.Name)
+		fmt.Fprintf(b, "-   controllerConfigRef: {name: %s}\n", p.ControllerConfig)
+		if p.RuntimeConfig != "" {
+			fmt.Fprintf(b, "-   runtimeConfigRef: {name: %s}\n", p.RuntimeConfig)
+		}
+		fmt.Fprintf(b, "+   runtimeConfigRef: {name: %s}\n", p.ControllerConfig)
+	}
+	for _, c := range m.Conflicts {
+		fmt.Fprintf(b, "! ControllerConfig %s: a different DeploymentRuntimeConfig %s exists, not repointing %s\n", c.Name, c.Name, strings.Join(c.Providers, ", "))
+	}
+	return b.String()
+}
+
+// PlanRuntimeConfigs returns the migration of the ControllerConfigs in the
+// cluster. Nothing is migrated if ControllerConfigs are not supported. The
+// converted DeploymentRuntimeConfigs are based on the default one, which
+// Providers without a ControllerConfig use, so that its settings, e.g. the
+// pull Secrets, are kept.
+func PlanRuntimeConfigs(ctx context.Context, c client.Client) (*RuntimeConfigMigration, error) {
+	m := &RuntimeConfigMigration{}
+	ccs := &unstructured.UnstructuredList{}
+	ccs.SetGroupVersionKind(ControllerConfigGroupVersionKind.GroupVersion().WithKind(ControllerConfigGroupVersionKind.Kind + "List"))
+	err := c.List(ctx, ccs)
+	if kmeta.IsNoMatchError(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errListControllerConfigs)
+	}
+	pl := &pkgv1.ProviderList{}
+	if err := c.List(ctx, pl); err != nil {
+		return nil, errors.Wrap(err, errListProviders)
+	}
+	def := &unstructured.Unstructured{}
+	def.SetGroupVersionKind(pullsecrets.RuntimeConfigGroupVersionKind)
+	err = c.Get(ctx, types.NamespacedName{Name: pullsecrets.DefaultRuntimeConfig}, def)
+	if client.IgnoreNotFound(err) != nil {
+		return nil, errors.Wrapf(err, errFmtGetRuntimeConfig, pullsecrets.DefaultRuntimeConfig)
+	}
+	base, _, _ := unstructured.NestedMap(def.Object, "spec")
+
+	for i := range ccs.Items {
+		cc := &ccs.Items[i]
+		desired, err := ConvertControllerConfig(cc)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtConvert, cc.GetName())
+		}
+		if cc.GetName() != pullsecrets.DefaultRuntimeConfig {
+			desired.Object["spec"] = mergeSpec(base, desired.Object["spec"].(map[string]any))
+		}
+		exists, differs, err := runtimeConfigExists(ctx, c, desired)
+		if err != nil {
+			return nil, err
+		}
+		var providers []ProviderRepoint
+		for _, p := range pl.Items {
+			ref := p.Spec.ControllerConfigReference
+			if ref == nil || ref.Name != cc.GetName() {
+				continue
+			}
+			r := ProviderRepoint{Name: p.GetName(), ControllerConfig: cc.GetName()}
+			if p.Spec.RuntimeConfigReference != nil {
+				r.RuntimeConfig = p.Spec.RuntimeConfigReference.Name
+			}
+			providers = append(providers, r)
+		}
+
+		switch {
+		case !exists:
+			m.Create = append(m.Create, desired)
+		case differs:
+			rc := RuntimeConfigConflict{Name: cc.GetName()}
+			for _, p := range providers {
+				rc.Providers = append(rc.Providers, p.Name)
+			}
+			m.Conflicts = append(m.Conflicts, rc)
+			continue
+		default:
+			m.Existing = append(m.Existing, cc.GetName())
+		}
+		m.Repoint = append(m.Repoint, providers...)
+	}
+	return m, nil
+}
+
+// runtimeConfigExists returns whether the given DeploymentRuntimeConfig
+// exists, and if so whether the existing one differs from it.
+func runtimeConfigExists(ctx context.Context, c client.Client, desired *unstructured.Unstructured) (exists, differs bool, err error) {
+	cur := &unstructured.Unstructured{}
+	cur.SetGroupVersionKind(pullsecrets.RuntimeConfigGroupVersionKind)
+	err = c.Get(ctx, types.NamespacedName{Name: desired.GetName()}, cur)
+	if kerrors.IsNotFound(err) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, errors.Wrapf(err, errFmtGetRuntimeConfig, desired.GetName())
+	}
+	return true, !reflect.DeepEqual(cur.Object["spec"], desired.Object["spec"]), nil
+}
+
+// ApplyRuntimeConfigs creates the DeploymentRuntimeConfigs of the given
+// migration and then repoints the Providers to them.
+func ApplyRuntimeConfigs(ctx context.Context, c client.Client, m *RuntimeConfigMigration) error {
+	for _, rc := range m.Create {
+		if err := c.Create(ctx, rc.DeepCopy()); err != nil && !kerrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, errFmtCreateRuntime, rc.GetName())
+		}
+	}
+	for _, r := range m.Repoint {
+		patch, err := json.Marshal(map[string]any{"spec": map[string]any{
+			"controllerConfigRef": nil,
+			"runtimeConfigRef":    map[string]any{"name": r.ControllerConfig},
+		}})
+		if err != nil {
+			return errors.Wrap(err, errMarshalPatch)
+		}
+		p := &pkgv1.Provider{}
+		p.SetName(r.Name)
+		if err := c.Patch(ctx, p, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return errors.Wrapf(err, errFmtRepointProvider, r.Name)
+		}
+	}
+	return nil
+}
+
+// ConvertControllerConfig returns the DeploymentRuntimeConfig that is
+// equivalent to the given ControllerConfig. The pod settings of the
+// ControllerConfig go to the pod template of the deployment, its container
+// settings to the package runtime container and its service account name to
+// the service account template.
+func ConvertControllerConfig(cc *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	spec, _, err := unstructured.NestedMap(cc.Object, "spec")
+	if err != nil {
+		return nil, err
+	}
+
+	pod := map[string]any{}
+	for src, dst := range map[string]string{
+		"affinity":           "affinity",
+		"imagePullSecrets":   "imagePullSecrets",
+		"nodeName":           "nodeName",
+		"nodeSelector":       "nodeSelector",
+		"podSecurityContext": "securityContext",
+		"priorityClassName":  "priorityClassName",
+		"runtimeClassName":   "runtimeClassName",
+		"tolerations":        "tolerations",
+		"volumes":            "volumes",
+	} {
+		if v, ok := spec[src]; ok {
+			pod[dst] = v
+		}
+	}
+	// The containers of the pod template are required, even if the
+	// ControllerConfig has no container settings.
+	container := map[string]any{"name": RuntimeContainerName}
+	for _, f := range []string{"args", "env", "envFrom", "image", "imagePullPolicy", "ports", "resources", "securityContext", "volumeMounts"} {
+		if v, ok := spec[f]; ok {
+			container[f] = v
+		}
+	}
+	pod["containers"] = []any{container}
+
+	template := map[string]any{"spec": pod}
+	if md, ok := spec["metadata"]; ok {
+		template["metadata"] = md
+	}
+	// The selector of the deployment template is required.
+	deployment := map[string]any{"selector": map[string]any{}, "template": template}
+	if v, ok := spec["replicas"]; ok {
+		deployment["replicas"] = v
+	}
+
+	rc := &unstructured.Unstructured{Object: map[string]any{}}
+	rc.SetGroupVersionKind(pullsecrets.RuntimeConfigGroupVersionKind)
+	rc.SetName(cc.GetName())
+	rcSpec := map[string]any{"deploymentTemplate": map[string]any{"spec": deployment}}
+	if sa, ok := spec["serviceAccountName"]; ok {
+		rcSpec["serviceAccountTemplate"] = map[string]any{"metadata": map[string]any{"name": sa}}
+	}
+	rc.Object["spec"] = rcSpec
+	return rc, nil
+}
+
+// mergeSpec returns the given base spec with the fields of the given spec
+// merged into it. Objects are merged recursively, and containers and pull
+// Secrets by name. Any other field of spec replaces the one of base.
+func mergeSpec(base, spec map[string]any) map[string]any {
+	out := runtime.DeepCopyJSON(base)
+	if out == nil {
+		out = map[string]any{}
+	}
+	for k, v := range spec {
+		switch cur := out[k].(type) {
+		case map[string]any:
+			if m, ok := v.(map[string]any); ok {
+				out[k] = mergeSpec(cur, m)
+				continue
+			}
+		case []any:
+			if l, ok := v.([]any); ok && (k == "containers" || k == "imagePullSecrets") {
+				out[k] = mergeByName(cur, l)
+				continue
+			}
+		}
+		out[k] = runtime.DeepCopyJSONValue(v)
+	}
+	return out
+}
+
+// mergeByName merges the given lists of named objects. Objects of the same
+// name are merged, and the others are appended.
+func mergeByName(base, items []any) []any {
+	out := make([]any, len(base))
+	for i := range base {
+		out[i] = runtime.DeepCopyJSONValue(base[i])
+	}
+	for _, it := range items {
+		m, _ := it.(map[string]any)
+		merged := false
+		for i := range out {
+			cur, _ := out[i].(map[string]any)
+			if cur != nil && m != nil && cur["name"] == m["name"] {
+				out[i] = mergeSpec(cur, m)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			out = append(out, runtime.DeepCopyJSONValue(it))
+		}
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A ValueDiffType is the way a value differs between the open source and
+// Universal Crossplane charts.
+type ValueDiffType string
+
+// Value diff types.
+const (
+	// ValueCarried values were set by the user and are carried over to the
+	// Universal Crossplane release.
+	ValueCarried ValueDiffType = "Carried"
+	// ValueUnsupported values were set by the user but are not values of
+	// the Universal Crossplane chart, so they are dropped.
+	ValueUnsupported ValueDiffType = "Unsupported"
+	// ValueDefaultChanged values were not set by the user but their default
+	// differs between the charts.
+	ValueDefaultChanged ValueDiffType = "DefaultChanged"
+	// ValueReplaced values were set by the user but are not carried over,
+	// since Universal Crossplane must replace them, e.g. the Crossplane
+	// image.
+	ValueReplaced ValueDiffType = "Replaced"
+)
+
+// replaced are the values that are never carried over.
+var replaced = map[string]bool{"image.repository": true, "image.tag": true} //nolint:gochecknoglobals // We treat this as a constant.
+
+// A ValueDiff is a difference between the values of the open source
+// Crossplane release and the Universal Crossplane chart.
+type ValueDiff struct {
+	Type ValueDiffType `json:"type"`
+	// Key is the dotted path of the value.
+	Key string `json:"key"`
+	// OSS is the value of the open source release, i.e. the value the user
+	// set or the default of the open source chart.
+	OSS any `json:"oss,omitempty"`
+	// UXP is the value of the Universal Crossplane release, i.e. the carried
+	// value or the default of the Universal Crossplane chart.
+	UXP any `json:"uxp,omitempty"`
+}
+
+// DiffValues returns how the values of an open source Crossplane release
+// with the given chart defaults and user supplied config differ from the
+// given Universal Crossplane chart defaults, sorted by key, and the values
+// to carry over to the Universal Crossplane release.
+func DiffValues(ossDefaults, config, uxpDefaults map[string]any) ([]ValueDiff, map[string]any) {
+	var diffs []ValueDiff
+	carried := map[string]any{}
+
+	set, uxp := leaves(config, nil), leaves(uxpDefaults, nil)
+	for k, l := range set {
+		if replaced[k] {
+			diffs = append(diffs, ValueDiff{Type: ValueReplaced, Key: k, OSS: l.value, UXP: uxp[k].value})
+			continue
+		}
+		if !defines(uxpDefaults, l.path) {
+			diffs = append(diffs, ValueDiff{Type: ValueUnsupported, Key: k, OSS: l.value})
+			continue
+		}
+		diffs = append(diffs, ValueDiff{Type: ValueCarried, Key: k, OSS: l.value, UXP: l.value})
+		setPath(carried, l.path, l.value)
+	}
+
+	oss := leaves(ossDefaults, nil)
+	for k, l := range oss {
+		if _, ok := set[k]; ok {
+			continue
+		}
+		if u, ok := uxp[k]; ok && !reflect.DeepEqual(u.value, l.value) {
+			diffs = append(diffs, ValueDiff{Type: ValueDefaultChanged, Key: k, OSS: l.value, UXP: u.value})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs, carried
+}
+
+type leaf struct {
+	path  []string
+	value any
+}
+
+// leaves returns the leaf values of the given nested map keyed by their
+// dotted paths. Lists and empty maps are leaves. The paths are kept since
+// keys like annotations may contain dots.
+func leaves(m map[string]any, prefix []string) map[string]leaf {
+	out := map[string]leaf{}
+	for k, v := range m {
+		path := append(append([]string{}, prefix...), k)
+		if sub, ok := v.(map[string]any); ok && len(sub) > 0 {
+			for sk, sl := range leaves(sub, path) {
+				out[sk] = sl
+			}
+			continue
+		}
+		out[strings.Join(path, ".")] = leaf{path: path, value: v}
+	}
+	return out
+}
+
+// defines returns true if the given values define the given path. Values
+// under a map that is empty by default, like resources or annotations, are
+// defined.
+func defines(values map[string]any, path []string) bool {
+	v, ok := values[path[0]]
+	if !ok {
+		return false
+	}
+	if len(path) == 1 {
+		return true
+	}
+	sub, ok := v.(map[string]any)
+	if !ok {
+		// A value that is null by default may be set to a map.
+		return v == nil
+	}
+	if len(sub) == 0 {
+		return true
+	}
+	return defines(sub, path[1:])
+}
+
+func setPath(m map[string]any, path []string, v any) {
+	for _, p := range path[:len(path)-1] {
+		sub, ok := m[p].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			m[p] = sub
+		}
+		m = sub
+	}
+	m[path[len(path)-1]] = v
+}
@@ -0,0 +1,269 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package preflight contains the checks that are run before Universal
+// Crossplane is installed or upgraded.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/version"
+	"github.com/upbound/universal-crossplane/internal/xpkg"
+)
+
+// Names of the checks.
+const (
+	CheckInstalledVersion = "InstalledVersion"
+	CheckUpgradePath      = "UpgradePath"
+	CheckCRDStorage       = "CRDStorageVersions"
+	CheckPackages         = "PackageCompatibility"
+)
+
+const (
+	errGetDeployment = "cannot get Crossplane deployment"
+	errGetCompat     = "cannot get compat configmap"
+	errFmtGetCRD     = "cannot get CustomResourceDefinition %s"
+)
+
+// A Result of a single check.
+type Result struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// A Report of all checks.
+type Report struct {
+	Installed string   `json:"installed,omitempty"`
+	Target    string   `json:"target"`
+	Results   []Result `json:"results"`
+}
+
+// Passed returns true if all checks passed.
+func (r Report) Passed() bool {
+	for _, c := range r.Results {
+		if !c.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a human readable report.
+func (r Report) String() string {
+	b := &strings.Builder{}
+	installed := r.Installed
+	if installed == "" {
+		installed = "none"
+	}
+	fmt.Fprintf(b, "Installed Crossplane: %s\nTarget Crossplane: %s\n\n", installed, r.Target)
+	for _, c := range r.Results {
+		status := "PASS"
+		if !c.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(b, "[%s] %s: %s\n", status, c.Name, c.Message)
+	}
+	return b.String()
+}
+
+// A CheckerOption configures a Checker.
+type CheckerOption func(*Checker)
+
+// WithAllowIncompatiblePackages specifies whether the packages that are not
+// compatible with the target version only produce a warning instead of
+// failing the checks.
+func WithAllowIncompatiblePackages(allow bool) CheckerOption {
+	return func(c *Checker) {
+		c.allowIncompatible = allow
+	}
+}
+
+// A Checker runs the preflight checks.
+type Checker struct {
+	client    client.Reader
+	namespace string
+	target    version.Semver
+	crds      []*extv1.CustomResourceDefinition
+
+	allowIncompatible bool
+}
+
+// NewChecker returns a Checker that checks whether the Crossplane installed
+// in the given namespace can be upgraded to the target version with the given
+// CRDs.
+func NewChecker(c client.Reader, namespace string, target version.Semver, crds []*extv1.CustomResourceDefinition, opts ...CheckerOption) *Checker {
+	ch := &Checker{client: c, namespace: namespace, target: target, crds: crds}
+	for _, f := range opts {
+		f(ch)
+	}
+	return ch
+}
+
+// Run runs all checks. An error is returned only if the checks cannot be run.
+func (c *Checker) Run(ctx context.Context) (Report, error) {
+	r := Report{Target: c.target.String()}
+
+	installed, res, err := c.installed(ctx)
+	if err != nil {
+		return r, err
+	}
+	r.Results = append(r.Results, res)
+	if installed != nil {
+		r.Installed = installed.String()
+		r.Results = append(r.Results, UpgradePath(*installed, c.target))
+	}
+
+	res, err = c.storageVersions(ctx)
+	if err != nil {
+		return r, err
+	}
+	r.Results = append(r.Results, res)
+
+	res, err = c.packages(ctx)
+	if err != nil {
+		return r, err
+	}
+	r.Results = append(r.Results, res)
+	return r, nil
+}
+
+func (c *Checker) installed(ctx context.Context) (*version.Semver, Result, error) {
+	res := Result{Name: CheckInstalledVersion}
+	d := &appsv1.Deployment{}
+	err := c.client.Get(ctx, types.NamespacedName{Name: meta.DeploymentNameCrossplane, Namespace: c.namespace}, d)
+	if kerrors.IsNotFound(err) {
+		res.Passed, res.Message = true, fmt.Sprintf("Crossplane is not installed in namespace %s", c.namespace)
+		return nil, res, nil
+	}
+	if err != nil {
+		return nil, res, errors.Wrap(err, errGetDeployment)
+	}
+	if len(d.Spec.Template.Spec.Containers) == 0 {
+		res.Message = "Crossplane deployment has no containers"
+		return nil, res, nil
+	}
+	tag := xpkg.ImageTag(d.Spec.Template.Spec.Containers[0].Image)
+	v, err := version.Parse(tag)
+	if err != nil {
+		// Images referenced by digest only do not tell the version. Failing
+		// would block every upgrade of them, so the upgrade path is not
+		// checked instead.
+		res.Passed, res.Message = true, fmt.Sprintf("Cannot determine the installed Crossplane version from image tag %q, the upgrade path is not checked", tag)
+		return nil, res, nil
+	}
+	res.Passed, res.Message = true, fmt.Sprintf("Found Crossplane %s", v)
+	return &v, res, nil
+}
+
+// UpgradePath checks whether Crossplane can be upgraded from the installed
+// version to the target version.
+func UpgradePath(installed, target version.Semver) Result {
+	res := Result{Name: CheckUpgradePath}
+	if err := version.CheckUpgrade(installed, target); err != nil {
+		res.Message = err.Error()
+		return res
+	}
+	res.Passed, res.Message = true, fmt.Sprintf("Upgrading Crossplane from %s to %s is supported", installed, target)
+	return res
+}
+
+// storageVersions checks that every version that is stored for a CRD in the
+// cluster is still defined by the bundled CRD, otherwise the objects stored in
+// that version could not be read anymore.
+func (c *Checker) storageVersions(ctx context.Context) (Result, error) {
+	res := Result{Name: CheckCRDStorage}
+	var problems []string
+	for _, want := range c.crds {
+		got := &extv1.CustomResourceDefinition{}
+		if err := c.client.Get(ctx, types.NamespacedName{Name: want.GetName()}, got); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return res, errors.Wrapf(err, errFmtGetCRD, want.GetName())
+		}
+		defined := map[string]bool{}
+		for _, v := range want.Spec.Versions {
+			defined[v.Name] = true
+		}
+		for _, v := range got.Status.StoredVersions {
+			if !defined[v] {
+				problems = append(problems, fmt.Sprintf("%s has objects stored in %s which is not defined anymore", want.GetName(), v))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		res.Message = strings.Join(problems, "; ")
+		return res, nil
+	}
+	res.Passed, res.Message = true, fmt.Sprintf("Stored versions of all %d bundled CRDs are defined", len(c.crds))
+	return res, nil
+}
+
+// packages checks that the Crossplane version constraints of the installed
+// packages, as reported by the compat controller, allow the target version.
+func (c *Checker) packages(ctx context.Context) (Result, error) {
+	res := Result{Name: CheckPackages}
+	cm := &corev1.ConfigMap{}
+	err := c.client.Get(ctx, types.NamespacedName{Name: meta.ConfigMapNameCompat, Namespace: c.namespace}, cm)
+	if kerrors.IsNotFound(err) {
+		res.Passed, res.Message = true, "Package compatibility is not known, the compat controller is not running"
+		return res, nil
+	}
+	if err != nil {
+		return res, errors.Wrap(err, errGetCompat)
+	}
+	statuses, err := xpkg.ParseCompatStatus(cm.Data[xpkg.KeyCompatStatus])
+	if err != nil {
+		res.Message = fmt.Sprintf("Cannot read package compatibility: %s", err)
+		return res, nil
+	}
+
+	unknown := 0
+	for _, s := range statuses {
+		if s.State == xpkg.CompatStateUnknown && s.Constraint == "" {
+			unknown++
+		}
+	}
+	var problems []string
+	for _, s := range xpkg.Incompatible(statuses, c.target) {
+		problems = append(problems, fmt.Sprintf("%s %s requires Crossplane %s", s.Kind, s.Name, s.Constraint))
+	}
+	switch {
+	case len(problems) > 0 && c.allowIncompatible:
+		res.Passed, res.Message = true, fmt.Sprintf("Ignoring packages that are not compatible with Crossplane %s: %s", c.target.Upstream(), strings.Join(problems, "; "))
+	case len(problems) > 0:
+		res.Message = fmt.Sprintf("Packages are not compatible with Crossplane %s: %s", c.target.Upstream(), strings.Join(problems, "; "))
+	case unknown > 0:
+		res.Passed, res.Message = true, fmt.Sprintf("%d of %d packages are compatible with Crossplane %s, the constraints of %d are not known", len(statuses)-unknown, len(statuses), c.target.Upstream(), unknown)
+	default:
+		res.Passed, res.Message = true, fmt.Sprintf("All %d packages are compatible with Crossplane %s", len(statuses), c.target.Upstream())
+	}
+	return res, nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preflight
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+	"github.com/upbound/universal-crossplane/internal/xpkg"
+)
+
+var errBoom = errors.New("boom")
+
+func mustParse(t *testing.T, s string) version.Semver {
+	t.Helper()
+	v, err := version.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestUpgradePath(t *testing.T) {
+	cases := map[string]struct {
+		installed string
+		target    string
+		want      bool
+	}{
+		"SameVersion":          {installed: "v1.20.4-up.1", target: "v1.20.4-up.1", want: true},
+		"UpboundPatch":         {installed: "v1.20.4-up.1", target: "v1.20.4-up.2", want: true},
+		"UpstreamPatch":        {installed: "v1.20.0-up.3", target: "v1.20.1-up.1", want: true},
+		"NextMinor":            {installed: "v1.19.2-up.1", target: "v1.20.4-up.1", want: true},
+		"SkipMinor":            {installed: "v1.18.2-up.1", target: "v1.20.4-up.1", want: false},
+		"Downgrade":            {installed: "v1.20.1-up.1", target: "v1.20.0-up.3", want: false},
+		"DowngradeUpbound":     {installed: "v1.20.4-up.2", target: "v1.20.4-up.1", want: false},
+		"OSSSameVersion":       {installed: "v1.20.4", target: "v1.20.4-up.1", want: true},
+		"OSSDifferentVersion":  {installed: "v1.20.3", target: "v1.20.4-up.1", want: false},
+		"OSSNewerThanUpstream": {installed: "v1.20.5", target: "v1.20.4-up.1", want: false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := UpgradePath(mustParse(t, tc.installed), mustParse(t, tc.target))
+			if got.Passed != tc.want {
+				t.Errorf("UpgradePath(%s, %s): want passed %t, got %t: %s", tc.installed, tc.target, tc.want, got.Passed, got.Message)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	bundled := []*extv1.CustomResourceDefinition{{
+		ObjectMeta: metav1.ObjectMeta{Name: "providers.pkg.crossplane.io"},
+		Spec:       extv1.CustomResourceDefinitionSpec{Versions: []extv1.CustomResourceDefinitionVersion{{Name: "v1", Served: true, Storage: true}}},
+	}}
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "")
+
+	withCluster := func(image, compatStatus string, stored ...string) test.MockGetFn {
+		return func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			switch o := obj.(type) {
+			case *appsv1.Deployment:
+				if image == "" {
+					return notFound
+				}
+				o.Spec.Template.Spec.Containers = []corev1.Container{{Image: image}}
+			case *corev1.ConfigMap:
+				if compatStatus == "" {
+					return notFound
+				}
+				o.Data = map[string]string{xpkg.KeyCompatStatus: compatStatus}
+			case *extv1.CustomResourceDefinition:
+				o.Status.StoredVersions = stored
+			}
+			return nil
+		}
+	}
+
+	compatible := `
+- {kind: Provider, name: provider-aws-s3, package: xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0, constraint: ">=v1.14.0-0", state: Compatible}
+- {kind: Function, name: function-patch-and-transform, package: xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.2.1, state: Compatible}
+`
+	incompatible := compatible + `- {kind: Provider, name: provider-old, package: xpkg.upbound.io/upbound/provider-old:v0.1.0, constraint: "<v1.20.0", state: Compatible}
+`
+
+	type want struct {
+		err     error
+		passed  bool
+		results []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		get    test.MockGetFn
+		opts   []CheckerOption
+		want   want
+	}{
+		"GetDeploymentError": {
+			reason: "We should return an error if the Crossplane deployment cannot be fetched.",
+			get:    test.NewMockGetFn(errBoom),
+			want:   want{err: errors.Wrap(errBoom, errGetDeployment)},
+		},
+		"FreshInstall": {
+			reason: "Installing when Crossplane is not installed should pass.",
+			get:    withCluster("", "", "v1"),
+			want:   want{passed: true, results: []string{CheckInstalledVersion, CheckCRDStorage, CheckPackages}},
+		},
+		"Downgrade": {
+			reason: "Downgrading Crossplane should fail.",
+			get:    withCluster("upbound/crossplane:v1.20.5-up.1", "", "v1"),
+			want:   want{passed: false, results: []string{CheckInstalledVersion, CheckUpgradePath, CheckCRDStorage, CheckPackages}},
+		},
+		"ReleaseCandidate": {
+			reason: "Upgrading from a release candidate should pass.",
+			get:    withCluster("upbound/crossplane:v1.20.4-up.2.rc.0", compatible, "v1"),
+			want:   want{passed: true, results: []string{CheckInstalledVersion, CheckUpgradePath, CheckCRDStorage, CheckPackages}},
+		},
+		"UnknownVersion": {
+			reason: "Upgrading an image referenced by digest should pass without checking the upgrade path.",
+			get:    withCluster("upbound/crossplane@sha256:0123", compatible, "v1"),
+			want:   want{passed: true, results: []string{CheckInstalledVersion, CheckCRDStorage, CheckPackages}},
+		},
+		"StoredVersionRemoved": {
+			reason: "Upgrading should fail if a stored version is not defined by the bundled CRDs.",
+			get:    withCluster("upbound/crossplane:v1.20.4-up.1", "", "v1beta1", "v1"),
+			want:   want{passed: false, results: []string{CheckInstalledVersion, CheckUpgradePath, CheckCRDStorage, CheckPackages}},
+		},
+		"Upgrade": {
+			reason: "Supported upgrades should pass.",
+			get:    withCluster("upbound/crossplane:v1.20.4-up.1", compatible, "v1"),
+			want:   want{passed: true, results: []string{CheckInstalledVersion, CheckUpgradePath, CheckCRDStorage, CheckPackages}},
+		},
+		"IncompatiblePackage": {
+			reason: "Upgrading should fail if the constraint of an installed package does not allow the target version.",
+			get:    withCluster("upbound/crossplane:v1.20.4-up.1", incompatible, "v1"),
+			want:   want{passed: false, results: []string{CheckInstalledVersion, CheckUpgradePath, CheckCRDStorage, CheckPackages}},
+		},
+		"AllowIncompatiblePackage": {
+			reason: "Upgrading should pass despite incompatible packages if they are allowed.",
+			get:    withCluster("upbound/crossplane:v1.20.4-up.1", incompatible, "v1"),
+			opts:   []CheckerOption{WithAllowIncompatiblePackages(true)},
+			want:   want{passed: true, results: []string{CheckInstalledVersion, CheckUpgradePath, CheckCRDStorage, CheckPackages}},
+		},
+		"GetCompatError": {
+			reason: "We should return an error if the compat ConfigMap cannot be fetched.",
+			get: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				if _, ok := obj.(*corev1.ConfigMap); ok {
+					return errBoom
+				}
+				return withCluster("upbound/crossplane:v1.20.4-up.1", "", "v1")(ctx, key, obj)
+			},
+			want: want{err: errors.Wrap(errBoom, errGetCompat)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := NewChecker(&test.MockClient{MockGet: tc.get}, "upbound-system", mustParse(t, "v1.20.4-up.2"), bundled, tc.opts...)
+			r, err := c.Run(context.Background())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nc.Run(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if r.Passed() != tc.want.passed {
+				t.Errorf("\nReason: %s\nc.Run(...): want passed %t, got report:\n%s", tc.reason, tc.want.passed, r)
+			}
+			got := make([]string, len(r.Results))
+			for i, res := range r.Results {
+				got[i] = res.Name
+			}
+			if diff := cmp.Diff(tc.want.results, got); diff != "" {
+				t.Errorf("\nReason: %s\nc.Run(...): -want checks, +got checks:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package support collects diagnostics of a Universal Crossplane
+// installation into a support bundle.
+package support
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+const (
+	// FileManifest is the path of the manifest in a bundle.
+	FileManifest = "manifest.json"
+
+	errFmtMarshal = "cannot marshal %s"
+	errFmtWrite   = "cannot write %s to bundle"
+	errCloseTar   = "cannot close bundle archive"
+	errCloseGzip  = "cannot close bundle compression"
+)
+
+// A Manifest describes the contents of a bundle.
+type Manifest struct {
+	// CreatedAt is when the bundle was collected.
+	CreatedAt time.Time `json:"createdAt"`
+	// Namespace Universal Crossplane is installed in.
+	Namespace string `json:"namespace"`
+	// Bootstrapper is the build information of the bootstrapper that
+	// collected the bundle.
+	Bootstrapper version.Info `json:"bootstrapper"`
+	// Files in the bundle, other than the manifest.
+	Files []File `json:"files"`
+	// Errors are the diagnostics that could not be collected.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// A File in a bundle.
+type File struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	Size        int    `json:"size"`
+}
+
+// A Bundle of diagnostics.
+type Bundle struct {
+	Manifest Manifest
+	contents map[string][]byte
+}
+
+// Name returns the timestamped name of the bundle archive.
+func (b *Bundle) Name() string {
+	return "uxp-support-bundle-" + b.Manifest.CreatedAt.UTC().Format("20060102T150405Z")
+}
+
+// Contents returns the contents of the file at the given path, or false if
+// there is no such file.
+func (b *Bundle) Contents(path string) ([]byte, bool) {
+	c, ok := b.contents[path]
+	return c, ok
+}
+
+// add adds a file with the given contents to the bundle.
+func (b *Bundle) add(path, description string, contents []byte) {
+	if b.contents == nil {
+		b.contents = map[string][]byte{}
+	}
+	b.contents[path] = contents
+	b.Manifest.Files = append(b.Manifest.Files, File{Path: path, Description: description, Size: len(contents)})
+}
+
+// addYAML adds a file with the given object serialized as YAML to the
+// bundle.
+func (b *Bundle) addYAML(path, description string, obj any) error {
+	y, err := yaml.Marshal(obj)
+	if err != nil {
+		return errors.Wrapf(err, errFmtMarshal, path)
+	}
+	b.add(path, description, y)
+	return nil
+}
+
+// addError records a diagnostic that could not be collected.
+func (b *Bundle) addError(err error) {
+	b.Manifest.Errors = append(b.Manifest.Errors, err.Error())
+}
+
+// Write writes the bundle as a gzipped tarball whose files are in a
+// directory named after the bundle.
+func (b *Bundle) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	m, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, errFmtMarshal, FileManifest)
+	}
+	if err := writeFile(tw, b.Name()+"/"+FileManifest, m, b.Manifest.CreatedAt); err != nil {
+		return err
+	}
+	for _, f := range b.Manifest.Files {
+		if err := writeFile(tw, b.Name()+"/"+f.Path, b.contents[f.Path], b.Manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, errCloseTar)
+	}
+	return errors.Wrap(gz.Close(), errCloseGzip)
+}
+
+func writeFile(tw *tar.Writer, path string, contents []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: path, Mode: 0o600, Size: int64(len(contents)), ModTime: modTime}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return errors.Wrapf(err, errFmtWrite, path)
+	}
+	_, err := tw.Write(contents)
+	return errors.Wrapf(err, errFmtWrite, path)
+}
@@ -0,0 +1,326 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package support
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+const (
+	// Redacted replaces the values of Secrets in a bundle.
+	Redacted = "REDACTED"
+
+	errFmtGetDeployment = "cannot get deployment %s"
+	errFmtListPods      = "cannot list pods of deployment %s"
+	errFmtLogs          = "cannot get logs of container %s of pod %s"
+	errFmtList          = "cannot list %s"
+	errListEvents       = "cannot list events"
+	errGetVersions      = "cannot get versions configmap"
+	errGetEntitlement   = "cannot get entitlement secret"
+)
+
+// Resources that are collected, keyed by the path of their file in a bundle.
+var Resources = map[string]schema.GroupVersionKind{ //nolint:gochecknoglobals // We treat this as a constant.
+	"resources/pkg.crossplane.io/providers.yaml":                              {Group: "pkg.crossplane.io", Version: "v1", Kind: "Provider"},
+	"resources/pkg.crossplane.io/providerrevisions.yaml":                      {Group: "pkg.crossplane.io", Version: "v1", Kind: "ProviderRevision"},
+	"resources/pkg.crossplane.io/configurations.yaml":                         {Group: "pkg.crossplane.io", Version: "v1", Kind: "Configuration"},
+	"resources/pkg.crossplane.io/configurationrevisions.yaml":                 {Group: "pkg.crossplane.io", Version: "v1", Kind: "ConfigurationRevision"},
+	"resources/pkg.crossplane.io/functions.yaml":                              {Group: "pkg.crossplane.io", Version: "v1", Kind: "Function"},
+	"resources/pkg.crossplane.io/functionrevisions.yaml":                      {Group: "pkg.crossplane.io", Version: "v1", Kind: "FunctionRevision"},
+	"resources/pkg.crossplane.io/locks.yaml":                                  {Group: "pkg.crossplane.io", Version: "v1beta1", Kind: "Lock"},
+	"resources/pkg.crossplane.io/deploymentruntimeconfigs.yaml":               {Group: "pkg.crossplane.io", Version: "v1beta1", Kind: "DeploymentRuntimeConfig"},
+	"resources/pkg.crossplane.io/imageconfigs.yaml":                           {Group: "pkg.crossplane.io", Version: "v1beta1", Kind: "ImageConfig"},
+	"resources/pkg.crossplane.io/controllerconfigs.yaml":                      {Group: "pkg.crossplane.io", Version: "v1alpha1", Kind: "ControllerConfig"},
+	"resources/apiextensions.crossplane.io/compositeresourcedefinitions.yaml": {Group: "apiextensions.crossplane.io", Version: "v1", Kind: "CompositeResourceDefinition"},
+	"resources/apiextensions.crossplane.io/compositions.yaml":                 {Group: "apiextensions.crossplane.io", Version: "v1", Kind: "Composition"},
+}
+
+// Components whose logs are collected, by the name of their Deployment.
+var Components = []string{meta.DeploymentNameCrossplane, meta.DeploymentNameRBACManager, meta.DeploymentNameBootstrapper} //nolint:gochecknoglobals // We treat this as a constant.
+
+// A LogSource returns the logs of containers.
+type LogSource interface {
+	Logs(ctx context.Context, namespace, pod, container string) ([]byte, error)
+}
+
+// A LogSourceFn is a function that satisfies LogSource.
+type LogSourceFn func(ctx context.Context, namespace, pod, container string) ([]byte, error)
+
+// Logs returns the logs of the given container.
+func (fn LogSourceFn) Logs(ctx context.Context, namespace, pod, container string) ([]byte, error) {
+	return fn(ctx, namespace, pod, container)
+}
+
+// NewClientsetLogSource returns a LogSource that reads at most the given
+// number of lines of logs with the given clientset.
+func NewClientsetLogSource(cs kubernetes.Interface, tailLines int64) LogSource {
+	return LogSourceFn(func(ctx context.Context, namespace, pod, container string) ([]byte, error) {
+		return cs.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{Container: container, TailLines: &tailLines}).DoRaw(ctx)
+	})
+}
+
+// EntitlementSummary describes the entitlement Secret without revealing its
+// values.
+type EntitlementSummary struct {
+	Exists            bool           `json:"exists"`
+	CreationTimestamp string         `json:"creationTimestamp,omitempty"`
+	Keys              map[string]int `json:"keys,omitempty"`
+}
+
+// CollectorOption is used to configure the Collector.
+type CollectorOption func(*Collector)
+
+// WithEventsSince specifies how old the events in a bundle can be.
+func WithEventsSince(d time.Duration) CollectorOption {
+	return func(c *Collector) {
+		c.eventsSince = d
+	}
+}
+
+// A Collector collects support bundles.
+type Collector struct {
+	client    client.Client
+	logs      LogSource
+	namespace string
+
+	eventsSince time.Duration
+	now         func() time.Time
+}
+
+// NewCollector returns a Collector that collects the diagnostics of the
+// Universal Crossplane installation in the given namespace.
+func NewCollector(c client.Client, logs LogSource, namespace string, opts ...CollectorOption) *Collector {
+	col := &Collector{
+		client:      c,
+		logs:        logs,
+		namespace:   namespace,
+		eventsSince: 1 * time.Hour,
+		now:         time.Now,
+	}
+	for _, f := range opts {
+		f(col)
+	}
+	return col
+}
+
+// Collect collects a bundle. Diagnostics that cannot be collected are
+// recorded in the manifest of the bundle rather than failing the collection.
+func (c *Collector) Collect(ctx context.Context) (*Bundle, error) {
+	b := &Bundle{Manifest: Manifest{
+		CreatedAt:    c.now().UTC().Truncate(time.Second),
+		Namespace:    c.namespace,
+		Bootstrapper: version.Get(),
+	}}
+
+	for _, name := range Components {
+		c.collectLogs(ctx, b, name)
+	}
+
+	paths := make([]string, 0, len(Resources))
+	for p := range Resources {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := c.collectResources(ctx, b, p, Resources[p]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.collectSecrets(ctx, b); err != nil {
+		return nil, err
+	}
+	if err := c.collectEvents(ctx, b); err != nil {
+		return nil, err
+	}
+	if err := c.collectVersions(ctx, b); err != nil {
+		return nil, err
+	}
+	if err := c.collectEntitlement(ctx, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// collectLogs adds the logs of all containers of the pods of the given
+// Deployment.
+func (c *Collector) collectLogs(ctx context.Context, b *Bundle, deployment string) {
+	d := &appsv1.Deployment{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: deployment, Namespace: c.namespace}, d); err != nil {
+		b.addError(errors.Wrapf(err, errFmtGetDeployment, deployment))
+		return
+	}
+	if d.Spec.Selector == nil {
+		return
+	}
+	pods := &corev1.PodList{}
+	if err := c.client.List(ctx, pods, client.InNamespace(c.namespace), client.MatchingLabels(d.Spec.Selector.MatchLabels)); err != nil {
+		b.addError(errors.Wrapf(err, errFmtListPods, deployment))
+		return
+	}
+	for _, p := range pods.Items {
+		for _, ct := range p.Spec.Containers {
+			l, err := c.logs.Logs(ctx, c.namespace, p.GetName(), ct.Name)
+			if err != nil {
+				b.addError(errors.Wrapf(err, errFmtLogs, ct.Name, p.GetName()))
+				continue
+			}
+			b.add(fmt.Sprintf("logs/%s/%s.log", p.GetName(), ct.Name), fmt.Sprintf("Logs of container %s of %s pod %s", ct.Name, deployment, p.GetName()), l)
+		}
+	}
+}
+
+// collectResources adds all resources of the given kind. Kinds that are not
+// served by the API server are recorded as errors.
+func (c *Collector) collectResources(ctx context.Context, b *Bundle, path string, gvk schema.GroupVersionKind) error {
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := c.client.List(ctx, l); err != nil {
+		if kmeta.IsNoMatchError(err) || kerrors.IsNotFound(err) || kerrors.IsForbidden(err) {
+			b.addError(errors.Wrapf(err, errFmtList, gvk.Kind))
+			return nil
+		}
+		return errors.Wrapf(err, errFmtList, gvk.Kind)
+	}
+	for i := range l.Items {
+		Redact(&l.Items[i])
+	}
+	return b.addYAML(path, fmt.Sprintf("%s objects", gvk.Kind), l.UnstructuredContent())
+}
+
+// collectSecrets adds the Secrets in the namespace with their values
+// redacted.
+func (c *Collector) collectSecrets(ctx context.Context, b *Bundle) error {
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("SecretList"))
+	if err := c.client.List(ctx, l, client.InNamespace(c.namespace)); err != nil {
+		return errors.Wrapf(err, errFmtList, "Secret")
+	}
+	for i := range l.Items {
+		Redact(&l.Items[i])
+	}
+	return b.addYAML("resources/core/secrets.yaml", fmt.Sprintf("Secrets in namespace %s, with their values redacted", c.namespace), l.UnstructuredContent())
+}
+
+// collectEvents adds the recent events in the namespace.
+func (c *Collector) collectEvents(ctx context.Context, b *Bundle) error {
+	l := &corev1.EventList{}
+	if err := c.client.List(ctx, l, client.InNamespace(c.namespace)); err != nil {
+		return errors.Wrap(err, errListEvents)
+	}
+	since := c.now().Add(-c.eventsSince)
+	events := make([]corev1.Event, 0, len(l.Items))
+	for _, e := range l.Items {
+		if last(e).After(since) {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return last(events[i]).Before(last(events[j])) })
+	return b.addYAML("events.yaml", fmt.Sprintf("Events of the last %s in namespace %s", c.eventsSince, c.namespace), events)
+}
+
+// last returns when the given event last occurred.
+func last(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// collectVersions adds the versions ConfigMap.
+func (c *Collector) collectVersions(ctx context.Context, b *Bundle) error {
+	cm := &corev1.ConfigMap{}
+	err := c.client.Get(ctx, types.NamespacedName{Name: meta.ConfigMapNameVersions, Namespace: c.namespace}, cm)
+	if kerrors.IsNotFound(err) {
+		b.addError(errors.Wrap(err, errGetVersions))
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, errGetVersions)
+	}
+	cm.SetManagedFields(nil)
+	return b.addYAML("configmaps/"+meta.ConfigMapNameVersions+".yaml", "Versions of the Universal Crossplane components", cm)
+}
+
+// collectEntitlement adds a summary of the entitlement Secret.
+func (c *Collector) collectEntitlement(ctx context.Context, b *Bundle) error {
+	s := &corev1.Secret{}
+	sum := EntitlementSummary{}
+	err := c.client.Get(ctx, types.NamespacedName{Name: meta.SecretNameEntitlement, Namespace: c.namespace}, s)
+	if client.IgnoreNotFound(err) != nil {
+		return errors.Wrap(err, errGetEntitlement)
+	}
+	if err == nil {
+		sum.Exists = true
+		sum.CreationTimestamp = s.GetCreationTimestamp().UTC().Format(time.RFC3339)
+		sum.Keys = make(map[string]int, len(s.Data))
+		for k, v := range s.Data {
+			// Only the sizes of the values are revealed.
+			sum.Keys[k] = len(v)
+		}
+	}
+	return b.addYAML("entitlement.yaml", "Summary of the entitlement Secret, without its values", sum)
+}
+
+// Redact removes the values of the given object if it is a Secret, along with
+// fields that may contain them and managed fields, which are noise in a
+// bundle.
+func Redact(u *unstructured.Unstructured) {
+	u.SetManagedFields(nil)
+	if u.GetKind() != "Secret" {
+		return
+	}
+	for _, f := range []string{"data", "stringData"} {
+		m, ok, _ := unstructured.NestedMap(u.Object, f)
+		if !ok {
+			continue
+		}
+		for k := range m {
+			m[k] = Redacted
+		}
+		_ = unstructured.SetNestedMap(u.Object, m, f)
+	}
+	a := u.GetAnnotations()
+	for k := range a {
+		if strings.HasPrefix(k, "kubectl.kubernetes.io/last-applied-configuration") {
+			a[k] = Redacted
+		}
+	}
+	if a != nil {
+		u.SetAnnotations(a)
+	}
+}
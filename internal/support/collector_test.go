@@ -0,0 +1,190 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package support
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+const ns = "upbound-system"
+
+var now = time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func objects() []runtime.Object {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "crossplane"}}
+	provider := &unstructured.Unstructured{}
+	provider.SetAPIVersion("pkg.crossplane.io/v1")
+	provider.SetKind("Provider")
+	provider.SetName("provider-aws-s3")
+	_ = unstructured.SetNestedField(provider.Object, "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", "spec", "package")
+
+	return []runtime.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: meta.DeploymentNameCrossplane, Namespace: ns}, Spec: appsv1.DeploymentSpec{Selector: selector}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "crossplane-abc", Namespace: ns, Labels: selector.MatchLabels},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "universal-crossplane"}}},
+		},
+		provider,
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: meta.SecretNameEntitlement, Namespace: ns}, Data: map[string][]byte{"token": []byte("s3cr3t")}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: meta.ConfigMapNameVersions, Namespace: ns}, Data: map[string]string{"crossplane": "v1.14.0-up.1"}},
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "recent", Namespace: ns}, Reason: "Recent", LastTimestamp: metav1.NewTime(now.Add(-10 * time.Minute))},
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: ns}, Reason: "Old", LastTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+	}
+}
+
+func TestCollect(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = corev1.AddToScheme(s)
+	_ = appsv1.AddToScheme(s)
+	kube := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objects()...).Build()
+	logs := LogSourceFn(func(_ context.Context, _, pod, container string) ([]byte, error) {
+		return []byte("logs of " + pod + "/" + container), nil
+	})
+
+	c := NewCollector(kube, logs, ns)
+	c.now = func() time.Time { return now }
+	b, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect(...): %s", err)
+	}
+
+	if diff := cmp.Diff("uxp-support-bundle-20231001T120000Z", b.Name()); diff != "" {
+		t.Errorf("Name(): -want, +got:\n%s", diff)
+	}
+
+	// contains checks that the file at the given path exists and contains
+	// the given string, or does not contain it if not is true.
+	contains := func(path, s string, not bool) {
+		t.Helper()
+		c, ok := b.Contents(path)
+		if !ok {
+			t.Errorf("Collect(...): missing %s", path)
+			return
+		}
+		if strings.Contains(string(c), s) == not {
+			t.Errorf("Collect(...): %s contains %q is %t, want %t:\n%s", path, s, !not, !not, c)
+		}
+	}
+	contains("logs/crossplane-abc/universal-crossplane.log", "logs of crossplane-abc/universal-crossplane", false)
+	contains("resources/pkg.crossplane.io/providers.yaml", "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", false)
+	contains("resources/core/secrets.yaml", Redacted, false)
+	contains("resources/core/secrets.yaml", "czNjcjN0", true) // s3cr3t in base64.
+	contains("events.yaml", "Recent", false)
+	contains("events.yaml", "Old", true)
+	contains("configmaps/"+meta.ConfigMapNameVersions+".yaml", "v1.14.0-up.1", false)
+	contains("entitlement.yaml", "token: 6", false)
+
+	// The RBAC manager and bootstrapper are not installed.
+	want := []string{
+		errors.Wrapf(errors.New(`deployments.apps "crossplane-rbac-manager" not found`), errFmtGetDeployment, meta.DeploymentNameRBACManager).Error(),
+		errors.Wrapf(errors.New(`deployments.apps "upbound-bootstrapper" not found`), errFmtGetDeployment, meta.DeploymentNameBootstrapper).Error(),
+	}
+	if diff := cmp.Diff(want, b.Manifest.Errors); diff != "" {
+		t.Errorf("Collect(...): -want errors, +got errors:\n%s", diff)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	b := &Bundle{Manifest: Manifest{CreatedAt: now, Namespace: ns}}
+	b.add("events.yaml", "Events", []byte("[]"))
+
+	buf := &bytes.Buffer{}
+	if err := b.Write(buf); err != nil {
+		t.Fatalf("Write(...): %s", err)
+	}
+
+	gz, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := io.ReadAll(tr)
+		files[hdr.Name] = c
+	}
+
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	want := []string{"uxp-support-bundle-20231001T120000Z/events.yaml", "uxp-support-bundle-20231001T120000Z/manifest.json"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("Write(...): -want files, +got files:\n%s", diff)
+	}
+	m := Manifest{}
+	if err := json.Unmarshal(files["uxp-support-bundle-20231001T120000Z/manifest.json"], &m); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(b.Manifest, m); diff != "" {
+		t.Errorf("Write(...): -want manifest, +got manifest:\n%s", diff)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]any{
+			"name":        "creds",
+			"annotations": map[string]any{"kubectl.kubernetes.io/last-applied-configuration": `{"data":{"key":"dmFsdWU="}}`},
+		},
+		"data":       map[string]any{"key": "dmFsdWU="},
+		"stringData": map[string]any{"other": "value"},
+	}}
+	Redact(u)
+	want := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]any{
+			"name":        "creds",
+			"annotations": map[string]any{"kubectl.kubernetes.io/last-applied-configuration": Redacted},
+		},
+		"data":       map[string]any{"key": Redacted},
+		"stringData": map[string]any{"other": Redacted},
+	}
+	if diff := cmp.Diff(want, u.Object); diff != "" {
+		t.Errorf("Redact(...): -want, +got:\n%s", diff)
+	}
+}
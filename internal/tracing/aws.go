@@ -0,0 +1,62 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	awsSpanMiddlewareID   = "UXPTracingSpan"
+	awsInjectMiddlewareID = "UXPTracingInject"
+)
+
+// AWSMiddleware adds middlewares to the AWS SDK stack that record a span for
+// every AWS API operation and propagate the trace context in the outgoing
+// HTTP request. It is meant to be appended to aws.Config.APIOptions.
+func AWSMiddleware(stack *middleware.Stack) error {
+	if err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc(awsSpanMiddlewareID, func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (out middleware.InitializeOutput, md middleware.Metadata, err error) {
+		svc, op := awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx)
+		ctx, span := Start(ctx, fmt.Sprintf("%s.%s", svc, op),
+			attribute.String("rpc.system", "aws-api"),
+			attribute.String("rpc.service", svc),
+			attribute.String("rpc.method", op),
+		)
+		span.SetAttributes(attribute.String("aws.region", awsmiddleware.GetRegion(ctx)))
+		defer End(span, &err)
+		out, md, err = next.HandleInitialize(ctx, in)
+		if id, ok := awsmiddleware.GetRequestIDMetadata(md); ok {
+			span.SetAttributes(attribute.String("aws.request_id", id))
+		}
+		return out, md, err
+	}), middleware.After); err != nil {
+		return err
+	}
+	return stack.Build.Add(middleware.BuildMiddlewareFunc(awsInjectMiddlewareID, func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+		if req, ok := in.Request.(*smithyhttp.Request); ok && trace.SpanContextFromContext(ctx).IsValid() {
+			otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+		}
+		return next.HandleBuild(ctx, in)
+	}), middleware.After)
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewClient returns a client.Client that records a span for every call made
+// to the Kubernetes API server.
+func NewClient(c client.Client) client.Client {
+	return &Client{Client: c}
+}
+
+// Client wraps a client.Client and records a span for every call.
+type Client struct {
+	client.Client
+}
+
+func (c *Client) attrs(obj any, verb string) []attribute.KeyValue {
+	a := []attribute.KeyValue{attribute.String("k8s.verb", verb)}
+	if o, ok := obj.(client.Object); ok {
+		a = append(a, attribute.String("k8s.namespace", o.GetNamespace()), attribute.String("k8s.name", o.GetName()))
+	}
+	if o, ok := obj.(interface{ GetObjectKind() schema.ObjectKind }); ok {
+		a = append(a, attribute.String("k8s.kind", kindOf(o)))
+	}
+	return a
+}
+
+func kindOf(o interface{ GetObjectKind() schema.ObjectKind }) string {
+	if k := o.GetObjectKind().GroupVersionKind().Kind; k != "" {
+		return k
+	}
+	// Typed objects usually have an empty TypeMeta, so we fall back to the
+	// name of their Go type.
+	return reflect.Indirect(reflect.ValueOf(o)).Type().Name()
+}
+
+func (c *Client) start(ctx context.Context, verb string, obj any) (context.Context, func(*error)) {
+	ctx, span := Start(ctx, fmt.Sprintf("kube.%s", verb), c.attrs(obj, verb)...)
+	return ctx, func(err *error) { End(span, err) }
+}
+
+// Get retrieves an object and records a span.
+func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) (err error) {
+	ctx, end := c.start(ctx, "Get", obj)
+	defer end(&err)
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+// List retrieves a list of objects and records a span.
+func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (err error) {
+	ctx, end := c.start(ctx, "List", list)
+	defer end(&err)
+	return c.Client.List(ctx, list, opts...)
+}
+
+// Create creates an object and records a span.
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) (err error) {
+	ctx, end := c.start(ctx, "Create", obj)
+	defer end(&err)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// Update updates an object and records a span.
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) (err error) {
+	ctx, end := c.start(ctx, "Update", obj)
+	defer end(&err)
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// Patch patches an object and records a span.
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) (err error) {
+	ctx, end := c.start(ctx, "Patch", obj)
+	defer end(&err)
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// Delete deletes an object and records a span.
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) (err error) {
+	ctx, end := c.start(ctx, "Delete", obj)
+	defer end(&err)
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+// DeleteAllOf deletes all matching objects and records a span.
+func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) (err error) {
+	ctx, end := c.start(ctx, "DeleteAllOf", obj)
+	defer end(&err)
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+// Status returns a status writer that records a span for every call.
+func (c *Client) Status() client.StatusWriter {
+	return &statusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type statusWriter struct {
+	client.StatusWriter
+	client *Client
+}
+
+func (s *statusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) (err error) {
+	ctx, end := s.client.start(ctx, "UpdateStatus", obj)
+	defer end(&err)
+	return s.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (s *statusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) (err error) {
+	ctx, end := s.client.start(ctx, "PatchStatus", obj)
+	defer end(&err)
+	return s.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
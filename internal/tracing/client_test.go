@@ -0,0 +1,88 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+var errBoom = errors.New("boom")
+
+func TestClientGet(t *testing.T) {
+	type want struct {
+		err    error
+		name   string
+		status codes.Code
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   client.Client
+		want   want
+	}{
+		"Success": {
+			reason: "A successful call should be recorded as a span without an error status",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(nil)},
+			want: want{
+				name:   "kube.Get",
+				status: codes.Unset,
+			},
+		},
+		"Error": {
+			reason: "A failed call should be recorded as a span with an error status",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			want: want{
+				err:    errBoom,
+				name:   "kube.Get",
+				status: codes.Error,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+			err := NewClient(tc.kube).Get(context.Background(), types.NamespacedName{Name: "cool"}, &corev1.Secret{})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\nReason: %s\nc.Get(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			spans := sr.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("\nReason: %s\nc.Get(...): want 1 span, got %d", tc.reason, len(spans))
+			}
+			if diff := cmp.Diff(tc.want.name, spans[0].Name()); diff != "" {
+				t.Errorf("\nReason: %s\nc.Get(...): -want span name, +got span name:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.status, spans[0].Status().Code); diff != "" {
+				t.Errorf("\nReason: %s\nc.Get(...): -want span status, +got span status:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing contains the OpenTelemetry setup of the bootstrapper.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+const (
+	// ServiceName is the name bootstrapper reports itself with in traces.
+	ServiceName = "uxp-bootstrapper"
+
+	instrumentationName = "github.com/upbound/universal-crossplane"
+
+	errNewExporter = "cannot create OTLP trace exporter"
+	errNewResource = "cannot create trace resource"
+)
+
+// Options configures how traces are exported.
+type Options struct {
+	// Endpoint is the host:port of the OTLP/HTTP collector. Tracing is
+	// disabled when it is empty.
+	Endpoint string
+	// Insecure disables TLS when talking to the collector.
+	Insecure bool
+	// SampleRatio is the fraction of root spans that are sampled.
+	SampleRatio float64
+}
+
+// Setup configures the global tracer provider and propagator. The returned
+// function flushes and stops the exporter and should be called on shutdown.
+// Nothing is exported if no endpoint is given but trace context is still
+// propagated.
+func Setup(ctx context.Context, o Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if o.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	eo := []otlptracehttp.Option{otlptracehttp.WithEndpoint(o.Endpoint)}
+	if o.Insecure {
+		eo = append(eo, otlptracehttp.WithInsecure())
+	}
+	exp, err := otlptracehttp.New(ctx, eo...)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewExporter)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+		semconv.ServiceVersion(version.Version),
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, errNewResource)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the tracer used across the bootstrapper.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a new span with the given name and attributes.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, on the span and ends it. It is meant to be
+// deferred with a pointer to a named error return value.
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
@@ -0,0 +1,313 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package uninstall removes the state the bootstrapper created.
+package uninstall
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	pkgv1 "github.com/upbound/universal-crossplane/internal/apis/pkg/v1"
+	"github.com/upbound/universal-crossplane/internal/controllers/mirror"
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+const (
+	errFmtList             = "cannot list %s"
+	errFmtDelete           = "cannot delete %s %s"
+	errFmtStripFinalizers  = "cannot remove finalizers of %s %s"
+	errStopBootstrapper    = "cannot scale down the bootstrapper deployment"
+	errGetBootstrapper     = "cannot get the bootstrapper deployment"
+	patchRemoveFinalizers  = `{"metadata":{"finalizers":null}}`
+	patchScaleToZero       = `{"spec":{"replicas":0}}`
+	defaultWaitTimeout     = 2 * time.Minute
+	defaultPollingInterval = 2 * time.Second
+)
+
+// ManagedKinds are the kinds of the objects that the bootstrapper creates,
+// other than packages, in the order they are deleted. Deployments come first
+// so that nothing recreates the rest.
+var ManagedKinds = []schema.GroupVersionKind{ //nolint:gochecknoglobals // We treat this as a constant.
+	appsv1.SchemeGroupVersion.WithKind("Deployment"),
+	corev1.SchemeGroupVersion.WithKind("Service"),
+	mirror.ImageConfigGroupVersionKind,
+	rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding"),
+	rbacv1.SchemeGroupVersion.WithKind("ClusterRole"),
+	rbacv1.SchemeGroupVersion.WithKind("RoleBinding"),
+	rbacv1.SchemeGroupVersion.WithKind("Role"),
+	corev1.SchemeGroupVersion.WithKind("ServiceAccount"),
+	corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+	corev1.SchemeGroupVersion.WithKind("Secret"),
+}
+
+// PackageTiers are the kinds of Crossplane packages in the order they are
+// deleted. A tier is deleted only once the previous one is gone, since the
+// package manager would reinstall the dependencies of the remaining
+// Configurations otherwise.
+var PackageTiers = [][]schema.GroupVersionKind{ //nolint:gochecknoglobals // We treat this as a constant.
+	{pkgv1.ConfigurationGroupVersionKind},
+	{pkgv1.ProviderGroupVersionKind, pkgv1.FunctionGroupVersionKind},
+}
+
+// An Object that was or would be removed.
+type Object struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Namespace  string   `json:"namespace,omitempty"`
+	Name       string   `json:"name"`
+	Finalizers []string `json:"finalizers,omitempty"`
+}
+
+func objectOf(u *unstructured.Unstructured) Object {
+	return Object{APIVersion: u.GetAPIVersion(), Kind: u.GetKind(), Namespace: u.GetNamespace(), Name: u.GetName(), Finalizers: u.GetFinalizers()}
+}
+
+func (o Object) String() string {
+	if o.Namespace != "" {
+		return o.Kind + " " + o.Namespace + "/" + o.Name
+	}
+	return o.Kind + " " + o.Name
+}
+
+// A Report of an uninstall.
+type Report struct {
+	// DryRun is true if nothing was removed.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Stopped are the Deployments that were scaled down so that they do not
+	// recreate the removed objects.
+	Stopped []Object `json:"stopped,omitempty"`
+	// Deleted are the objects that were deleted.
+	Deleted []Object `json:"deleted,omitempty"`
+	// Stuck are the deleted objects that still exist because of their
+	// finalizers.
+	Stuck []Object `json:"stuck,omitempty"`
+	// FinalizersRemoved are the stuck objects whose finalizers were removed.
+	FinalizersRemoved []Object `json:"finalizersRemoved,omitempty"`
+	// Errors are the objects that could not be removed.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// A ConfirmFn confirms that the finalizers of the given stuck objects may be
+// removed.
+type ConfirmFn func(stuck []Object) bool
+
+// An Option configures the Uninstaller.
+type Option func(*Uninstaller)
+
+// WithLogger specifies how the Uninstaller should log messages.
+func WithLogger(log logging.Logger) Option {
+	return func(u *Uninstaller) {
+		u.log = log
+	}
+}
+
+// WithDeletePackages specifies whether the Uninstaller should delete all
+// Crossplane packages, including the ones the bootstrapper did not install.
+func WithDeletePackages(packages bool) Option {
+	return func(u *Uninstaller) {
+		u.packages = packages
+	}
+}
+
+// WithStripFinalizers specifies that the Uninstaller should remove the
+// finalizers of stuck objects if the given function confirms it.
+func WithStripFinalizers(fn ConfirmFn) Option {
+	return func(u *Uninstaller) {
+		u.confirm = fn
+	}
+}
+
+// WithDryRun specifies whether the Uninstaller should only report what it
+// would remove.
+func WithDryRun(dryRun bool) Option {
+	return func(u *Uninstaller) {
+		u.dryRun = dryRun
+	}
+}
+
+// WithWaitTimeout specifies how long the Uninstaller should wait for deleted
+// objects to be gone before it considers them stuck.
+func WithWaitTimeout(d time.Duration) Option {
+	return func(u *Uninstaller) {
+		u.waitTimeout = d
+	}
+}
+
+// An Uninstaller removes the objects the bootstrapper created.
+type Uninstaller struct {
+	client      client.Client
+	namespace   string
+	log         logging.Logger
+	packages    bool
+	confirm     ConfirmFn
+	dryRun      bool
+	waitTimeout time.Duration
+	pollEvery   time.Duration
+}
+
+// New returns an Uninstaller for the Universal Crossplane installation in
+// the given namespace.
+func New(c client.Client, namespace string, opts ...Option) *Uninstaller {
+	u := &Uninstaller{
+		client:      c,
+		namespace:   namespace,
+		log:         logging.NewNopLogger(),
+		waitTimeout: defaultWaitTimeout,
+		pollEvery:   defaultPollingInterval,
+	}
+	for _, f := range opts {
+		f(u)
+	}
+	return u
+}
+
+// Run stops the bootstrapper, deletes the packages tier by tier if asked to,
+// then every other object labeled as managed by the bootstrapper, and finally
+// removes the finalizers of the objects that are stuck if that is confirmed.
+// Objects that cannot be removed are recorded in the report rather than
+// stopping the uninstall.
+func (u *Uninstaller) Run(ctx context.Context) *Report {
+	r := &Report{DryRun: u.dryRun}
+	u.stopBootstrapper(ctx, r)
+
+	// Packages are deleted regardless of who installed them, since deleting
+	// a package deletes its CRDs and with them every managed resource.
+	if u.packages {
+		for _, kinds := range PackageTiers {
+			u.wait(ctx, u.deleteAll(ctx, kinds, nil, r), r)
+		}
+	}
+	u.wait(ctx, u.deleteAll(ctx, ManagedKinds, client.MatchingLabels{meta.LabelKeyManagedBy: meta.LabelValueManagedBy}, r), r)
+
+	if len(r.Stuck) == 0 || u.confirm == nil || u.dryRun || !u.confirm(r.Stuck) {
+		return r
+	}
+	for _, o := range r.Stuck {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(o.APIVersion)
+		obj.SetKind(o.Kind)
+		obj.SetNamespace(o.Namespace)
+		obj.SetName(o.Name)
+		err := u.client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, []byte(patchRemoveFinalizers)))
+		if err != nil && !kerrors.IsNotFound(err) {
+			r.Errors = append(r.Errors, errors.Wrapf(err, errFmtStripFinalizers, o.Kind, o.Name).Error())
+			continue
+		}
+		u.log.Info("Removed finalizers", "object", o.String(), "finalizers", o.Finalizers)
+		r.FinalizersRemoved = append(r.FinalizersRemoved, o)
+	}
+	return r
+}
+
+// stopBootstrapper scales the bootstrapper down so that its controllers do
+// not recreate the objects that are removed.
+func (u *Uninstaller) stopBootstrapper(ctx context.Context, r *Report) {
+	d := &unstructured.Unstructured{}
+	d.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+	err := u.client.Get(ctx, types.NamespacedName{Namespace: u.namespace, Name: meta.DeploymentNameBootstrapper}, d)
+	if kerrors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		r.Errors = append(r.Errors, errors.Wrap(err, errGetBootstrapper).Error())
+		return
+	}
+	if replicas, found, _ := unstructured.NestedInt64(d.Object, "spec", "replicas"); found && replicas == 0 {
+		return
+	}
+	if !u.dryRun {
+		if err := u.client.Patch(ctx, d, client.RawPatch(types.MergePatchType, []byte(patchScaleToZero))); err != nil {
+			r.Errors = append(r.Errors, errors.Wrap(err, errStopBootstrapper).Error())
+			return
+		}
+	}
+	r.Stopped = append(r.Stopped, objectOf(d))
+}
+
+// deleteAll deletes the objects of the given kinds that match the given
+// labels and returns the deleted objects.
+func (u *Uninstaller) deleteAll(ctx context.Context, kinds []schema.GroupVersionKind, labels client.MatchingLabels, r *Report) []*unstructured.Unstructured {
+	var deleted []*unstructured.Unstructured
+	for _, gvk := range kinds {
+		l := &unstructured.UnstructuredList{}
+		l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		err := u.client.List(ctx, l, labels)
+		if kmeta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			r.Errors = append(r.Errors, errors.Wrapf(err, errFmtList, gvk.Kind).Error())
+			continue
+		}
+		for i := range l.Items {
+			o := &l.Items[i]
+			if !u.dryRun {
+				err := u.client.Delete(ctx, o, client.PropagationPolicy(metav1.DeletePropagationBackground))
+				if kerrors.IsNotFound(err) {
+					continue
+				}
+				if err != nil {
+					r.Errors = append(r.Errors, errors.Wrapf(err, errFmtDelete, o.GetKind(), o.GetName()).Error())
+					continue
+				}
+			}
+			u.log.Info("Deleted", "object", objectOf(o).String(), "dryRun", u.dryRun)
+			r.Deleted = append(r.Deleted, objectOf(o))
+			deleted = append(deleted, o)
+		}
+	}
+	return deleted
+}
+
+// wait waits for the given objects to be gone and records the ones that are
+// not as stuck.
+func (u *Uninstaller) wait(ctx context.Context, objs []*unstructured.Unstructured, r *Report) {
+	if u.dryRun || len(objs) == 0 {
+		return
+	}
+	remaining := objs
+	_ = wait.PollImmediateWithContext(ctx, u.pollEvery, u.waitTimeout, func(ctx context.Context) (bool, error) {
+		still := make([]*unstructured.Unstructured, 0, len(remaining))
+		for _, o := range remaining {
+			err := u.client.Get(ctx, types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}, o)
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			// Objects that cannot be read are assumed to be there still.
+			still = append(still, o)
+		}
+		remaining = still
+		return len(remaining) == 0, nil
+	})
+	for _, o := range remaining {
+		u.log.Info("Object is stuck", "object", objectOf(o).String(), "finalizers", o.GetFinalizers())
+		r.Stuck = append(r.Stuck, objectOf(o))
+	}
+}
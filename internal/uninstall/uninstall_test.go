@@ -0,0 +1,162 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uninstall
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/upbound/universal-crossplane/internal/meta"
+)
+
+func object(apiVersion, kind, namespace, name string, finalizers ...string) unstructured.Unstructured {
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetFinalizers(finalizers)
+	return u
+}
+
+func TestRun(t *testing.T) {
+	// The objects in the cluster by list kind. The ConfigMap is stuck on its
+	// finalizer.
+	cluster := map[string][]unstructured.Unstructured{
+		"ConfigurationList": {object("pkg.crossplane.io/v1", "Configuration", "", "platform-ref-aws")},
+		"ProviderList":      {object("pkg.crossplane.io/v1", "Provider", "", "provider-aws-s3")},
+		"DeploymentList":    {object("apps/v1", "Deployment", "upbound-system", "xgql")},
+		"ConfigMapList":     {object("v1", "ConfigMap", "upbound-system", "universal-crossplane-health", "example.org/finalizer")},
+	}
+	type want struct {
+		report  *Report
+		deleted []string
+		patched []string
+	}
+	cases := map[string]struct {
+		reason string
+		opts   []Option
+		want   want
+	}{
+		"DryRun": {
+			reason: "Nothing should be changed in dry run mode.",
+			opts:   []Option{WithDryRun(true), WithDeletePackages(true), WithStripFinalizers(func([]Object) bool { return true })},
+			want: want{
+				report: &Report{
+					DryRun:  true,
+					Stopped: []Object{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "upbound-system", Name: meta.DeploymentNameBootstrapper}},
+					Deleted: []Object{
+						{APIVersion: "pkg.crossplane.io/v1", Kind: "Configuration", Name: "platform-ref-aws"},
+						{APIVersion: "pkg.crossplane.io/v1", Kind: "Provider", Name: "provider-aws-s3"},
+						{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "upbound-system", Name: "xgql"},
+						{APIVersion: "v1", Kind: "ConfigMap", Namespace: "upbound-system", Name: "universal-crossplane-health", Finalizers: []string{"example.org/finalizer"}},
+					},
+				},
+			},
+		},
+		"KeepPackages": {
+			reason: "Packages should be kept unless asked otherwise, and finalizers should be kept unless confirmed.",
+			opts:   []Option{WithStripFinalizers(func([]Object) bool { return false })},
+			want: want{
+				report: &Report{
+					Stopped: []Object{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "upbound-system", Name: meta.DeploymentNameBootstrapper}},
+					Deleted: []Object{
+						{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "upbound-system", Name: "xgql"},
+						{APIVersion: "v1", Kind: "ConfigMap", Namespace: "upbound-system", Name: "universal-crossplane-health", Finalizers: []string{"example.org/finalizer"}},
+					},
+					Stuck: []Object{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "upbound-system", Name: "universal-crossplane-health", Finalizers: []string{"example.org/finalizer"}}},
+				},
+				deleted: []string{"Deployment upbound-system/xgql", "ConfigMap upbound-system/universal-crossplane-health"},
+				patched: []string{`Deployment {"spec":{"replicas":0}}`},
+			},
+		},
+		"DeletePackagesAndStripFinalizers": {
+			reason: "Configurations should be deleted before Providers, and confirmed finalizers should be removed.",
+			opts:   []Option{WithDeletePackages(true), WithStripFinalizers(func([]Object) bool { return true })},
+			want: want{
+				report: &Report{
+					Stopped: []Object{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "upbound-system", Name: meta.DeploymentNameBootstrapper}},
+					Deleted: []Object{
+						{APIVersion: "pkg.crossplane.io/v1", Kind: "Configuration", Name: "platform-ref-aws"},
+						{APIVersion: "pkg.crossplane.io/v1", Kind: "Provider", Name: "provider-aws-s3"},
+						{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "upbound-system", Name: "xgql"},
+						{APIVersion: "v1", Kind: "ConfigMap", Namespace: "upbound-system", Name: "universal-crossplane-health", Finalizers: []string{"example.org/finalizer"}},
+					},
+					Stuck:             []Object{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "upbound-system", Name: "universal-crossplane-health", Finalizers: []string{"example.org/finalizer"}}},
+					FinalizersRemoved: []Object{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "upbound-system", Name: "universal-crossplane-health", Finalizers: []string{"example.org/finalizer"}}},
+				},
+				deleted: []string{"Configuration platform-ref-aws", "Provider provider-aws-s3", "Deployment upbound-system/xgql", "ConfigMap upbound-system/universal-crossplane-health"},
+				patched: []string{`Deployment {"spec":{"replicas":0}}`, `ConfigMap {"metadata":{"finalizers":null}}`},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var deleted, patched []string
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					u := obj.(*unstructured.Unstructured)
+					switch {
+					case key.Name == meta.DeploymentNameBootstrapper:
+						u.SetNamespace(key.Namespace)
+						u.SetName(key.Name)
+						return unstructured.SetNestedField(u.Object, int64(1), "spec", "replicas")
+					case len(u.GetFinalizers()) > 0:
+						return nil
+					}
+					return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+				},
+				MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+					l := list.(*unstructured.UnstructuredList)
+					for _, o := range cluster[l.GetKind()] {
+						l.Items = append(l.Items, *o.DeepCopy())
+					}
+					return nil
+				},
+				MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+					deleted = append(deleted, objectOf(obj.(*unstructured.Unstructured)).String())
+					return nil
+				},
+				MockPatch: func(_ context.Context, obj client.Object, p client.Patch, _ ...client.PatchOption) error {
+					b, _ := p.Data(obj)
+					patched = append(patched, obj.GetObjectKind().GroupVersionKind().Kind+" "+string(b))
+					return nil
+				},
+			}
+			u := New(kube, "upbound-system", tc.opts...)
+			u.pollEvery, u.waitTimeout = time.Millisecond, 10*time.Millisecond
+
+			got := u.Run(context.Background())
+			if diff := cmp.Diff(tc.want.report, got); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want report, +got report:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want deleted, +got deleted:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patched, patched); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want patched, +got patched:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	errFmtInvalidRange      = "%q is not a valid version range"
+	errFmtInvalidConstraint = "%q is not a valid version constraint"
+)
+
+// partialRe matches versions with optional minor and patch numbers, which may
+// be wildcards. Pre-release suffixes other than the Upbound patch, e.g. the
+// "-0" in ">=v1.14.0-0", are accepted and ignored.
+var partialRe = regexp.MustCompile(`^v?(0|[1-9]\d*|[xX*])(?:\.(0|[1-9]\d*|[xX*]))?(?:\.(0|[1-9]\d*|[xX*]))?(?:-up\.([1-9]\d*)|-[0-9A-Za-z.-]+)?$`) //nolint:gochecknoglobals // We treat this as a constant.
+
+var operators = []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} //nolint:gochecknoglobals // We treat this as a constant.
+
+// A Range is a set of versions, e.g. ">=1.2.0 <2.0.0", "~1.2", "^1.2.3",
+// "1.2.x" or ">=v1.14.0-0". Constraints separated by spaces or commas must all
+// be satisfied, and "||" separates alternatives.
+type Range struct {
+	raw  string
+	sets [][]comparator
+}
+
+type comparator struct {
+	op string
+	v  Semver
+}
+
+func (c comparator) matches(v Semver) bool {
+	r := v.Compare(c.v)
+	switch c.op {
+	case ">=":
+		return r >= 0
+	case ">":
+		return r > 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case "!=":
+		return r != 0
+	}
+	return r == 0
+}
+
+// ParseRange parses the given version range. An empty range or "*" contains
+// all versions.
+func ParseRange(s string) (Range, error) {
+	r := Range{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		fields := strings.Fields(strings.ReplaceAll(alt, ",", " "))
+		set := []comparator{}
+		for i := 0; i < len(fields); i++ {
+			c := fields[i]
+			// Allow a space between the operator and the version.
+			if isOperator(c) && i+1 < len(fields) {
+				i++
+				c += fields[i]
+			}
+			cs, err := parseConstraint(c)
+			if err != nil {
+				return Range{}, err
+			}
+			set = append(set, cs...)
+		}
+		if len(fields) == 0 && strings.Contains(s, "||") {
+			return Range{}, errors.Errorf(errFmtInvalidRange, s)
+		}
+		r.sets = append(r.sets, set)
+	}
+	return r, nil
+}
+
+// MustParseRange is like ParseRange but panics if the range is not valid.
+func MustParseRange(s string) Range {
+	r, err := ParseRange(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Contains returns true if the given version is in the range.
+func (r Range) Contains(v Semver) bool {
+	if len(r.sets) == 0 {
+		return true
+	}
+	for _, set := range r.sets {
+		ok := true
+		for _, c := range set {
+			if !c.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the range as it was parsed.
+func (r Range) String() string {
+	return r.raw
+}
+
+func isOperator(s string) bool {
+	for _, op := range operators {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConstraint returns the comparators of a single constraint. Partial
+// versions are expanded to the versions they stand for, e.g. "<=1.2" to
+// "<1.3.0" and "~1.2.3" to ">=1.2.3 <1.3.0".
+func parseConstraint(s string) ([]comparator, error) {
+	op := ""
+	for _, o := range operators {
+		if strings.HasPrefix(s, o) {
+			op = o
+			break
+		}
+	}
+	v, n, err := parsePartial(strings.TrimPrefix(s, op))
+	if err != nil {
+		return nil, errors.Errorf(errFmtInvalidConstraint, s)
+	}
+	if n == 0 {
+		// A wildcard only excludes every version if negated.
+		if op == "!=" || op == "<" || op == ">" {
+			return nil, errors.Errorf(errFmtInvalidConstraint, s)
+		}
+		return nil, nil
+	}
+	next := bump(v, n)
+
+	switch op {
+	case "", "=":
+		if n == 3 {
+			return []comparator{{op: "=", v: v}}, nil
+		}
+		return []comparator{{op: ">=", v: v}, {op: "<", v: next}}, nil
+	case "!=":
+		if n != 3 {
+			return nil, errors.Errorf(errFmtInvalidConstraint, s)
+		}
+		return []comparator{{op: "!=", v: v}}, nil
+	case ">":
+		if n == 3 {
+			return []comparator{{op: ">", v: v}}, nil
+		}
+		return []comparator{{op: ">=", v: next}}, nil
+	case "<=":
+		if n == 3 {
+			return []comparator{{op: "<=", v: v}}, nil
+		}
+		return []comparator{{op: "<", v: next}}, nil
+	case ">=", "<":
+		return []comparator{{op: op, v: v}}, nil
+	case "~":
+		if n == 3 {
+			next = bump(v, 2)
+		}
+		return []comparator{{op: ">=", v: v}, {op: "<", v: next}}, nil
+	}
+
+	// The caret allows changes that do not modify the left-most non-zero
+	// number.
+	upper := Semver{Major: v.Major + 1}
+	switch {
+	case v.Major > 0 || n == 1:
+	case v.Minor > 0 || n == 2:
+		upper = Semver{Minor: v.Minor + 1}
+	default:
+		upper = Semver{Patch: v.Patch + 1}
+	}
+	return []comparator{{op: ">=", v: v}, {op: "<", v: upper}}, nil
+}
+
+// parsePartial parses a possibly partial version and returns it with the
+// number of components that were given before the first wildcard.
+func parsePartial(s string) (Semver, int, error) {
+	m := partialRe.FindStringSubmatch(s)
+	if m == nil {
+		return Semver{}, 0, errors.Errorf(errFmtInvalid, s)
+	}
+	nums := make([]int, 3)
+	n := 0
+	for i := 0; i < 3; i++ {
+		c := m[i+1]
+		if c == "" || c == "x" || c == "X" || c == "*" {
+			break
+		}
+		nums[i], _ = strconv.Atoi(c)
+		n++
+	}
+	v := Semver{Major: nums[0], Minor: nums[1], Patch: nums[2]}
+	if n == 3 && m[4] != "" {
+		v.Up, _ = strconv.Atoi(m[4])
+	}
+	return v, n, nil
+}
+
+// bump returns the lowest version that is higher than all the versions the
+// given partial version with n components stands for.
+func bump(v Semver, n int) Semver {
+	switch n {
+	case 1:
+		return Semver{Major: v.Major + 1}
+	case 2:
+		return Semver{Major: v.Major, Minor: v.Minor + 1}
+	}
+	// Upbound patches are ordered after the version they are based on.
+	return Semver{Major: v.Major, Minor: v.Minor, Patch: v.Patch, Up: v.Up + 1}
+}
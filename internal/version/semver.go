@@ -0,0 +1,236 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	errFmtInvalid    = "%q is not a valid version, must be in x.y.z or x.y.z-up.n format with an optional pre-release suffix"
+	errFmtNotUXP     = "%q is not a Universal Crossplane version, must be in x.y.z-up.n format"
+	errFmtDowngrade  = "downgrading from %s to %s is not supported"
+	errFmtOSSToUXP   = "open source Crossplane %s can only be upgraded to Universal Crossplane of the same version, upgrade Crossplane to %s first"
+	errFmtMajor      = "upgrading from %s to %s changes the major version, which is not supported"
+	errFmtSkipsMinor = "upgrading from %s to %s skips minor versions, upgrade to v%d.%d first"
+	errFmtUXPToOSS   = "downgrading Universal Crossplane %s to open source Crossplane %s is not supported"
+)
+
+// semverRe matches upstream versions with an optional pre-release, e.g.
+// v1.20.0-rc.1, and Universal Crossplane versions with an optional pre-release
+// after the Upbound patch, e.g. v1.20.0-up.1.rc.0.
+var semverRe = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-up\.([1-9]\d*)(?:\.([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?|-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?$`) //nolint:gochecknoglobals // We treat this as a constant.
+
+// Semver is a Crossplane or Universal Crossplane version. Universal
+// Crossplane appends an Upbound patch number to the upstream Crossplane
+// version, i.e. x.y.z-up.n.
+type Semver struct {
+	Major int
+	Minor int
+	Patch int
+	// Up is the Upbound patch number. It is zero for upstream Crossplane
+	// versions.
+	Up int
+	// Pre is the pre-release of the version, e.g. rc.1. It is empty for
+	// releases.
+	Pre string
+}
+
+// Parse parses the given version. The "v" prefix is optional.
+func Parse(s string) (Semver, error) {
+	m := semverRe.FindStringSubmatch(s)
+	if m == nil || strings.HasPrefix(m[6], "up.") {
+		// An Upbound patch that is not a number is not a pre-release.
+		return Semver{}, errors.Errorf(errFmtInvalid, s)
+	}
+	v := Semver{}
+	// The regular expression guarantees these are numbers.
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	if m[4] != "" {
+		v.Up, _ = strconv.Atoi(m[4])
+	}
+	v.Pre = m[5] + m[6]
+	return v, nil
+}
+
+// MustParse is like Parse but panics if the version is not valid. It is
+// intended for versions that are known to be valid, e.g. constants.
+func MustParse(s string) Semver {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// ParseUXP parses the given version and returns an error if it is not a
+// Universal Crossplane version, i.e. has no Upbound patch number.
+func ParseUXP(s string) (Semver, error) {
+	v, err := Parse(s)
+	if err != nil {
+		return Semver{}, err
+	}
+	if !v.IsUXP() {
+		return Semver{}, errors.Errorf(errFmtNotUXP, s)
+	}
+	return v, nil
+}
+
+// IsValid returns true if the given string is a valid Crossplane or Universal
+// Crossplane version.
+func IsValid(s string) bool {
+	return semverRe.MatchString(s)
+}
+
+// String returns the version with the "v" prefix.
+func (v Semver) String() string {
+	s := fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+	sep := "-"
+	if v.Up > 0 {
+		s, sep = s+fmt.Sprintf("-up.%d", v.Up), "."
+	}
+	if v.Pre != "" {
+		s += sep + v.Pre
+	}
+	return s
+}
+
+// IsUXP returns true if this is a Universal Crossplane version.
+func (v Semver) IsUXP() bool {
+	return v.Up > 0
+}
+
+// IsPrerelease returns true if this is a pre-release, e.g. v1.20.0-up.1.rc.0.
+func (v Semver) IsPrerelease() bool {
+	return v.Pre != ""
+}
+
+// Upstream returns the upstream Crossplane version a Universal Crossplane
+// version is based on, e.g. v1.2.0 for v1.2.0-up.3 and v1.2.0-up.3.rc.0.
+// Upstream versions are returned as is.
+func (v Semver) Upstream() Semver {
+	if !v.IsUXP() {
+		return v
+	}
+	return Semver{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower than, equal to or
+// greater than o. Unlike the usual semantic versioning pre-release rules, an
+// Upbound patch is ordered after the upstream version it is based on, i.e.
+// v1.2.0 < v1.2.0-up.1 < v1.2.0-up.2 < v1.2.1. Pre-releases are ordered
+// before the release they precede, i.e. v1.2.0-up.1.rc.0 < v1.2.0-up.1.
+func (v Semver) Compare(o Semver) int {
+	for _, d := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}, {v.Up, o.Up}} {
+		switch {
+		case d[0] < d[1]:
+			return -1
+		case d[0] > d[1]:
+			return 1
+		}
+	}
+	return comparePre(v.Pre, o.Pre)
+}
+
+// comparePre compares pre-releases as semantic versioning does: a release
+// is greater than any pre-release, numeric identifiers are compared
+// numerically and ordered before alphanumeric ones, and a pre-release with
+// more identifiers is greater if the others are equal.
+func comparePre(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aerr == nil && berr != nil:
+			return -1
+		case aerr != nil && berr == nil:
+			return 1
+		case as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// LessThan returns true if v is lower than o.
+func (v Semver) LessThan(o Semver) bool {
+	return v.Compare(o) < 0
+}
+
+// Equal returns true if v and o are the same version.
+func (v Semver) Equal(o Semver) bool {
+	return v.Compare(o) == 0
+}
+
+// Sort sorts the given versions in ascending order.
+func Sort(vs []Semver) {
+	sort.SliceStable(vs, func(i, j int) bool { return vs[i].LessThan(vs[j]) })
+}
+
+// CheckUpgrade returns an error explaining why upgrading from one version to
+// another is not supported, or nil if it is. Downgrades, major version changes
+// and skipping minor versions are never supported. Open source Crossplane can
+// only be upgraded to a Universal Crossplane release based on the same
+// upstream version, and Universal Crossplane cannot be replaced by open source
+// Crossplane.
+func CheckUpgrade(from, to Semver) error {
+	switch {
+	case to.LessThan(from):
+		return errors.Errorf(errFmtDowngrade, from, to)
+	case from.IsUXP() && !to.IsUXP():
+		return errors.Errorf(errFmtUXPToOSS, from, to)
+	case !from.IsUXP() && to.IsUXP() && !to.Upstream().Equal(from):
+		return errors.Errorf(errFmtOSSToUXP, from, to.Upstream())
+	case to.Major != from.Major:
+		return errors.Errorf(errFmtMajor, from, to)
+	case to.Minor > from.Minor+1:
+		return errors.Errorf(errFmtSkipsMinor, from, to, from.Major, from.Minor+1)
+	}
+	return nil
+}
+
+// IsSupportedUpgrade returns true if upgrading from one version to another is
+// supported. See CheckUpgrade for the rules.
+func IsSupportedUpgrade(from, to Semver) bool {
+	return CheckUpgrade(from, to) == nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestParse(t *testing.T) {
+	type want struct {
+		v   Semver
+		err error
+	}
+	cases := map[string]struct {
+		s    string
+		want want
+	}{
+		"Upstream":         {s: "v1.2.3", want: want{v: Semver{Major: 1, Minor: 2, Patch: 3}}},
+		"UXP":              {s: "v1.2.3-up.4", want: want{v: Semver{Major: 1, Minor: 2, Patch: 3, Up: 4}}},
+		"NoPrefix":         {s: "1.12.0-up.1", want: want{v: Semver{Major: 1, Minor: 12, Patch: 0, Up: 1}}},
+		"ZeroUpboundPatch": {s: "v1.2.3-up.0", want: want{err: errors.Errorf(errFmtInvalid, "v1.2.3-up.0")}},
+		"LeadingZero":      {s: "v1.02.3", want: want{err: errors.Errorf(errFmtInvalid, "v1.02.3")}},
+		"PreRelease":       {s: "v1.2.3-rc.1", want: want{v: Semver{Major: 1, Minor: 2, Patch: 3, Pre: "rc.1"}}},
+		"UXPPreRelease":    {s: "v1.20.0-up.1.rc.0", want: want{v: Semver{Major: 1, Minor: 20, Patch: 0, Up: 1, Pre: "rc.0"}}},
+		"InvalidUpbound":   {s: "v1.2.3-up.x", want: want{err: errors.Errorf(errFmtInvalid, "v1.2.3-up.x")}},
+		"EmptyPreRelease":  {s: "v1.2.3-up.1.", want: want{err: errors.Errorf(errFmtInvalid, "v1.2.3-up.1.")}},
+		"Empty":            {s: "", want: want{err: errors.Errorf(errFmtInvalid, "")}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, err := Parse(tc.s)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Parse(%q): -want error, +got error:\n%s", tc.s, diff)
+			}
+			if diff := cmp.Diff(tc.want.v, v); diff != "" {
+				t.Errorf("Parse(%q): -want, +got:\n%s", tc.s, diff)
+			}
+		})
+	}
+}
+
+func TestParseUXP(t *testing.T) {
+	if _, err := ParseUXP("v1.2.3"); err == nil {
+		t.Errorf("ParseUXP(%q): want error, got nil", "v1.2.3")
+	}
+	if _, err := ParseUXP("v1.2.3-up.1"); err != nil {
+		t.Errorf("ParseUXP(%q): want no error, got %s", "v1.2.3-up.1", err)
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, s := range []string{"v1.2.3", "v1.2.3-up.4", "v1.2.3-rc.1", "v1.2.3-up.4.rc.0"} {
+		if got := MustParse(s).String(); got != s {
+			t.Errorf("MustParse(%q).String(): want %q, got %q", s, s, got)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	vs := []Semver{
+		MustParse("v1.2.1-up.1"),
+		MustParse("v1.10.0"),
+		MustParse("v1.2.0-up.3"),
+		MustParse("v1.2.0"),
+		MustParse("v1.2.0-up.1"),
+		MustParse("v1.2.1"),
+		MustParse("v1.2.0-up.1.rc.10"),
+		MustParse("v1.2.0-up.1.rc.2"),
+		MustParse("v1.2.0-rc.1"),
+		MustParse("v1.2.0-up.1.rc"),
+	}
+	want := []Semver{
+		MustParse("v1.2.0-rc.1"),
+		MustParse("v1.2.0"),
+		MustParse("v1.2.0-up.1.rc"),
+		MustParse("v1.2.0-up.1.rc.2"),
+		MustParse("v1.2.0-up.1.rc.10"),
+		MustParse("v1.2.0-up.1"),
+		MustParse("v1.2.0-up.3"),
+		MustParse("v1.2.1"),
+		MustParse("v1.2.1-up.1"),
+		MustParse("v1.10.0"),
+	}
+	Sort(vs)
+	if diff := cmp.Diff(want, vs); diff != "" {
+		t.Errorf("Sort(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestUpstream(t *testing.T) {
+	for s, want := range map[string]string{"v1.2.0-up.3": "v1.2.0", "v1.2.0-up.3.rc.0": "v1.2.0", "v1.2.0-rc.1": "v1.2.0-rc.1"} {
+		if got := MustParse(s).Upstream(); !got.Equal(MustParse(want)) {
+			t.Errorf("MustParse(%q).Upstream(): want %s, got %s", s, want, got)
+		}
+	}
+}
+
+func TestCheckUpgrade(t *testing.T) {
+	cases := map[string]struct {
+		from string
+		to   string
+		want error
+	}{
+		"SameVersion":          {from: "v1.20.4-up.1", to: "v1.20.4-up.1"},
+		"ReleaseCandidate":     {from: "v1.20.4-up.1.rc.0", to: "v1.20.4-up.1"},
+		"UpboundPatch":         {from: "v1.20.4-up.1", to: "v1.20.4-up.2"},
+		"UpstreamPatch":        {from: "v1.20.0-up.3", to: "v1.20.1-up.1"},
+		"NextMinor":            {from: "v1.19.2-up.1", to: "v1.20.4-up.1"},
+		"OSSSameVersion":       {from: "v1.20.4", to: "v1.20.4-up.1"},
+		"OSSUpgrade":           {from: "v1.20.3", to: "v1.20.4"},
+		"SkipMinor":            {from: "v1.18.2-up.1", to: "v1.20.4-up.1", want: errors.Errorf(errFmtSkipsMinor, "v1.18.2-up.1", "v1.20.4-up.1", 1, 19)},
+		"MajorChange":          {from: "v1.14.0-up.1", to: "v2.0.0-up.1", want: errors.Errorf(errFmtMajor, "v1.14.0-up.1", "v2.0.0-up.1")},
+		"Downgrade":            {from: "v1.20.1-up.1", to: "v1.20.0-up.3", want: errors.Errorf(errFmtDowngrade, "v1.20.1-up.1", "v1.20.0-up.3")},
+		"DowngradeUpbound":     {from: "v1.20.4-up.2", to: "v1.20.4-up.1", want: errors.Errorf(errFmtDowngrade, "v1.20.4-up.2", "v1.20.4-up.1")},
+		"UXPToOSS":             {from: "v1.20.4-up.1", to: "v1.20.5", want: errors.Errorf(errFmtUXPToOSS, "v1.20.4-up.1", "v1.20.5")},
+		"OSSDifferentVersion":  {from: "v1.20.3", to: "v1.20.4-up.1", want: errors.Errorf(errFmtOSSToUXP, "v1.20.3", "v1.20.4")},
+		"OSSNewerThanUpstream": {from: "v1.20.5", to: "v1.20.4-up.1", want: errors.Errorf(errFmtDowngrade, "v1.20.5", "v1.20.4-up.1")},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckUpgrade(MustParse(tc.from), MustParse(tc.to))
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("CheckUpgrade(%s, %s): -want error, +got error:\n%s", tc.from, tc.to, diff)
+			}
+			if got := IsSupportedUpgrade(MustParse(tc.from), MustParse(tc.to)); got != (tc.want == nil) {
+				t.Errorf("IsSupportedUpgrade(%s, %s): want %t, got %t", tc.from, tc.to, tc.want == nil, got)
+			}
+		})
+	}
+}
+
+func TestRange(t *testing.T) {
+	cases := map[string]struct {
+		in  []string
+		out []string
+		err error
+	}{
+		"":                     {in: []string{"v0.0.1", "v2.0.0-up.1"}},
+		"*":                    {in: []string{"v1.2.3"}},
+		">=1.2.0 <2.0.0":       {in: []string{"v1.2.0", "v1.9.9"}, out: []string{"v1.1.9", "v2.0.0"}},
+		">= 1.2, <2":           {in: []string{"v1.2.0", "v1.9.9"}, out: []string{"v1.1.9", "v2.0.0"}},
+		"~1.2.3":               {in: []string{"v1.2.3", "v1.2.9"}, out: []string{"v1.2.2", "v1.3.0"}},
+		"~1":                   {in: []string{"v1.0.0", "v1.9.0"}, out: []string{"v2.0.0"}},
+		"^1.2.3":               {in: []string{"v1.2.3", "v1.9.0"}, out: []string{"v1.2.2", "v2.0.0"}},
+		"^0.2.3":               {in: []string{"v0.2.3", "v0.2.9"}, out: []string{"v0.3.0"}},
+		"^0.0.3":               {in: []string{"v0.0.3"}, out: []string{"v0.0.4"}},
+		"1.2.x":                {in: []string{"v1.2.0", "v1.2.9"}, out: []string{"v1.3.0"}},
+		"v1.2.3":               {in: []string{"v1.2.3"}, out: []string{"v1.2.3-up.1"}},
+		">1.2":                 {in: []string{"v1.3.0"}, out: []string{"v1.2.9"}},
+		"<=1.2":                {in: []string{"v1.2.9"}, out: []string{"v1.3.0"}},
+		">=v1.14.0-0":          {in: []string{"v1.14.0", "v1.14.0-up.1"}, out: []string{"v1.13.9"}},
+		"<1.2.0 || >=2.0.0":    {in: []string{"v1.1.0", "v2.0.0"}, out: []string{"v1.2.0"}},
+		">=1.2.3-up.2 !=1.3.0": {in: []string{"v1.2.3-up.2", "v1.3.1"}, out: []string{"v1.2.3-up.1", "v1.3.0"}},
+		">=one":                {err: errors.Errorf(errFmtInvalidConstraint, ">=one")},
+		"!=1.2":                {err: errors.Errorf(errFmtInvalidConstraint, "!=1.2")},
+	}
+	for s, tc := range cases {
+		t.Run(s, func(t *testing.T) {
+			r, err := ParseRange(s)
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("ParseRange(%q): -want error, +got error:\n%s", s, diff)
+			}
+			for _, v := range tc.in {
+				if !r.Contains(MustParse(v)) {
+					t.Errorf("ParseRange(%q).Contains(%s): want true, got false", s, v)
+				}
+			}
+			for _, v := range tc.out {
+				if r.Contains(MustParse(v)) {
+					t.Errorf("ParseRange(%q).Contains(%s): want false, got true", s, v)
+				}
+			}
+		})
+	}
+}
@@ -15,5 +15,77 @@
 // Package version contains the injected version of the package.
 package version
 
-// Version will be overridden with the current version at build time using the -X linker flag.
-var Version = "0.0.0" //nolint:gochecknoglobals // Version will be overridden at build time.
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+)
+
+// The following will be overridden at build time using the -X linker flag.
+// Commit and Dirty fall back to the VCS information Go embeds in the binary
+// if they are not set. BuildDate stays empty, since the time of the commit is
+// not the time the binary was built at.
+var (
+	// Version is the version of Universal Crossplane.
+	Version = "0.0.0" //nolint:gochecknoglobals // Version will be overridden at build time.
+	// Commit is the git commit the binary is built from.
+	Commit = "" //nolint:gochecknoglobals // Commit will be overridden at build time.
+	// BuildDate is the time the binary is built at in RFC3339 format.
+	BuildDate = "" //nolint:gochecknoglobals // BuildDate will be overridden at build time.
+	// Dirty is "true" if the working tree had uncommitted changes at build
+	// time.
+	Dirty = "" //nolint:gochecknoglobals // Dirty will be overridden at build time.
+	// CrossplaneVersion is the version of Crossplane bundled in this release.
+	CrossplaneVersion = "" //nolint:gochecknoglobals // CrossplaneVersion will be overridden at build time.
+	// XGQLVersion is the version of XGQL bundled in this release.
+	XGQLVersion = "" //nolint:gochecknoglobals // XGQLVersion will be overridden at build time.
+)
+
+// Info is the build information of the binary.
+type Info struct {
+	Version           string `json:"version"`
+	Commit            string `json:"commit"`
+	BuildDate         string `json:"buildDate"`
+	Dirty             bool   `json:"dirty"`
+	GoVersion         string `json:"goVersion"`
+	Platform          string `json:"platform"`
+	CrossplaneVersion string `json:"crossplaneVersion"`
+}
+
+// String returns a human readable representation of the build information.
+func (i Info) String() string {
+	return fmt.Sprintf("Version: %s\nCommit: %s\nBuild Date: %s\nDirty: %t\nGo Version: %s\nPlatform: %s\nCrossplane Version: %s\n",
+		i.Version, i.Commit, i.BuildDate, i.Dirty, i.GoVersion, i.Platform, i.CrossplaneVersion)
+}
+
+// Get returns the build information of the binary.
+func Get() Info {
+	i := Info{
+		Version:           Version,
+		Commit:            Commit,
+		BuildDate:         BuildDate,
+		GoVersion:         runtime.Version(),
+		Platform:          fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+		CrossplaneVersion: CrossplaneVersion,
+	}
+	i.Dirty, _ = strconv.ParseBool(Dirty)
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return i
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if i.Commit == "" {
+				i.Commit = s.Value
+			}
+		case "vcs.modified":
+			if Dirty == "" {
+				i.Dirty, _ = strconv.ParseBool(s.Value)
+			}
+		}
+	}
+	return i
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGet(t *testing.T) {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+
+	// Test binaries carry no VCS information, so only the linker flags are
+	// reflected.
+	type flags struct {
+		version, commit, buildDate, dirty, crossplane string
+	}
+	cases := map[string]struct {
+		flags flags
+		want  Info
+	}{
+		"LinkerFlags": {
+			flags: flags{version: "v1.20.4-up.1", commit: "0123abc", buildDate: "2021-05-18T00:00:00Z", dirty: "true", crossplane: "v1.20.4-up.1"},
+			want:  Info{Version: "v1.20.4-up.1", Commit: "0123abc", BuildDate: "2021-05-18T00:00:00Z", Dirty: true, GoVersion: runtime.Version(), Platform: platform, CrossplaneVersion: "v1.20.4-up.1"},
+		},
+		"NotSet": {
+			flags: flags{version: "0.0.0"},
+			want:  Info{Version: "0.0.0", GoVersion: runtime.Version(), Platform: platform},
+		},
+		"InvalidDirty": {
+			flags: flags{version: "0.0.0", dirty: "maybe"},
+			want:  Info{Version: "0.0.0", GoVersion: runtime.Version(), Platform: platform},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func(v, c, b, d, x string) {
+				Version, Commit, BuildDate, Dirty, CrossplaneVersion = v, c, b, d, x
+			}(Version, Commit, BuildDate, Dirty, CrossplaneVersion)
+			Version, Commit, BuildDate, Dirty, CrossplaneVersion = tc.flags.version, tc.flags.commit, tc.flags.buildDate, tc.flags.dirty, tc.flags.crossplane
+
+			if diff := cmp.Diff(tc.want, Get()); diff != "" {
+				t.Errorf("Get(): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInfoString(t *testing.T) {
+	i := Info{Version: "v1.20.4-up.1", Commit: "0123abc", BuildDate: "2021-05-18T00:00:00Z", Dirty: true, GoVersion: "go1.19", Platform: "linux/amd64", CrossplaneVersion: "v1.20.4-up.1"}
+	want := "Version: v1.20.4-up.1\nCommit: 0123abc\nBuild Date: 2021-05-18T00:00:00Z\nDirty: true\nGo Version: go1.19\nPlatform: linux/amd64\nCrossplane Version: v1.20.4-up.1\n"
+	if diff := cmp.Diff(want, i.String()); diff != "" {
+		t.Errorf("i.String(): -want, +got:\n%s", diff)
+	}
+}
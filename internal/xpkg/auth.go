@@ -0,0 +1,87 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xpkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	errParseDockerConfig = "cannot parse docker config"
+	errDecodeAuth        = "cannot decode docker config auth"
+)
+
+// An Auth is the basic auth credentials of a registry.
+type Auth struct {
+	Username string
+	Password string
+}
+
+// A Keychain holds the credentials of registries by host. A nil Keychain
+// accesses all registries anonymously.
+type Keychain map[string]Auth
+
+// dockerAuth is an entry of a docker config.
+type dockerAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// AddSecret adds the credentials of the given pull Secret, which holds a
+// .dockerconfigjson or a legacy .dockercfg. Secrets of other types are
+// ignored.
+func (k Keychain) AddSecret(s *corev1.Secret) error {
+	auths := map[string]dockerAuth{}
+	switch {
+	case len(s.Data[corev1.DockerConfigJsonKey]) > 0:
+		cfg := struct {
+			Auths map[string]dockerAuth `json:"auths"`
+		}{}
+		if err := json.Unmarshal(s.Data[corev1.DockerConfigJsonKey], &cfg); err != nil {
+			return errors.Wrap(err, errParseDockerConfig)
+		}
+		auths = cfg.Auths
+	case len(s.Data[corev1.DockerConfigKey]) > 0:
+		if err := json.Unmarshal(s.Data[corev1.DockerConfigKey], &auths); err != nil {
+			return errors.Wrap(err, errParseDockerConfig)
+		}
+	}
+	for host, a := range auths {
+		if a.Auth != "" {
+			b, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return errors.Wrap(err, errDecodeAuth)
+			}
+			a.Username, a.Password, _ = strings.Cut(string(b), ":")
+		}
+		k[registryHost(host)] = Auth{Username: a.Username, Password: a.Password}
+	}
+	return nil
+}
+
+// registryHost returns the host of the given docker config key, which may be
+// a URL, e.g. https://index.docker.io/v1/.
+func registryHost(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ := strings.Cut(key, "/")
+	return host
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xpkg
+
+import (
+	"sigs.k8s.io/yaml"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+// KeyCompatStatus is the key of the compat ConfigMap that holds the
+// compatibility of the packages as a list of CompatStatus.
+const KeyCompatStatus = "status.yaml"
+
+// A CompatState is the compatibility of a package with the running
+// Crossplane version.
+type CompatState string
+
+// Compatibility states.
+const (
+	// CompatStateCompatible means the package allows the running Crossplane
+	// version.
+	CompatStateCompatible CompatState = "Compatible"
+	// CompatStateIncompatible means the package does not allow the running
+	// Crossplane version.
+	CompatStateIncompatible CompatState = "Incompatible"
+	// CompatStateUnknown means the constraint of the package or the running
+	// Crossplane version is not known.
+	CompatStateUnknown CompatState = "Unknown"
+)
+
+// CompatStatus is the compatibility of a package with the running Crossplane
+// version.
+type CompatStatus struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Package is the package image of the active revision, or of the package
+	// if it has none.
+	Package string `json:"package"`
+	// Constraint is the range of Crossplane versions the package declares it
+	// is compatible with. It is empty if the package allows any version.
+	Constraint string      `json:"constraint,omitempty"`
+	State      CompatState `json:"state"`
+	Message    string      `json:"message,omitempty"`
+}
+
+// Allows returns true if the constraint of the package allows the given
+// Crossplane version. Constraints that cannot be parsed allow any version.
+func (s CompatStatus) Allows(crossplane version.Semver) bool {
+	r, err := version.ParseRange(s.Constraint)
+	return err != nil || r.Contains(crossplane.Upstream())
+}
+
+// ParseCompatStatus parses the compatibility of the packages from the
+// KeyCompatStatus of the compat ConfigMap.
+func ParseCompatStatus(data string) ([]CompatStatus, error) {
+	var statuses []CompatStatus
+	err := yaml.Unmarshal([]byte(data), &statuses)
+	return statuses, err
+}
+
+// Incompatible returns the packages whose known constraints do not allow the
+// given Crossplane version.
+func Incompatible(statuses []CompatStatus, crossplane version.Semver) []CompatStatus {
+	var out []CompatStatus
+	for _, s := range statuses {
+		if !s.Allows(crossplane) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xpkg
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+
+	kyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	// StreamFile is the file of a package layer that holds the package
+	// metadata and objects.
+	StreamFile = "package.yaml"
+
+	// AnnotationKeyLayer annotates the layers of a package with their
+	// purpose. The base layer holds the StreamFile.
+	AnnotationKeyLayer = "io.crossplane.xpkg"
+	// LayerBase is the purpose of the base layer of a package.
+	LayerBase = "base"
+
+	// metaGroup is the API group of the package metadata.
+	metaGroup = "meta.pkg.crossplane.io"
+
+	// maxStreamSize is the largest package stream that is read.
+	maxStreamSize = 100 << 20
+
+	mediaTypeManifests = "application/vnd.oci.image.index.v1+json, " +
+		"application/vnd.oci.image.manifest.v1+json, " +
+		"application/vnd.docker.distribution.manifest.list.v2+json, " +
+		"application/vnd.docker.distribution.manifest.v2+json"
+
+	errGetManifest      = "cannot get package manifest"
+	errDecodeManifest   = "cannot decode package manifest"
+	errGetLayer         = "cannot get package layer"
+	errReadLayer        = "cannot read package layer"
+	errDecodeStream     = "cannot decode package stream"
+	errNoManifest       = "package index has no manifests"
+	errNoMeta           = "package has no metadata"
+	errFmtNoStream      = "package has no %s"
+	errFmtNoBaseLayer   = "package has %d layers but none is annotated as base"
+	errFmtReadPackage   = "cannot read package %s"
+	errFmtUnknownFormat = "cannot read package layer %s as a tarball"
+)
+
+// A manifest is an OCI image manifest or index.
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Layers    []descriptor `json:"layers"`
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// Meta is the part of the metadata of a package that is read.
+type Meta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Crossplane *struct {
+			Version string `json:"version"`
+		} `json:"crossplane,omitempty"`
+	} `json:"spec"`
+}
+
+// CrossplaneConstraint returns the range of Crossplane versions the package
+// is compatible with, or an empty string if it is compatible with all.
+func (m Meta) CrossplaneConstraint() string {
+	if m.Spec.Crossplane == nil {
+		return ""
+	}
+	return m.Spec.Crossplane.Version
+}
+
+// Meta returns the metadata of the given package reference, e.g.
+// xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0, using the credentials of
+// its registry in the given Keychain.
+func (c *Client) Meta(ctx context.Context, pkg string, k Keychain) (Meta, error) {
+	repo, ref := splitReference(pkg)
+	s := c.session(repo, k)
+	layer, err := s.baseLayer(ctx, ref)
+	if err != nil {
+		return Meta{}, errors.Wrapf(err, errFmtReadPackage, pkg)
+	}
+	stream, err := s.stream(ctx, layer)
+	if err != nil {
+		return Meta{}, errors.Wrapf(err, errFmtReadPackage, pkg)
+	}
+	m, err := ParseMeta(stream)
+	return m, errors.Wrapf(err, errFmtReadPackage, pkg)
+}
+
+// baseLayer returns the digest of the layer that holds the package stream.
+// The first manifest of an index is used.
+func (s *session) baseLayer(ctx context.Context, ref string) (string, error) {
+	resp, err := s.get(ctx, s.base+"/v2/"+s.path+"/manifests/"+ref, mediaTypeManifests)
+	if err != nil {
+		return "", errors.Wrap(err, errGetManifest)
+	}
+	m := manifest{}
+	if err := decode(resp, &m, errDecodeManifest); err != nil {
+		return "", err
+	}
+	if len(m.Manifests) > 0 {
+		return s.baseLayer(ctx, m.Manifests[0].Digest)
+	}
+	if strings.Contains(m.MediaType, "index") || strings.Contains(m.MediaType, "manifest.list") {
+		return "", errors.New(errNoManifest)
+	}
+	for _, l := range m.Layers {
+		if l.Annotations[AnnotationKeyLayer] == LayerBase {
+			return l.Digest, nil
+		}
+	}
+	// Packages built before layers were annotated have a single layer.
+	if len(m.Layers) != 1 {
+		return "", errors.Errorf(errFmtNoBaseLayer, len(m.Layers))
+	}
+	return m.Layers[0].Digest, nil
+}
+
+// stream returns the package stream of the given layer, which is a tarball
+// that may be compressed with gzip.
+func (s *session) stream(ctx context.Context, digest string) ([]byte, error) {
+	resp, err := s.get(ctx, s.base+"/v2/"+s.path+"/blobs/"+digest, "")
+	if err != nil {
+		return nil, errors.Wrap(err, errGetLayer)
+	}
+	if err := check(resp); err != nil {
+		return nil, errors.Wrap(err, errGetLayer)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Nothing to do if closing the body fails.
+
+	var r io.Reader = bufio.NewReader(resp.Body)
+	if magic, _ := r.(*bufio.Reader).Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrap(err, errReadLayer)
+		}
+		defer gz.Close() //nolint:errcheck // Nothing to do if closing the reader fails.
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.Errorf(errFmtNoStream, StreamFile)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtUnknownFormat, digest)
+		}
+		if strings.TrimPrefix(h.Name, "./") != StreamFile {
+			continue
+		}
+		b, err := io.ReadAll(io.LimitReader(tr, maxStreamSize))
+		return b, errors.Wrap(err, errReadLayer)
+	}
+}
+
+// ParseMeta returns the metadata of the given package stream, which is the
+// first object of the meta.pkg.crossplane.io group.
+func ParseMeta(stream []byte) (Meta, error) {
+	r := kyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(stream)))
+	for {
+		doc, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return Meta{}, errors.New(errNoMeta)
+		}
+		if err != nil {
+			return Meta{}, errors.Wrap(err, errDecodeStream)
+		}
+		m := Meta{}
+		if err := yaml.Unmarshal(doc, &m); err != nil {
+			return Meta{}, errors.Wrap(err, errDecodeStream)
+		}
+		if strings.HasPrefix(m.APIVersion, metaGroup+"/") {
+			return m, nil
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xpkg contains a client that reads Crossplane packages from OCI
+// registries.
+package xpkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/upbound/universal-crossplane/internal/version"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+
+	// DefaultRegistry is the registry of package references without one,
+	// like Crossplane's.
+	DefaultRegistry = "xpkg.upbound.io"
+
+	errNewRequest    = "cannot build registry request"
+	errRequest       = "cannot send registry request"
+	errListTags      = "cannot list tags"
+	errGetToken      = "cannot get registry token"
+	errDecodeTags    = "cannot decode tag list"
+	errDecodeToken   = "cannot decode registry token"
+	errFmtStatusCode = "registry responded with %d: %s"
+)
+
+var (
+	challengeRe = regexp.MustCompile(`(\w+)="([^"]*)"`)         //nolint:gochecknoglobals // We treat this as a constant.
+	nextRe      = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`) //nolint:gochecknoglobals // We treat this as a constant.
+)
+
+// Client reads packages with the OCI distribution API. Registries are
+// accessed anonymously unless credentials for them are given.
+type Client struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewClient returns a Client that reads packages from the registry of each
+// repository, or from the given endpoint instead if it is not empty, e.g.
+// http://localhost:5000 for a local OCI registry that mirrors them.
+func NewClient(endpoint string) *Client {
+	return &Client{endpoint: strings.TrimSuffix(endpoint, "/"), client: &http.Client{Timeout: requestTimeout}}
+}
+
+// Tags returns all tags of the given repository, e.g.
+// xpkg.upbound.io/upbound/provider-aws-s3, following pagination.
+func (c *Client) Tags(ctx context.Context, repository string) ([]string, error) {
+	s := c.session(repository, nil)
+	next := s.base + "/v2/" + s.path + "/tags/list"
+	var tags []string
+	for next != "" {
+		resp, err := s.get(ctx, next, "")
+		if err != nil {
+			return nil, errors.Wrap(err, errListTags)
+		}
+		page := struct {
+			Tags []string `json:"tags"`
+		}{}
+		if err := decode(resp, &page, errDecodeTags); err != nil {
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+		next = ""
+		if m := nextRe.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			// The link may be relative to the request.
+			ref, err := resp.Request.URL.Parse(m[1])
+			if err != nil {
+				return nil, errors.Wrap(err, errListTags)
+			}
+			next = ref.String()
+		}
+	}
+	return tags, nil
+}
+
+// A session sends requests for a single repository and remembers how it is
+// authorized.
+type session struct {
+	client *http.Client
+	base   string
+	path   string
+	// basic is the basic auth credentials of the registry, if any.
+	basic string
+	// authorization is the Authorization header of the requests once the
+	// registry asked for one.
+	authorization string
+}
+
+// session returns a session for the given repository that uses the
+// credentials of its registry in the given Keychain, if any.
+func (c *Client) session(repository string, k Keychain) *session {
+	registry, path := SplitRepository(repository)
+	base := "https://" + registry
+	if c.endpoint != "" {
+		base = c.endpoint
+	}
+	s := &session{client: c.client, base: base, path: path}
+	if a, ok := k[registry]; ok {
+		s.basic = "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password))
+	}
+	return s
+}
+
+// get sends a GET request, authorizing first if the registry requires it.
+func (s *session) get(ctx context.Context, u, accept string) (*http.Response, error) {
+	resp, err := s.do(ctx, u, accept, s.authorization)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || s.authorization != "" {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	_ = resp.Body.Close()
+	if strings.HasPrefix(strings.ToLower(challenge), "basic") && s.basic != "" {
+		s.authorization = s.basic
+		return s.do(ctx, u, accept, s.authorization)
+	}
+	token, err := s.getToken(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+	s.authorization = "Bearer " + token
+	return s.do(ctx, u, accept, s.authorization)
+}
+
+// getToken returns a pull token for the repository from the realm of the
+// given bearer challenge. The token is anonymous unless the session has
+// credentials.
+func (s *session) getToken(ctx context.Context, challenge string) (string, error) {
+	params := map[string]string{}
+	for _, m := range challengeRe.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer") || params["realm"] == "" {
+		return "", errors.Errorf(errFmtStatusCode, http.StatusUnauthorized, challenge)
+	}
+	q := url.Values{}
+	q.Set("scope", "repository:"+s.path+":pull")
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	resp, err := s.do(ctx, params["realm"]+"?"+q.Encode(), "", s.basic)
+	if err != nil {
+		return "", errors.Wrap(err, errGetToken)
+	}
+	t := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := decode(resp, &t, errDecodeToken); err != nil {
+		return "", errors.Wrap(err, errGetToken)
+	}
+	if t.Token != "" {
+		return t.Token, nil
+	}
+	return t.AccessToken, nil
+}
+
+func (s *session) do(ctx context.Context, u, accept, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewRequest)
+	}
+	req.Header.Set("User-Agent", "uxp-bootstrapper/"+version.Version)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := s.client.Do(req)
+	return resp, errors.Wrap(err, errRequest)
+}
+
+// check closes the body of the given response and returns an error if its
+// status code is not successful.
+func check(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	defer resp.Body.Close() //nolint:errcheck // Nothing to do if closing the body fails.
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return errors.Errorf(errFmtStatusCode, resp.StatusCode, bytes.TrimSpace(body))
+}
+
+func decode(resp *http.Response, into any, msg string) error {
+	if err := check(resp); err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck // Nothing to do if closing the body fails.
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(into), msg)
+}
+
+// SplitRepository returns the registry and the path of the given repository.
+// The first element is the registry if it looks like a host, as in the
+// Docker reference format.
+func SplitRepository(repository string) (registry, path string) {
+	host, rest, ok := strings.Cut(repository, "/")
+	if !ok || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		return DefaultRegistry, repository
+	}
+	return host, rest
+}
+
+// SplitPackage returns the repository and tag of the given package
+// reference. The tag is empty if the package is referenced by digest or has
+// no tag.
+func SplitPackage(pkg string) (repository, tag string) {
+	if strings.Contains(pkg, "@") {
+		return pkg, ""
+	}
+	i := strings.LastIndex(pkg, ":")
+	if i < 0 || strings.Contains(pkg[i:], "/") {
+		return pkg, ""
+	}
+	return pkg[:i], pkg[i+1:]
+}
+
+// ImageTag returns the tag of the given image reference, or the digest if it
+// has no tag.
+func ImageTag(image string) string {
+	ref := image
+	if i := strings.Index(ref, "@"); i >= 0 {
+		if !strings.Contains(ref[:i], ":") || strings.LastIndex(ref[:i], ":") < strings.LastIndex(ref[:i], "/") {
+			return ref[i+1:]
+		}
+		ref = ref[:i]
+	}
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || i < strings.LastIndex(ref, "/") {
+		return "latest"
+	}
+	return ref[i+1:]
+}
+
+// splitReference returns the repository and the tag or digest of the given
+// package reference. Packages without either are referenced by the latest
+// tag.
+func splitReference(pkg string) (repository, reference string) {
+	if repo, digest, ok := strings.Cut(pkg, "@"); ok {
+		return repo, digest
+	}
+	repo, tag := SplitPackage(pkg)
+	if tag == "" {
+		tag = "latest"
+	}
+	return repo, tag
+}
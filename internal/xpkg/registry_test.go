@@ -0,0 +1,323 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xpkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+// registry returns a registry that requires an anonymous token for the
+// upbound/provider-aws-s3 repository and serves the given paths.
+func registry(t *testing.T, paths map[string][]byte) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:upbound/provider-aws-s3:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token": "t0k3n"}`))
+		case r.Header.Get("Authorization") != "Bearer t0k3n":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case paths[r.URL.RequestURI()] != nil:
+			_, _ = w.Write(paths[r.URL.RequestURI()])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTags(t *testing.T) {
+	srv := registry(t, map[string][]byte{
+		"/v2/upbound/provider-aws-s3/tags/list":                 []byte(`{"tags": ["v1.0.0", "v1.1.0"]}`),
+		"/v2/upbound/provider-aws-s3/tags/list?n=2&last=v1.1.0": []byte(`{"tags": ["v1.2.0"]}`),
+	})
+	paged := srv.Config.Handler
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RequestURI() == "/v2/upbound/provider-aws-s3/tags/list" {
+			w.Header().Set("Link", `</v2/upbound/provider-aws-s3/tags/list?n=2&last=v1.1.0>; rel="next"`)
+		}
+		paged.ServeHTTP(w, r)
+	})
+
+	got, err := NewClient(srv.URL).Tags(context.Background(), "xpkg.upbound.io/upbound/provider-aws-s3")
+	if err != nil {
+		t.Fatalf("Tags(...): %s", err)
+	}
+	if diff := cmp.Diff([]string{"v1.0.0", "v1.1.0", "v1.2.0"}, got); diff != "" {
+		t.Errorf("Tags(...): -want, +got:\n%s", diff)
+	}
+}
+
+func layer(t *testing.T, compress bool, files map[string]string) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	tw := tar.NewWriter(b)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !compress {
+		return b.Bytes()
+	}
+	gz := &bytes.Buffer{}
+	zw := gzip.NewWriter(gz)
+	if _, err := zw.Write(b.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return gz.Bytes()
+}
+
+func TestMeta(t *testing.T) {
+	stream := `---
+apiVersion: meta.pkg.crossplane.io/v1
+kind: Provider
+metadata:
+  name: provider-aws-s3
+spec:
+  crossplane:
+    version: ">=v1.14.0-0"
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: buckets.s3.aws.upbound.io
+`
+	repo := "/v2/upbound/provider-aws-s3"
+	type want struct {
+		constraint string
+		err        error
+	}
+	cases := map[string]struct {
+		reason string
+		pkg    string
+		paths  map[string][]byte
+		want   want
+	}{
+		"BaseLayer": {
+			reason: "The constraint should be read from the layer annotated as base.",
+			pkg:    "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0",
+			paths: map[string][]byte{
+				repo + "/manifests/v1.1.0": []byte(`{"layers": [
+					{"digest": "sha256:base", "annotations": {"io.crossplane.xpkg": "base"}},
+					{"digest": "sha256:upbound", "annotations": {"io.crossplane.xpkg": "upbound"}}
+				]}`),
+				repo + "/blobs/sha256:base": layer(t, true, map[string]string{"package.yaml": stream}),
+			},
+			want: want{constraint: ">=v1.14.0-0"},
+		},
+		"IndexByDigest": {
+			reason: "The first manifest of an index should be read, and a single unannotated uncompressed layer is the base layer.",
+			pkg:    "upbound/provider-aws-s3@sha256:index",
+			paths: map[string][]byte{
+				repo + "/manifests/sha256:index": []byte(`{"mediaType": "application/vnd.oci.image.index.v1+json", "manifests": [{"digest": "sha256:amd64"}]}`),
+				repo + "/manifests/sha256:amd64": []byte(`{"layers": [{"digest": "sha256:only"}]}`),
+				repo + "/blobs/sha256:only":      layer(t, false, map[string]string{"package.yaml": stream}),
+			},
+			want: want{constraint: ">=v1.14.0-0"},
+		},
+		"NoConstraint": {
+			reason: "Packages without a constraint should be compatible with any Crossplane.",
+			pkg:    "xpkg.upbound.io/upbound/provider-aws-s3",
+			paths: map[string][]byte{
+				repo + "/manifests/latest":  []byte(`{"layers": [{"digest": "sha256:only"}]}`),
+				repo + "/blobs/sha256:only": layer(t, true, map[string]string{"package.yaml": "apiVersion: meta.pkg.crossplane.io/v1\nkind: Provider\n"}),
+			},
+		},
+		"NoStream": {
+			reason: "Layers without a package stream should return an error.",
+			pkg:    "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0",
+			paths: map[string][]byte{
+				repo + "/manifests/v1.1.0":  []byte(`{"layers": [{"digest": "sha256:only"}]}`),
+				repo + "/blobs/sha256:only": layer(t, true, map[string]string{"README.md": "hi"}),
+			},
+			want: want{err: errors.Wrapf(errors.Errorf(errFmtNoStream, StreamFile), errFmtReadPackage, "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0")},
+		},
+		"AmbiguousLayers": {
+			reason: "Packages with several unannotated layers should return an error.",
+			pkg:    "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0",
+			paths: map[string][]byte{
+				repo + "/manifests/v1.1.0": []byte(`{"layers": [{"digest": "sha256:a"}, {"digest": "sha256:b"}]}`),
+			},
+			want: want{err: errors.Wrapf(errors.Errorf(errFmtNoBaseLayer, 2), errFmtReadPackage, "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := registry(t, tc.paths)
+			m, err := NewClient(srv.URL).Meta(context.Background(), tc.pkg, nil)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nMeta(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.constraint, m.CrossplaneConstraint()); diff != "" {
+				t.Errorf("\n%s\nMeta(...): -want constraint, +got constraint:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// privateRegistry returns a registry that serves the given paths only to the
+// user with password pass, with a bearer token or with basic auth if basic is
+// true.
+func privateRegistry(t *testing.T, basic bool, paths map[string][]byte) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		authorized := ok && u == "user" && p == "pass"
+		switch {
+		case r.URL.Path == "/token" && !authorized:
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/token":
+			_, _ = w.Write([]byte(`{"access_token": "t0k3n"}`))
+		case basic && !authorized:
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case !basic && r.Header.Get("Authorization") != "Bearer t0k3n":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case paths[r.URL.RequestURI()] != nil:
+			_, _ = w.Write(paths[r.URL.RequestURI()])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMetaWithCredentials(t *testing.T) {
+	pkg := "registry.corp/upbound/provider-aws-s3:v1.1.0"
+	paths := map[string][]byte{
+		"/v2/upbound/provider-aws-s3/manifests/v1.1.0":  []byte(`{"layers": [{"digest": "sha256:only"}]}`),
+		"/v2/upbound/provider-aws-s3/blobs/sha256:only": layer(t, true, map[string]string{"package.yaml": "apiVersion: meta.pkg.crossplane.io/v1\nkind: Provider\n"}),
+	}
+	valid := Keychain{"registry.corp": {Username: "user", Password: "pass"}}
+	cases := map[string]struct {
+		reason   string
+		basic    bool
+		keychain Keychain
+		wantErr  bool
+	}{
+		"BearerToken": {
+			reason:   "The credentials of the registry should be used to get a token.",
+			keychain: valid,
+		},
+		"Basic": {
+			reason:   "The credentials of the registry should be used if it asks for basic auth.",
+			basic:    true,
+			keychain: valid,
+		},
+		"OtherRegistry": {
+			reason:   "The credentials of other registries should not be used.",
+			keychain: Keychain{"xpkg.upbound.io": {Username: "user", Password: "pass"}},
+			wantErr:  true,
+		},
+		"Anonymous": {
+			reason:  "Private packages should not be readable anonymously.",
+			basic:   true,
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := privateRegistry(t, tc.basic, paths)
+			_, err := NewClient(srv.URL).Meta(context.Background(), pkg, tc.keychain)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("\n%s\nMeta(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeychainAddSecret(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		data   map[string][]byte
+		want   Keychain
+	}{
+		"DockerConfigJSON": {
+			reason: "Credentials should be read from a .dockerconfigjson, decoding auth and normalizing URLs.",
+			data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths": {
+				"https://index.docker.io/v1/": {"auth": "dXNlcjpwYXNz"},
+				"xpkg.upbound.io": {"username": "robot", "password": "s3cr3t"}
+			}}`)},
+			want: Keychain{"index.docker.io": {Username: "user", Password: "pass"}, "xpkg.upbound.io": {Username: "robot", Password: "s3cr3t"}},
+		},
+		"DockerConfig": {
+			reason: "Credentials should be read from a legacy .dockercfg.",
+			data:   map[string][]byte{corev1.DockerConfigKey: []byte(`{"registry.corp": {"auth": "dXNlcjpwYXNz"}}`)},
+			want:   Keychain{"registry.corp": {Username: "user", Password: "pass"}},
+		},
+		"Opaque": {
+			reason: "Secrets without a docker config should be ignored.",
+			data:   map[string][]byte{"token": []byte("t0k3n")},
+			want:   Keychain{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			k := Keychain{}
+			if err := k.AddSecret(&corev1.Secret{Data: tc.data}); err != nil {
+				t.Fatalf("\n%s\nk.AddSecret(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, k); diff != "" {
+				t.Errorf("\n%s\nk.AddSecret(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	cases := map[string]string{
+		"upbound/crossplane:v1.20.4-up.1":                          "v1.20.4-up.1",
+		"xpkg.upbound.io/upbound/crossplane:v1.20.4-up.1@sha256:a": "v1.20.4-up.1",
+		"localhost:5000/upbound/crossplane":                        "latest",
+		"localhost:5000/upbound/crossplane@sha256:a":               "sha256:a",
+	}
+	for image, want := range cases {
+		if diff := cmp.Diff(want, ImageTag(image)); diff != "" {
+			t.Errorf("ImageTag(%q): -want, +got:\n%s", image, diff)
+		}
+	}
+}